@@ -455,7 +455,13 @@ recordComponent
     ;
 
 recordBody
-    : '{' classBodyDeclaration* '}'
+    : '{' (classBodyDeclaration | compactConstructorDeclaration)* '}'
+    ;
+
+// The generated parser in ../javaparser was patched by hand to add this rule, so regenerating it will renumber
+// the rules and states that the patch reuses
+compactConstructorDeclaration
+    : modifier* identifier constructorBody=block
     ;
 
 // STATEMENTS / BLOCKS
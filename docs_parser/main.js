@@ -34,35 +34,27 @@ function stripWhitespace(text) {
 const openingAngleBracket = '<'.charCodeAt(0);
 const closingAngleBracket = '>'.charCodeAt(0);
 
-function stripGenerics(text) {
-  if (!text.includes('<')) {
-    return text;
-  }
-
-  // We have to iterate through it point by point since the generics can
-  // be fairly complicated, e.g. `addAll<A>(Collection<List<A, B>, C> c)`
-
+// Splits `text` on `separator`, except where the separator is nested inside of generics,
+// e.g. `Map<K, V>, List<E>` split on ',' gives `Map<K, V>` and `List<E>`.
+function splitTopLevel(text, separator) {
+  const parts = [];
   let nestingLevel = 0;
-  const builtString = [];
+  let start = 0;
   for (let i = 0; i < text.length; i++) {
     const ch = text.charCodeAt(i);
 
     if (ch === openingAngleBracket) {
       nestingLevel++;
-    } if (ch === closingAngleBracket) {
+    } else if (ch === closingAngleBracket) {
       nestingLevel--;
-    } else if (nestingLevel === 0) {
-      builtString.push(ch);
+    } else if (nestingLevel === 0 && text[i] === separator) {
+      parts.push(text.substring(start, i));
+      start = i + 1;
     }
   }
+  parts.push(text.substring(start));
 
-  if (nestingLevel !== 0) {
-    // Angle brackets didn't match up, so it probably wasn't actual generics.
-    console.log(`Angle brackets didn't match up: ${text}`);
-    return text;
-  }
-
-  return String.fromCharCode(...builtString);
+  return parts.map(s => s.trim()).filter(s => s !== '');
 }
 
 async function getData(url) {
@@ -93,7 +85,8 @@ async function genType(url) {
   const titleStr = root.querySelector('h1.title').textContent;
   const titleSplit = titleStr.split(' ');
   data.type = titleSplit[0].toLowerCase();
-  data.name = titleSplit[1];
+  // Keep the type parameters, e.g. `Map<K,V>`, which may contain spaces
+  data.name = titleSplit.slice(1).join(' ');
 
   const subtitles = root.querySelectorAll('.header > .sub-title');
   for (const st of subtitles) {
@@ -147,7 +140,7 @@ async function genType(url) {
 }
 
 function parseTypeList(typeList) {
-  return typeList.split(',').map(s => s.trim()).map(stripGenerics);
+  return splitTopLevel(typeList, ',');
 }
 
 function parseTable(type, root, tableSelector) {
@@ -180,15 +173,22 @@ function parseTable(type, root, tableSelector) {
 
     for (let j = 0; j < numColumns; j++) {
       const columnName = columns[j];
-      let content = stripWhitespace(stripGenerics(tableChildren[i + j].textContent));
+      let content = stripWhitespace(tableChildren[i + j].textContent);
 
       if (columnName === 'modifierAndType') {
         // modifierAndType could look like "static final String", in which case we want
-        // modifiers to be ["static", "final"] and type to be "string"
-        const modifiers = content.split(' ');
+        // modifiers to be ["static", "final"] and type to be "string".
+        // Generic methods also declare their type parameters here, e.g. "static <T> List<T>",
+        // in which case we want typeParams to be ["T"].
+        const modifiers = splitTopLevel(content, ' ');
         const [ type ] = modifiers.splice(-1);
+        const typeParamsIdx = modifiers.findIndex(m => m.startsWith('<'));
+        if (typeParamsIdx !== -1) {
+          const [ typeParams ] = modifiers.splice(typeParamsIdx, 1);
+          item.typeParams = splitTopLevel(typeParams.substring(1, typeParams.length - 1), ',');
+        }
         item.modifiers = modifiers;
-        item.type = type; 
+        item.type = type;
       } else if (columnName === 'modifier') {
         item.modifiers = [content];
       } else {
@@ -196,6 +196,11 @@ function parseTable(type, root, tableSelector) {
       }
     }
 
+    // An empty list (rather than none at all) tells the loader that this method isn't generic
+    if (type === 'method' && !item.typeParams) {
+      item.typeParams = [];
+    }
+
     items.push(item);
   }
   
@@ -203,8 +208,7 @@ function parseTable(type, root, tableSelector) {
 }
 
 function parseArgs(item) {
-  let { name } = item;
-  name = stripGenerics(name);
+  const { name } = item;
 
   const matches = /(.*)\((.*)\)/.exec(name);
   if (!matches) {
@@ -218,12 +222,14 @@ function parseArgs(item) {
   if (args === '') {
     args = [];
   } else {
-    args = args.split(',')
+    // The type can contain spaces too, e.g. `Collection<? extends E> c`
+    args = splitTopLevel(args, ',')
       .map(a => {
-        const split = a.trim().split(' ');
+        const split = splitTopLevel(a, ' ');
+        const [ argName ] = split.splice(-1);
         return {
-          type: split[0],
-          name: split[1]
+          type: split.join(' '),
+          name: argName
         };
       });
   }
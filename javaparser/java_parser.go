@@ -105,7 +105,7 @@ func javaparserParserInit() {
 		"innerCreator", "arrayCreatorRest", "classCreatorRest", "explicitGenericInvocation",
 		"typeArgumentsOrDiamond", "nonWildcardTypeArgumentsOrDiamond", "nonWildcardTypeArguments",
 		"typeList", "typeType", "primitiveType", "typeArguments", "superSuffix",
-		"explicitGenericInvocationSuffix", "arguments",
+		"explicitGenericInvocationSuffix", "arguments", "compactConstructorDeclaration",
 	}
 	staticData.predictionContextCache = antlr.NewPredictionContextCache()
 	staticData.serializedATN = []int32{
@@ -1290,6 +1290,7 @@ const (
 	JavaParserRULE_superSuffix                       = 129
 	JavaParserRULE_explicitGenericInvocationSuffix   = 130
 	JavaParserRULE_arguments                         = 131
+	JavaParserRULE_compactConstructorDeclaration     = 132
 )
 
 // ICompilationUnitContext is an interface to support dynamic dispatch.
@@ -5793,7 +5794,8 @@ func (p *JavaParser) MemberDeclaration() (localctx IMemberDeclarationContext) {
 
 	p.SetState(512)
 	p.GetErrorHandler().Sync(p)
-	switch p.GetInterpreter().AdaptivePredict(p.GetTokenStream(), 39, p.GetParserRuleContext()) {
+	// Patched by hand, since compactConstructorDeclaration isn't in the serialized ATN
+	switch p.predictRecordDeclaration(39, 10) {
 	case 1:
 		p.EnterOuterAlt(localctx, 1)
 		{
@@ -7335,7 +7337,8 @@ func (p *JavaParser) InterfaceMemberDeclaration() (localctx IInterfaceMemberDecl
 
 	p.SetState(574)
 	p.GetErrorHandler().Sync(p)
-	switch p.GetInterpreter().AdaptivePredict(p.GetTokenStream(), 47, p.GetParserRuleContext()) {
+	// Patched by hand, since compactConstructorDeclaration isn't in the serialized ATN
+	switch p.predictRecordDeclaration(47, 8) {
 	case 1:
 		p.EnterOuterAlt(localctx, 1)
 		{
@@ -13555,7 +13558,8 @@ func (p *JavaParser) AnnotationTypeElementRest() (localctx IAnnotationTypeElemen
 
 	p.SetState(932)
 	p.GetErrorHandler().Sync(p)
-	switch p.GetInterpreter().AdaptivePredict(p.GetTokenStream(), 102, p.GetParserRuleContext()) {
+	// Patched by hand, since compactConstructorDeclaration isn't in the serialized ATN
+	switch p.predictRecordDeclaration(102, 6) {
 	case 1:
 		p.EnterOuterAlt(localctx, 1)
 		{
@@ -15636,6 +15640,47 @@ func (s *RecordBodyContext) ClassBodyDeclaration(i int) IClassBodyDeclarationCon
 	return t.(IClassBodyDeclarationContext)
 }
 
+func (s *RecordBodyContext) AllCompactConstructorDeclaration() []ICompactConstructorDeclarationContext {
+	children := s.GetChildren()
+	len := 0
+	for _, ctx := range children {
+		if _, ok := ctx.(ICompactConstructorDeclarationContext); ok {
+			len++
+		}
+	}
+
+	tst := make([]ICompactConstructorDeclarationContext, len)
+	i := 0
+	for _, ctx := range children {
+		if t, ok := ctx.(ICompactConstructorDeclarationContext); ok {
+			tst[i] = t.(ICompactConstructorDeclarationContext)
+			i++
+		}
+	}
+
+	return tst
+}
+
+func (s *RecordBodyContext) CompactConstructorDeclaration(i int) ICompactConstructorDeclarationContext {
+	var t antlr.RuleContext
+	j := 0
+	for _, ctx := range s.GetChildren() {
+		if _, ok := ctx.(ICompactConstructorDeclarationContext); ok {
+			if j == i {
+				t = ctx.(antlr.RuleContext)
+				break
+			}
+			j++
+		}
+	}
+
+	if t == nil {
+		return nil
+	}
+
+	return t.(ICompactConstructorDeclarationContext)
+}
+
 func (s *RecordBodyContext) GetRuleContext() antlr.RuleContext {
 	return s
 }
@@ -15690,7 +15735,11 @@ func (p *JavaParser) RecordBody() (localctx IRecordBodyContext) {
 	_la = p.GetTokenStream().LA(1)
 
 	for (((_la)&-(0x1f+1)) == 0 && ((1<<uint(_la))&((1<<JavaParserABSTRACT)|(1<<JavaParserBOOLEAN)|(1<<JavaParserBYTE)|(1<<JavaParserCHAR)|(1<<JavaParserCLASS)|(1<<JavaParserDOUBLE)|(1<<JavaParserENUM)|(1<<JavaParserFINAL)|(1<<JavaParserFLOAT)|(1<<JavaParserINT)|(1<<JavaParserINTERFACE)|(1<<JavaParserLONG)|(1<<JavaParserNATIVE))) != 0) || (((_la-33)&-(0x1f+1)) == 0 && ((1<<uint((_la-33)))&((1<<(JavaParserPRIVATE-33))|(1<<(JavaParserPROTECTED-33))|(1<<(JavaParserPUBLIC-33))|(1<<(JavaParserSHORT-33))|(1<<(JavaParserSTATIC-33))|(1<<(JavaParserSTRICTFP-33))|(1<<(JavaParserSYNCHRONIZED-33))|(1<<(JavaParserTRANSIENT-33))|(1<<(JavaParserVOID-33))|(1<<(JavaParserVOLATILE-33))|(1<<(JavaParserMODULE-33))|(1<<(JavaParserOPEN-33))|(1<<(JavaParserREQUIRES-33))|(1<<(JavaParserEXPORTS-33))|(1<<(JavaParserOPENS-33))|(1<<(JavaParserTO-33))|(1<<(JavaParserUSES-33))|(1<<(JavaParserPROVIDES-33))|(1<<(JavaParserWITH-33))|(1<<(JavaParserTRANSITIVE-33))|(1<<(JavaParserVAR-33))|(1<<(JavaParserYIELD-33))|(1<<(JavaParserRECORD-33))|(1<<(JavaParserSEALED-33)))) != 0) || (((_la-65)&-(0x1f+1)) == 0 && ((1<<uint((_la-65)))&((1<<(JavaParserPERMITS-65))|(1<<(JavaParserNON_SEALED-65))|(1<<(JavaParserLBRACE-65))|(1<<(JavaParserSEMI-65))|(1<<(JavaParserLT-65)))) != 0) || _la == JavaParserAT || _la == JavaParserIDENTIFIER {
-		{
+		// Patched by hand, since compactConstructorDeclaration isn't in the serialized ATN
+		if p.atCompactConstructorDeclaration() {
+			p.SetState(1035)
+			p.CompactConstructorDeclaration()
+		} else {
 			p.SetState(1035)
 			p.ClassBodyDeclaration()
 		}
@@ -15707,6 +15756,261 @@ func (p *JavaParser) RecordBody() (localctx IRecordBodyContext) {
 	return localctx
 }
 
+// ICompactConstructorDeclarationContext is an interface to support dynamic dispatch.
+type ICompactConstructorDeclarationContext interface {
+	antlr.ParserRuleContext
+
+	// GetParser returns the parser.
+	GetParser() antlr.Parser
+
+	// GetConstructorBody returns the constructorBody rule contexts.
+	GetConstructorBody() IBlockContext
+
+	// SetConstructorBody sets the constructorBody rule contexts.
+	SetConstructorBody(IBlockContext)
+
+	// IsCompactConstructorDeclarationContext differentiates from other interfaces.
+	IsCompactConstructorDeclarationContext()
+}
+
+type CompactConstructorDeclarationContext struct {
+	*antlr.BaseParserRuleContext
+	parser          antlr.Parser
+	constructorBody IBlockContext
+}
+
+func NewEmptyCompactConstructorDeclarationContext() *CompactConstructorDeclarationContext {
+	var p = new(CompactConstructorDeclarationContext)
+	p.BaseParserRuleContext = antlr.NewBaseParserRuleContext(nil, -1)
+	p.RuleIndex = JavaParserRULE_compactConstructorDeclaration
+	return p
+}
+
+func (*CompactConstructorDeclarationContext) IsCompactConstructorDeclarationContext() {}
+
+func NewCompactConstructorDeclarationContext(parser antlr.Parser, parent antlr.ParserRuleContext, invokingState int) *CompactConstructorDeclarationContext {
+	var p = new(CompactConstructorDeclarationContext)
+
+	p.BaseParserRuleContext = antlr.NewBaseParserRuleContext(parent, invokingState)
+
+	p.parser = parser
+	p.RuleIndex = JavaParserRULE_compactConstructorDeclaration
+
+	return p
+}
+
+func (s *CompactConstructorDeclarationContext) GetParser() antlr.Parser { return s.parser }
+
+func (s *CompactConstructorDeclarationContext) GetConstructorBody() IBlockContext {
+	return s.constructorBody
+}
+
+func (s *CompactConstructorDeclarationContext) SetConstructorBody(v IBlockContext) {
+	s.constructorBody = v
+}
+
+func (s *CompactConstructorDeclarationContext) Identifier() IIdentifierContext {
+	var t antlr.RuleContext
+	for _, ctx := range s.GetChildren() {
+		if _, ok := ctx.(IIdentifierContext); ok {
+			t = ctx.(antlr.RuleContext)
+			break
+		}
+	}
+
+	if t == nil {
+		return nil
+	}
+
+	return t.(IIdentifierContext)
+}
+
+func (s *CompactConstructorDeclarationContext) Block() IBlockContext {
+	var t antlr.RuleContext
+	for _, ctx := range s.GetChildren() {
+		if _, ok := ctx.(IBlockContext); ok {
+			t = ctx.(antlr.RuleContext)
+			break
+		}
+	}
+
+	if t == nil {
+		return nil
+	}
+
+	return t.(IBlockContext)
+}
+
+func (s *CompactConstructorDeclarationContext) AllModifier() []IModifierContext {
+	children := s.GetChildren()
+	len := 0
+	for _, ctx := range children {
+		if _, ok := ctx.(IModifierContext); ok {
+			len++
+		}
+	}
+
+	tst := make([]IModifierContext, len)
+	i := 0
+	for _, ctx := range children {
+		if t, ok := ctx.(IModifierContext); ok {
+			tst[i] = t.(IModifierContext)
+			i++
+		}
+	}
+
+	return tst
+}
+
+func (s *CompactConstructorDeclarationContext) Modifier(i int) IModifierContext {
+	var t antlr.RuleContext
+	j := 0
+	for _, ctx := range s.GetChildren() {
+		if _, ok := ctx.(IModifierContext); ok {
+			if j == i {
+				t = ctx.(antlr.RuleContext)
+				break
+			}
+			j++
+		}
+	}
+
+	if t == nil {
+		return nil
+	}
+
+	return t.(IModifierContext)
+}
+
+func (s *CompactConstructorDeclarationContext) GetRuleContext() antlr.RuleContext {
+	return s
+}
+
+func (s *CompactConstructorDeclarationContext) ToStringTree(ruleNames []string, recog antlr.Recognizer) string {
+	return antlr.TreesStringTree(s, ruleNames, recog)
+}
+
+func (s *CompactConstructorDeclarationContext) EnterRule(listener antlr.ParseTreeListener) {
+	if listenerT, ok := listener.(JavaParserListener); ok {
+		listenerT.EnterCompactConstructorDeclaration(s)
+	}
+}
+
+func (s *CompactConstructorDeclarationContext) ExitRule(listener antlr.ParseTreeListener) {
+	if listenerT, ok := listener.(JavaParserListener); ok {
+		listenerT.ExitCompactConstructorDeclaration(s)
+	}
+}
+
+// CompactConstructorDeclaration is written by hand. It reuses the states of constructorDeclaration, so that
+// the rules it calls predict and recover from errors like they would there.
+func (p *JavaParser) CompactConstructorDeclaration() (localctx ICompactConstructorDeclarationContext) {
+	this := p
+	_ = this
+
+	localctx = NewCompactConstructorDeclarationContext(p, p.GetParserRuleContext(), p.GetState())
+	p.EnterRule(localctx, 60, JavaParserRULE_compactConstructorDeclaration)
+
+	defer func() {
+		p.ExitRule()
+	}()
+
+	defer func() {
+		if err := recover(); err != nil {
+			if v, ok := err.(antlr.RecognitionException); ok {
+				localctx.SetException(v)
+				p.GetErrorHandler().ReportError(p, v)
+				p.GetErrorHandler().Recover(p, v)
+			} else {
+				panic(err)
+			}
+		}
+	}()
+
+	p.EnterOuterAlt(localctx, 1)
+	for p.GetTokenStream().LA(1) != JavaParserIDENTIFIER && p.GetTokenStream().LA(1) != antlr.TokenEOF {
+		{
+			p.SetState(493)
+			p.Modifier()
+		}
+	}
+	{
+		p.SetState(544)
+		p.Identifier()
+	}
+	{
+		p.SetState(550)
+
+		var _x = p.Block()
+
+		localctx.(*CompactConstructorDeclarationContext).constructorBody = _x
+	}
+
+	return localctx
+}
+
+// atCompactConstructorDeclaration says whether a compactConstructorDeclaration comes next in a record body,
+// e.g. `public Point {`, which is some modifiers and then a name that's directly followed by a block.
+func (p *JavaParser) atCompactConstructorDeclaration() bool {
+	stream := p.GetTokenStream()
+	i := p.skipModifiers()
+	return stream.LA(i) == JavaParserIDENTIFIER && stream.LA(i+1) == JavaParserLBRACE
+}
+
+// atRecordDeclaration says whether a recordDeclaration comes next, e.g. `final record Point(`, which is some
+// modifiers and then `record`, the record's name, and its header or type parameters.
+func (p *JavaParser) atRecordDeclaration() bool {
+	stream := p.GetTokenStream()
+	i := p.skipModifiers()
+	return stream.LA(i) == JavaParserRECORD && stream.LA(i+1) == JavaParserIDENTIFIER &&
+		(stream.LA(i+2) == JavaParserLPAREN || stream.LA(i+2) == JavaParserLT)
+}
+
+// predictRecordDeclaration predicts the alternative to take at a decision where recordAlt is a
+// recordDeclaration. The serialized ATN doesn't know about compactConstructorDeclaration, so it can't predict a
+// record whose body has one, and would take `record Point(int x) { Point { } }` for a method returning a record.
+func (p *JavaParser) predictRecordDeclaration(decision int, recordAlt int) int {
+	if p.atRecordDeclaration() {
+		return recordAlt
+	}
+	return p.GetInterpreter().AdaptivePredict(p.GetTokenStream(), decision, p.GetParserRuleContext())
+}
+
+// skipModifiers returns how far ahead the first token after any modifiers and annotations coming next is.
+func (p *JavaParser) skipModifiers() int {
+	stream := p.GetTokenStream()
+	for i := 1; ; i++ {
+		switch stream.LA(i) {
+		case JavaParserPUBLIC, JavaParserPROTECTED, JavaParserPRIVATE, JavaParserSTATIC, JavaParserFINAL,
+			JavaParserABSTRACT, JavaParserSTRICTFP, JavaParserNATIVE, JavaParserSYNCHRONIZED, JavaParserTRANSIENT,
+			JavaParserVOLATILE:
+			continue
+		case JavaParserAT:
+			// An annotation, e.g. `@Deprecated` or `@SuppressWarnings("unchecked")`
+			i++
+			for stream.LA(i+1) == JavaParserDOT {
+				i += 2
+			}
+			if stream.LA(i+1) == JavaParserLPAREN {
+				i++
+				for depth := 1; depth > 0; {
+					i++
+					switch stream.LA(i) {
+					case JavaParserLPAREN:
+						depth++
+					case JavaParserRPAREN:
+						depth--
+					case antlr.TokenEOF:
+						return i
+					}
+				}
+			}
+		default:
+			return i
+		}
+	}
+}
+
 // IBlockContext is an interface to support dynamic dispatch.
 type IBlockContext interface {
 	antlr.ParserRuleContext
@@ -16000,7 +16304,8 @@ func (p *JavaParser) BlockStatement() (localctx IBlockStatementContext) {
 
 	p.SetState(1057)
 	p.GetErrorHandler().Sync(p)
-	switch p.GetInterpreter().AdaptivePredict(p.GetTokenStream(), 117, p.GetParserRuleContext()) {
+	// Patched by hand, since compactConstructorDeclaration isn't in the serialized ATN
+	switch p.predictRecordDeclaration(117, 3) {
 	case 1:
 		p.EnterOuterAlt(localctx, 1)
 		{
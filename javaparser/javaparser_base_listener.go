@@ -543,6 +543,14 @@ func (s *BaseJavaParserListener) EnterRecordBody(ctx *RecordBodyContext) {}
 // ExitRecordBody is called when production recordBody is exited.
 func (s *BaseJavaParserListener) ExitRecordBody(ctx *RecordBodyContext) {}
 
+// EnterCompactConstructorDeclaration is called when production compactConstructorDeclaration is entered.
+func (s *BaseJavaParserListener) EnterCompactConstructorDeclaration(ctx *CompactConstructorDeclarationContext) {
+}
+
+// ExitCompactConstructorDeclaration is called when production compactConstructorDeclaration is exited.
+func (s *BaseJavaParserListener) ExitCompactConstructorDeclaration(ctx *CompactConstructorDeclarationContext) {
+}
+
 // EnterBlock is called when production block is entered.
 func (s *BaseJavaParserListener) EnterBlock(ctx *BlockContext) {}
 
@@ -253,6 +253,9 @@ type JavaParserListener interface {
 	// EnterRecordBody is called when entering the recordBody production.
 	EnterRecordBody(c *RecordBodyContext)
 
+	// EnterCompactConstructorDeclaration is called when entering the compactConstructorDeclaration production.
+	EnterCompactConstructorDeclaration(c *CompactConstructorDeclarationContext)
+
 	// EnterBlock is called when entering the block production.
 	EnterBlock(c *BlockContext)
 
@@ -649,6 +652,9 @@ type JavaParserListener interface {
 	// ExitRecordBody is called when exiting the recordBody production.
 	ExitRecordBody(c *RecordBodyContext)
 
+	// ExitCompactConstructorDeclaration is called when exiting the compactConstructorDeclaration production.
+	ExitCompactConstructorDeclaration(c *CompactConstructorDeclarationContext)
+
 	// ExitBlock is called when exiting the block production.
 	ExitBlock(c *BlockContext)
 
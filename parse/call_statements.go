@@ -0,0 +1,170 @@
+package parse
+
+import (
+	"java-mini-ls-go/javaparser"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+)
+
+// joinSplitCallStatements works around an ambiguity in the grammar: since the semicolon after an expression
+// statement is optional, a call statement with a single argument like `print(x);` (or `this(x);`) can also
+// be read as the two statements `print` and `(x);`, which is what the parser goes for. This finds such pairs
+// of statements in the tree and joins them back into one statement with a method call.
+func joinSplitCallStatements(tree antlr.Tree) {
+	ctx, ok := tree.(antlr.ParserRuleContext)
+	if !ok {
+		return
+	}
+
+	for _, child := range ctx.GetChildren() {
+		joinSplitCallStatements(child)
+	}
+
+	for i := 0; i+1 < len(ctx.GetChildren()); i++ {
+		children := ctx.GetChildren()
+		first, ok1 := children[i].(*javaparser.BlockStatementContext)
+		second, ok2 := children[i+1].(*javaparser.BlockStatementContext)
+		if ok1 && ok2 && joinCallStatement(first, second) {
+			removeChild(ctx, i+1)
+			// The joined statement might also be the first half of another split call, e.g. `f(1)(2);`
+			i--
+		}
+	}
+}
+
+// joinCallStatement joins the second statement into the first one, if the first one ends with a bare method
+// name and the second one starts with its parenthesized argument. Returns whether it did.
+func joinCallStatement(first *javaparser.BlockStatementContext, second *javaparser.BlockStatementContext) bool {
+	dangling := danglingStatement(first)
+	if dangling == nil {
+		return false
+	}
+	callee := bareCallee(dangling)
+	if callee == nil {
+		return false
+	}
+
+	statement, ok := second.Statement().(*javaparser.StatementContext)
+	if !ok || statement.GetStatementExpression() == nil {
+		return false
+	}
+	expression, primary := leftmostPrimary(statement.GetStatementExpression().(*javaparser.ExpressionContext))
+	if primary == nil || primary.LPAREN() == nil || primary.RPAREN() == nil || primary.Expression() == nil {
+		return false
+	}
+
+	// Turn `(x)` into `print(x)`
+	parser := primary.GetParser()
+	call := javaparser.NewMethodCallContext(parser, expression, primary.GetInvokingState())
+	var start antlr.Token
+	switch callee := callee.(type) {
+	case *javaparser.IdentifierContext:
+		call.AddChild(callee)
+		callee.SetParent(call)
+		start = callee.GetStart()
+	case antlr.TerminalNode:
+		call.AddTokenNode(callee.GetSymbol())
+		start = callee.GetSymbol()
+	}
+	call.AddTokenNode(primary.LPAREN().GetSymbol())
+	arg := primary.Expression().(*javaparser.ExpressionContext)
+	args := javaparser.NewExpressionListContext(parser, call, primary.GetInvokingState())
+	args.AddChild(arg)
+	arg.SetParent(args)
+	args.SetStart(arg.GetStart())
+	args.SetStop(arg.GetStop())
+	call.AddChild(args)
+	call.AddTokenNode(primary.RPAREN().GetSymbol())
+	call.SetStart(start)
+	call.SetStop(primary.GetStop())
+	replaceChild(expression, primary, call)
+
+	// Everything from the call up to the statement now starts at the method name
+	var node antlr.ParserRuleContext = expression
+	for node != second {
+		node.SetStart(start)
+		node = node.GetParent().(antlr.ParserRuleContext)
+	}
+
+	// Put the statement in the place of the bare method name, and extend everything around it to its end
+	parent := dangling.GetParent().(antlr.ParserRuleContext)
+	replaceChild(parent, dangling, statement)
+	statement.SetParent(parent)
+	for node = parent; ; node = node.GetParent().(antlr.ParserRuleContext) {
+		node.SetStop(statement.GetStop())
+		if node == first {
+			break
+		}
+	}
+
+	return true
+}
+
+// danglingStatement returns the expression statement without a semicolon that a block statement ends with,
+// possibly as the body of an if, loop or labeled statement, or nil if there's none.
+func danglingStatement(blockStatement *javaparser.BlockStatementContext) *javaparser.StatementContext {
+	statement, ok := blockStatement.Statement().(*javaparser.StatementContext)
+	for ok {
+		if statement.GetStatementExpression() != nil {
+			if statement.GetChildCount() == 1 {
+				return statement
+			}
+			return nil
+		}
+		statement, ok = statement.GetChild(statement.GetChildCount() - 1).(*javaparser.StatementContext)
+	}
+	return nil
+}
+
+// bareCallee returns the identifier, `this` or `super` an expression statement consists of, or nil.
+func bareCallee(statement *javaparser.StatementContext) antlr.Tree {
+	expression, ok := statement.GetStatementExpression().(*javaparser.ExpressionContext)
+	if !ok || expression.GetChildCount() != 1 {
+		return nil
+	}
+	primary, ok := expression.GetChild(0).(*javaparser.PrimaryContext)
+	if !ok || primary.GetChildCount() != 1 {
+		return nil
+	}
+
+	switch child := primary.GetChild(0).(type) {
+	case *javaparser.IdentifierContext:
+		return child
+	case antlr.TerminalNode:
+		tokenType := child.GetSymbol().GetTokenType()
+		if tokenType == javaparser.JavaLexerTHIS || tokenType == javaparser.JavaLexerSUPER {
+			return child
+		}
+	}
+	return nil
+}
+
+// leftmostPrimary returns the primary expression an expression starts with, along with the expression it's
+// directly in. Returns nil if the expression starts with something else, e.g. a cast or a prefix operator.
+func leftmostPrimary(expression *javaparser.ExpressionContext) (*javaparser.ExpressionContext, *javaparser.PrimaryContext) {
+	for {
+		switch child := expression.GetChild(0).(type) {
+		case *javaparser.ExpressionContext:
+			expression = child
+		case *javaparser.PrimaryContext:
+			return expression, child
+		default:
+			return nil, nil
+		}
+	}
+}
+
+func replaceChild(parent antlr.ParserRuleContext, old antlr.Tree, new antlr.Tree) {
+	children := parent.GetChildren()
+	for i, child := range children {
+		if child == old {
+			children[i] = new
+		}
+	}
+}
+
+func removeChild(parent antlr.ParserRuleContext, idx int) {
+	children := parent.GetChildren()
+	copy(children[idx:], children[idx+1:])
+	parent.RemoveLastChild()
+}
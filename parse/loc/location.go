@@ -35,6 +35,14 @@ func ParserRuleContextToBounds(ctx antlr.ParserRuleContext) Bounds {
 	}
 }
 
+// TokenToBounds returns the bounds of a single token, e.g. a keyword.
+func TokenToBounds(token antlr.Token) Bounds {
+	return Bounds{
+		Start: FileLocation{token.GetLine(), token.GetColumn()},
+		End:   FileLocation{token.GetLine(), token.GetColumn() + len(token.GetText())},
+	}
+}
+
 func BoundsToRange(bounds Bounds) protocol.Range {
 	return protocol.Range{
 		Start: protocol.Position{
@@ -40,6 +40,7 @@ func Parse(input string) (*javaparser.CompilationUnitContext, []SyntaxError) {
 	p.AddErrorListener(errListener)
 	p.BuildParseTrees = true
 	parsed := p.CompilationUnit().(*javaparser.CompilationUnitContext)
+	joinSplitCallStatements(parsed)
 
 	return parsed, errListener.errors
 }
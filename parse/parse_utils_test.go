@@ -52,3 +52,37 @@ func TestLex(t *testing.T) {
 		assert.Equalf(t, expected[i].ttext, tokens[i].GetText(), "token text not equal for index %d", i)
 	}
 }
+
+type callListener struct {
+	*javaparser.BaseJavaParserListener
+	calls           []string
+	blockStatements []string
+}
+
+func (l *callListener) EnterBlockStatement(ctx *javaparser.BlockStatementContext) {
+	l.blockStatements = append(l.blockStatements, ctx.GetText()+"@"+ctx.GetStart().GetText())
+}
+
+func (l *callListener) EnterMethodCall(ctx *javaparser.MethodCallContext) {
+	l.calls = append(l.calls, ctx.GetText())
+}
+
+func TestParse_SingleArgumentCallStatements(t *testing.T) {
+	parsed, errors := Parse(`class MyClass {
+	MyClass(int a) {
+		this(a, a);
+	}
+	MyClass(int a, int b) {
+		super(a);
+		g(1);
+		if (a > b) g(2).h();
+		for (;;) g(3) + 1;
+	}
+}`)
+	listener := &callListener{}
+	antlr.ParseTreeWalkerDefault.Walk(listener, parsed)
+	assert.Equal(t, 0, len(errors))
+	assert.Equal(t, []string{"this(a,a)", "super(a)", "g(1)", "g(2)", "h()", "g(3)"}, listener.calls)
+
+	assert.Equal(t, []string{"this(a,a);@this", "super(a);@super", "g(1);@g", "if(a>b)g(2).h();@if", "for(;;)g(3)+1;@for"}, listener.blockStatements)
+}
@@ -1,6 +1,7 @@
 package parse
 
 import (
+	"fmt"
 	"java-mini-ls-go/javaparser"
 	"java-mini-ls-go/parse/loc"
 	"java-mini-ls-go/util"
@@ -22,9 +23,12 @@ const (
 	ScopeTypeEnum           ScopeType = iota
 	ScopeTypeRecord         ScopeType = iota
 	ScopeTypeInterface      ScopeType = iota
+	// The body of an anonymous class, e.g. the `{ ... }` in `new Runnable() { ... }`
+	ScopeTypeAnonymousClass ScopeType = iota
 
 	// method types
 
+	ScopeTypeCompactConstructor     ScopeType = iota
 	ScopeTypeConstructor            ScopeType = iota
 	ScopeTypeGenericConstructor     ScopeType = iota
 	ScopeTypeGenericInterfaceMethod ScopeType = iota
@@ -39,9 +43,11 @@ var classTypes = []ScopeType{
 	ScopeTypeEnum,
 	ScopeTypeInterface,
 	ScopeTypeRecord,
+	ScopeTypeAnonymousClass,
 }
 
 var methodTypes = []ScopeType{
+	ScopeTypeCompactConstructor,
 	ScopeTypeConstructor,
 	ScopeTypeGenericConstructor,
 	ScopeTypeGenericInterfaceMethod,
@@ -69,21 +75,30 @@ type Scope struct {
 	Parent *Scope
 	// Children is a list of all scopes nested under this one
 	Children []*Scope
+	// LocalIndex numbers the local and anonymous classes in a top-level type in the order they're declared,
+	// starting at 1. It's 0 for other scopes.
+	LocalIndex int
 }
 
 type ScopeTracker struct {
 	// A stack of scopes
 	ScopeStack util.Stack[*Scope]
+	// How many local and anonymous classes there have been so far in the current top-level type
+	localTypeCount int
 }
 
 func NewScopeTracker() *ScopeTracker {
 	return &ScopeTracker{
-		ScopeStack: util.NewStack[*Scope](),
+		ScopeStack:     util.NewStack[*Scope](),
+		localTypeCount: 0,
 	}
 }
 
 func (st *ScopeTracker) CheckEnterScope(ctx antlr.ParserRuleContext) *Scope {
-	if st.shouldCreateScope(ctx.GetRuleIndex()) {
+	if st.shouldCreateScope(ctx) {
+		if st.ScopeStack.Empty() {
+			st.localTypeCount = 0
+		}
 		// Create new scope and add to stack
 		newScope := st.createScope(st.ScopeStack.Top(), ctx)
 		st.ScopeStack.Push(newScope)
@@ -93,7 +108,7 @@ func (st *ScopeTracker) CheckEnterScope(ctx antlr.ParserRuleContext) *Scope {
 }
 
 func (st *ScopeTracker) CheckExitScope(ctx antlr.ParserRuleContext) *Scope {
-	if st.shouldCreateScope(ctx.GetRuleIndex()) {
+	if st.shouldCreateScope(ctx) {
 		// Pop top scope from the stack
 		return st.ScopeStack.Pop()
 	}
@@ -108,8 +123,8 @@ func (st *ScopeTracker) CurrScopeName() string {
 	return strings.Join(scopeNames, ".")
 }
 
-func (st *ScopeTracker) shouldCreateScope(ruleType int) bool {
-	switch ruleType {
+func (st *ScopeTracker) shouldCreateScope(ctx antlr.ParserRuleContext) bool {
+	switch ctx.GetRuleIndex() {
 
 	// class types
 
@@ -123,6 +138,8 @@ func (st *ScopeTracker) shouldCreateScope(ruleType int) bool {
 		return true
 	case javaparser.JavaParserRULE_recordDeclaration:
 		return true
+	case javaparser.JavaParserRULE_classBody:
+		return isAnonymousClassBody(ctx)
 
 	// method types
 
@@ -130,21 +147,28 @@ func (st *ScopeTracker) shouldCreateScope(ruleType int) bool {
 		return true
 	case javaparser.JavaParserRULE_genericMethodDeclaration:
 		return true
+	case javaparser.JavaParserRULE_interfaceMethodDeclaration:
+		return true
+	case javaparser.JavaParserRULE_genericInterfaceMethodDeclaration:
+		return true
 	case javaparser.JavaParserRULE_constructorDeclaration:
 		return true
 	case javaparser.JavaParserRULE_genericConstructorDeclaration:
 		return true
+	case javaparser.JavaParserRULE_compactConstructorDeclaration:
+		return true
 	}
 	return false
 }
 
 func (st *ScopeTracker) createScope(parent *Scope, ctx antlr.ParserRuleContext) *Scope {
 	ret := &Scope{
-		Name:     "",
-		Type:     ScopeTypeUnset,
-		Bounds:   loc.Bounds{}, //nolint:exhaustruct
-		Parent:   parent,
-		Children: make([]*Scope, 0),
+		Name:       "",
+		Type:       ScopeTypeUnset,
+		Bounds:     loc.Bounds{}, //nolint:exhaustruct
+		Parent:     parent,
+		Children:   make([]*Scope, 0),
+		LocalIndex: 0,
 	}
 
 	var subCtx javaparser.IIdentifierContext = nil
@@ -164,13 +188,16 @@ func (st *ScopeTracker) createScope(parent *Scope, ctx antlr.ParserRuleContext)
 		subCtx = ctx.(*javaparser.InterfaceMethodDeclarationContext).InterfaceCommonBodyDeclaration().(*javaparser.InterfaceCommonBodyDeclarationContext).Identifier()
 	case javaparser.JavaParserRULE_genericInterfaceMethodDeclaration:
 		ret.Type = ScopeTypeGenericInterfaceMethod
-		subCtx = ctx.(*javaparser.InterfaceMethodDeclarationContext).InterfaceCommonBodyDeclaration().(*javaparser.InterfaceCommonBodyDeclarationContext).Identifier()
+		subCtx = ctx.(*javaparser.GenericInterfaceMethodDeclarationContext).InterfaceCommonBodyDeclaration().(*javaparser.InterfaceCommonBodyDeclarationContext).Identifier()
 	case javaparser.JavaParserRULE_constructorDeclaration:
 		ret.Type = ScopeTypeConstructor
 		subCtx = ctx.(*javaparser.ConstructorDeclarationContext).Identifier()
 	case javaparser.JavaParserRULE_genericConstructorDeclaration:
 		ret.Type = ScopeTypeGenericConstructor
 		subCtx = ctx.(*javaparser.GenericConstructorDeclarationContext).ConstructorDeclaration().(*javaparser.ConstructorDeclarationContext).Identifier()
+	case javaparser.JavaParserRULE_compactConstructorDeclaration:
+		ret.Type = ScopeTypeCompactConstructor
+		subCtx = ctx.(*javaparser.CompactConstructorDeclarationContext).Identifier()
 	case javaparser.JavaParserRULE_interfaceDeclaration:
 		ret.Type = ScopeTypeInterface
 		subCtx = ctx.(*javaparser.InterfaceDeclarationContext).Identifier()
@@ -183,11 +210,44 @@ func (st *ScopeTracker) createScope(parent *Scope, ctx antlr.ParserRuleContext)
 	case javaparser.JavaParserRULE_recordDeclaration:
 		ret.Type = ScopeTypeRecord
 		subCtx = ctx.(*javaparser.RecordDeclarationContext).Identifier()
+	case javaparser.JavaParserRULE_classBody:
+		// Anonymous classes don't have a name, so they're described by what they're created from
+		ret.Type = ScopeTypeAnonymousClass
+		if constant, ok := ctx.GetParent().(*javaparser.EnumConstantContext); ok {
+			ret.Name = fmt.Sprintf("%s {...}", constant.Identifier().GetText())
+			ret.Bounds = loc.ParserRuleContextToBounds(constant.Identifier())
+			break
+		}
+		createdName := ctx.GetParent().GetParent().(*javaparser.CreatorContext).CreatedName()
+		ret.Name = fmt.Sprintf("new %s() {...}", createdName.GetText())
+		ret.Bounds = loc.ParserRuleContextToBounds(createdName)
+	}
+	if subCtx != nil {
+		ret.Name, ret.Bounds = nameAndBoundsForCtx(subCtx)
+	}
+
+	if _, ok := ctx.GetParent().(*javaparser.LocalTypeDeclarationContext); ok || ret.Type == ScopeTypeAnonymousClass {
+		st.localTypeCount++
+		ret.LocalIndex = st.localTypeCount
 	}
-	ret.Name, ret.Bounds = nameAndBoundsForCtx(subCtx)
 	return ret
 }
 
+// isAnonymousClassBody says whether a class body is that of an anonymous class, e.g. `new Runnable() { ... }`,
+// or the body of an enum constant, which is an anonymous subclass of the enum.
+func isAnonymousClassBody(ctx antlr.ParserRuleContext) bool {
+	if _, ok := ctx.GetParent().(*javaparser.EnumConstantContext); ok {
+		return true
+	}
+	_, ok := ctx.GetParent().(*javaparser.ClassCreatorRestContext)
+	if !ok {
+		return false
+	}
+	// Array creators can't have class bodies, so it's always a creator of a class
+	_, ok = ctx.GetParent().GetParent().(*javaparser.CreatorContext)
+	return ok
+}
+
 func nameAndBoundsForCtx(ident javaparser.IIdentifierContext) (string, loc.Bounds) {
 	return ident.GetText(), loc.ParserRuleContextToBounds(ident)
 }
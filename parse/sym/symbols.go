@@ -40,7 +40,9 @@ var CodeSymbolTypeNames = map[CodeSymbolType]string{
 
 var ScopeTypesToCodeSymboltypes = map[parse.ScopeType]CodeSymbolType{
 	parse.ScopeTypeAnnotationType:         CodeSymbolClass,
+	parse.ScopeTypeAnonymousClass:         CodeSymbolClass,
 	parse.ScopeTypeClass:                  CodeSymbolClass,
+	parse.ScopeTypeCompactConstructor:     CodeSymbolConstructor,
 	parse.ScopeTypeConstructor:            CodeSymbolConstructor,
 	parse.ScopeTypeEnum:                   CodeSymbolEnum,
 	parse.ScopeTypeGenericConstructor:     CodeSymbolConstructor,
@@ -0,0 +1,20 @@
+package typ
+
+import "java-mini-ls-go/util"
+
+// UnimplementedMethods returns the abstract methods this type inherits without there being an implementation
+// of them, e.g. `run()` for a class that implements `Runnable` without declaring it, with the type arguments of
+// its supertypes filled in. A class that isn't abstract has to implement all of them. Abstract methods that are
+// also public methods of Object, like `equals` in `Comparator`, don't count, since Object implements them.
+func (jt *JavaType) UnimplementedMethods() []*JavaMethod {
+	unimplemented := []*JavaMethod{}
+	for _, name := range jt.GetOriginal().allMethodNames(util.NewSet[*JavaType]()) {
+		for _, method := range jt.LookupMethods(name) {
+			if method.ParentType == jt || !method.IsAbstract || isObjectMethod(method) {
+				continue
+			}
+			unimplemented = append(unimplemented, method)
+		}
+	}
+	return unimplemented
+}
@@ -0,0 +1,82 @@
+package typ
+
+// IsAccessible says whether a field, method or constructor can be accessed from code inside of the given type,
+// following JLS 6.6:
+//   - public members can be accessed from anywhere
+//   - private ones only from inside of the top-level type they're declared in
+//   - package-private ones only from inside of the same package
+//   - protected ones from inside of the same package, and from subclasses, as long as instance members are
+//     accessed through an expression of that subclass (JLS 6.6.2)
+//
+// The qualifier is the type of the expression the member is accessed through, e.g. the type of `a` in `a.b`,
+// or nil if there isn't one. For constructors, it's the type being created by `new`, or nil for `super(...)`.
+func IsAccessible(member JavaSymbol, from *JavaType, qualifier *JavaType) bool {
+	declaringType := DeclaringType(member)
+	if declaringType == nil || from == nil {
+		return true
+	}
+	declaringType = declaringType.GetOriginal()
+
+	switch member.GetVisibility() {
+	case VisibilityPrivate:
+		return from.TopLevelType() == declaringType.TopLevelType()
+	case VisibilityDefault:
+		return from.Package == declaringType.Package
+	case VisibilityProtected:
+		if from.Package == declaringType.Package {
+			return true
+		}
+		if member.Kind() == JavaSymbolConstructor {
+			// Only a subclass's own constructors can call a protected one, through `super(...)`
+			return qualifier == nil && from.IsSubclassOf(declaringType)
+		}
+		for subclass := from; subclass != nil; subclass = subclass.EnclosingType {
+			if !subclass.IsSubclassOf(declaringType) {
+				continue
+			}
+			if qualifier == nil || qualifier.Type == JavaTypeLSPClass || IsStaticMember(member) || qualifier.IsSubclassOf(subclass) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// DeclaringType returns the type a field, method or constructor is declared in, or nil for other symbols.
+func DeclaringType(member JavaSymbol) *JavaType {
+	switch member := member.(type) {
+	case *JavaField:
+		return member.ParentType
+	case *JavaMethod:
+		return member.ParentType
+	case *JavaConstructor:
+		return member.ParentType
+	}
+	return nil
+}
+
+// TopLevelType returns the type that this one is nested inside of, directly or indirectly, that isn't nested
+// inside of anything itself. For top-level types, that's the type itself.
+func (jt *JavaType) TopLevelType() *JavaType {
+	topLevel := jt.GetOriginal()
+	for topLevel.EnclosingType != nil {
+		topLevel = topLevel.EnclosingType
+	}
+	return topLevel
+}
+
+// IsSubclassOf says whether this type is the given type, or extends or implements it, directly or indirectly.
+// Type arguments are ignored.
+func (jt *JavaType) IsSubclassOf(other *JavaType) bool {
+	other = other.GetOriginal()
+	if jt.GetOriginal() == other {
+		return true
+	}
+	for _, supertype := range jt.AllSuperClasses() {
+		if supertype.GetOriginal() == other {
+			return true
+		}
+	}
+	return false
+}
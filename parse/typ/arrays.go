@@ -0,0 +1,128 @@
+package typ
+
+import (
+	"strings"
+
+	"java-mini-ls-go/parse/loc"
+)
+
+// NewArrayType creates the type of an array with the given number of dimensions, e.g. `String[][]` for
+// `String` and 2. If the element type is itself an array type, its dimensions are added on.
+//
+// Array types have the `length` field and `clone()` method, and otherwise inherit the members of `Object`.
+func NewArrayType(elementType *JavaType, dimensions int) *JavaType {
+	if elementType.Type == JavaTypeArray {
+		dimensions += elementType.Dimensions
+		elementType = elementType.ElementType
+	}
+
+	t := NewJavaType(elementType.Name+strings.Repeat("[]", dimensions), elementType.Package, VisibilityPublic, JavaTypeArray, nil)
+	t.ElementType = elementType
+	t.Dimensions = dimensions
+
+	if object := builtinType("java.lang.Object"); object != nil {
+		t.Extends = []*JavaType{object}
+	}
+	for _, iface := range []string{"java.lang.Cloneable", "java.io.Serializable"} {
+		if ifaceType := builtinType(iface); ifaceType != nil {
+			t.Implements = append(t.Implements, ifaceType)
+		}
+	}
+
+	intType := builtinType("int")
+	if intType == nil {
+		intType = NewPrimitiveType("int")
+	}
+	t.Fields = []*JavaField{{
+		Name:           "length",
+		Type:           intType,
+		ParentType:     t,
+		Definition:     nil,
+		Usages:         []loc.CodeLocation{},
+		Visibility:     VisibilityPublic,
+		IsStatic:       false,
+		IsFinal:        true,
+		IsEnumConstant: false,
+		Original:       nil,
+	}}
+	t.Methods = []*JavaMethod{{
+		Name:       "clone",
+		ParentType: t,
+		ReturnType: t,
+		Params:     []*JavaParameter{},
+		Definition: nil,
+		Usages:     []loc.CodeLocation{},
+		Visibility: VisibilityPublic,
+		IsStatic:   false,
+		TypeParams: nil,
+		Original:   nil,
+	}}
+
+	return t
+}
+
+// ComponentType returns the type of the elements of an array type, e.g. `String[]` for `String[][]`.
+func (jt *JavaType) ComponentType() *JavaType {
+	if jt.Dimensions <= 1 {
+		return jt.ElementType
+	}
+	return NewArrayType(jt.ElementType, jt.Dimensions-1)
+}
+
+// IteratedType returns the type of the elements an enhanced `for` loop goes through for a value of this type,
+// e.g. `String` for `String[]` or `List<String>`, and Object for a raw Iterable, which is reported as raw since
+// its elements could really be anything. Returns nil if it's neither an array nor an Iterable.
+func (jt *JavaType) IteratedType() (elementType *JavaType, raw bool) {
+	if jt.Type == JavaTypeArray {
+		return jt.ComponentType(), false
+	}
+
+	iterable := builtinType("java.lang.Iterable")
+	if iterable == nil {
+		return nil, false
+	}
+	for _, super := range append([]*JavaType{jt}, jt.AllSuperClasses()...) {
+		if super == nil || super.GetOriginal() != iterable {
+			continue
+		}
+		if len(super.GenericArgs) == 0 {
+			return builtinType("java.lang.Object"), true
+		}
+		return super.NonWildcardParameterization().GenericArgs[0], false
+	}
+	return nil, false
+}
+
+// withElementType returns this array type with a different element type, or the type itself if the
+// element type is the same.
+func (jt *JavaType) withElementType(elementType *JavaType) *JavaType {
+	if elementType == jt.ElementType {
+		return jt
+	}
+	return NewArrayType(elementType, jt.Dimensions)
+}
+
+// SplitArrayDims splits the name of an array type like `String[][]` into the name of its element type
+// (`String`) and its number of dimensions (2). The dimensions are 0 if it's not an array type.
+func SplitArrayDims(name string) (string, int) {
+	name = strings.TrimSpace(name)
+	dimensions := 0
+	for strings.HasSuffix(name, "]") {
+		openIdx := strings.LastIndex(name, "[")
+		if openIdx == -1 || strings.TrimSpace(name[openIdx+1:len(name)-1]) != "" {
+			break
+		}
+		name = strings.TrimSpace(name[:openIdx])
+		dimensions++
+	}
+	return name, dimensions
+}
+
+// builtinType returns the standard library type with the given qualified name, or nil if the standard
+// library hasn't been loaded (yet).
+func builtinType(qualifiedName string) *JavaType {
+	if builtinTypes == nil {
+		return nil
+	}
+	return builtinTypes.Get(qualifiedName)
+}
@@ -17,34 +17,150 @@ import (
 	"golang.org/x/exp/slices"
 )
 
-// TypeMap is a collection of currently loaded types
+// TypeMap is a collection of currently loaded types, keyed by their fully qualified names
 type TypeMap struct {
 	sync.RWMutex
 	contents map[string]*JavaType
+	// Nested name (e.g. `Map.Entry`) -> qualified names of all the types with that name, in the order they were added
+	byNestedName map[string][]string
 }
 
 func NewTypeMap() *TypeMap {
-	return &TypeMap{contents: make(map[string]*JavaType)}
+	return &TypeMap{
+		contents:     make(map[string]*JavaType),
+		byNestedName: make(map[string][]string),
+	}
 }
 
 func (tm *TypeMap) Add(t *JavaType) {
 	tm.Lock()
 	defer tm.Unlock()
 
-	if existing, ok := tm.contents[t.FullName()]; ok {
+	if existing, ok := tm.contents[t.QualifiedName()]; ok {
 		// Merge existing usages into the current one
 		// TODO check for out-of-date ones
 		t.Usages = append(t.Usages, existing.Usages...)
 	}
 
-	tm.contents[t.FullName()] = t
+	tm.put(t)
+}
+
+// put adds a type, replacing any existing type with the same qualified name. Must hold the write lock.
+func (tm *TypeMap) put(t *JavaType) {
+	qualifiedName := t.QualifiedName()
+	if _, ok := tm.contents[qualifiedName]; !ok {
+		nestedName := t.NestedName()
+		tm.byNestedName[nestedName] = append(tm.byNestedName[nestedName], qualifiedName)
+	}
+
+	tm.contents[qualifiedName] = t
+}
+
+// remove removes the type with the given qualified name. Must hold the write lock.
+func (tm *TypeMap) remove(qualifiedName string) {
+	t, ok := tm.contents[qualifiedName]
+	if !ok {
+		return
+	}
+	delete(tm.contents, qualifiedName)
+
+	nestedName := t.NestedName()
+	remaining := []string{}
+	for _, name := range tm.byNestedName[nestedName] {
+		if name != qualifiedName {
+			remaining = append(remaining, name)
+		}
+	}
+	if len(remaining) == 0 {
+		delete(tm.byNestedName, nestedName)
+	} else {
+		tm.byNestedName[nestedName] = remaining
+	}
+}
+
+// ReplaceFile atomically replaces all the types that were declared in the given file with the given
+// types, which should be the result of gathering the given version of that file. Types that the file
+// used to declare but doesn't anymore are removed. Usages of the old types from other files are
+// carried over to the new types of the same name. Types from other files that refer to the old types, e.g.
+// as their supertype, keep referring to them until those files are gathered again.
+//
+// If the map already has types from a newer version of the file, nothing is changed and false is returned.
+func (tm *TypeMap) ReplaceFile(fileURI string, version int, types []*JavaType) bool {
+	tm.Lock()
+	defer tm.Unlock()
+
+	oldTypes := map[string]*JavaType{}
+	for name, existing := range tm.contents {
+		if existing.Definition == nil || existing.Definition.FileUri != fileURI {
+			continue
+		}
+		if existing.Definition.Version > version {
+			return false
+		}
+		oldTypes[name] = existing
+	}
+
+	for name := range oldTypes {
+		tm.remove(name)
+	}
+
+	for _, t := range types {
+		existing, ok := oldTypes[t.QualifiedName()]
+		if !ok {
+			existing, ok = tm.contents[t.QualifiedName()]
+		}
+		if ok {
+			for _, usage := range existing.Usages {
+				if usage.FileUri != fileURI {
+					t.Usages = append(t.Usages, usage)
+				}
+			}
+		}
+
+		tm.put(t)
+	}
+
+	return true
+}
+
+// Get looks up a type by its fully qualified name
+func (tm *TypeMap) Get(qualifiedName string) *JavaType {
+	tm.RLock()
+	defer tm.RUnlock()
+
+	return tm.contents[qualifiedName]
+}
+
+// GetByNestedName looks up a type by its name without the package (e.g. `String` or `Map.Entry`).
+// If there are several, types in java.lang are preferred, followed by whichever was added first.
+func (tm *TypeMap) GetByNestedName(nestedName string) *JavaType {
+	tm.RLock()
+	defer tm.RUnlock()
+
+	qualifiedNames := tm.byNestedName[nestedName]
+	if len(qualifiedNames) == 0 {
+		return nil
+	}
+
+	for _, qualifiedName := range qualifiedNames {
+		if t := tm.contents[qualifiedName]; t.Package == "java.lang" {
+			return t
+		}
+	}
+	return tm.contents[qualifiedNames[0]]
 }
 
-func (tm *TypeMap) Get(s string) *JavaType {
+// HasPackage returns whether any of the types are in the given package.
+func (tm *TypeMap) HasPackage(packageName string) bool {
 	tm.RLock()
 	defer tm.RUnlock()
 
-	return tm.contents[s]
+	for _, t := range tm.contents {
+		if t.Package == packageName {
+			return true
+		}
+	}
+	return false
 }
 
 func (tm *TypeMap) Size() int {
@@ -101,6 +217,7 @@ func LoadBuiltinTypes() (*TypeMap, error) {
 // JSON-based types for loading from file
 
 type javaJsonType struct {
+	// Name includes the type parameters of generic types, e.g. `Map<K,V>`
 	Name         string                `json:"name"`
 	Type         string                `json:"type"`
 	Module       string                `json:"module"`
@@ -110,13 +227,28 @@ type javaJsonType struct {
 	Fields       []javaJsonField       `json:"fields"`
 	Methods      []javaJsonMethod      `json:"methods"`
 	Constructors []javaJsonConstructor `json:"constructors"`
+}
+
+// NestedName returns the name of the type without its package or type parameters, e.g. `Map.Entry`
+// for `Map.Entry<K,V>`. Should mimic the output of JavaType.NestedName()
+func (jjt *javaJsonType) NestedName() string {
+	return stripTypeParams(jjt.Name)
+}
 
-	// TODO add generics
+// QualifiedName should mimic the output of JavaType.QualifiedName()
+func (jjt *javaJsonType) QualifiedName() string {
+	return jjt.Package + "." + jjt.NestedName()
 }
 
-func (jjt *javaJsonType) FullName() string {
-	// Should mimic the output of JavaType.FullName()
-	return jjt.Name
+// TypeParams returns the declarations of the type's type parameters, e.g. `K` and `V` for `Map<K,V>`.
+// Older versions of the JSON file cut names off at the first space, e.g. `Enum<E` for
+// `Enum<E extends Enum<E>>`, so the closing bracket may be missing.
+func (jjt *javaJsonType) TypeParams() []string {
+	idx := strings.Index(jjt.Name, "<")
+	if idx == -1 {
+		return nil
+	}
+	return SplitTopLevel(strings.TrimSuffix(jjt.Name[idx+1:], ">"), ',')
 }
 
 type javaJsonField struct {
@@ -132,6 +264,9 @@ type javaJsonMethod struct {
 	Type        string        `json:"type"`
 	Description string        `json:"description"`
 	Args        []javaJsonArg `json:"args"`
+	// TypeParams are the declarations of the type parameters of a generic method, e.g. `T extends Comparable<T>`.
+	// Older versions of the JSON file don't have them at all, in which case this is nil.
+	TypeParams []string `json:"typeParams"`
 }
 
 type javaJsonArg struct {
@@ -252,47 +387,122 @@ func convertJsonTypeType(jsonTypeType string) JavaTypeType {
 }
 
 func convertJsonField(parentType *JavaType, jsonField javaJsonField) *JavaField {
+	isStatic := slices.Contains(jsonField.Modifiers, "static")
+	context := jsonTypeContext{parentType: parentType, method: nil, isStatic: isStatic, guessMethodTypeParams: false, keepRaw: false}
+
 	return &JavaField{
 		Name:       jsonField.Name,
 		ParentType: parentType,
-		Type:       getOrCreateBuiltinType(jsonField.Type),
-		Visibility: VisibilityPublic,
+		Type:       context.convertTypeName(jsonField.Type),
+		Visibility: jsonVisibility(jsonField.Modifiers),
 		IsStatic:   slices.Contains(jsonField.Modifiers, "static"),
 		IsFinal:    slices.Contains(jsonField.Modifiers, "final"),
 		Definition: nil,
 		Usages:     []loc.CodeLocation{},
+		// The JSON file doesn't list the constants of enums
+		IsEnumConstant: false,
+		Original:       nil,
 	}
 }
 
 func convertJsonMethod(parentType *JavaType, jsonMethod javaJsonMethod) *JavaMethod {
-	return &JavaMethod{
+	method := &JavaMethod{
 		Name:       jsonMethod.Name,
 		ParentType: parentType,
-		ReturnType: getOrCreateBuiltinType(jsonMethod.Type),
-		Params:     util.Map(jsonMethod.Args, toArg),
-		Visibility: VisibilityPublic,
+		ReturnType: nil,
+		Params:     nil,
+		Visibility: jsonVisibility(jsonMethod.Modifiers),
 		IsStatic:   slices.Contains(jsonMethod.Modifiers, "static"),
+		IsAbstract: isAbstractJsonMethod(parentType, jsonMethod),
 		Definition: nil,
 		Usages:     []loc.CodeLocation{},
+		TypeParams: nil,
+		Original:   nil,
+	}
+
+	context := jsonTypeContext{
+		parentType:            parentType,
+		method:                method,
+		isStatic:              method.IsStatic,
+		guessMethodTypeParams: jsonMethod.TypeParams == nil,
+		keepRaw:               false,
+	}
+	method.TypeParams = context.convertTypeParams(jsonMethod.TypeParams)
+
+	// Convert the params before the return type, so that any guessed type parameters are found in the
+	// params first, e.g. `T` for `static <T> Optional<T> of(T value)`
+	method.Params = context.convertArgs(jsonMethod.Args)
+	method.ReturnType = context.convertTypeName(jsonMethod.Type)
+
+	return method
+}
+
+// jsonVisibility returns the visibility of a field or method from the JSON file, which only lists the ones that
+// are public or protected.
+func jsonVisibility(modifiers []string) VisibilityType {
+	if slices.Contains(modifiers, "protected") {
+		return VisibilityProtected
 	}
+	return VisibilityPublic
+}
+
+// isAbstractJsonMethod says whether a method is abstract. Interface methods are implicitly abstract, unless
+// they're default or static.
+func isAbstractJsonMethod(parentType *JavaType, jsonMethod javaJsonMethod) bool {
+	if slices.Contains(jsonMethod.Modifiers, "abstract") {
+		return true
+	}
+	return parentType.Type == JavaTypeInterface && !slices.Contains(jsonMethod.Modifiers, "static") && !slices.Contains(jsonMethod.Modifiers, "default")
 }
 
 // Loads provided JSON types into builtinTypes map
 func loadJsonTypes(jsonTypes []javaJsonType) error {
 	// First, get just the bare types defined
+	newTypes := make(map[string]*JavaType, len(jsonTypes))
+	for _, jsonType := range jsonTypes {
+		nestedName := jsonType.NestedName()
+		simpleName := nestedName[strings.LastIndex(nestedName, ".")+1:]
+		newType := NewJavaType(simpleName, jsonType.Package, VisibilityPublic, convertJsonTypeType(jsonType.Type), nil)
+		for _, typeParam := range jsonType.TypeParams() {
+			name, _ := SplitTypeParam(typeParam)
+			newType.TypeParams = append(newType.TypeParams, NewTypeVariable(name, nil))
+		}
+		newTypes[jsonType.QualifiedName()] = newType
+	}
+
+	// Nested types need to know which type they're nested in before they can be added to the map,
+	// since that's part of their qualified name
+	for _, jsonType := range jsonTypes {
+		nestedName := jsonType.NestedName()
+		if dotIdx := strings.LastIndex(nestedName, "."); dotIdx != -1 {
+			ttype := newTypes[jsonType.QualifiedName()]
+			ttype.EnclosingType = newTypes[jsonType.Package+"."+nestedName[:dotIdx]]
+		}
+	}
 	for _, jsonType := range jsonTypes {
-		newType := NewJavaType(jsonType.Name, jsonType.Package, VisibilityPublic, convertJsonTypeType(jsonType.Type), nil)
-		builtinTypes.Add(newType)
+		builtinTypes.Add(newTypes[jsonType.QualifiedName()])
 	}
 
-	// Next, fill in extends/implements references
+	// Next, fill in the bounds of type parameters, and extends/implements references
 	for _, jsonType := range jsonTypes {
-		ttype := builtinTypes.Get(jsonType.Name)
+		ttype := builtinTypes.Get(jsonType.QualifiedName())
+		context := jsonTypeContext{parentType: ttype, method: nil, isStatic: false, guessMethodTypeParams: false, keepRaw: false}
+
+		// The JSON file can have several entries for the same type, which might not agree on the type parameters
+		declaredTypeParams := jsonType.TypeParams()
+		for i, typeParam := range ttype.TypeParams {
+			var bounds []string
+			if i < len(declaredTypeParams) {
+				_, bounds = SplitTypeParam(declaredTypeParams[i])
+			}
+			typeParam.Extends = context.convertBounds(bounds)
+		}
+
 		if jsonType.Extends != nil {
-			ttype.Extends = util.Map(jsonType.Extends, getOrCreateBuiltinType)
+			ttype.Extends = util.Map(splitJsonTypeList(jsonType.Extends), context.convertSupertypeName)
 		}
 		if jsonType.Implements != nil {
-			ttype.Implements = util.Map(jsonType.Implements, getOrCreateBuiltinType)
+			ttype.Implements = util.Map(splitJsonTypeList(jsonType.Implements), context.convertSupertypeName)
 		}
 	}
 
@@ -300,15 +510,18 @@ func loadJsonTypes(jsonTypes []javaJsonType) error {
 	for _, jsonType := range jsonTypes {
 		constructors := make([]*JavaConstructor, 0, len(jsonType.Constructors))
 
-		parentType := builtinTypes.Get(jsonType.FullName())
+		parentType := builtinTypes.Get(jsonType.QualifiedName())
+
+		context := jsonTypeContext{parentType: parentType, method: nil, isStatic: false, guessMethodTypeParams: false, keepRaw: false}
 
 		for _, jsonConstructor := range jsonType.Constructors {
 			constructors = append(constructors, &JavaConstructor{
 				ParentType: parentType,
-				Params:     util.Map(jsonConstructor.Args, toArg),
+				Params:     context.convertArgs(jsonConstructor.Args),
 				Definition: nil,
 				Usages:     []loc.CodeLocation{},
 				Visibility: VisibilityPublic,
+				Original:   nil,
 			})
 		}
 
@@ -317,7 +530,7 @@ func loadJsonTypes(jsonTypes []javaJsonType) error {
 
 	// Next, fill in the fields & methods
 	for _, jsonType := range jsonTypes {
-		parentType := builtinTypes.Get(jsonType.FullName())
+		parentType := builtinTypes.Get(jsonType.QualifiedName())
 		parentType.Fields = util.Map(jsonType.Fields, func(jsonField javaJsonField) *JavaField {
 			return convertJsonField(parentType, jsonField)
 		})
@@ -329,18 +542,216 @@ func loadJsonTypes(jsonTypes []javaJsonType) error {
 	return nil
 }
 
-func toArg(arg javaJsonArg) *JavaParameter {
-	return &JavaParameter{
-		Name:      arg.Name,
-		Type:      getOrCreateBuiltinType(arg.Type),
-		IsVarargs: false,
+// jsonTypeContext is the place in the JSON file that a type name appears in, which determines which
+// type variables are in scope.
+type jsonTypeContext struct {
+	parentType *JavaType
+	// The method the type name appears in the signature of, if any
+	method *JavaMethod
+	// Type variables of the parent type aren't in scope in static members
+	isStatic bool
+	// Older versions of the JSON file don't list the type parameters of generic methods, so we
+	// have to guess which names refer to them
+	guessMethodTypeParams bool
+	// Whether a generic type without type arguments stays raw, rather than getting the type variables in
+	// scope with the same names as its type arguments. Functional interfaces get `? super` them instead.
+	keepRaw bool
+}
+
+func (jtc jsonTypeContext) convertArgs(args []javaJsonArg) []*JavaParameter {
+	// Parameters usually take wildcards, e.g. `addAll(Collection<? extends E>)` or `containsAll(Collection<?>)`,
+	// which older versions of the JSON file leave out along with the rest of the type arguments. Guessing
+	// `Collection<E>` would reject arguments that fit, so raw types are left raw.
+	jtc.keepRaw = true
+	return util.Map(args, func(arg javaJsonArg) *JavaParameter {
+		// Varargs params look like `Object...`, and are arrays within the method
+		isVarargs := strings.HasSuffix(arg.Type, "...")
+		paramType := jtc.convertTypeName(strings.TrimSuffix(arg.Type, "..."))
+		if isVarargs {
+			paramType = NewArrayType(paramType, 1)
+		}
+
+		return &JavaParameter{
+			Name:      arg.Name,
+			Type:      paramType,
+			IsVarargs: isVarargs,
+		}
+	})
+}
+
+// convertTypeParams creates the type variables for the given type parameter declarations of a generic method.
+// Returns nil if there are none.
+func (jtc jsonTypeContext) convertTypeParams(typeParams []string) []*JavaType {
+	if len(typeParams) == 0 {
+		return nil
+	}
+
+	// Create all the type variables before converting the bounds, since the bounds can refer to them,
+	// e.g. `T extends Comparable<T>`
+	jtc.method.TypeParams = util.Map(typeParams, func(typeParam string) *JavaType {
+		name, _ := SplitTypeParam(typeParam)
+		return NewTypeVariable(name, nil)
+	})
+	for i, typeParam := range typeParams {
+		_, bounds := SplitTypeParam(typeParam)
+		jtc.method.TypeParams[i].Extends = jtc.convertBounds(bounds)
+	}
+	return jtc.method.TypeParams
+}
+
+// convertBounds converts the bounds of a type parameter. Type parameters without bounds are bounded by `Object`.
+func (jtc jsonTypeContext) convertBounds(bounds []string) []*JavaType {
+	if len(bounds) == 0 {
+		return []*JavaType{getOrCreateBuiltinType("Object", "java.lang")}
+	}
+	return util.Map(bounds, jtc.convertTypeName)
+}
+
+// convertTypeName looks up a type that's referred to by name in the JSON file, e.g. `String`, `E`, or `List<E>`.
+func (jtc jsonTypeContext) convertTypeName(name string) *JavaType {
+	if elementName, dimensions := SplitArrayDims(name); dimensions > 0 {
+		return NewArrayType(jtc.convertTypeName(elementName), dimensions)
+	}
+
+	baseName, argNames := SplitTypeArgs(name)
+	if argNames != nil {
+		base := getOrCreateBuiltinType(baseName, jtc.parentType.Package)
+		if len(argNames) != len(base.TypeParams) {
+			return base
+		}
+		return base.Parameterize(util.Map(argNames, jtc.convertTypeArg))
+	}
+
+	if typeVar := jtc.lookupTypeVariable(name); typeVar != nil {
+		return typeVar
+	}
+
+	if jtc.guessMethodTypeParams && jtc.method != nil && looksLikeTypeVariable(name) && builtinTypes.GetByNestedName(name) == nil {
+		typeVar := NewTypeVariable(name, nil)
+		typeVar.Extends = jtc.convertBounds(nil)
+		jtc.method.TypeParams = append(jtc.method.TypeParams, typeVar)
+		return typeVar
+	}
+
+	ttype := getOrCreateBuiltinType(name, jtc.parentType.Package)
+	if !ttype.IsGeneric() {
+		return ttype
+	}
+	if jtc.keepRaw && !isFunctionalLibraryType(ttype) {
+		return ttype.Parameterize(nil)
+	}
+
+	// Older versions of the JSON file leave out type arguments. When the type variables of the type
+	// have the same names as ones in scope, it's almost always because they're being passed along,
+	// e.g. `ArrayList<E>` extends `AbstractList<E>`. Otherwise, go with the raw type. Parameters of a
+	// functional interface type take the values it's called with, e.g. `forEach(Consumer<? super T>)`.
+	object := getOrCreateBuiltinType("Object", "java.lang")
+	args := make([]*JavaType, 0, len(ttype.TypeParams))
+	for _, typeParam := range ttype.TypeParams {
+		typeVar := jtc.lookupTypeVariable(typeParam.Name)
+		if typeVar == nil {
+			return ttype.Parameterize(nil)
+		}
+		if jtc.keepRaw {
+			typeVar = NewWildcardType(object, typeVar)
+		}
+		args = append(args, typeVar)
+	}
+	return ttype.Parameterize(args)
+}
+
+// isFunctionalLibraryType says whether a library type is one of the functional interfaces that library
+// methods take, e.g. `Consumer` or `Comparator`. Their members may not have been loaded yet, so this goes by
+// where they're declared.
+func isFunctionalLibraryType(ttype *JavaType) bool {
+	return ttype.Package == "java.util.function" || ttype.QualifiedName() == "java.util.Comparator"
+}
+
+// convertSupertypeName looks up a type that the parent type extends or implements. Older versions of the
+// JSON file leave out the type arguments of these too, but a generic type with as many type parameters as
+// its generic supertype almost always passes them along in order, e.g. `Collection<E>` extends
+// `Iterable<E>`, not the raw `Iterable`.
+func (jtc jsonTypeContext) convertSupertypeName(name string) *JavaType {
+	super := jtc.convertTypeName(name)
+	if super.Original == nil || len(super.GenericArgs) > 0 || len(super.Original.TypeParams) != len(jtc.parentType.TypeParams) {
+		return super
+	}
+	return super.Original.Parameterize(jtc.parentType.TypeParams)
+}
+
+// convertTypeArg converts a type argument, which might be a wildcard like `? extends E`.
+func (jtc jsonTypeContext) convertTypeArg(name string) *JavaType {
+	isWildcard, boundKind, bound := SplitWildcard(name)
+	if !isWildcard {
+		return jtc.convertTypeName(name)
+	}
+
+	object := getOrCreateBuiltinType("Object", "java.lang")
+	switch boundKind {
+	case "extends":
+		return NewWildcardType(jtc.convertTypeName(bound), nil)
+	case "super":
+		return NewWildcardType(object, jtc.convertTypeName(bound))
+	default:
+		return NewWildcardType(object, nil)
+	}
+}
+
+// lookupTypeVariable finds the type variable with the given name that's in scope, or nil if there isn't one.
+func (jtc jsonTypeContext) lookupTypeVariable(name string) *JavaType {
+	if jtc.method != nil {
+		for _, typeParam := range jtc.method.TypeParams {
+			if typeParam.Name == name {
+				return typeParam
+			}
+		}
+	}
+
+	if jtc.isStatic {
+		return nil
+	}
+	for ttype := jtc.parentType; ttype != nil; ttype = ttype.EnclosingType {
+		for _, typeParam := range ttype.TypeParams {
+			if typeParam.Name == name {
+				return typeParam
+			}
+		}
+	}
+	return nil
+}
+
+// looksLikeTypeVariable says whether a name follows the naming convention for type variables, e.g. `T` or `T2`
+func looksLikeTypeVariable(name string) bool {
+	if len(name) == 0 || len(name) > 2 || name[0] < 'A' || name[0] > 'Z' {
+		return false
+	}
+	return len(name) == 1 || (name[1] >= '0' && name[1] <= '9') || (name[1] >= 'A' && name[1] <= 'Z')
+}
+
+// stripTypeParams removes the type parameters from a type name, e.g. `Map<K,V>` -> `Map`
+func stripTypeParams(name string) string {
+	if idx := strings.Index(name, "<"); idx != -1 {
+		return name[:idx]
 	}
+	return name
+}
+
+// splitJsonTypeList fixes up lists of types from older versions of the JSON file, which were naively
+// split on commas, so a type with several type arguments like `Map<K,V>` got split into `Map<K` and `V>`.
+func splitJsonTypeList(names []string) []string {
+	return SplitTopLevel(strings.Join(names, ","), ',')
 }
 
 // Function for getting/creating builtin types that *should* exist but for some reason we haven't parsed them.
 // Creates a basic placeholder type for them.
-func getOrCreateBuiltinType(name string) *JavaType {
-	jtype := builtinTypes.Get(name)
+//
+// The JSON refers to types by their name without a package, so types in the same package as the
+// type referring to them are preferred.
+func getOrCreateBuiltinType(name string, fromPackage string) *JavaType {
+	jtype := builtinTypes.Get(fromPackage + "." + name)
+	if jtype == nil {
+		jtype = builtinTypes.GetByNestedName(name)
+	}
 	if jtype == nil {
 		//fmt.Println("Creating built-in type: ", name)
 		jtype = NewJavaType(name, "", VisibilityPublic, JavaTypeClass, nil)
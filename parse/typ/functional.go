@@ -0,0 +1,102 @@
+package typ
+
+import "java-mini-ls-go/util"
+
+// FunctionalMethod returns the single abstract method of a functional interface, e.g. `apply` for
+// `Function<T, R>`, with the type arguments filled in if it's a parameterized type. Abstract methods that
+// are also public methods of Object, like `equals` in `Comparator`, don't count (JLS 9.8). Returns nil if
+// the type isn't a functional interface.
+func (jt *JavaType) FunctionalMethod() *JavaMethod {
+	if jt == nil || jt.Type != JavaTypeInterface {
+		return nil
+	}
+
+	var functional *JavaMethod
+	for _, name := range jt.GetOriginal().allMethodNames(util.NewSet[*JavaType]()) {
+		for _, method := range jt.LookupMethods(name) {
+			if !method.IsAbstract || isObjectMethod(method) {
+				continue
+			}
+			if functional != nil {
+				return nil
+			}
+			functional = method
+		}
+	}
+	return functional
+}
+
+// allMethodNames returns the names of the methods this type declares or inherits through its supertypes,
+// without duplicates.
+func (jt *JavaType) allMethodNames(visited *util.Set[*JavaType]) []string {
+	if visited.Contains(jt) {
+		return nil
+	}
+	visited.Add(jt)
+
+	names := []string{}
+	seen := util.NewSet[string]()
+	add := func(name string) {
+		if !seen.Contains(name) {
+			seen.Add(name)
+			names = append(names, name)
+		}
+	}
+
+	for _, method := range jt.Methods {
+		add(method.Name)
+	}
+	for _, supertype := range jt.superTypes() {
+		if supertype == nil {
+			continue
+		}
+		for _, name := range supertype.GetOriginal().allMethodNames(visited) {
+			add(name)
+		}
+	}
+	return names
+}
+
+// isObjectMethod says whether the method has the signature of one of the public methods of Object that
+// interfaces may redeclare as abstract.
+func isObjectMethod(method *JavaMethod) bool {
+	params := method.GetType().CallableParams()
+	switch method.Name {
+	case "equals":
+		return len(params) == 1 && params[0] != nil && params[0].QualifiedName() == "java.lang.Object"
+	case "hashCode", "toString":
+		return len(params) == 0
+	}
+	return false
+}
+
+// NonWildcardParameterization returns the type a lambda expression targeting this type gets, which
+// replaces each wildcard type argument with its bound, e.g. `Function<String, Integer>` for
+// `Function<? super String, ? extends Integer>` (JLS 9.9). Types without wildcard type arguments are
+// returned as they are.
+func (jt *JavaType) NonWildcardParameterization() *JavaType {
+	if jt == nil || jt.Original == nil || len(jt.GenericArgs) == 0 {
+		return jt
+	}
+
+	hasWildcard := false
+	args := make([]*JavaType, len(jt.GenericArgs))
+	for i, arg := range jt.GenericArgs {
+		args[i] = arg
+		if arg == nil || arg.Type != JavaTypeWildcard {
+			continue
+		}
+		hasWildcard = true
+		args[i] = wildcardBound(arg)
+		if args[i] == nil && i < len(jt.Original.TypeParams) {
+			args[i] = jt.Original.TypeParams[i].Erasure()
+		}
+		if args[i] == nil {
+			args[i] = builtinType("java.lang.Object")
+		}
+	}
+	if !hasWildcard {
+		return jt
+	}
+	return jt.Original.Parameterize(args)
+}
@@ -0,0 +1,421 @@
+package typ
+
+import (
+	"strings"
+
+	"java-mini-ls-go/util"
+)
+
+// TypeBindings maps type variables to the types they stand for, e.g. `E` -> `String` for `List<String>`.
+// The type variables of raw types are bound to nil, which erases whatever type mentions them (JLS 4.8).
+type TypeBindings map[*JavaType]*JavaType
+
+// GetOriginal returns the generic type this one is a parameterization of, or the type itself if it isn't one.
+func (jt *JavaType) GetOriginal() *JavaType {
+	if jt.Original != nil {
+		return jt.Original
+	}
+	return jt
+}
+
+// IsGeneric says whether this type declares any type parameters.
+func (jt *JavaType) IsGeneric() bool {
+	return len(jt.TypeParams) > 0
+}
+
+// IsRaw says whether this type is a generic type used without type arguments, e.g. `List` rather than
+// `List<String>`.
+func (jt *JavaType) IsRaw() bool {
+	return jt.Original != nil && len(jt.GenericArgs) == 0 && jt.Original.IsGeneric()
+}
+
+// Parameterize returns this generic type with the given type arguments, e.g. `List<String>` for `List<E>`
+// with `String`. Passing no type arguments gives the raw type, e.g. `List`, whose members use the
+// erasures of the type variables.
+func (jt *JavaType) Parameterize(args []*JavaType) *JavaType {
+	original := jt.GetOriginal()
+
+	return &JavaType{
+		Name:          original.Name,
+		Package:       original.Package,
+		Module:        original.Module,
+		EnclosingType: original.EnclosingType,
+		LocalIndex:    original.LocalIndex,
+		Extends:       nil,
+		Implements:    nil,
+		Constructors:  nil,
+		Fields:        nil,
+		Methods:       nil,
+		TypeParams:    nil,
+		GenericArgs:   args,
+		Original:      original,
+		LowerBound:    nil,
+		ElementType:   nil,
+		Dimensions:    0,
+		Definition:    original.Definition,
+		Usages:        nil,
+		Visibility:    original.Visibility,
+		Type:          original.Type,
+		IsVarargs:     false,
+		IsFinal:       original.IsFinal,
+	}
+}
+
+// IsSameType says whether two types are the same type, e.g. two separately created `List<String>`s.
+func (jt *JavaType) IsSameType(other *JavaType) bool {
+	if jt == other {
+		return true
+	}
+	if jt == nil || other == nil {
+		return false
+	}
+
+	if jt.Type == JavaTypeWildcard && other.Type == JavaTypeWildcard {
+		return allSameTypes(jt.Extends, other.Extends) && jt.LowerBound.IsSameType(other.LowerBound)
+	}
+	if jt.Type == JavaTypeArray || other.Type == JavaTypeArray {
+		return jt.Type == other.Type && jt.Dimensions == other.Dimensions && jt.ElementType.IsSameType(other.ElementType)
+	}
+
+	return jt.GetOriginal() == other.GetOriginal() && allSameTypes(jt.GenericArgs, other.GenericArgs)
+}
+
+func allSameTypes(a []*JavaType, b []*JavaType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].IsSameType(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Erasure returns the type with its type arguments removed, e.g. `List` for `List<String>`. Type
+// variables and wildcards are erased to the erasure of their (first) upper bound.
+func (jt *JavaType) Erasure() *JavaType {
+	if jt.Type == JavaTypeArray {
+		return jt.withElementType(jt.ElementType.Erasure())
+	}
+	if jt.Type == JavaTypeTypeVariable || jt.Type == JavaTypeWildcard {
+		if len(jt.Extends) > 0 && jt.Extends[0] != nil {
+			return jt.Extends[0].Erasure()
+		}
+		return jt
+	}
+	return jt.GetOriginal()
+}
+
+// typeBindings returns what each of the type variables of the generic type stands for in this
+// parameterization of it. For raw types, that's nil for each type variable, so that the members of e.g. the raw
+// `Stream` take the raw `Predicate` rather than `Predicate<Object>`.
+func (jt *JavaType) typeBindings() TypeBindings {
+	if jt.Original == nil {
+		return nil
+	}
+
+	bindings := TypeBindings{}
+	for i, param := range jt.Original.TypeParams {
+		if i < len(jt.GenericArgs) {
+			bindings[param] = jt.GenericArgs[i]
+		} else {
+			bindings[param] = nil
+		}
+	}
+	return bindings
+}
+
+// Substitute replaces the type variables in this type with the types they're bound to, e.g. `List<E>`
+// becomes `List<String>` if `E` is bound to `String`.
+func (jt *JavaType) Substitute(bindings TypeBindings) *JavaType {
+	if jt == nil || len(bindings) == 0 {
+		return jt
+	}
+
+	switch jt.Type {
+	case JavaTypeTypeVariable:
+		if bound, ok := bindings[jt]; ok {
+			if bound == nil {
+				return jt.Erasure()
+			}
+			return bound
+		}
+		return jt
+	case JavaTypeWildcard:
+		var upperBound *JavaType
+		if len(jt.Extends) > 0 {
+			upperBound = jt.Extends[0].Substitute(bindings)
+		}
+		return NewWildcardType(upperBound, jt.LowerBound.Substitute(bindings))
+	case JavaTypeArray:
+		return jt.withElementType(jt.ElementType.Substitute(bindings))
+	}
+
+	if jt.Original != nil && len(jt.GenericArgs) > 0 {
+		if jt.Mentions(bindings.erased()) {
+			return jt.Original.Parameterize(nil)
+		}
+		return jt.Original.Parameterize(util.Map(jt.GenericArgs, func(arg *JavaType) *JavaType {
+			return arg.Substitute(bindings)
+		}))
+	}
+
+	return jt
+}
+
+// erased returns the type variables that are bound to nil, i.e. those of a raw type.
+func (bindings TypeBindings) erased() []*JavaType {
+	erased := []*JavaType{}
+	for typeVar, bound := range bindings {
+		if bound == nil {
+			erased = append(erased, typeVar)
+		}
+	}
+	return erased
+}
+
+// Mentions says whether any of the given type variables appear in this type, e.g. `T` does in
+// `List<? extends T>`.
+func (jt *JavaType) Mentions(typeVars []*JavaType) bool {
+	if jt == nil {
+		return false
+	}
+
+	switch jt.Type {
+	case JavaTypeTypeVariable:
+		for _, typeVar := range typeVars {
+			if jt == typeVar {
+				return true
+			}
+		}
+		return false
+	case JavaTypeWildcard:
+		return (len(jt.Extends) > 0 && jt.Extends[0].Mentions(typeVars)) || jt.LowerBound.Mentions(typeVars)
+	case JavaTypeArray:
+		return jt.ElementType.Mentions(typeVars)
+	}
+
+	for _, arg := range jt.GenericArgs {
+		if arg.Mentions(typeVars) {
+			return true
+		}
+	}
+	return false
+}
+
+// superTypes returns the types this type extends or implements, with type arguments filled in for
+// parameterized types. Interfaces that don't extend anything still have Object as a supertype (JLS 4.10.2).
+func (jt *JavaType) superTypes() []*JavaType {
+	if jt.Original == nil {
+		supers := util.CombineSlices(jt.Extends, jt.Implements)
+		if jt.Type == JavaTypeInterface && len(jt.Extends) == 0 {
+			if object := builtinType("java.lang.Object"); object != nil {
+				supers = append(supers, object)
+			}
+		}
+		return supers
+	}
+
+	bindings := jt.typeBindings()
+	return util.Map(jt.Original.superTypes(), func(super *JavaType) *JavaType {
+		return super.Substitute(bindings)
+	})
+}
+
+// typeArgsContained says whether the type arguments of `from` fit into those of `to`, which are
+// parameterizations of the same generic type, e.g. `List<Integer>` fits into `List<? extends Number>`
+// but not `List<Number>`. Raw types fit either way, like unchecked conversions in Java.
+func typeArgsContained(from *JavaType, to *JavaType) bool {
+	if len(from.GenericArgs) == 0 || len(to.GenericArgs) == 0 {
+		return true
+	}
+	if len(from.GenericArgs) != len(to.GenericArgs) {
+		return false
+	}
+
+	for i, toArg := range to.GenericArgs {
+		fromArg := from.GenericArgs[i]
+
+		if toArg.Type != JavaTypeWildcard {
+			if !fromArg.IsSameType(toArg) {
+				return false
+			}
+			continue
+		}
+
+		for _, upperBound := range toArg.Extends {
+			if upperBound != nil && !fromArg.CoercesTo(upperBound) {
+				return false
+			}
+		}
+		if toArg.LowerBound != nil {
+			if fromArg.Type == JavaTypeWildcard && fromArg.LowerBound == nil {
+				return false
+			}
+			lowerBound := fromArg
+			if fromArg.Type == JavaTypeWildcard {
+				lowerBound = fromArg.LowerBound
+			}
+			if !toArg.LowerBound.CoercesTo(lowerBound) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (jt *JavaType) wildcardName(nameFunc func(*JavaType) string) string {
+	if jt.LowerBound != nil {
+		return "? super " + nameFunc(jt.LowerBound)
+	}
+	if len(jt.Extends) > 0 && jt.Extends[0] != nil && jt.Extends[0].QualifiedName() != "java.lang.Object" {
+		return "? extends " + nameFunc(jt.Extends[0])
+	}
+	return "?"
+}
+
+// substituteMember returns a copy of the given field or method with the type variables in its signature
+// replaced according to the bindings. Usages of the copy are recorded on the original.
+func substituteMember(member JavaSymbol, bindings TypeBindings) JavaSymbol {
+	if len(bindings) == 0 {
+		return member
+	}
+
+	switch m := member.(type) {
+	case *JavaField:
+		return m.substitute(bindings)
+	case *JavaMethod:
+		return m.substitute(bindings)
+	}
+	return member
+}
+
+func (jf *JavaField) substitute(bindings TypeBindings) *JavaField {
+	substituted := *jf
+	substituted.Type = jf.Type.Substitute(bindings)
+	substituted.Original = jf.GetOriginal()
+	return &substituted
+}
+
+// GetOriginal returns the field as declared, undoing any substitution of type arguments.
+func (jf *JavaField) GetOriginal() *JavaField {
+	if jf.Original != nil {
+		return jf.Original
+	}
+	return jf
+}
+
+func (jc *JavaConstructor) substitute(bindings TypeBindings) *JavaConstructor {
+	substituted := *jc
+	substituted.Params = util.Map(jc.Params, func(param *JavaParameter) *JavaParameter {
+		return &JavaParameter{
+			Name:      param.Name,
+			Type:      param.Type.Substitute(bindings),
+			IsVarargs: param.IsVarargs,
+		}
+	})
+	substituted.Original = jc.GetOriginal()
+	return &substituted
+}
+
+// GetOriginal returns the constructor as declared, undoing any substitution of type arguments.
+func (jc *JavaConstructor) GetOriginal() *JavaConstructor {
+	if jc.Original != nil {
+		return jc.Original
+	}
+	return jc
+}
+
+func (jm *JavaMethod) substitute(bindings TypeBindings) *JavaMethod {
+	substituted := *jm
+	substituted.ReturnType = jm.ReturnType.Substitute(bindings)
+	substituted.Params = util.Map(jm.Params, func(param *JavaParameter) *JavaParameter {
+		return &JavaParameter{
+			Name:      param.Name,
+			Type:      param.Type.Substitute(bindings),
+			IsVarargs: param.IsVarargs,
+		}
+	})
+	substituted.Original = jm.GetOriginal()
+	return &substituted
+}
+
+// GetOriginal returns the method as declared, undoing any substitution of type arguments.
+func (jm *JavaMethod) GetOriginal() *JavaMethod {
+	if jm.Original != nil {
+		return jm.Original
+	}
+	return jm
+}
+
+// SplitTypeArgs splits a type name like `Map<String,List<Integer>>` into the name of the generic
+// type (`Map`) and the names of its type arguments (`String` and `List<Integer>`). The type arguments
+// are nil if there aren't any, and empty for the diamond `<>`.
+func SplitTypeArgs(name string) (string, []string) {
+	name = strings.TrimSpace(name)
+	openIdx := strings.Index(name, "<")
+	if openIdx == -1 || !strings.HasSuffix(name, ">") {
+		return name, nil
+	}
+
+	return strings.TrimSpace(name[:openIdx]), SplitTopLevel(name[openIdx+1:len(name)-1], ',')
+}
+
+// SplitTopLevel splits a list of type names on the given separator, ignoring separators nested inside
+// of type arguments, e.g. `Map<K,V>,List<E>` is split into `Map<K,V>` and `List<E>`. Each part is trimmed
+// of whitespace, and empty parts are left out.
+func SplitTopLevel(text string, sep rune) []string {
+	ret := []string{}
+	depth := 0
+	start := 0
+	for i, ch := range text {
+		switch ch {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case sep:
+			if depth == 0 {
+				if part := strings.TrimSpace(text[start:i]); part != "" {
+					ret = append(ret, part)
+				}
+				start = i + 1
+			}
+		}
+	}
+	if part := strings.TrimSpace(text[start:]); part != "" {
+		ret = append(ret, part)
+	}
+	return ret
+}
+
+// SplitWildcard checks whether a type argument is a wildcard, and if so, returns its bound, e.g.
+// `? extends Number` gives `extends` and `Number`. Also handles the bound being written without
+// spaces, like the text of a parse tree: `?extendsNumber`.
+func SplitWildcard(typeArg string) (isWildcard bool, boundKind string, bound string) {
+	typeArg = strings.TrimSpace(typeArg)
+	if !strings.HasPrefix(typeArg, "?") {
+		return false, "", ""
+	}
+
+	rest := strings.TrimSpace(typeArg[1:])
+	for _, kind := range []string{"extends", "super"} {
+		if strings.HasPrefix(rest, kind) {
+			return true, kind, strings.TrimSpace(rest[len(kind):])
+		}
+	}
+	return true, "", ""
+}
+
+// SplitTypeParam splits the declaration of a type parameter like `T extends Number & Comparable<T>`
+// into its name (`T`) and the names of its bounds (`Number` and `Comparable<T>`).
+func SplitTypeParam(typeParam string) (string, []string) {
+	typeParam = strings.TrimSpace(typeParam)
+	name, bounds, found := strings.Cut(typeParam, " extends ")
+	if !found {
+		return typeParam, nil
+	}
+	return strings.TrimSpace(name), SplitTopLevel(bounds, '&')
+}
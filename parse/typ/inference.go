@@ -0,0 +1,238 @@
+package typ
+
+// InferTypeArgs infers what the type variables of a generic method stand for at a call site, e.g. `T` is
+// `String` in `Collections.singletonList("hi")`. It also works for the type variables of a generic class
+// created with the diamond `<>`, treating the class like a generic method returning `ArrayList<E>`.
+//
+// This follows a pragmatic subset of JLS 18: the arguments give lower bounds for the type variables,
+// and the target type (e.g. the declared type of the variable the result is assigned to, or nil if
+// there isn't one) gives exact types where it has type arguments, or upper bounds otherwise. Type
+// variables that can't be inferred default to their erasure. Primitive arguments should be boxed
+// beforehand.
+//
+// The returned bindings can then be used to substitute the method's parameter and return types, which
+// should still be checked against the arguments and target type, since the inference doesn't report errors.
+func InferTypeArgs(typeParams []*JavaType, formals []*JavaType, actuals []*JavaType, returnType *JavaType, target *JavaType) TypeBindings {
+	inf := &inference{
+		vars:  typeParams,
+		equal: TypeBindings{},
+		lower: TypeBindings{},
+		upper: TypeBindings{},
+	}
+
+	if returnType != nil && target != nil {
+		inf.reduce(returnType, target, constraintUpper)
+	}
+	for i, formal := range formals {
+		if i < len(actuals) && actuals[i] != nil {
+			inf.reduce(formal, actuals[i], constraintLower)
+		}
+	}
+
+	bindings := TypeBindings{}
+	for _, typeParam := range typeParams {
+		if equal, ok := inf.equal[typeParam]; ok {
+			bindings[typeParam] = equal
+		} else if lower, ok := inf.lower[typeParam]; ok {
+			bindings[typeParam] = lower
+		} else if upper, ok := inf.upper[typeParam]; ok {
+			bindings[typeParam] = upper
+		} else {
+			bindings[typeParam] = typeParam.Erasure()
+		}
+	}
+	return bindings
+}
+
+type constraintKind int
+
+const (
+	// The type of the formal must be exactly the other type, e.g. `T` in `List<T>` and `String` in `List<String>`
+	constraintEqual constraintKind = iota
+	// The other type must fit into the formal, e.g. `T` and the type of an argument passed in for it
+	constraintLower
+	// The formal must fit into the other type, e.g. a return type `T` and the type of the variable it's assigned to
+	constraintUpper
+)
+
+type inference struct {
+	vars []*JavaType
+
+	equal TypeBindings
+	lower TypeBindings
+	upper TypeBindings
+}
+
+func (inf *inference) isVar(ttype *JavaType) bool {
+	for _, v := range inf.vars {
+		if v == ttype {
+			return true
+		}
+	}
+	return false
+}
+
+// reduce records what the type variables in `formal` must be for it to relate to `other` in the given way.
+func (inf *inference) reduce(formal *JavaType, other *JavaType, kind constraintKind) {
+	if formal == nil || other == nil || !isInferable(other) {
+		return
+	}
+
+	if inf.isVar(formal) {
+		inf.addBound(formal, other, kind)
+		return
+	}
+
+	// e.g. `T[]` with `String[]` means `String` must fit into `T`
+	if formal.Type == JavaTypeArray && other.Type == JavaTypeArray {
+		inf.reduce(formal.ComponentType(), other.ComponentType(), kind)
+		return
+	}
+
+	if formal.Original == nil || len(formal.GenericArgs) == 0 {
+		return
+	}
+
+	switch kind {
+	case constraintEqual, constraintLower:
+		// The argument is a subtype of the formal, so look for the formal's generic type among its supertypes
+		super := findSuperType(other, formal.GetOriginal())
+		if super != nil {
+			inf.reduceArgs(formal.GenericArgs, super.GenericArgs)
+		}
+	case constraintUpper:
+		// The formal is a subtype of the target type, so look for the target's generic type among its supertypes
+		super := findSuperType(formal, other.GetOriginal())
+		if super != nil {
+			inf.reduceArgs(super.GenericArgs, other.GenericArgs)
+		}
+	}
+}
+
+// reduceArgs relates the type arguments of two parameterizations of the same generic type.
+func (inf *inference) reduceArgs(formalArgs []*JavaType, otherArgs []*JavaType) {
+	if len(formalArgs) != len(otherArgs) {
+		return
+	}
+
+	for i, formalArg := range formalArgs {
+		otherArg := otherArgs[i]
+
+		if formalArg.Type == JavaTypeWildcard {
+			// e.g. `Collection<? extends T>` with `List<String>` means `String` must fit into `T`
+			otherBound := otherArg
+			if otherArg.Type == JavaTypeWildcard {
+				otherBound = wildcardBound(otherArg)
+			}
+			if formalArg.LowerBound != nil {
+				inf.reduce(formalArg.LowerBound, otherBound, constraintUpper)
+			} else if len(formalArg.Extends) > 0 {
+				inf.reduce(formalArg.Extends[0], otherBound, constraintLower)
+			}
+			continue
+		}
+
+		if otherArg.Type == JavaTypeWildcard {
+			// e.g. `List<T>` with `List<? extends Number>` means `T` must fit into `Number`
+			if otherArg.LowerBound != nil {
+				inf.reduce(formalArg, otherArg.LowerBound, constraintLower)
+			} else if len(otherArg.Extends) > 0 {
+				inf.reduce(formalArg, otherArg.Extends[0], constraintUpper)
+			}
+			continue
+		}
+
+		inf.reduce(formalArg, otherArg, constraintEqual)
+	}
+}
+
+func (inf *inference) addBound(typeVar *JavaType, bound *JavaType, kind constraintKind) {
+	switch kind {
+	case constraintEqual:
+		if _, ok := inf.equal[typeVar]; !ok {
+			inf.equal[typeVar] = bound
+		}
+	case constraintLower:
+		existing, ok := inf.lower[typeVar]
+		if !ok {
+			inf.lower[typeVar] = bound
+			return
+		}
+		// Several arguments for the same type variable: use the closest type they all fit into
+		if lub := leastUpperBound(existing, bound); lub != nil {
+			inf.lower[typeVar] = lub
+		} else {
+			inf.lower[typeVar] = typeVar.Erasure()
+		}
+	case constraintUpper:
+		if _, ok := inf.upper[typeVar]; !ok {
+			inf.upper[typeVar] = bound
+		}
+	}
+}
+
+// isInferable says whether a type says anything about the type variables it's related to. Special
+// types, like the type of `null` or of a method, don't.
+func isInferable(ttype *JavaType) bool {
+	switch ttype.Type {
+	case JavaTypeLSPAny, JavaTypeLSPClass, JavaTypeLSPMethod, JavaTypeLSPConstructor, JavaTypePrimitive:
+		return false
+	}
+	return true
+}
+
+// findSuperType returns the parameterization of the given generic type that `ttype` is, or extends.
+// Returns nil if it doesn't extend it at all.
+func findSuperType(ttype *JavaType, generic *JavaType) *JavaType {
+	for _, super := range append([]*JavaType{ttype}, ttype.AllSuperClasses()...) {
+		if super != nil && super.GetOriginal() == generic {
+			return super
+		}
+	}
+	return nil
+}
+
+func wildcardBound(wildcard *JavaType) *JavaType {
+	if wildcard.LowerBound != nil {
+		return wildcard.LowerBound
+	}
+	if len(wildcard.Extends) > 0 {
+		return wildcard.Extends[0]
+	}
+	return nil
+}
+
+// leastUpperBound returns the closest type that both types fit into, or nil if the only one is Object.
+func leastUpperBound(a *JavaType, b *JavaType) *JavaType {
+	if b.CoercesTo(a) {
+		return a
+	}
+	if a.CoercesTo(b) {
+		return b
+	}
+	for _, super := range a.AllSuperClasses() {
+		if super != nil && b.CoercesTo(super) {
+			return super
+		}
+	}
+	return nil
+}
+
+// LeastUpperBound returns the closest type that all the given types fit into, e.g. `Number` for `Integer`
+// and `Double`, or Object if that's the only one. Primitive types should be boxed first.
+func LeastUpperBound(types []*JavaType) *JavaType {
+	var lub *JavaType
+	for _, ttype := range types {
+		if ttype == nil || ttype.Type == JavaTypeLSPAny {
+			continue
+		}
+		if lub == nil {
+			lub = ttype
+			continue
+		}
+		if lub = leastUpperBound(lub, ttype); lub == nil {
+			return builtinType("java.lang.Object")
+		}
+	}
+	return lub
+}
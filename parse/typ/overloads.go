@@ -0,0 +1,253 @@
+package typ
+
+// LookupMethods returns every method by this name that can be called on this type: its own, and the ones
+// it inherits that it doesn't override, from its superclass and then its interfaces. Overloads are listed in
+// the order they're declared in, starting with this type's own methods.
+func (jt *JavaType) LookupMethods(name string) []*JavaMethod {
+	if jt.Type == JavaTypeLSPClass {
+		referringType := jt.GenericArgs[0]
+		return referringType.LookupMethods(name)
+	}
+	if jt.Original != nil {
+		// Look them up on the generic type, then fill in the type arguments
+		bindings := jt.typeBindings()
+		methods := jt.Original.LookupMethods(name)
+		if len(bindings) == 0 {
+			return methods
+		}
+		for i, method := range methods {
+			methods[i] = method.substitute(bindings)
+		}
+		return methods
+	}
+
+	methods := []*JavaMethod{}
+	for _, method := range jt.Methods {
+		if method.Name == name {
+			methods = append(methods, method)
+		}
+	}
+
+	for _, supertype := range jt.superTypes() {
+		if supertype == nil {
+			continue
+		}
+		for _, inherited := range supertype.LookupMethods(name) {
+			if isInherited(inherited, supertype, jt) && !overridesAny(inherited, methods) {
+				methods = append(methods, inherited)
+			}
+		}
+	}
+
+	return methods
+}
+
+// LookupConstructors returns the constructors of this type, with the type arguments filled in if it's a
+// parameterized type. Unlike methods, constructors aren't inherited.
+func (jt *JavaType) LookupConstructors() []*JavaConstructor {
+	if jt.Original == nil {
+		return jt.Constructors
+	}
+
+	bindings := jt.typeBindings()
+	constructors := make([]*JavaConstructor, len(jt.Original.Constructors))
+	for i, constructor := range jt.Original.Constructors {
+		constructors[i] = constructor.substitute(bindings)
+		constructors[i].ParentType = jt
+	}
+	return constructors
+}
+
+// isInherited says whether a member of the given supertype is also a member of the subtype. Static methods of
+// interfaces aren't, and have to be called through the name of the interface. Interfaces only get the public
+// methods of Object, e.g. `equals` but not `clone` (JLS 9.2).
+func isInherited(member JavaSymbol, supertype *JavaType, subtype *JavaType) bool {
+	if method, ok := member.(*JavaMethod); ok && method.IsStatic && supertype.Type == JavaTypeInterface {
+		return false
+	}
+	if subtype.Type == JavaTypeInterface && supertype.Type != JavaTypeInterface {
+		return member.GetVisibility() == VisibilityPublic
+	}
+	return true
+}
+
+// overridesAny says whether any of the methods has the same parameter types as the given one, so that
+// it overrides (or hides) it.
+func overridesAny(method *JavaMethod, methods []*JavaMethod) bool {
+	for _, other := range methods {
+		if sameParamTypes(method.GetType().CallableParams(), other.GetType().CallableParams()) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameParamTypes(a []*JavaType, b []*JavaType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] == nil || b[i] == nil || !a[i].Erasure().IsSameType(b[i].Erasure()) {
+			return false
+		}
+	}
+	return true
+}
+
+// CallableParams returns the types of the parameters of the special LSP method or constructor types.
+func (jt *JavaType) CallableParams() []*JavaType {
+	switch jt.Type {
+	case JavaTypeLSPMethod:
+		return jt.GenericArgs[2:]
+	case JavaTypeLSPConstructor:
+		return jt.GenericArgs[1:]
+	}
+	return nil
+}
+
+// CallableReturnType returns the type that calling the special LSP method or constructor types results
+// in: the return type of a method, or the type a constructor creates.
+func (jt *JavaType) CallableReturnType() *JavaType {
+	switch jt.Type {
+	case JavaTypeLSPMethod:
+		return jt.GenericArgs[1]
+	case JavaTypeLSPConstructor:
+		return jt.GenericArgs[0]
+	}
+	return nil
+}
+
+// ResolveOverload picks which of several overloads (the special LSP method or constructor types) a call with
+// the given argument types refers to. It follows the phases of JLS 15.12.2: first only the overloads that
+// are applicable without boxing or varargs are considered, then the ones that are with boxing, and only
+// then varargs ones. Among the applicable overloads of the first phase that finds any, the most specific
+// one is chosen, e.g. `println(int)` over `println(long)` for `println(1)`.
+//
+// Returns the indexes of the chosen overloads: exactly one if the call resolves, several if it's ambiguous
+// which one is most specific, or none if no overload is applicable. Argument types that aren't known
+// (nil) fit into anything.
+func ResolveOverload(candidates []*JavaType, argTypes []*JavaType) []int {
+	for _, phase := range []applicabilityPhase{phaseStrict, phaseLoose, phaseVarargs} {
+		applicable := []int{}
+		formals := map[int][]*JavaType{}
+		for i, candidate := range candidates {
+			if candidateFormals, ok := applicableFormals(candidate, argTypes, phase); ok {
+				applicable = append(applicable, i)
+				formals[i] = candidateFormals
+			}
+		}
+		if len(applicable) > 0 {
+			return mostSpecific(applicable, formals)
+		}
+	}
+	return []int{}
+}
+
+type applicabilityPhase int
+
+const (
+	// Applicable by strict invocation: subtyping and widening primitive conversions only
+	phaseStrict applicabilityPhase = iota
+	// Applicable by loose invocation: also boxing and unboxing
+	phaseLoose
+	// Applicable by variable arity invocation: loose, with any number of args for a varargs parameter
+	phaseVarargs
+)
+
+// applicableFormals returns the parameter types a candidate would be called with (with the varargs parameter
+// expanded, and the type variables of a generic method inferred), if the args fit into them in the given phase.
+func applicableFormals(candidate *JavaType, argTypes []*JavaType, phase applicabilityPhase) ([]*JavaType, bool) {
+	formals := candidate.CallableParams()
+
+	if phase == phaseVarargs {
+		if !candidate.IsVarargs || len(argTypes) < len(formals)-1 {
+			return nil, false
+		}
+		componentType := formals[len(formals)-1].ComponentType()
+		expanded := append([]*JavaType{}, formals[:len(formals)-1]...)
+		for len(expanded) < len(argTypes) {
+			expanded = append(expanded, componentType)
+		}
+		formals = expanded
+	} else if len(argTypes) != len(formals) {
+		return nil, false
+	}
+
+	if len(candidate.TypeParams) > 0 {
+		boxedArgs := make([]*JavaType, len(argTypes))
+		for i, argType := range argTypes {
+			if argType != nil {
+				boxedArgs[i] = argType.Boxed()
+			}
+		}
+		bindings := InferTypeArgs(candidate.TypeParams, formals, boxedArgs, nil, nil)
+		substituted := make([]*JavaType, len(formals))
+		for i, formal := range formals {
+			substituted[i] = formal.Substitute(bindings)
+		}
+		formals = substituted
+	}
+
+	for i, argType := range argTypes {
+		if argType == nil || formals[i] == nil {
+			continue
+		}
+		if phase == phaseStrict && !argType.strictlyCoercesTo(formals[i]) {
+			return nil, false
+		}
+		if !argType.CoercesTo(formals[i]) {
+			return nil, false
+		}
+	}
+	return formals, true
+}
+
+// strictlyCoercesTo says whether a type can be converted to another type without boxing or unboxing.
+func (jt *JavaType) strictlyCoercesTo(other *JavaType) bool {
+	if jt.Type == JavaTypeLSPAny {
+		return true
+	}
+	if (jt.Type == JavaTypePrimitive) != (other.Type == JavaTypePrimitive) {
+		return false
+	}
+	return jt.CoercesTo(other)
+}
+
+// mostSpecific narrows down applicable overloads to the ones that no other one is more specific than
+// (JLS 15.12.2.5). One overload is more specific than another if each of its parameter types fits into
+// the other one's, e.g. `String` is more specific than `Object`, and `int` than `long`.
+func mostSpecific(applicable []int, formals map[int][]*JavaType) []int {
+	maximal := []int{}
+	for _, i := range applicable {
+		isMaximal := true
+		for _, j := range applicable {
+			if i != j && moreSpecific(formals[j], formals[i]) && !moreSpecific(formals[i], formals[j]) {
+				isMaximal = false
+				break
+			}
+		}
+		// Overloads with the same parameter types (e.g. inherited from several places) aren't ambiguous
+		if isMaximal && !sameAsAny(formals[i], maximal, formals) {
+			maximal = append(maximal, i)
+		}
+	}
+	return maximal
+}
+
+func moreSpecific(a []*JavaType, b []*JavaType) bool {
+	for i := range a {
+		if a[i] != nil && b[i] != nil && !a[i].strictlyCoercesTo(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameAsAny(params []*JavaType, others []int, formals map[int][]*JavaType) bool {
+	for _, other := range others {
+		if sameParamTypes(params, formals[other]) {
+			return true
+		}
+	}
+	return false
+}
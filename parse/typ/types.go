@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"java-mini-ls-go/parse/loc"
 	"java-mini-ls-go/util"
+	"strconv"
 	"strings"
 
 	"golang.org/x/exp/slices"
@@ -56,13 +57,18 @@ type JavaSymbol interface {
 // up-to-date usages.
 //
 // Every time we add a usage to a symbol, we call this function to "garbage collect" any
-// old usages from a previous version of the given file.
+// old usages from a previous version of the given file. If the usage that was just added (the last
+// one) was already there, e.g. from re-checking the same version of the file, the older copy is
+// removed too.
 func pruneUsages(usages []loc.CodeLocation, fileURI string, currVersion int) []loc.CodeLocation {
 	toRemove := make([]int, 0)
 
-	for i, u := range usages {
+	newest := usages[len(usages)-1]
+	for i, u := range usages[:len(usages)-1] {
 		if u.FileUri == fileURI && u.Version < currVersion {
 			toRemove = append(toRemove, i)
+		} else if u.Version == newest.Version && u.Equals(newest) {
+			toRemove = append(toRemove, i)
 		}
 	}
 
@@ -115,6 +121,13 @@ const (
 	JavaTypeLSPMethod JavaTypeType = iota
 	// JavaTypeLSPAny is a special Java type used internally by the LSP to represent error states, which shouldn't create further type errors
 	JavaTypeLSPAny JavaTypeType = iota
+
+	// JavaTypeTypeVariable is a type variable declared by a generic type or method, e.g. `E` in `List<E>`
+	JavaTypeTypeVariable JavaTypeType = iota
+	// JavaTypeWildcard is a wildcard type argument, e.g. `? extends Number` in `List<? extends Number>`
+	JavaTypeWildcard JavaTypeType = iota
+	// JavaTypeArray is an array type, e.g. `int[]` or `String[][]`
+	JavaTypeArray JavaTypeType = iota
 )
 
 const (
@@ -131,6 +144,10 @@ var JavaTypeTypeStrs = map[JavaTypeType]string{
 	JavaTypeEnum:       "enum",
 	JavaTypeRecord:     "record",
 	JavaTypeAnnotation: "annotation",
+
+	JavaTypeTypeVariable: "type variable",
+	JavaTypeWildcard:     "wildcard",
+	JavaTypeArray:        "array",
 }
 
 func getStaticStr(isStatic bool) string {
@@ -141,25 +158,75 @@ func getStaticStr(isStatic bool) string {
 	}
 }
 
+// IsStaticMember says whether a symbol is a static field or method.
+func IsStaticMember(symbol JavaSymbol) bool {
+	switch member := symbol.(type) {
+	case *JavaField:
+		return member.IsStatic
+	case *JavaMethod:
+		return member.IsStatic
+	}
+	return false
+}
+
+// IsInstanceMember says whether a symbol is a field or method that isn't static.
+func IsInstanceMember(symbol JavaSymbol) bool {
+	switch member := symbol.(type) {
+	case *JavaField:
+		return !member.IsStatic
+	case *JavaMethod:
+		return !member.IsStatic
+	}
+	return false
+}
+
 type JavaType struct {
+	// Name is the simple name of the type, e.g. `Entry` for `java.util.Map.Entry`
 	Name    string
 	Package string
 	Module  string
+	// EnclosingType is the type this one is nested inside of, or nil if it's a top-level type
+	EnclosingType *JavaType
+	// LocalIndex numbers the local and anonymous classes inside of a top-level type in the order they're
+	// declared, starting at 1, like Java's binary names do (`Outer$1Local`, `Outer$2`). Since they aren't
+	// members of the type they're in, this keeps them apart from member types, and from each other.
+	// Anonymous classes don't have a name. It's 0 for other types.
+	LocalIndex int
 	// Note Extends is a slice only because interfaces can extend multiple other interfaces.
 	// For classes this will have a maximum of one element.
+	// For type variables and wildcards, these are the upper bounds.
 	Extends      []*JavaType
 	Implements   []*JavaType
 	Constructors []*JavaConstructor
 	Fields       []*JavaField
 	Methods      []*JavaMethod
 
-	// TODO add generic constraints
+	// TypeParams are the type variables declared by a generic type, e.g. `E` for `List<E>`.
+	// Nil if the type isn't generic.
+	TypeParams []*JavaType
 
 	// For a *concrete* generic type (e.g. List<String>), this contains the type arguments (String).
 	// For an *abstract* generic type (e.g. List<T>), this is nil.
-	// NOTE: These are only used for method resolution currently
+	// The special LSP types use these to encode method signatures.
 	GenericArgs []*JavaType
 
+	// Original is the generic type that this one is a parameterization of, e.g. `List<E>` for
+	// `List<String>` or for the raw type `List`. Nil if this type isn't a parameterization.
+	// Parameterized types don't have members of their own; they're looked up on the Original.
+	Original *JavaType
+
+	// LowerBound is the bound of a `? super T` wildcard
+	LowerBound *JavaType
+
+	// IsVarargs says whether the last parameter of the special LSP method and constructor types is
+	// a varargs parameter, e.g. `Object... args`. Its type is then an array type.
+	IsVarargs bool
+
+	// ElementType is the type of the innermost elements of an array type, e.g. `String` for `String[][]`,
+	// and Dimensions is how many levels of arrays there are around it, e.g. 2 for `String[][]`.
+	ElementType *JavaType
+	Dimensions  int
+
 	// Definition stores where this symbol is defined in the code.
 	// Is nil for built-in/library types.
 	Definition *loc.CodeLocation
@@ -168,23 +235,35 @@ type JavaType struct {
 
 	Visibility VisibilityType
 	Type       JavaTypeType
+	// IsFinal says whether a class is declared `final`, so that it can't have subclasses. Library types don't
+	// say whether they are, so it's false for them.
+	IsFinal bool
 }
 
 func NewJavaType(name string, ppackage string, visibility VisibilityType, ttype JavaTypeType, definition *loc.CodeLocation) *JavaType {
 	return &JavaType{
-		Name:         name,
-		Package:      ppackage,
-		Module:       "",
-		Extends:      make([]*JavaType, 0),
-		Implements:   make([]*JavaType, 0),
-		Constructors: make([]*JavaConstructor, 0),
-		Fields:       make([]*JavaField, 0),
-		Methods:      make([]*JavaMethod, 0),
-		GenericArgs:  nil,
-		Definition:   definition,
-		Usages:       make([]loc.CodeLocation, 0),
-		Visibility:   visibility,
-		Type:         ttype,
+		Name:          name,
+		Package:       ppackage,
+		Module:        "",
+		EnclosingType: nil,
+		LocalIndex:    0,
+		Extends:       make([]*JavaType, 0),
+		Implements:    make([]*JavaType, 0),
+		Constructors:  make([]*JavaConstructor, 0),
+		Fields:        make([]*JavaField, 0),
+		Methods:       make([]*JavaMethod, 0),
+		TypeParams:    nil,
+		GenericArgs:   nil,
+		Original:      nil,
+		LowerBound:    nil,
+		ElementType:   nil,
+		Dimensions:    0,
+		Definition:    definition,
+		Usages:        make([]loc.CodeLocation, 0),
+		Visibility:    visibility,
+		Type:          ttype,
+		IsVarargs:     false,
+		IsFinal:       false,
 	}
 }
 
@@ -192,6 +271,23 @@ func NewPrimitiveType(name string) *JavaType {
 	return NewJavaType(name, "", VisibilityPublic, JavaTypePrimitive, nil)
 }
 
+// NewTypeVariable creates a type variable without any bounds. Unbounded type variables should be
+// given `Object` as their bound once it's available.
+func NewTypeVariable(name string, definition *loc.CodeLocation) *JavaType {
+	return NewJavaType(name, "", VisibilityPublic, JavaTypeTypeVariable, definition)
+}
+
+// NewWildcardType creates a wildcard type argument. `? extends Number` has the upper bound `Number`,
+// `? super Integer` has the lower bound `Integer` and the upper bound `Object`.
+func NewWildcardType(upperBound *JavaType, lowerBound *JavaType) *JavaType {
+	t := NewJavaType("?", "", VisibilityPublic, JavaTypeWildcard, nil)
+	if upperBound != nil {
+		t.Extends = []*JavaType{upperBound}
+	}
+	t.LowerBound = lowerBound
+	return t
+}
+
 // Compile-time check that JavaType implements JavaSymbol interface
 var _ JavaSymbol = (*JavaType)(nil)
 
@@ -204,10 +300,29 @@ func (jt *JavaType) PackageName() string {
 }
 
 func (jt *JavaType) ShortName() string {
+	if jt.IsAnonymous() {
+		return "<anonymous " + jt.anonymousSupertype().ShortName() + ">"
+	}
+	if jt.Type == JavaTypeWildcard {
+		return jt.wildcardName((*JavaType).ShortName)
+	}
+	if jt.Type == JavaTypeArray {
+		return jt.ElementType.ShortName() + strings.Repeat("[]", jt.Dimensions)
+	}
+	if jt.Original != nil && len(jt.GenericArgs) > 0 {
+		return jt.Name + "<" + strings.Join(util.Map(jt.GenericArgs, (*JavaType).ShortName), ",") + ">"
+	}
 	return jt.Name
 }
 
 func (jt *JavaType) FullName() string {
+	if jt.Type == JavaTypeWildcard {
+		return jt.wildcardName((*JavaType).FullName)
+	}
+	if jt.Type == JavaTypeArray {
+		return jt.ElementType.FullName() + strings.Repeat("[]", jt.Dimensions)
+	}
+
 	genericsStr := ""
 	if jt.GenericArgs != nil && len(jt.GenericArgs) > 0 {
 		genericsStr = strings.Join(util.Map(jt.GenericArgs, func(t *JavaType) string {
@@ -217,15 +332,52 @@ func (jt *JavaType) FullName() string {
 		genericsStr = "<" + genericsStr + ">"
 	}
 
-	// TODO use this when we can handle package names
-	//var mainName string
-	//if jt.Package != "" {
-	//	mainName = fmt.Sprintf("%s.%s", jt.Package, jt.Name)
-	//} else {
-	//	mainName =
-	//}
+	return jt.QualifiedName() + genericsStr
+}
 
-	return jt.Name + genericsStr
+// NestedName returns the name of this type including the names of the types it's nested in,
+// but not its package, e.g. `Map.Entry`
+func (jt *JavaType) NestedName() string {
+	name := jt.Name
+	if jt.LocalIndex > 0 {
+		name = LocalTypeName(jt.LocalIndex, jt.Name)
+	}
+	if jt.EnclosingType != nil {
+		return jt.EnclosingType.NestedName() + "." + name
+	}
+	return name
+}
+
+// LocalTypeName is the name a local or anonymous class is known by inside of the type it's declared in,
+// e.g. `1Local` or `2`. See JavaType.LocalIndex.
+func LocalTypeName(localIndex int, name string) string {
+	return strconv.Itoa(localIndex) + name
+}
+
+// IsAnonymous says whether this is the type of an anonymous class body, e.g. the `{ ... }` in
+// `new Runnable() { ... }`.
+func (jt *JavaType) IsAnonymous() bool {
+	return jt.LocalIndex > 0 && jt.Name == ""
+}
+
+// anonymousSupertype returns the class an anonymous class extends, or the interface it implements. The
+// interface comes first, since an anonymous class that implements one also extends Object.
+func (jt *JavaType) anonymousSupertype() *JavaType {
+	for _, supertype := range util.CombineSlices(jt.Implements, jt.Extends) {
+		if supertype != nil {
+			return supertype
+		}
+	}
+	return NewJavaType(TypeNameLSPAny, "", VisibilityPublic, JavaTypeLSPAny, nil)
+}
+
+// QualifiedName returns the fully qualified name of this type, without any generic arguments,
+// e.g. `java.util.Map.Entry`. This is what identifies the type in a TypeMap.
+func (jt *JavaType) QualifiedName() string {
+	if jt.Package != "" {
+		return jt.Package + "." + jt.NestedName()
+	}
+	return jt.NestedName()
 }
 
 func (jt *JavaType) GetVisibility() VisibilityType {
@@ -237,10 +389,18 @@ func (jt *JavaType) GetDefinition() *loc.CodeLocation {
 }
 
 func (jt *JavaType) GetUsages() []loc.CodeLocation {
+	if jt.Original != nil {
+		return jt.Original.GetUsages()
+	}
 	return jt.Usages
 }
 
 func (jt *JavaType) AddUsage(location loc.CodeLocation) {
+	if jt.Original != nil {
+		jt.Original.AddUsage(location)
+		return
+	}
+
 	jt.Usages = append(jt.Usages, location)
 	jt.Usages = pruneUsages(jt.Usages, location.FileUri, location.Version)
 }
@@ -253,6 +413,11 @@ func (jt *JavaType) GetType() *JavaType {
 	// Maybe TODO: If this type already exists, use the existing one, otherwise use the newly created one
 }
 
+// IsOwnedBy says whether this type was declared in the given version of the given file.
+func (jt *JavaType) IsOwnedBy(fileURI string, version int) bool {
+	return jt.Definition != nil && jt.Definition.FileUri == fileURI && jt.Definition.Version == version
+}
+
 func (jt *JavaType) GetClassName() string {
 	if jt.Type == JavaTypeLSPClass {
 		referringType := jt.GenericArgs[0]
@@ -263,8 +428,21 @@ func (jt *JavaType) GetClassName() string {
 
 func (jt *JavaType) AllMembers() []JavaSymbol {
 	if jt.Type == JavaTypeLSPClass {
+		// Only static members can be accessed through the name of a type
 		referringType := jt.GenericArgs[0]
-		return referringType.AllMembers()
+		staticMembers := []JavaSymbol{}
+		for _, member := range referringType.AllMembers() {
+			if IsStaticMember(member) {
+				staticMembers = append(staticMembers, member)
+			}
+		}
+		return staticMembers
+	}
+	if jt.Original != nil {
+		bindings := jt.typeBindings()
+		return util.Map(jt.Original.AllMembers(), func(member JavaSymbol) JavaSymbol {
+			return substituteMember(member, bindings)
+		})
 	}
 
 	ret := []JavaSymbol{}
@@ -276,18 +454,45 @@ func (jt *JavaType) AllMembers() []JavaSymbol {
 		ret = append(ret, m)
 	}
 
-	// Go to parent class/interfaces and add their members too
-	for _, supertype := range jt.Extends {
-		ret = append(ret, supertype.AllMembers()...)
+	// Go to parent class/interfaces and add their members too, unless they're overridden or were already
+	// inherited some other way, e.g. the members of Object
+	for _, supertype := range jt.superTypes() {
+		if supertype == nil {
+			continue
+		}
+		for _, member := range supertype.AllMembers() {
+			if isInherited(member, supertype, jt) && !hidesMember(ret, member) {
+				ret = append(ret, member)
+			}
+		}
 	}
 
 	return ret
 }
 
+// hidesMember says whether any of the members is a field with the same name as the given member, or a method
+// with the same signature, so that the given member doesn't need to be listed alongside them.
+func hidesMember(members []JavaSymbol, member JavaSymbol) bool {
+	method, isMethod := member.(*JavaMethod)
+	for _, other := range members {
+		if other.ShortName() != member.ShortName() || other.Kind() != member.Kind() {
+			continue
+		}
+		if !isMethod || overridesAny(method, []*JavaMethod{other.(*JavaMethod)}) {
+			return true
+		}
+	}
+	return false
+}
+
 func (jt *JavaType) LookupMember(name string) JavaSymbol {
 	if jt.Type == JavaTypeLSPClass {
 		return jt.lookupStaticMember(name)
 	}
+	if jt.Original != nil {
+		// Look it up on the generic type, then fill in the type arguments
+		return substituteMember(jt.Original.LookupMember(name), jt.typeBindings())
+	}
 
 	// First check fields
 	idx := slices.IndexFunc(jt.Fields, func(field *JavaField) bool {
@@ -306,15 +511,16 @@ func (jt *JavaType) LookupMember(name string) JavaSymbol {
 		return jt.Methods[idx]
 	}
 
-	// Go to parent class/interfaces and see if any of them have the field
-	for _, supertype := range jt.Extends {
+	// Go to parent class/interfaces and see if any of them have the field, e.g. a constant or default method
+	// of an interface
+	for _, supertype := range jt.superTypes() {
 		if supertype == nil {
 			// this shouldn't happen, but whatever
 			continue
 		}
 
 		member := supertype.LookupMember(name)
-		if member != nil {
+		if member != nil && isInherited(member, supertype, jt) {
 			return member
 		}
 	}
@@ -332,14 +538,15 @@ func (jt *JavaType) String() string {
 	return fmt.Sprintf("%s %s %s", VisibilityTypeStrs[jt.Visibility], JavaTypeTypeStrs[jt.Type], jt.Name)
 }
 
+// AllSuperClasses returns every type this type extends or implements, directly or indirectly.
 func (jt *JavaType) AllSuperClasses() []*JavaType {
 	supers := []*JavaType{}
 
-	if jt.Extends == nil {
-		return supers
-	}
+	for _, e := range jt.superTypes() {
+		if e == nil {
+			continue
+		}
 
-	for _, e := range jt.Extends {
 		// Append immediate superclass
 		supers = append(supers, e)
 
@@ -350,21 +557,21 @@ func (jt *JavaType) AllSuperClasses() []*JavaType {
 	return supers
 }
 
-// Map of which other primitive/boxed types each primitive type can coerce to
+// Map of which other primitive types each primitive type can be widened to
 var primitivesCoercions = map[string][]string{
-	"byte":    {"Byte", "short", "int", "long", "float", "double"},
-	"short":   {"Short", "int", "long", "float", "double"},
-	"int":     {"Integer", "long", "float", "double"},
-	"long":    {"Long", "float", "double"},
-	"float":   {"Float", "double"},
-	"double":  {"Double"},
-	"char":    {"Character", "int", "long", "float", "double"},
-	"boolean": {"Boolean"},
-}
-
-// Map of boxed primitives back to their unboxed primitives
-var boxedPrimitives = map[string]string{
-	"Byte":    "byte",
+	"byte":    {"short", "int", "long", "float", "double"},
+	"short":   {"int", "long", "float", "double"},
+	"int":     {"long", "float", "double"},
+	"long":    {"float", "double"},
+	"float":   {"double"},
+	"double":  {},
+	"char":    {"int", "long", "float", "double"},
+	"boolean": {},
+}
+
+// Map of primitive types to the classes that wrap them
+var primitiveBoxes = map[string]string{
+	"byte":    "Byte",
 	"short":   "Short",
 	"int":     "Integer",
 	"long":    "Long",
@@ -374,6 +581,58 @@ var boxedPrimitives = map[string]string{
 	"boolean": "Boolean",
 }
 
+// Map of boxed primitives back to their unboxed primitives
+var boxedPrimitives = map[string]string{
+	"Byte":      "byte",
+	"Short":     "short",
+	"Integer":   "int",
+	"Long":      "long",
+	"Float":     "float",
+	"Double":    "double",
+	"Character": "char",
+	"Boolean":   "boolean",
+}
+
+// BoxedName returns the name of the class that wraps this primitive type, e.g. `Integer` for `int`.
+// Returns an empty string if this isn't a primitive type.
+func (jt *JavaType) BoxedName() string {
+	if jt.Type != JavaTypePrimitive {
+		return ""
+	}
+	return primitiveBoxes[jt.Name]
+}
+
+// Boxed returns the class that wraps this primitive type, e.g. `Integer` for `int`, or the type itself
+// if it isn't a primitive type (or the class can't be found).
+func (jt *JavaType) Boxed() *JavaType {
+	if boxedName := jt.BoxedName(); boxedName != "" {
+		if boxedType := builtinType("java.lang." + boxedName); boxedType != nil {
+			return boxedType
+		}
+	}
+	return jt
+}
+
+// Unboxed returns the primitive type this class wraps, e.g. `int` for `Integer`, or the type itself if it
+// isn't a boxed primitive.
+func (jt *JavaType) Unboxed() *JavaType {
+	if unboxedName := jt.unboxedName(); unboxedName != "" {
+		if unboxedType := builtinType(unboxedName); unboxedType != nil {
+			return unboxedType
+		}
+	}
+	return jt
+}
+
+// unboxedName returns the name of the primitive type this class wraps, e.g. `int` for `Integer`.
+// Returns an empty string if this isn't a boxed primitive.
+func (jt *JavaType) unboxedName() string {
+	if jt.Type != JavaTypeClass || jt.Package != "java.lang" {
+		return ""
+	}
+	return boxedPrimitives[jt.Name]
+}
+
 // CoercesTo says whether a type can be converted to another type without a type cast.
 func (jt *JavaType) CoercesTo(other *JavaType) bool {
 	if jt.Type == JavaTypeLSPAny {
@@ -385,27 +644,43 @@ func (jt *JavaType) CoercesTo(other *JavaType) bool {
 		return true
 	}
 
-	// Any type, including primitives, can be coerced to java.lang.Object
-	if other.Name == "Object" {
-		return true
+	// Type variables could stand for any type within their bounds, so nothing else is known to fit
+	if other.Type == JavaTypeTypeVariable {
+		return jt.IsSameType(other)
+	}
+	// ... but anything that fits into the lower bound of a `? super T` wildcard will do
+	if other.Type == JavaTypeWildcard {
+		return other.LowerBound != nil && jt.CoercesTo(other.LowerBound)
 	}
 
-	// If it's a primitive type, there are several coercions that can be made automatically
-	if jt.Type == JavaTypePrimitive {
-		return slices.Contains(primitivesCoercions[jt.Name], other.Name)
+	// Arrays of objects are covariant, e.g. `String[]` fits into `Object[]`, but arrays of primitives
+	// only fit into arrays of the exact same primitive
+	if jt.Type == JavaTypeArray && other.Type == JavaTypeArray {
+		from, to := jt.ComponentType(), other.ComponentType()
+		if from.Type == JavaTypePrimitive || to.Type == JavaTypePrimitive {
+			return from.IsSameType(to)
+		}
+		return from.CoercesTo(to)
 	}
 
-	// Boxed primitive types can be converted to the non-boxed primitives
-	if jt.Type == JavaTypeClass && other.Type == JavaTypePrimitive {
-		if boxed, ok := boxedPrimitives[jt.Name]; ok && other.Name == boxed {
-			return true
+	// If it's a primitive type, it can be widened to a bigger primitive type...
+	if jt.Type == JavaTypePrimitive {
+		if other.Type == JavaTypePrimitive {
+			return slices.Contains(primitivesCoercions[jt.Name], other.Name)
 		}
+		// ... or boxed, after which it fits wherever its class does, e.g. `int` into `Number` or `Object`
+		boxed := jt.Boxed()
+		return boxed != jt && boxed.CoercesTo(other)
+	}
+
+	// Boxed primitive types can be converted to the non-boxed primitives, and then widened
+	if unboxed := jt.unboxedName(); unboxed != "" && other.Type == JavaTypePrimitive {
+		return other.Name == unboxed || slices.Contains(primitivesCoercions[unboxed], other.Name)
 	}
 
-	// Is it a superclass of this type?
-	superclasses := jt.AllSuperClasses()
-	for _, super := range superclasses {
-		if super == other {
+	// Is it this type or a superclass of it, with compatible type arguments?
+	for _, super := range append([]*JavaType{jt}, jt.AllSuperClasses()...) {
+		if super.GetOriginal() == other.GetOriginal() && typeArgsContained(super, other) {
 			return true
 		}
 	}
@@ -414,6 +689,60 @@ func (jt *JavaType) CoercesTo(other *JavaType) bool {
 	return false
 }
 
+// CastsTo says whether a value of this type could also be of the other type, so that casting it or testing
+// it with `instanceof` makes sense (JLS 5.5). Unlike CoercesTo, this also holds for narrowing, e.g. from
+// `Object` to `String`, or from a class to an interface that a subclass of it could implement. Type
+// arguments are ignored.
+func (jt *JavaType) CastsTo(other *JavaType) bool {
+	if jt.Type == JavaTypeLSPAny || other.Type == JavaTypeLSPAny {
+		return true
+	}
+
+	// Numeric types cast to each other, but not to boolean. Otherwise, primitives only box or unbox.
+	if jt.Type == JavaTypePrimitive && other.Type == JavaTypePrimitive {
+		return (jt.Name == "boolean") == (other.Name == "boolean")
+	}
+	if jt.Type == JavaTypePrimitive || other.Type == JavaTypePrimitive {
+		return jt.CoercesTo(other) || other.CoercesTo(jt)
+	}
+
+	if jt.Type == JavaTypeTypeVariable || other.Type == JavaTypeTypeVariable ||
+		jt.Type == JavaTypeWildcard || other.Type == JavaTypeWildcard {
+		return jt.Erasure().CastsTo(other.Erasure())
+	}
+
+	if jt.Type == JavaTypeArray && other.Type == JavaTypeArray {
+		from, to := jt.ComponentType(), other.ComponentType()
+		if from.Type == JavaTypePrimitive || to.Type == JavaTypePrimitive {
+			return from.IsSameType(to)
+		}
+		return from.CastsTo(to)
+	}
+
+	if jt.CoercesTo(other) || other.CoercesTo(jt) || jt.IsSubclassOf(other) || other.IsSubclassOf(jt) {
+		return true
+	}
+
+	// Some subclass of the class could implement the interface, unless it can't have any (JLS 5.5.1). Arrays
+	// only implement the interfaces they're subtypes of, which were covered above.
+	switch {
+	case jt.Type == JavaTypeArray || other.Type == JavaTypeArray:
+		return false
+	case jt.Type == JavaTypeInterface:
+		return !other.GetOriginal().hasFixedInterfaces()
+	case other.Type == JavaTypeInterface:
+		return !jt.GetOriginal().hasFixedInterfaces()
+	}
+	return false
+}
+
+// hasFixedInterfaces says whether every value of a class type implements just the interfaces the class does,
+// since it's final, or it's a record or an enum. The bodies of enum constants are subclasses of the enum, but
+// they can't implement any other interfaces.
+func (jt *JavaType) hasFixedInterfaces() bool {
+	return jt.IsFinal || jt.Type == JavaTypeRecord || jt.Type == JavaTypeEnum
+}
+
 type JavaField struct {
 	Name       string
 	Type       *JavaType
@@ -428,6 +757,13 @@ type JavaField struct {
 	Visibility VisibilityType
 	IsStatic   bool
 	IsFinal    bool
+	// IsEnumConstant says whether the field is one of the constants of an enum, e.g. `RED` in
+	// `enum Color { RED, GREEN }`.
+	IsEnumConstant bool
+
+	// Original is the field as declared, for a field of a parameterized type whose type has had the
+	// type arguments filled in. Nil otherwise.
+	Original *JavaField
 }
 
 var _ JavaSymbol = (*JavaField)(nil)
@@ -457,10 +793,18 @@ func (jf *JavaField) GetDefinition() *loc.CodeLocation {
 }
 
 func (jf *JavaField) GetUsages() []loc.CodeLocation {
+	if jf.Original != nil {
+		return jf.Original.GetUsages()
+	}
 	return jf.Usages
 }
 
 func (jf *JavaField) AddUsage(location loc.CodeLocation) {
+	if jf.Original != nil {
+		jf.Original.AddUsage(location)
+		return
+	}
+
 	jf.Usages = append(jf.Usages, location)
 	jf.Usages = pruneUsages(jf.Usages, location.FileUri, location.Version)
 }
@@ -484,6 +828,10 @@ type JavaConstructor struct {
 	Usages []loc.CodeLocation
 
 	Visibility VisibilityType
+
+	// Original is the constructor as declared, for a constructor of a parameterized type whose parameter
+	// types have had the type arguments filled in. Nil otherwise.
+	Original *JavaConstructor
 }
 
 var _ JavaSymbol = (*JavaConstructor)(nil)
@@ -517,6 +865,11 @@ func (jc *JavaConstructor) GetUsages() []loc.CodeLocation {
 }
 
 func (jc *JavaConstructor) AddUsage(location loc.CodeLocation) {
+	if jc.Original != nil {
+		jc.Original.AddUsage(location)
+		return
+	}
+
 	jc.Usages = append(jc.Usages, location)
 	jc.Usages = pruneUsages(jc.Usages, location.FileUri, location.Version)
 }
@@ -531,9 +884,9 @@ func (jc *JavaConstructor) GetType() *JavaType {
 	t.GenericArgs[0] = jc.ParentType
 
 	for i, param := range jc.Params {
-		// TODO if it's a varargs param, change it into the array type
 		t.GenericArgs[i+1] = param.Type
 	}
+	t.IsVarargs = len(jc.Params) > 0 && jc.Params[len(jc.Params)-1].IsVarargs
 
 	return t
 
@@ -554,6 +907,17 @@ type JavaMethod struct {
 
 	Visibility VisibilityType
 	IsStatic   bool
+	// IsAbstract says whether the method has no body, so that subclasses have to implement it, like the
+	// methods of an interface that aren't default or static.
+	IsAbstract bool
+
+	// TypeParams are the type variables declared by a generic method, e.g. `T` for `<T> T first(List<T> list)`.
+	// Nil if the method isn't generic.
+	TypeParams []*JavaType
+
+	// Original is the method as declared, for a method of a parameterized type whose signature has had
+	// the type arguments filled in. Nil otherwise.
+	Original *JavaMethod
 }
 
 var _ JavaSymbol = (*JavaMethod)(nil)
@@ -594,10 +958,18 @@ func (jm *JavaMethod) GetDefinition() *loc.CodeLocation {
 }
 
 func (jm *JavaMethod) GetUsages() []loc.CodeLocation {
+	if jm.Original != nil {
+		return jm.Original.GetUsages()
+	}
 	return jm.Usages
 }
 
 func (jm *JavaMethod) AddUsage(location loc.CodeLocation) {
+	if jm.Original != nil {
+		jm.Original.AddUsage(location)
+		return
+	}
+
 	jm.Usages = append(jm.Usages, location)
 	jm.Usages = pruneUsages(jm.Usages, location.FileUri, location.Version)
 }
@@ -611,9 +983,11 @@ func (jm *JavaMethod) GetType() *JavaType {
 	t.GenericArgs[0] = jm.ParentType
 	t.GenericArgs[1] = jm.ReturnType
 	for i, param := range jm.Params {
-		// TODO if it's a varargs param, change it into the array type
 		t.GenericArgs[i+2] = param.Type
 	}
+	t.IsVarargs = len(jm.Params) > 0 && jm.Params[len(jm.Params)-1].IsVarargs
+	// The method's own type variables, to be inferred wherever it's called
+	t.TypeParams = jm.TypeParams
 
 	return t
 
@@ -636,13 +1010,18 @@ type JavaParameter struct {
 }
 
 func (jp *JavaParameter) String() string {
-	return fmt.Sprintf("%s %s", jp.Type.Name, jp.Name)
+	if jp.IsVarargs && jp.Type.Type == JavaTypeArray {
+		return fmt.Sprintf("%s... %s", jp.Type.ComponentType().ShortName(), jp.Name)
+	}
+	return fmt.Sprintf("%s %s", jp.Type.ShortName(), jp.Name)
 }
 
 type JavaLocal struct {
-	Name         string
-	Type         *JavaType
-	ParentMethod *JavaMethod
+	Name string
+	Type *JavaType
+	// ParentMethod is the method or constructor the local is declared in, or the type for the parameters
+	// of a lambda expression outside of one, e.g. in a field initializer
+	ParentMethod JavaSymbol
 
 	// Definition stores where this method is defined in the code.
 	Definition *loc.CodeLocation
@@ -650,7 +1029,7 @@ type JavaLocal struct {
 	Usages []loc.CodeLocation
 }
 
-func NewJavaLocal(name string, ttype *JavaType, parentMethod *JavaMethod, definition loc.CodeLocation) *JavaLocal {
+func NewJavaLocal(name string, ttype *JavaType, parentMethod JavaSymbol, definition loc.CodeLocation) *JavaLocal {
 	return &JavaLocal{
 		Name:         name,
 		Type:         ttype,
@@ -667,7 +1046,7 @@ func (jl *JavaLocal) Kind() JavaSymbolKind {
 }
 
 func (jl *JavaLocal) PackageName() string {
-	return jl.ParentMethod.ParentType.Package
+	return jl.ParentMethod.PackageName()
 }
 
 func (jl *JavaLocal) ShortName() string {
@@ -0,0 +1,184 @@
+package typecheck
+
+import (
+	"fmt"
+	"java-mini-ls-go/javaparser"
+	"java-mini-ls-go/parse/loc"
+	"java-mini-ls-go/parse/typ"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+)
+
+// localScope is a part of a method with locals that are only in scope inside of it, like a block, or the part
+// where pattern variables are in scope because it's only reached when the patterns match, e.g. the body of
+// `if (o instanceof String s)`.
+type localScope struct {
+	// The part of the code the locals are in scope for. The scope ends when leaving it.
+	ctx   antlr.ParserRuleContext
+	scope *TypeCheckingScope
+}
+
+// opensLocalScope says whether the locals declared in a part of the code are only in scope inside of it. That's
+// the case for blocks and the bodies of switches, and for the statements and clauses that declare locals for
+// the block that follows them: `for` loops, `try` statements with resources and `catch` clauses.
+func opensLocalScope(ctx antlr.ParserRuleContext) bool {
+	switch ctx := ctx.(type) {
+	case *javaparser.BlockContext:
+		// The locals in the body of a method, lambda or `catch` clause share the scope of its parameters
+		switch ctx.GetParent().(type) {
+		case *javaparser.MethodBodyContext, *javaparser.ConstructorDeclarationContext, *javaparser.CompactConstructorDeclarationContext,
+			*javaparser.LambdaBodyContext, *javaparser.CatchClauseContext:
+			return false
+		}
+		return true
+	case *javaparser.CatchClauseContext, *javaparser.SwitchExpressionContext:
+		return true
+	case *javaparser.StatementContext:
+		return ctx.FOR() != nil || ctx.SWITCH() != nil || ctx.ResourceSpecification() != nil
+	}
+	return false
+}
+
+func (tc *typeChecker) enterLocalScope(ctx antlr.ParserRuleContext) {
+	if opensLocalScope(ctx) {
+		tc.pushLocalScope(ctx, loc.ParserRuleContextToBounds(ctx))
+	}
+}
+
+// pushLocalScope starts a scope for locals that lasts until leaving the given part of the code.
+func (tc *typeChecker) pushLocalScope(ctx antlr.ParserRuleContext, bounds loc.Bounds) *TypeCheckingScope {
+	var enclosingMethod typ.JavaSymbol
+	if symbol := tc.currentScope.Symbol; symbol != nil && isMethodOrConstructor(symbol) {
+		enclosingMethod = symbol
+	}
+	scope := newTypeCheckingScope(enclosingMethod, tc.currentScope, bounds)
+	tc.currentScope = scope
+	tc.localScopes.Push(localScope{ctx: ctx, scope: scope})
+	return scope
+}
+
+// exitLocalScopes ends the scopes of the locals that were in scope throughout the given part of the code.
+func (tc *typeChecker) exitLocalScopes(ctx antlr.ParserRuleContext) {
+	for !tc.localScopes.Empty() && tc.localScopes.Top().ctx == ctx {
+		tc.currentScope = tc.localScopes.Pop().scope.Parent
+	}
+}
+
+// ExitForControl checks the condition of a basic `for` loop, e.g. `i < 10` in `for (int i = 0; i < 10; i++)`.
+// The variables it declares were added to the scope of the loop already.
+func (tc *typeChecker) ExitForControl(ctx *javaparser.ForControlContext) {
+	if ctx.EnhancedForControl() != nil {
+		return
+	}
+
+	if update, ok := ctx.GetForUpdate().(*javaparser.ExpressionListContext); ok {
+		for range update.AllExpression() {
+			tc.expressionStack.Pop()
+		}
+	}
+	if ctx.Expression() != nil {
+		tc.checkCondition(tc.expressionStack.Pop())
+	}
+	tc.expressionStack.Clear()
+}
+
+// ExitEnhancedForControl declares the variable of an enhanced `for` loop, e.g. `name` in
+// `for (String name : names)`, after checking that the elements of what it goes through fit into it.
+func (tc *typeChecker) ExitEnhancedForControl(ctx *javaparser.EnhancedForControlContext) {
+	iterable := tc.expressionStack.Pop()
+	elementType := tc.lookupOrCreateType(typ.TypeNameLSPAny)
+	raw := false
+	if iterable.ttype != nil && iterable.ttype.Type != typ.JavaTypeLSPAny {
+		if elementType, raw = iterable.ttype.IteratedType(); elementType == nil {
+			tc.addError(TypeError{
+				Loc:         iterable.loc,
+				Message:     "Can only iterate over an array or an instance of java.lang.Iterable",
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+			elementType = tc.lookupOrCreateType(typ.TypeNameLSPAny)
+		}
+	}
+
+	declaratorID := ctx.VariableDeclaratorId().(*javaparser.VariableDeclaratorIdContext)
+	varType := elementType
+	if typeType := ctx.TypeType(); !isVar(ctx.VAR(), typeType) {
+		varType = withDeclaratorDims(tc.lookupOrCreateType(typeType.GetText()), declaratorID)
+		// The elements of a raw Iterable, like the `Set` that the library's `Map.entrySet` returns, are only
+		// known to be Objects, so any type is taken at its word
+		if !raw && !elementType.CoercesTo(varType) {
+			tc.addError(TypeError{
+				Loc:         iterable.loc,
+				Message:     fmt.Sprintf("Type mismatch: cannot convert from element type %s to %s", elementType.ShortName(), varType.ShortName()),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+		}
+	}
+
+	ident := declaratorID.Identifier()
+	tc.checkAndAddVariable(ident.GetText(), varType, loc.ParserRuleContextToBounds(ident), "method")
+}
+
+// EnterCatchClause declares the exception parameter of a `catch` clause. If it catches several types of
+// exceptions, e.g. `catch (IOException | RuntimeException e)`, its type is the closest one they have in common.
+func (tc *typeChecker) EnterCatchClause(ctx *javaparser.CatchClauseContext) {
+	catchType := ctx.CatchType().(*javaparser.CatchTypeContext)
+	var caughtTypes []*typ.JavaType
+	for _, name := range catchType.AllQualifiedName() {
+		caughtTypes = append(caughtTypes, tc.lookupOrCreateType(name.GetText()))
+	}
+
+	paramType := typ.LeastUpperBound(caughtTypes)
+	if paramType == nil {
+		paramType = tc.lookupOrCreateType(typ.TypeNameLSPAny)
+	}
+	ident := ctx.Identifier()
+	tc.checkAndAddVariable(ident.GetText(), paramType, loc.ParserRuleContextToBounds(ident), "method")
+}
+
+// ExitResource declares a resource of a `try` statement, e.g. `reader` in
+// `try (Reader reader = new FileReader(file))`, or checks an existing variable that's used as one.
+func (tc *typeChecker) ExitResource(ctx *javaparser.ResourceContext) {
+	if ctx.Expression() == nil {
+		// An existing variable, e.g. `try (reader)`
+		tc.handleIdentifier(ctx.Identifier().(*javaparser.IdentifierContext))
+		tc.expressionStack.Pop()
+		return
+	}
+
+	ident := ctx.Identifier()
+	if declaratorID, ok := ctx.VariableDeclaratorId().(*javaparser.VariableDeclaratorIdContext); ok {
+		ident = declaratorID.Identifier()
+	}
+
+	value := tc.expressionStack.Pop()
+	varType := value.ttype
+	if typeName := ctx.ClassOrInterfaceType(); !isVar(ctx.VAR(), typeName) {
+		varType = withDeclaratorDims(tc.lookupOrCreateType(typeName.GetText()), ctx.VariableDeclaratorId())
+		if value.ttype != nil && !tc.assignable(value, varType) {
+			tc.addError(TypeError{
+				Loc:         value.loc,
+				Message:     fmt.Sprintf("Type mismatch: cannot convert from %s to %s", value.ttype.ShortName(), varType.ShortName()),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+		}
+	}
+	if varType == nil {
+		varType = tc.lookupOrCreateType(typ.TypeNameLSPAny)
+	}
+	tc.checkAndAddVariable(ident.GetText(), varType, loc.ParserRuleContextToBounds(ident), "method")
+}
+
+// isVar says whether a declaration leaves the type of its variable to be inferred with `var`, which the
+// parser may also take for the name of a type.
+func isVar(varToken antlr.TerminalNode, typeName antlr.ParseTree) bool {
+	return varToken != nil || typeName == nil || typeName.GetText() == "var"
+}
@@ -100,3 +100,20 @@ func (dul *DefinitionsUsagesLookup) Lookup(loc loc.FileLocation) typ.JavaSymbol
 	}
 	return nil
 }
+
+// ReferencedFiles returns the URIs of all the other files that define a symbol referenced in this lookup.
+// Symbols without a definition (built-ins/library types) and symbols defined in fileURI itself are left out.
+func (dul *DefinitionsUsagesLookup) ReferencedFiles(fileURI string) []string {
+	files := util.NewSet[string]()
+
+	for _, line := range dul.DefUsagesByLine.Values() {
+		for _, symLoc := range line {
+			definition := symLoc.Symbol.GetDefinition()
+			if definition != nil && definition.FileUri != fileURI {
+				files.Add(definition.FileUri)
+			}
+		}
+	}
+
+	return files.Values()
+}
@@ -0,0 +1,94 @@
+package typecheck
+
+import (
+	"java-mini-ls-go/javaparser"
+	"java-mini-ls-go/parse/loc"
+	"java-mini-ls-go/parse/typ"
+)
+
+// addEnumConstants adds a public static final field for each of the constants of an enum, and makes the enum
+// extend java.lang.Enum and implement the interfaces it says it does.
+func (tg *typeGatherer) addEnumConstants(ctx *javaparser.EnumDeclarationContext) {
+	enumType := tg.ownedType(tg.resolver.currentType())
+	if enumType == nil {
+		return
+	}
+
+	// e.g. `enum Color` extends `Enum<Color>`
+	enumType.Extends = []*typ.JavaType{}
+	if enumBase := tg.lookupType("java.lang.Enum"); enumBase != nil {
+		enumType.Extends = append(enumType.Extends, enumBase.GetOriginal().Parameterize([]*typ.JavaType{enumType}))
+	}
+	enumType.Implements = tg.getImplementsTypes(ctx)
+
+	constants, ok := ctx.EnumConstants().(*javaparser.EnumConstantsContext)
+	if !ok {
+		return
+	}
+	for _, constantI := range constants.AllEnumConstant() {
+		ident := constantI.(*javaparser.EnumConstantContext).Identifier()
+		location := tg.makeCodeLocation(loc.ParserRuleContextToBounds(ident))
+		field := &typ.JavaField{
+			Name:           ident.GetText(),
+			Type:           enumType,
+			ParentType:     enumType,
+			Definition:     &location,
+			Usages:         []loc.CodeLocation{},
+			Visibility:     typ.VisibilityPublic,
+			IsStatic:       true,
+			IsFinal:        true,
+			IsEnumConstant: true,
+			Original:       nil,
+		}
+		enumType.Fields = append(enumType.Fields, field)
+		tg.defUsages.Add(location, field, false)
+	}
+}
+
+// addImplicitEnumMembers adds the members that an enum has without declaring them (JLS 8.9.3): the static
+// values() and valueOf(String) methods, name() and ordinal(), which would otherwise come from java.lang.Enum,
+// and a private no-arg constructor if it doesn't declare any.
+func (tg *typeGatherer) addImplicitEnumMembers(enumType *typ.JavaType) {
+	if enumType == nil {
+		return
+	}
+
+	values := implicitMethod(enumType, "values", typ.NewArrayType(enumType, 1), nil)
+	values.IsStatic = true
+	valueOf := implicitMethod(enumType, "valueOf", enumType, nil, tg.lookupType("java.lang.String"))
+	valueOf.IsStatic = true
+	enumType.Methods = append(enumType.Methods,
+		values,
+		valueOf,
+		implicitMethod(enumType, "name", tg.lookupType("java.lang.String"), nil),
+		implicitMethod(enumType, "ordinal", tg.lookupType("int"), nil),
+	)
+
+	if len(enumType.Constructors) == 0 {
+		enumType.Constructors = append(enumType.Constructors, &typ.JavaConstructor{
+			ParentType: enumType,
+			Params:     []*typ.JavaParameter{},
+			Definition: nil,
+			Usages:     []loc.CodeLocation{},
+			Visibility: typ.VisibilityPrivate,
+			Original:   nil,
+		})
+	}
+}
+
+// ExitEnumConstant checks the args an enum constant is created with, e.g. `RED(255, 0, 0)`, against the
+// constructors of the enum. Constants without args call the no-arg one.
+func (tc *typeChecker) ExitEnumConstant(ctx *javaparser.EnumConstantContext) {
+	var exprList javaparser.IExpressionListContext
+	if arguments, ok := ctx.Arguments().(*javaparser.ArgumentsContext); ok {
+		exprList = arguments.ExpressionList()
+	}
+	args := tc.popArgs(exprList)
+
+	enumType := tc.getEnclosingType()
+	if enumType == nil || enumType.Type != typ.JavaTypeEnum {
+		return
+	}
+	ident := ctx.Identifier().(*javaparser.IdentifierContext)
+	tc.handleConstructorCall(ident, ident, enumType, args, false, false, nil)
+}
@@ -0,0 +1,105 @@
+package typecheck
+
+import (
+	"fmt"
+	"java-mini-ls-go/javaparser"
+	"java-mini-ls-go/parse/loc"
+	"java-mini-ls-go/parse/typ"
+	"java-mini-ls-go/util"
+	"strings"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+)
+
+// ExitClassOrInterfaceType reports the name of a type that's written out, e.g. in a variable declaration, if
+// there's no type by that name in scope.
+func (tc *typeChecker) ExitClassOrInterfaceType(ctx *javaparser.ClassOrInterfaceTypeContext) {
+	identifiers := ctx.AllIdentifier()
+	// `var` isn't a type, but parses as one in some places, e.g. `for (var item : items)`
+	if len(identifiers) == 0 || ctx.GetText() == "var" {
+		return
+	}
+
+	// The type arguments are types of their own, which get checked separately
+	name := strings.Join(util.Map(identifiers, func(ident javaparser.IIdentifierContext) string {
+		return ident.GetText()
+	}), ".")
+	if tc.lookupType(name) != nil {
+		return
+	}
+
+	bounds := loc.Bounds{
+		Start: loc.ParserRuleContextToBounds(identifiers[0]).Start,
+		End:   loc.ParserRuleContextToBounds(identifiers[len(identifiers)-1]).End,
+	}
+	tc.reportUnresolvedType(ctx, name, bounds)
+}
+
+// reportUnresolvedType reports a type name that can't be found, e.g. `ArrayList` without importing it. If
+// there's a public type by that name in another package, the error comes with a quick fix that imports it.
+func (tc *typeChecker) reportUnresolvedType(ctx antlr.Tree, name string, bounds loc.Bounds) {
+	tc.addError(TypeError{
+		Loc:         bounds,
+		Message:     fmt.Sprintf("%s cannot be resolved to a type", name),
+		Related:     nil,
+		Severity:    SeverityError,
+		Unnecessary: false,
+		Fix:         tc.importFix(ctx, name),
+	})
+}
+
+// importFix makes a quick fix that imports the type that the first part of a name refers to, e.g. `Map` for
+// `Map.Entry`, from whichever package has one by that name. Returns nil if none do.
+func (tc *typeChecker) importFix(ctx antlr.Tree, name string) *QuickFix {
+	firstPart, _, _ := strings.Cut(name, ".")
+	imported := tc.importableType(firstPart)
+	if imported == nil {
+		return nil
+	}
+	compilationUnit := enclosingCompilationUnit(ctx)
+	if compilationUnit == nil {
+		return nil
+	}
+
+	// Put the import after the last one there is, or else after the package declaration
+	importText := fmt.Sprintf("import %s;", imported.QualifiedName())
+	insertAt := loc.FileLocation{Line: 1, Character: 0}
+	newText := importText + "\n\n"
+	if imports := compilationUnit.AllImportDeclaration(); len(imports) > 0 {
+		insertAt = loc.ParserRuleContextToBounds(imports[len(imports)-1]).End
+		newText = "\n" + importText
+	} else if packageDecl := compilationUnit.PackageDeclaration(); packageDecl != nil {
+		insertAt = loc.ParserRuleContextToBounds(packageDecl).End
+		newText = "\n\n" + importText
+	}
+
+	return &QuickFix{
+		Title: fmt.Sprintf("Import '%s' (%s)", imported.Name, imported.Package),
+		Edits: []TextEdit{{
+			Loc:     loc.Bounds{Start: insertAt, End: insertAt},
+			NewText: newText,
+		}},
+	}
+}
+
+// importableType returns a public type from another package that could be imported to refer to it by the
+// given simple name, preferring the workspace's own types over the standard library's.
+func (tc *typeChecker) importableType(simpleName string) *typ.JavaType {
+	for _, types := range []*typ.TypeMap{tc.userTypes, tc.builtins} {
+		found := types.GetByNestedName(simpleName)
+		if found != nil && found.Visibility == typ.VisibilityPublic && found.Package != tc.resolver.packageName {
+			return found
+		}
+	}
+	return nil
+}
+
+// enclosingCompilationUnit returns the root of the parse tree that a node is in.
+func enclosingCompilationUnit(node antlr.Tree) *javaparser.CompilationUnitContext {
+	for ; node != nil; node = node.GetParent() {
+		if compilationUnit, ok := node.(*javaparser.CompilationUnitContext); ok {
+			return compilationUnit
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package typecheck
+
+import (
+	"fmt"
+	"java-mini-ls-go/javaparser"
+	"java-mini-ls-go/parse"
+	"java-mini-ls-go/parse/loc"
+	"java-mini-ls-go/parse/typ"
+	"java-mini-ls-go/util"
+	"strings"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+)
+
+// checkUnimplementedMethods reports each abstract method that a class that isn't abstract inherits without
+// implementing it, with a quick fix that adds stubs for all of them.
+func (tc *typeChecker) checkUnimplementedMethods(scope *parse.Scope, ctx antlr.ParserRuleContext, ttype *typ.JavaType) {
+	if ttype == nil || !mustImplementAbstractMethods(ctx) {
+		return
+	}
+
+	unimplemented := ttype.UnimplementedMethods()
+	if len(unimplemented) == 0 {
+		return
+	}
+
+	fix := unimplementedMethodsFix(ctx, unimplemented)
+	for _, method := range unimplemented {
+		tc.addError(TypeError{
+			Loc:         scope.Bounds,
+			Message:     fmt.Sprintf("The type %s must implement the inherited abstract method %s.%s", ttype.ShortName(), typeNameOrUnknown(method.ParentType), method.NameWithArgs()),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         fix,
+		})
+	}
+}
+
+// mustImplementAbstractMethods says whether the declared type has to implement every abstract method it
+// inherits, which is the case for classes, enums and records that aren't abstract. An enum whose constants
+// have bodies may leave them to the constants instead.
+func mustImplementAbstractMethods(ctx antlr.ParserRuleContext) bool {
+	switch ctx := ctx.(type) {
+	case *javaparser.ClassDeclarationContext:
+		return !typeModifiers(ctx).isAbstract
+	case *javaparser.EnumDeclarationContext:
+		if constants, ok := ctx.EnumConstants().(*javaparser.EnumConstantsContext); ok {
+			for _, constant := range constants.AllEnumConstant() {
+				if constant.(*javaparser.EnumConstantContext).ClassBody() != nil {
+					return false
+				}
+			}
+		}
+		return true
+	case *javaparser.RecordDeclarationContext, *javaparser.ClassBodyContext:
+		return true
+	}
+	return false
+}
+
+// unimplementedMethodsFix makes a quick fix that adds stubs for the given methods right before the closing brace
+// of the body of a type declaration. Returns nil if there's nowhere to put them, e.g. the brace is missing.
+func unimplementedMethodsFix(ctx antlr.ParserRuleContext, methods []*typ.JavaMethod) *QuickFix {
+	closingBrace := ctx.GetStop()
+	if closingBrace == nil || closingBrace.GetText() != "}" {
+		return nil
+	}
+	members := bodyDeclarations(ctx)
+	if members == nil {
+		return nil
+	}
+
+	braceIndent, braceFirstOnLine := lineIndent(closingBrace)
+	memberIndent := braceIndent + indentUnit(braceIndent)
+	if len(members) > 0 {
+		if indent, firstOnLine := lineIndent(members[0].GetStart()); firstOnLine {
+			memberIndent = indent
+		}
+	}
+
+	stubs := util.Map(methods, func(method *typ.JavaMethod) string {
+		return methodStub(method, memberIndent, indentUnit(memberIndent))
+	})
+
+	// Put the stubs on their own lines, keeping the brace where it is
+	insertAt := loc.FileLocation{Line: closingBrace.GetLine(), Character: closingBrace.GetColumn()}
+	newText := "\n" + strings.Join(stubs, "\n") + braceIndent
+	if braceFirstOnLine {
+		insertAt.Character = 0
+		newText = "\n" + strings.Join(stubs, "\n")
+	}
+
+	return &QuickFix{
+		Title: "Add unimplemented methods",
+		Edits: []TextEdit{{
+			Loc:     loc.Bounds{Start: insertAt, End: insertAt},
+			NewText: newText,
+		}},
+	}
+}
+
+// bodyDeclarations returns the members declared in the body of a type declaration, or nil if it can't have any
+// more of them, like an enum without a `;` after its constants.
+func bodyDeclarations(ctx antlr.ParserRuleContext) []javaparser.IClassBodyDeclarationContext {
+	switch ctx := ctx.(type) {
+	case *javaparser.ClassDeclarationContext:
+		if body, ok := ctx.ClassBody().(*javaparser.ClassBodyContext); ok {
+			return body.AllClassBodyDeclaration()
+		}
+	case *javaparser.ClassBodyContext:
+		return ctx.AllClassBodyDeclaration()
+	case *javaparser.RecordDeclarationContext:
+		if body, ok := ctx.RecordBody().(*javaparser.RecordBodyContext); ok {
+			return body.AllClassBodyDeclaration()
+		}
+	case *javaparser.EnumDeclarationContext:
+		if body, ok := ctx.EnumBodyDeclarations().(*javaparser.EnumBodyDeclarationsContext); ok {
+			return body.AllClassBodyDeclaration()
+		}
+	}
+	return nil
+}
+
+// methodStub writes out a method that overrides the given one, whose body returns a default value.
+// Each line is indented by the given indent, and the body by one more unit of indentation.
+func methodStub(method *typ.JavaMethod, indent string, unit string) string {
+	signature := strings.Builder{}
+	switch method.Visibility {
+	case typ.VisibilityPublic:
+		signature.WriteString("public ")
+	case typ.VisibilityProtected:
+		signature.WriteString("protected ")
+	}
+	if len(method.TypeParams) > 0 {
+		signature.WriteString("<" + strings.Join(util.Map(method.TypeParams, (*typ.JavaType).ShortName), ", ") + "> ")
+	}
+	signature.WriteString(returnTypeName(method.ReturnType) + " ")
+	signature.WriteString(method.Name + "(" + strings.Join(util.MapToString(method.Params), ", ") + ")")
+
+	lines := []string{
+		indent + "@Override",
+		indent + signature.String() + " {",
+		indent + unit + "// TODO Auto-generated method stub",
+	}
+	if !isVoid(method.ReturnType) {
+		lines = append(lines, indent+unit+"return "+defaultValue(method.ReturnType)+";")
+	}
+	lines = append(lines, indent+"}")
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func returnTypeName(ttype *typ.JavaType) string {
+	if isVoid(ttype) {
+		return "void"
+	}
+	return ttype.ShortName()
+}
+
+// defaultValue returns the value that fields of the given type start out with, e.g. `0` for `int`.
+func defaultValue(ttype *typ.JavaType) string {
+	if ttype.Type != typ.JavaTypePrimitive {
+		return "null"
+	}
+	if ttype.Name == "boolean" {
+		return "false"
+	}
+	return "0"
+}
+
+// lineIndent returns the whitespace at the start of the line a token is on, and whether the token is the first
+// thing on it.
+func lineIndent(token antlr.Token) (string, bool) {
+	if token.GetColumn() == 0 {
+		return "", true
+	}
+
+	lineStart := token.GetStart() - token.GetColumn()
+	beforeToken := token.GetInputStream().GetText(lineStart, token.GetStart()-1)
+	afterIndent := strings.TrimLeft(beforeToken, " \t")
+	return beforeToken[:len(beforeToken)-len(afterIndent)], afterIndent == ""
+}
+
+// indentUnit guesses what one level of indentation is, based on some existing indentation.
+func indentUnit(indent string) string {
+	if indent != "" && !strings.Contains(indent, "\t") {
+		return "    "
+	}
+	return "\t"
+}
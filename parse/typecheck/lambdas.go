@@ -0,0 +1,281 @@
+package typecheck
+
+import (
+	"fmt"
+	"java-mini-ls-go/javaparser"
+	"java-mini-ls-go/parse/loc"
+	"java-mini-ls-go/parse/typ"
+	"java-mini-ls-go/util"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+)
+
+// lambdaContext is what's known about a lambda expression while its body is being checked.
+type lambdaContext struct {
+	ctx *javaparser.LambdaExpressionContext
+	// The type the lambda is expected to have where it appears, e.g. `Function<? super T, ? extends R>` when
+	// it's passed to `map`. Nil if there isn't one.
+	target *typ.JavaType
+	// The method of the target type that the lambda implements. Nil if the target type isn't a functional
+	// interface.
+	function *typ.JavaMethod
+	// The type variables of the generic method the lambda is passed to that the arguments before it don't
+	// determine, e.g. `R` for `map`. They're inferred from what the lambda returns instead.
+	freeVars []*typ.JavaType
+	// How many parameters the lambda declares
+	arity int
+	// The scope the parameters of the lambda are declared in
+	scope *TypeCheckingScope
+	// The values returned by `return` statements in a block body
+	returns []typedExpression
+}
+
+// lambdaParam is a parameter of a lambda expression. Its type is nil if it's left to be inferred, like
+// `x` in `x -> x + 1` or `var x`.
+type lambdaParam struct {
+	ident antlr.ParserRuleContext
+	ttype *typ.JavaType
+}
+
+func (tc *typeChecker) EnterLambdaExpression(ctx *javaparser.LambdaExpressionContext) {
+	params := tc.lambdaParams(ctx.LambdaParameters().(*javaparser.LambdaParametersContext))
+	var target *typ.JavaType
+	var freeVars []*typ.JavaType
+	if expr, ok := ctx.GetParent().(*javaparser.ExpressionContext); ok {
+		target, freeVars = tc.functionalTarget(expr, func(function *typ.JavaMethod) bool {
+			return len(function.Params) == len(params)
+		})
+	}
+	var function *typ.JavaMethod
+	if target != nil {
+		function = target.NonWildcardParameterization().FunctionalMethod()
+	}
+
+	// The body gets checked on its own, like the body of a method
+	tc.outerExpressionStacks.Push(tc.expressionStack)
+	tc.expressionStack = util.NewStack[typedExpression]()
+
+	var enclosingMethod typ.JavaSymbol
+	if symbol := tc.currentScope.Symbol; symbol != nil && isMethodOrConstructor(symbol) {
+		enclosingMethod = symbol
+	}
+	scope := newTypeCheckingScope(enclosingMethod, tc.currentScope, loc.ParserRuleContextToBounds(ctx))
+	tc.currentScope = scope
+	tc.lambdas.Push(&lambdaContext{
+		ctx:      ctx,
+		target:   target,
+		function: function,
+		freeVars: freeVars,
+		arity:    len(params),
+		scope:    scope,
+		returns:  nil,
+	})
+
+	// The parameter types of a raw target, like the `Function` that library methods often take, are just the
+	// erasures of its type variables, which would make every parameter an Object
+	var paramTypes []*typ.JavaType
+	if function != nil && !target.IsRaw() {
+		paramTypes = function.GetType().CallableParams()
+	}
+	for i, param := range params {
+		ttype := param.ttype
+		if ttype == nil {
+			// Inferred from the functional interface, if that's known
+			if len(paramTypes) == len(params) && !paramTypes[i].Mentions(freeVars) {
+				ttype = paramTypes[i]
+			} else {
+				ttype = tc.lookupOrCreateType(typ.TypeNameLSPAny)
+			}
+		}
+		tc.checkAndAddVariable(param.ident.GetText(), ttype, loc.ParserRuleContextToBounds(param.ident), "method")
+	}
+}
+
+func (tc *typeChecker) ExitLambdaExpression(ctx *javaparser.LambdaExpressionContext) {
+	lc := tc.lambdas.Pop()
+
+	// What the body evaluates to. An expression body is allowed where nothing is returned if it's an
+	// expression that could be a statement on its own, e.g. `list.add(x)`, but not `x + 1`.
+	var values []typedExpression
+	voidCompatible := true
+	body := ctx.LambdaBody().(*javaparser.LambdaBodyContext)
+	if expr := body.Expression(); expr != nil {
+		values = []typedExpression{tc.expressionStack.Pop()}
+		voidCompatible = isStatementExpression(expr.(*javaparser.ExpressionContext))
+	} else {
+		values = lc.returns
+		voidCompatible = len(values) == 0
+	}
+
+	tc.expressionStack = tc.outerExpressionStacks.Pop()
+	tc.currentScope = lc.scope.Parent
+
+	bounds := loc.ParserRuleContextToBounds(ctx)
+	tc.pushExprType(tc.checkLambda(lc, values, voidCompatible, bounds), bounds)
+}
+
+// checkLambda checks a lambda expression against the functional interface it's expected to implement, and
+// returns the type of the lambda.
+func (tc *typeChecker) checkLambda(lc *lambdaContext, values []typedExpression, voidCompatible bool, bounds loc.Bounds) *typ.JavaType {
+	if lc.target == nil {
+		// Can't say anything about it
+		return tc.lookupOrCreateType(typ.TypeNameLSPAny)
+	}
+	if lc.function == nil {
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     "The target type of this expression must be a functional interface",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+		return tc.lookupOrCreateType(typ.TypeNameLSPAny)
+	}
+
+	lambdaType := lc.target.NonWildcardParameterization()
+	if lc.arity != len(lc.function.Params) {
+		tc.addError(TypeError{
+			Loc:         loc.ParserRuleContextToBounds(lc.ctx.LambdaParameters()),
+			Message:     fmt.Sprintf("Lambda expression's signature does not match the signature of the functional interface method %s", lc.function.NameWithArgs()),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+		return lambdaType
+	}
+
+	returnType := lc.function.ReturnType
+	if isVoid(returnType) {
+		if !voidCompatible {
+			for _, value := range values {
+				tc.addError(TypeError{
+					Loc:         value.loc,
+					Message:     "Void methods cannot return a value",
+					Related:     nil,
+					Severity:    SeverityError,
+					Unnecessary: false,
+					Fix:         nil,
+				})
+			}
+		}
+		return lambdaType
+	}
+
+	// Return types that depend on the type variables being inferred fit anything, since the lambda is what
+	// they're inferred from
+	dependsOnFreeVars := returnType.Mentions(lc.freeVars)
+	for _, value := range values {
+		if isVoid(value.ttype) {
+			tc.addError(TypeError{
+				Loc:         value.loc,
+				Message:     fmt.Sprintf("Type mismatch: cannot convert from void to %s", returnType.ShortName()),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+		} else if !dependsOnFreeVars && !value.ttype.CoercesTo(returnType) {
+			tc.addError(TypeError{
+				Loc:         value.loc,
+				Message:     fmt.Sprintf("Type mismatch: cannot convert from %s to %s", value.ttype.ShortName(), returnType.ShortName()),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+		}
+	}
+
+	return tc.functionalType(lc.target, lc.function, lc.freeVars, util.Map(values, func(value typedExpression) *typ.JavaType {
+		return value.ttype
+	}))
+}
+
+// lambdaParams returns the parameters of a lambda expression, with the types they're declared with.
+func (tc *typeChecker) lambdaParams(ctx *javaparser.LambdaParametersContext) []lambdaParam {
+	params := []lambdaParam{}
+
+	// `x -> ...` or `(x, y) -> ...`
+	for _, ident := range ctx.AllIdentifier() {
+		params = append(params, lambdaParam{ident: ident.(*javaparser.IdentifierContext), ttype: nil})
+	}
+
+	// `(var x, var y) -> ...`
+	if lvtiList, ok := ctx.LambdaLVTIList().(*javaparser.LambdaLVTIListContext); ok {
+		for _, paramI := range lvtiList.AllLambdaLVTIParameter() {
+			param := paramI.(*javaparser.LambdaLVTIParameterContext)
+			params = append(params, lambdaParam{ident: param.Identifier().(*javaparser.IdentifierContext), ttype: nil})
+		}
+	}
+
+	// `(String x, int y) -> ...`
+	if paramList, ok := ctx.FormalParameterList().(*javaparser.FormalParameterListContext); ok {
+		for _, paramI := range paramList.AllFormalParameter() {
+			param := paramI.(*javaparser.FormalParameterContext)
+			declaratorID := param.VariableDeclaratorId()
+			params = append(params, lambdaParam{
+				ident: declaratorID.(*javaparser.VariableDeclaratorIdContext).Identifier().(*javaparser.IdentifierContext),
+				ttype: withDeclaratorDims(tc.lookupOrCreateType(param.TypeType().GetText()), declaratorID),
+			})
+		}
+		if lastParam, ok := paramList.LastFormalParameter().(*javaparser.LastFormalParameterContext); ok {
+			declaratorID := lastParam.VariableDeclaratorId()
+			params = append(params, lambdaParam{
+				ident: declaratorID.(*javaparser.VariableDeclaratorIdContext).Identifier().(*javaparser.IdentifierContext),
+				ttype: typ.NewArrayType(tc.lookupOrCreateType(lastParam.TypeType().GetText()), 1),
+			})
+		}
+	}
+
+	return params
+}
+
+// enclosingLambda returns the innermost lambda expression the given part of the code is in, or nil if it's
+// not inside of one, or only in a lambda outside of the class member it's in.
+func enclosingLambda(tree antlr.Tree) *javaparser.LambdaExpressionContext {
+	for node := tree.GetParent(); node != nil; node = node.GetParent() {
+		switch ctx := node.(type) {
+		case *javaparser.LambdaExpressionContext:
+			return ctx
+		case *javaparser.ClassBodyDeclarationContext, *javaparser.InterfaceBodyDeclarationContext:
+			return nil
+		}
+	}
+	return nil
+}
+
+// lambdaReturnType returns the type the innermost lambda expression should return, or nil if that isn't
+// known, or depends on type variables that are still being inferred.
+func (tc *typeChecker) lambdaReturnType() *typ.JavaType {
+	if tc.lambdas.Empty() {
+		return nil
+	}
+	lc := tc.lambdas.Top()
+	if lc.function == nil || isVoid(lc.function.ReturnType) || lc.function.ReturnType.Mentions(lc.freeVars) {
+		return nil
+	}
+	return lc.function.ReturnType
+}
+
+// isVoid says whether a type is the return type of a method that doesn't return anything. That's nil for
+// methods declared in the code, and a placeholder type named `void` for ones from the standard library.
+func isVoid(ttype *typ.JavaType) bool {
+	return ttype == nil || ttype.Name == "void"
+}
+
+// isStatementExpression says whether an expression could be a statement on its own (JLS 14.8), e.g. a
+// method call or an assignment.
+func isStatementExpression(expr *javaparser.ExpressionContext) bool {
+	if expr.MethodCall() != nil || expr.NEW() != nil || expr.GetPostfix() != nil {
+		return true
+	}
+	if prefix := expr.GetPrefix(); prefix != nil {
+		return prefix.GetText() == "++" || prefix.GetText() == "--"
+	}
+	if bop := expr.GetBop(); bop != nil {
+		return assignmentBops.Contains(bop.GetText())
+	}
+	return false
+}
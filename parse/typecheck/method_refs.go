@@ -0,0 +1,287 @@
+package typecheck
+
+import (
+	"fmt"
+	"java-mini-ls-go/javaparser"
+	"java-mini-ls-go/parse/loc"
+	"java-mini-ls-go/parse/typ"
+	"java-mini-ls-go/util"
+	"strings"
+)
+
+// methodReference is what a method reference like `String::length` refers to, before it's resolved against
+// the functional interface it implements.
+type methodReference struct {
+	// The type before the `::`, e.g. `String` in `String::length`, or the type of the expression for
+	// references to a method of a particular object, e.g. `PrintStream` in `System.out::println`
+	qualifier *typ.JavaType
+	// Whether the qualifier is a type name rather than an expression. Type names can refer to static
+	// methods, or to instance methods that take the receiver as their first argument.
+	isTypeName bool
+	// The name of the method, or "" for constructor references like `ArrayList::new`
+	name string
+	// Where the name (or `new`) is
+	nameBounds loc.Bounds
+}
+
+// handleMethodReference checks a method reference, e.g. `String::length`, `list::add` or `ArrayList::new`,
+// against the functional interface it's expected to implement (JLS 15.13), and pushes its type.
+func (tc *typeChecker) handleMethodReference(ctx *javaparser.ExpressionContext) {
+	bounds := loc.ParserRuleContextToBounds(ctx)
+
+	ref, ok := tc.methodReference(ctx)
+	if !ok {
+		tc.pushAnyType(bounds)
+		return
+	}
+
+	target, freeVars := tc.functionalTarget(ctx, func(function *typ.JavaMethod) bool {
+		_, _, found := tc.resolveMethodReference(ref, function.GetType().CallableParams(), nil)
+		return found
+	})
+	if target == nil {
+		// Can't say anything about it
+		tc.pushAnyType(bounds)
+		return
+	}
+	function := target.NonWildcardParameterization().FunctionalMethod()
+	if function == nil {
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     "The target type of this expression must be a functional interface",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+		tc.pushAnyType(bounds)
+		return
+	}
+
+	paramTypes := function.GetType().CallableParams()
+	expectedReturn := function.ReturnType
+	knownReturn := expectedReturn
+	if isVoid(knownReturn) || knownReturn.Mentions(freeVars) {
+		knownReturn = nil
+	}
+	member, returnType, found := tc.resolveMethodReference(ref, paramTypes, knownReturn)
+	if !found && target.IsRaw() {
+		// The parameter types are just the erasures of the type variables of the target, which don't say what
+		// the method reference is really called with
+		tc.pushExprType(target, bounds)
+		return
+	}
+	if !found {
+		name := ref.name
+		if name == "" {
+			name = ref.qualifier.ShortName()
+		}
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     fmt.Sprintf("The type %s does not define %s(%s) that is applicable here", ref.qualifier.ShortName(), name, strings.Join(util.Map(paramTypes, typeNameOrUnknown), ", ")),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+		tc.pushExprType(target.NonWildcardParameterization(), bounds)
+		return
+	}
+
+	switch member := member.(type) {
+	case *typ.JavaMethod:
+		tc.defUsages.Add(tc.makeCodeLocation(ref.nameBounds), member, true)
+		tc.checkAccess(member, ref.qualifier, ref.nameBounds)
+	case *typ.JavaConstructor:
+		tc.addConstructorUsage(ref.nameBounds, member)
+		tc.checkAccess(member, ref.qualifier, ref.nameBounds)
+	}
+
+	if knownReturn != nil && (isVoid(returnType) || !returnType.CoercesTo(expectedReturn)) {
+		returnName := "void"
+		if !isVoid(returnType) {
+			returnName = returnType.ShortName()
+		}
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     fmt.Sprintf("The type of %s from the type %s is %s, this is incompatible with the descriptor's return type: %s", referenceSignature(member, ref), ref.qualifier.ShortName(), returnName, expectedReturn.ShortName()),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+
+	tc.pushExprType(tc.functionalType(target, function, freeVars, []*typ.JavaType{returnType}), bounds)
+}
+
+// methodReference reads what's on either side of the `::` of a method reference. The expression before it,
+// if any, has already been evaluated. Returns false if it's unclear what's being referred to.
+func (tc *typeChecker) methodReference(ctx *javaparser.ExpressionContext) (methodReference, bool) {
+	ref := methodReference{
+		qualifier:  nil,
+		isTypeName: true,
+		name:       "",
+		nameBounds: loc.Bounds{}, //nolint:exhaustruct
+	}
+	if ident := ctx.Identifier(); ident != nil {
+		ref.name = ident.GetText()
+		ref.nameBounds = loc.ParserRuleContextToBounds(ident)
+	} else {
+		ref.nameBounds = loc.TokenToBounds(ctx.NEW().GetSymbol())
+	}
+
+	switch {
+	case ctx.TypeType() != nil:
+		// e.g. `int[]::new`
+		ref.qualifier = tc.lookupOrCreateType(ctx.TypeType().GetText())
+	case ctx.ClassType() != nil:
+		ref.qualifier = tc.lookupType(ctx.ClassType().GetText())
+	case isSuper(ctx.Expression(0)):
+		// `super::method` refers to the superclass's version of a method of `this`
+		if enclosing := tc.getEnclosingType(); enclosing != nil {
+			ref.qualifier = tc.superclass(enclosing)
+			ref.isTypeName = false
+		}
+	default:
+		qualifier := tc.expressionStack.Pop().ttype
+		if qualifier != nil && qualifier.Type == typ.JavaTypeLSPClass {
+			ref.qualifier = qualifier.GenericArgs[0]
+		} else {
+			ref.qualifier = qualifier
+			ref.isTypeName = false
+		}
+	}
+
+	return ref, ref.qualifier != nil && ref.qualifier.Type != typ.JavaTypeLSPAny
+}
+
+// isSuper says whether an expression is just `super`, which doesn't push anything onto the expression stack.
+func isSuper(expr javaparser.IExpressionContext) bool {
+	exprCtx, ok := expr.(*javaparser.ExpressionContext)
+	if !ok {
+		return false
+	}
+	primary, ok := exprCtx.Primary().(*javaparser.PrimaryContext)
+	return ok && primary.SUPER() != nil
+}
+
+// resolveMethodReference finds the method or constructor that a method reference refers to when it
+// implements a functional interface method with the given parameter types (JLS 15.13.1), and the type
+// calling it results in. Returns false if there's no such method. Array constructor references, like
+// `int[]::new`, don't refer to anything, so the member is nil for those. expectedReturn is what the
+// functional interface method returns, if known, which type arguments of constructor references are
+// inferred from.
+func (tc *typeChecker) resolveMethodReference(ref methodReference, paramTypes []*typ.JavaType, expectedReturn *typ.JavaType) (typ.JavaSymbol, *typ.JavaType, bool) {
+	if ref.name == "" {
+		if ref.qualifier.Type == typ.JavaTypeArray {
+			// Takes the length of the array
+			isLength := len(paramTypes) == 1 && paramTypes[0] != nil && paramTypes[0].CoercesTo(tc.lookupType("int"))
+			return nil, ref.qualifier, isLength
+		}
+
+		generic := ref.qualifier.GetOriginal()
+		if generic.IsGeneric() && len(ref.qualifier.GenericArgs) == 0 {
+			// e.g. `ArrayList::new`, whose type arguments are inferred like for `new ArrayList<>()`
+			return referencedConstructor(generic, generic.Constructors, paramTypes, expectedReturn, true)
+		}
+		return referencedConstructor(ref.qualifier, ref.qualifier.LookupConstructors(), paramTypes, expectedReturn, false)
+	}
+
+	methods := ref.qualifier.LookupMethods(ref.name)
+	if !ref.isTypeName {
+		// e.g. `System.out::println`, which is called on `System.out` with all the args
+		return referencedMethod(filterMethods(methods, typ.IsInstanceMember), paramTypes)
+	}
+
+	// e.g. `String::valueOf`, a static method that's called with all the args
+	if method, returnType, found := referencedMethod(filterMethods(methods, typ.IsStaticMember), paramTypes); found {
+		return method, returnType, true
+	}
+
+	// e.g. `String::length`, an instance method that's called on the first arg with the rest of them
+	if len(paramTypes) == 0 || paramTypes[0] == nil || !paramTypes[0].CoercesTo(ref.qualifier) {
+		return nil, nil, false
+	}
+	if paramTypes[0].GetOriginal() == ref.qualifier.GetOriginal() {
+		// The first arg says what the type arguments are, e.g. `List<String>` for `List::size`
+		methods = paramTypes[0].LookupMethods(ref.name)
+	}
+	return referencedMethod(filterMethods(methods, typ.IsInstanceMember), paramTypes[1:])
+}
+
+// referencedConstructor picks which of the constructors of a type a constructor reference refers to, given
+// the types of the args it's called with, and returns the constructor along with the created type. For
+// raw references to generic types, the type arguments are inferred from the args and the expected type.
+func referencedConstructor(createdType *typ.JavaType, constructors []*typ.JavaConstructor, argTypes []*typ.JavaType, expectedType *typ.JavaType, isDiamond bool) (typ.JavaSymbol, *typ.JavaType, bool) {
+	idx := resolveReferenceOverload(util.Map(constructors, (*typ.JavaConstructor).GetType), argTypes)
+	if idx == -1 {
+		return nil, nil, false
+	}
+	if !isDiamond {
+		return constructors[idx], createdType, true
+	}
+
+	// Treat it like a generic method returning e.g. `ArrayList<E>`
+	generic := createdType.GetOriginal()
+	returnType := generic.Parameterize(generic.TypeParams)
+	bindings := typ.InferTypeArgs(generic.TypeParams, constructors[idx].GetType().CallableParams(), boxedTypes(argTypes), returnType, boxedOrNil(expectedType))
+	return constructors[idx], returnType.Substitute(bindings), true
+}
+
+// referencedMethod picks which of the overloads of a method a method reference refers to, given the types
+// of the args it's called with, and returns the method along with the type calling it results in.
+func referencedMethod(methods []*typ.JavaMethod, argTypes []*typ.JavaType) (typ.JavaSymbol, *typ.JavaType, bool) {
+	idx := resolveReferenceOverload(util.Map(methods, (*typ.JavaMethod).GetType), argTypes)
+	if idx == -1 {
+		return nil, nil, false
+	}
+
+	method := methods[idx]
+	returnType := method.ReturnType
+	if len(method.TypeParams) > 0 {
+		bindings := typ.InferTypeArgs(method.TypeParams, method.GetType().CallableParams(), boxedTypes(argTypes), returnType, nil)
+		returnType = returnType.Substitute(bindings)
+	}
+	return method, returnType, true
+}
+
+// resolveReferenceOverload picks the overload that a method reference called with the given args refers to,
+// or returns -1 if there isn't exactly one.
+func resolveReferenceOverload(candidates []*typ.JavaType, argTypes []*typ.JavaType) int {
+	resolved := typ.ResolveOverload(candidates, argTypes)
+	if len(resolved) != 1 {
+		return -1
+	}
+	return resolved[0]
+}
+
+func boxedTypes(types []*typ.JavaType) []*typ.JavaType {
+	return util.Map(types, boxedOrNil)
+}
+
+func boxedOrNil(ttype *typ.JavaType) *typ.JavaType {
+	if ttype == nil {
+		return nil
+	}
+	return ttype.Boxed()
+}
+
+func filterMethods(methods []*typ.JavaMethod, keep func(symbol typ.JavaSymbol) bool) []*typ.JavaMethod {
+	filtered := []*typ.JavaMethod{}
+	for _, method := range methods {
+		if keep(method) {
+			filtered = append(filtered, method)
+		}
+	}
+	return filtered
+}
+
+// referenceSignature is how the method or constructor a method reference refers to is named in error
+// messages, e.g. `length()`.
+func referenceSignature(member typ.JavaSymbol, ref methodReference) string {
+	if member == nil {
+		return ref.qualifier.ShortName() + "::new"
+	}
+	return signature(member)
+}
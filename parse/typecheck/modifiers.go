@@ -0,0 +1,156 @@
+package typecheck
+
+import (
+	"java-mini-ls-go/javaparser"
+	"java-mini-ls-go/parse/typ"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+)
+
+// modifiers is what the modifiers of a declaration say about the thing it declares.
+type modifiers struct {
+	visibility typ.VisibilityType
+	isStatic   bool
+	isFinal    bool
+	isAbstract bool
+}
+
+// noModifiers is what a declaration without any modifiers is: package-private, not static, not final and
+// not abstract.
+var noModifiers = modifiers{
+	visibility: typ.VisibilityDefault,
+	isStatic:   false,
+	isFinal:    false,
+	isAbstract: false,
+}
+
+// parseModifiers reads the modifiers of a class, interface, or one of their members.
+func parseModifiers(classOrInterfaceModifiers []javaparser.IClassOrInterfaceModifierContext) modifiers {
+	mods := noModifiers
+	for _, modifierI := range classOrInterfaceModifiers {
+		modifier := modifierI.(*javaparser.ClassOrInterfaceModifierContext)
+		switch {
+		case modifier.PUBLIC() != nil:
+			mods.visibility = typ.VisibilityPublic
+		case modifier.PROTECTED() != nil:
+			mods.visibility = typ.VisibilityProtected
+		case modifier.PRIVATE() != nil:
+			mods.visibility = typ.VisibilityPrivate
+		case modifier.STATIC() != nil:
+			mods.isStatic = true
+		case modifier.FINAL() != nil:
+			mods.isFinal = true
+		case modifier.ABSTRACT() != nil:
+			mods.isAbstract = true
+		}
+	}
+	return mods
+}
+
+// hasFinalModifier says whether the modifiers of a local variable or parameter include `final`.
+func hasFinalModifier(variableModifiers []javaparser.IVariableModifierContext) bool {
+	for _, modifier := range variableModifiers {
+		if modifier.(*javaparser.VariableModifierContext).FINAL() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// classOrInterfaceModifiers returns the modifiers of a member that could also be modifiers of a class or
+// interface, leaving out ones like `native` that only apply to members.
+func classOrInterfaceModifiers(modifiers []javaparser.IModifierContext) []javaparser.IClassOrInterfaceModifierContext {
+	classOrInterfaceModifiers := []javaparser.IClassOrInterfaceModifierContext{}
+	for _, modifierI := range modifiers {
+		classModifier := modifierI.(*javaparser.ModifierContext).ClassOrInterfaceModifier()
+		if classModifier != nil {
+			classOrInterfaceModifiers = append(classOrInterfaceModifiers, classModifier)
+		}
+	}
+	return classOrInterfaceModifiers
+}
+
+// memberModifiers reads the modifiers of a class body declaration. Static initializer blocks count as static,
+// and so do member interfaces, enums and records, which can't be inner classes.
+func memberModifiers(ctx *javaparser.ClassBodyDeclarationContext) modifiers {
+	mods := parseModifiers(classOrInterfaceModifiers(ctx.AllModifier()))
+	mods.isStatic = mods.isStatic || ctx.STATIC() != nil
+	if member, ok := ctx.MemberDeclaration().(*javaparser.MemberDeclarationContext); ok {
+		mods.isStatic = mods.isStatic || member.InterfaceDeclaration() != nil || member.EnumDeclaration() != nil || member.RecordDeclaration() != nil
+	}
+	return mods
+}
+
+// compactConstructorModifiers reads the modifiers of the compact canonical constructor of a record.
+func compactConstructorModifiers(ctx *javaparser.CompactConstructorDeclarationContext) modifiers {
+	return parseModifiers(classOrInterfaceModifiers(ctx.AllModifier()))
+}
+
+// interfaceMemberModifiers reads the modifiers of an interface body declaration, along with the ones members
+// of interfaces have implicitly. Constants are public, static and final, methods are public and abstract unless
+// they have a body, and member types are public and static.
+func interfaceMemberModifiers(ctx *javaparser.InterfaceBodyDeclarationContext) modifiers {
+	mods := parseModifiers(classOrInterfaceModifiers(ctx.AllModifier()))
+	if mods.visibility != typ.VisibilityPrivate {
+		mods.visibility = typ.VisibilityPublic
+	}
+
+	member, ok := ctx.InterfaceMemberDeclaration().(*javaparser.InterfaceMemberDeclarationContext)
+	if !ok {
+		return mods
+	}
+	if member.ConstDeclaration() != nil {
+		mods.isStatic = true
+		mods.isFinal = true
+		return mods
+	}
+
+	method := interfaceMethodOf(member)
+	if method == nil {
+		// A member type
+		mods.isStatic = true
+		return mods
+	}
+	for _, modifierI := range method.AllInterfaceMethodModifier() {
+		if modifierI.(*javaparser.InterfaceMethodModifierContext).STATIC() != nil {
+			mods.isStatic = true
+		}
+	}
+	body := method.InterfaceCommonBodyDeclaration().(*javaparser.InterfaceCommonBodyDeclarationContext)
+	mods.isAbstract = body.MethodBody().(*javaparser.MethodBodyContext).Block() == nil
+	return mods
+}
+
+// interfaceMethodCtx is a method declared in an interface, which may or may not be generic.
+type interfaceMethodCtx interface {
+	AllInterfaceMethodModifier() []javaparser.IInterfaceMethodModifierContext
+	InterfaceCommonBodyDeclaration() javaparser.IInterfaceCommonBodyDeclarationContext
+}
+
+// interfaceMethodOf returns the method declared by an interface member declaration, or nil if it declares
+// something else.
+func interfaceMethodOf(member *javaparser.InterfaceMemberDeclarationContext) interfaceMethodCtx {
+	if method, ok := member.InterfaceMethodDeclaration().(*javaparser.InterfaceMethodDeclarationContext); ok {
+		return method
+	}
+	if method, ok := member.GenericInterfaceMethodDeclaration().(*javaparser.GenericInterfaceMethodDeclarationContext); ok {
+		return method
+	}
+	return nil
+}
+
+// typeModifiers reads the modifiers of a type declaration, which are either those of a top-level type, or those
+// of a member of the enclosing class or interface. Local types don't have any.
+func typeModifiers(ctx antlr.ParserRuleContext) modifiers {
+	switch parent := ctx.GetParent().(type) {
+	case *javaparser.TypeDeclarationContext:
+		return parseModifiers(parent.AllClassOrInterfaceModifier())
+	case *javaparser.MemberDeclarationContext:
+		if classBodyDeclaration, ok := parent.GetParent().(*javaparser.ClassBodyDeclarationContext); ok {
+			return memberModifiers(classBodyDeclaration)
+		}
+	case *javaparser.InterfaceMemberDeclarationContext:
+		return interfaceMemberModifiers(parent.GetParent().(*javaparser.InterfaceBodyDeclarationContext))
+	}
+	return noModifiers
+}
@@ -0,0 +1,622 @@
+package typecheck
+
+import (
+	"fmt"
+	"java-mini-ls-go/javaparser"
+	"java-mini-ls-go/parse/loc"
+	"java-mini-ls-go/parse/typ"
+	"java-mini-ls-go/util"
+	"strconv"
+	"strings"
+)
+
+// Binary operators, grouped by what they do with their operands (JLS 15.17-15.24)
+var multiplicativeBops = util.SetFromValues("*", "/", "%")
+var additiveBops = util.SetFromValues("+", "-")
+var shiftBops = util.SetFromValues("<<", ">>", ">>>")
+var comparisonBops = util.SetFromValues("<", ">", "<=", ">=")
+var equalityBops = util.SetFromValues("==", "!=")
+var bitwiseBops = util.SetFromValues("&", "|", "^")
+var conditionalBops = util.SetFromValues("&&", "||")
+var assignmentBops = util.SetFromValues("=", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", ">>=", ">>>=", "<<=")
+
+// e.g. `a + b`, `a < b` or `a = b`
+func (tc *typeChecker) handleBinaryExpression(ctx *javaparser.ExpressionContext) {
+	exprBounds := loc.ParserRuleContextToBounds(ctx)
+	bop := ctx.GetBop().GetText()
+
+	right := tc.expressionStack.Pop()
+	left := tc.expressionStack.Pop()
+
+	if assignmentBops.Contains(bop) && !isVariable(ctx.Expression(0)) {
+		tc.addError(TypeError{
+			Loc:         loc.ParserRuleContextToBounds(ctx.Expression(0)),
+			Message:     "The left-hand side of an assignment must be a variable",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+		tc.pushAnyType(exprBounds)
+		return
+	}
+
+	exprNilFunc := func(side string) {
+		tc.addError(TypeError{
+			Message:     fmt.Sprintf("TODO: %s expression is nil (this shouldn't happen, contact extension maintainers)", side),
+			Loc:         exprBounds,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+		tc.pushAnyType(exprBounds)
+	}
+	if right.ttype == nil {
+		exprNilFunc("right")
+		return
+	}
+	if left.ttype == nil {
+		exprNilFunc("left")
+		return
+	}
+
+	if assignmentBops.Contains(bop) {
+		tc.checkFinalFieldAssignment(ctx, left)
+		tc.pushExprType(tc.checkAssignment(ctx, bop, left, right), exprBounds)
+		return
+	}
+
+	if left.ttype.Type == typ.JavaTypeLSPAny || right.ttype.Type == typ.JavaTypeLSPAny {
+		// There's already an error for the operand, or it's `null`, so go with what the operator usually returns
+		tc.pushExprType(tc.binaryTypeWithUnknownOperand(bop, left.ttype, right.ttype), exprBounds)
+		return
+	}
+
+	result := tc.binaryType(bop, left.ttype, right.ttype)
+	if result == nil {
+		tc.addOperatorError(bop, exprBounds, left.ttype, right.ttype)
+		tc.pushAnyType(exprBounds)
+		return
+	}
+
+	if left.constant != nil && right.constant != nil && assertIsIntegral(result) {
+		if value, ok := binaryConstant(bop, result, *left.constant, *right.constant); ok {
+			tc.pushConstant(result, value, exprBounds)
+			return
+		}
+	}
+	tc.pushExprType(result, exprBounds)
+}
+
+// binaryType returns the type of the result of a binary operator that isn't an assignment, or nil if the
+// operator isn't defined for the types of its operands. Boxed operands are unboxed (JLS 5.6.2).
+func (tc *typeChecker) binaryType(bop string, left, right *typ.JavaType) *typ.JavaType {
+	leftPrim, rightPrim := left.Unboxed(), right.Unboxed()
+
+	switch {
+	case bop == "+" && (isString(left) || isString(right)):
+		if isVoid(left) || isVoid(right) {
+			return nil
+		}
+		return tc.lookupOrCreateType("java.lang.String")
+	case multiplicativeBops.Contains(bop) || additiveBops.Contains(bop):
+		if assertIsNumeric(leftPrim) && assertIsNumeric(rightPrim) {
+			return tc.binaryNumericPromotion(leftPrim, rightPrim)
+		}
+	case shiftBops.Contains(bop):
+		// The type of the shift distance doesn't matter, e.g. `i << 2L` is an int
+		if assertIsIntegral(leftPrim) && assertIsIntegral(rightPrim) {
+			return tc.unaryNumericPromotion(leftPrim)
+		}
+	case comparisonBops.Contains(bop):
+		if assertIsNumeric(leftPrim) && assertIsNumeric(rightPrim) {
+			return tc.lookupType("boolean")
+		}
+	case equalityBops.Contains(bop):
+		if canCompareForEquality(left, right) {
+			return tc.lookupType("boolean")
+		}
+	case bitwiseBops.Contains(bop):
+		if assertIsBoolean(leftPrim) && assertIsBoolean(rightPrim) {
+			return tc.lookupType("boolean")
+		}
+		if assertIsIntegral(leftPrim) && assertIsIntegral(rightPrim) {
+			return tc.binaryNumericPromotion(leftPrim, rightPrim)
+		}
+	case conditionalBops.Contains(bop):
+		if assertIsBoolean(leftPrim) && assertIsBoolean(rightPrim) {
+			return tc.lookupType("boolean")
+		}
+	}
+	return nil
+}
+
+// binaryTypeWithUnknownOperand guesses the type of the result of a binary operator when the type of one of its
+// operands isn't known. Comparisons are always boolean, and adding anything to a String makes a String.
+func (tc *typeChecker) binaryTypeWithUnknownOperand(bop string, left, right *typ.JavaType) *typ.JavaType {
+	switch {
+	case comparisonBops.Contains(bop) || equalityBops.Contains(bop) || conditionalBops.Contains(bop):
+		return tc.lookupType("boolean")
+	case bop == "+" && (isString(left) || isString(right)):
+		return tc.lookupOrCreateType("java.lang.String")
+	}
+	return tc.lookupOrCreateType(typ.TypeNameLSPAny)
+}
+
+// canCompareForEquality says whether two values can be compared with `==` or `!=` (JLS 15.21). Numbers and
+// booleans are compared by value if either of them is a primitive, and references only if one could be cast
+// to the other, e.g. an Integer can't be the same object as a String.
+func canCompareForEquality(left, right *typ.JavaType) bool {
+	if left.Type == typ.JavaTypePrimitive || right.Type == typ.JavaTypePrimitive {
+		leftPrim, rightPrim := left.Unboxed(), right.Unboxed()
+		return assertIsNumeric(leftPrim) && assertIsNumeric(rightPrim) ||
+			assertIsBoolean(leftPrim) && assertIsBoolean(rightPrim)
+	}
+	return left.CastsTo(right)
+}
+
+// checkAssignment checks the value assigned to a variable, e.g. `b` in `a = b` or `a += b`, and returns the
+// type of the assignment, which is the type of the variable (JLS 15.26).
+func (tc *typeChecker) checkAssignment(ctx *javaparser.ExpressionContext, bop string, left, right typedExpression) *typ.JavaType {
+	if left.ttype.Type == typ.JavaTypeLSPAny || right.ttype.Type == typ.JavaTypeLSPAny {
+		return left.ttype
+	}
+
+	if bop == "=" {
+		if !tc.assignable(right, left.ttype) {
+			tc.addError(TypeError{
+				Loc:         right.loc,
+				Message:     fmt.Sprintf("Type mismatch: cannot convert from %s to %s", right.ttype.ShortName(), left.ttype.ShortName()),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+		}
+		return left.ttype
+	}
+
+	// `a op= b` is the same as `a = (T) (a op b)`, where T is the type of `a`, so the result of the operator is
+	// converted back implicitly, e.g. `i += 1.5` is fine for an int
+	op := strings.TrimSuffix(bop, "=")
+	result := tc.binaryType(op, left.ttype, right.ttype)
+	if result == nil {
+		tc.addOperatorError(op, loc.ParserRuleContextToBounds(ctx), left.ttype, right.ttype)
+	} else if !result.CastsTo(left.ttype) {
+		tc.addError(TypeError{
+			Loc:         loc.ParserRuleContextToBounds(ctx),
+			Message:     fmt.Sprintf("Type mismatch: cannot convert from %s to %s", result.ShortName(), left.ttype.ShortName()),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+	return left.ttype
+}
+
+// checkFinalFieldAssignment reports assigning to a final field, e.g. `array.length = 3`, unless it's in a
+// constructor or initializer of the class that declares it, which is where blank final fields get their values.
+func (tc *typeChecker) checkFinalFieldAssignment(ctx *javaparser.ExpressionContext, variable typedExpression) {
+	field, ok := variable.variable.(*typ.JavaField)
+	if !ok || !field.IsFinal || field.ParentType == nil {
+		return
+	}
+	if tc.inInitializerOf(ctx, field.ParentType, field.IsStatic) {
+		return
+	}
+
+	// Arrays aren't declared anywhere, so their length is just known as `array.length`
+	parentName := field.ParentType.ShortName()
+	if field.ParentType.Type == typ.JavaTypeArray {
+		parentName = "array"
+	}
+	tc.addError(TypeError{
+		Loc:         variable.loc,
+		Message:     fmt.Sprintf("The final field %s.%s cannot be assigned", parentName, field.Name),
+		Related:     nil,
+		Severity:    SeverityError,
+		Unnecessary: false,
+		Fix:         nil,
+	})
+}
+
+// inInitializerOf says whether some code is directly in a constructor or initializer block of the given type,
+// rather than e.g. in a lambda expression or a method. Static initializers initialize the static fields, and
+// constructors and instance initializers the others.
+func (tc *typeChecker) inInitializerOf(ctx *javaparser.ExpressionContext, ttype *typ.JavaType, isStatic bool) bool {
+	enclosing := tc.getEnclosingType()
+	if enclosing == nil || enclosing.GetOriginal() != ttype.GetOriginal() {
+		return false
+	}
+
+	for node := ctx.GetParent(); node != nil; node = node.GetParent() {
+		switch node := node.(type) {
+		case *javaparser.LambdaExpressionContext:
+			return false
+		case *javaparser.ConstructorDeclarationContext, *javaparser.CompactConstructorDeclarationContext:
+			return !isStatic
+		case *javaparser.ClassBodyDeclarationContext:
+			return node.Block() != nil && (node.STATIC() != nil) == isStatic
+		}
+	}
+	return false
+}
+
+// isVariable says whether an expression refers to a variable that can be assigned to: a local or a field, e.g.
+// `x` or `point.x`, or an element of an array, e.g. `values[i]`, possibly in parentheses.
+func isVariable(exprI javaparser.IExpressionContext) bool {
+	expr, ok := exprI.(*javaparser.ExpressionContext)
+	if !ok {
+		return false
+	}
+	if primary, ok := expr.Primary().(*javaparser.PrimaryContext); ok {
+		if primary.Expression() != nil {
+			return isVariable(primary.Expression())
+		}
+		return primary.Identifier() != nil
+	}
+	return expr.GetIndexop() != nil || expr.GetDotop() != nil && expr.Identifier() != nil
+}
+
+// e.g. `-x`, `!done` or `i++`
+func (tc *typeChecker) handleUnaryExpression(ctx *javaparser.ExpressionContext, op string) {
+	bounds := loc.ParserRuleContextToBounds(ctx)
+	operand := tc.expressionStack.Pop()
+
+	if operand.ttype == nil || operand.ttype.Type == typ.JavaTypeLSPAny {
+		if op == "!" {
+			tc.pushExprTypeName("boolean", bounds)
+		} else {
+			tc.pushAnyType(bounds)
+		}
+		return
+	}
+
+	prim := operand.ttype.Unboxed()
+	var result *typ.JavaType
+	switch op {
+	case "++", "--":
+		if !isVariable(ctx.Expression(0)) {
+			tc.addError(TypeError{
+				Loc:         bounds,
+				Message:     "Invalid argument to operation ++/--",
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+			tc.pushExprType(operand.ttype, bounds)
+			return
+		}
+		tc.checkFinalFieldAssignment(ctx, operand)
+		if assertIsNumeric(prim) {
+			result = operand.ttype
+		}
+	case "+", "-":
+		if assertIsNumeric(prim) {
+			result = tc.unaryNumericPromotion(prim)
+		}
+	case "~":
+		if assertIsIntegral(prim) {
+			result = tc.unaryNumericPromotion(prim)
+		}
+	case "!":
+		if assertIsBoolean(prim) {
+			result = prim
+		}
+	}
+
+	if result == nil {
+		tc.addOperatorError(op, bounds, operand.ttype)
+		tc.pushAnyType(bounds)
+		return
+	}
+
+	if operand.constant != nil && op != "++" && op != "--" {
+		if value, ok := unaryConstant(op, *operand.constant); ok {
+			tc.pushConstant(result, value, bounds)
+			return
+		}
+	}
+	tc.pushExprType(result, bounds)
+}
+
+// addOperatorError reports that an operator can't be used on operands of the given types.
+func (tc *typeChecker) addOperatorError(op string, bounds loc.Bounds, operands ...*typ.JavaType) {
+	message := fmt.Sprintf("The operator %s is undefined for the argument type(s) %s", op, strings.Join(util.Map(operands, (*typ.JavaType).ShortName), ", "))
+	if equalityBops.Contains(op) {
+		message = fmt.Sprintf("Incompatible operand types %s and %s", operands[0].ShortName(), operands[1].ShortName())
+	}
+
+	tc.addError(TypeError{
+		Loc:         bounds,
+		Message:     message,
+		Related:     nil,
+		Severity:    SeverityError,
+		Unnecessary: false,
+		Fix:         nil,
+	})
+}
+
+// e.g. `done ? 1 : 0`
+func (tc *typeChecker) handleTernary(ctx *javaparser.ExpressionContext) {
+	bounds := loc.ParserRuleContextToBounds(ctx)
+	ifFalse := tc.expressionStack.Pop()
+	ifTrue := tc.expressionStack.Pop()
+	tc.checkCondition(tc.expressionStack.Pop())
+
+	// Where a particular type of object is expected, like in `CharSequence text = done ? s : builder`, that's
+	// the type, and both operands have to fit into it (JLS 15.25.3)
+	expected := tc.expectedType(ctx)
+	if expected != nil && expected.Type != typ.JavaTypePrimitive && isReferenceConditional(ifTrue, ifFalse) {
+		for _, operand := range []typedExpression{ifTrue, ifFalse} {
+			if operand.ttype != nil && !tc.assignable(operand, expected) {
+				tc.addError(TypeError{
+					Loc:         operand.loc,
+					Message:     fmt.Sprintf("Type mismatch: cannot convert from %s to %s", operand.ttype.ShortName(), expected.ShortName()),
+					Related:     nil,
+					Severity:    SeverityError,
+					Unnecessary: false,
+					Fix:         nil,
+				})
+			}
+		}
+		tc.pushExprType(expected, bounds)
+		return
+	}
+
+	tc.pushExprType(tc.conditionalType(ifTrue, ifFalse), bounds)
+}
+
+// isReferenceConditional says whether a conditional expression with the given operands is neither a boolean
+// nor a numeric one, e.g. `done ? "yes" : null`.
+func isReferenceConditional(ifTrue, ifFalse typedExpression) bool {
+	if ifTrue.ttype == nil || ifFalse.ttype == nil {
+		return false
+	}
+	truePrim, falsePrim := ifTrue.ttype.Unboxed(), ifFalse.ttype.Unboxed()
+	return !(assertIsBoolean(truePrim) && assertIsBoolean(falsePrim)) && !(assertIsNumeric(truePrim) && assertIsNumeric(falsePrim))
+}
+
+// conditionalType returns the type of a conditional expression, e.g. `done ? 1 : 0`, given its two operands
+// (JLS 15.25). If both are numbers, it's the type they're promoted to, unless a constant fits into the type of
+// the other one, e.g. `done ? 'a' : 0` is a char. Otherwise it's the closest type they have in common.
+func (tc *typeChecker) conditionalType(ifTrue, ifFalse typedExpression) *typ.JavaType {
+	switch {
+	case ifTrue.ttype == nil || ifFalse.ttype == nil:
+		return tc.lookupOrCreateType(typ.TypeNameLSPAny)
+	case ifTrue.ttype.Type == typ.JavaTypeLSPAny:
+		// e.g. `done ? null : 0`, which is an Integer
+		return tc.boxed(ifFalse.ttype)
+	case ifFalse.ttype.Type == typ.JavaTypeLSPAny:
+		return tc.boxed(ifTrue.ttype)
+	case ifTrue.ttype.IsSameType(ifFalse.ttype):
+		return ifTrue.ttype
+	}
+
+	truePrim, falsePrim := ifTrue.ttype.Unboxed(), ifFalse.ttype.Unboxed()
+	switch {
+	case assertIsBoolean(truePrim) && assertIsBoolean(falsePrim):
+		return tc.lookupType("boolean")
+	case assertIsNumeric(truePrim) && assertIsNumeric(falsePrim):
+		switch {
+		case truePrim.IsSameType(falsePrim):
+			return truePrim
+		case truePrim.Name == "byte" && falsePrim.Name == "short" || truePrim.Name == "short" && falsePrim.Name == "byte":
+			return tc.lookupType("short")
+		case smallIntegralTypes.Contains(truePrim.Name) && ifFalse.ttype.Name == "int" && fitsConstant(ifFalse, truePrim):
+			return truePrim
+		case smallIntegralTypes.Contains(falsePrim.Name) && ifTrue.ttype.Name == "int" && fitsConstant(ifTrue, falsePrim):
+			return falsePrim
+		}
+		return tc.binaryNumericPromotion(truePrim, falsePrim)
+	}
+
+	if lub := typ.LeastUpperBound([]*typ.JavaType{tc.boxed(ifTrue.ttype), tc.boxed(ifFalse.ttype)}); lub != nil {
+		return lub
+	}
+	return tc.lookupOrCreateType(typ.TypeNameLSPAny)
+}
+
+// ExitCastExpr checks a cast, e.g. `(String) value`, which has to be to a type the value could have.
+func (tc *typeChecker) ExitCastExpr(ctx *javaparser.CastExprContext) {
+	bounds := loc.ParserRuleContextToBounds(ctx)
+	operand := tc.expressionStack.Pop()
+	target := tc.lookupOrCreateType(ctx.TypeType(0).GetText())
+
+	if operand.ttype != nil && !operand.ttype.CastsTo(target) {
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     fmt.Sprintf("Cannot cast from %s to %s", operand.ttype.ShortName(), target.ShortName()),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+
+	if operand.constant != nil && assertIsIntegral(target) {
+		tc.pushConstant(target, *operand.constant, bounds)
+		return
+	}
+	tc.pushExprType(target, bounds)
+}
+
+// handleParenthesized gives an expression in parentheses, e.g. `(a + b)`, the bounds of the parentheses.
+func (tc *typeChecker) handleParenthesized(ctx *javaparser.PrimaryContext) {
+	inner := tc.expressionStack.Pop()
+	inner.loc = loc.ParserRuleContextToBounds(ctx)
+	tc.expressionStack.Push(inner)
+}
+
+func isString(ttype *typ.JavaType) bool {
+	return ttype.Type != typ.JavaTypeLSPAny && ttype.QualifiedName() == "java.lang.String"
+}
+
+var numericTypes = util.SetFromValues("byte", "char", "short", "int", "long", "float", "double")
+
+func assertIsNumeric(ttype *typ.JavaType) bool {
+	return ttype.Type == typ.JavaTypePrimitive && numericTypes.Contains(ttype.Name)
+}
+
+var integralTypes = util.SetFromValues("byte", "char", "short", "int", "long")
+
+func assertIsIntegral(ttype *typ.JavaType) bool {
+	return ttype.Type == typ.JavaTypePrimitive && integralTypes.Contains(ttype.Name)
+}
+
+func assertIsBoolean(ttype *typ.JavaType) bool {
+	return ttype.Type == typ.JavaTypePrimitive && ttype.Name == "boolean"
+}
+
+// The integral types narrower than int, which arithmetic promotes to int
+var smallIntegralTypes = util.SetFromValues("byte", "short", "char")
+
+// unaryNumericPromotion returns the type a numeric operand of a unary operator is converted to (JLS 5.6.1),
+// which is int for the types narrower than it. Boxed types should be unboxed first.
+func (tc *typeChecker) unaryNumericPromotion(ttype *typ.JavaType) *typ.JavaType {
+	if smallIntegralTypes.Contains(ttype.Name) {
+		return tc.lookupType("int")
+	}
+	return ttype
+}
+
+// binaryNumericPromotion returns the type the numeric operands of a binary operator are converted to
+// (JLS 5.6.2): the wider of the two, but at least int. Boxed types should be unboxed first.
+func (tc *typeChecker) binaryNumericPromotion(left, right *typ.JavaType) *typ.JavaType {
+	for _, name := range []string{"double", "float", "long"} {
+		if left.Name == name || right.Name == name {
+			return tc.lookupType(name)
+		}
+	}
+	return tc.lookupType("int")
+}
+
+// pushConstant pushes the value of a constant expression of an integral type, e.g. `'a' + 1`, wrapped around
+// to fit in the type. Knowing it lets constants narrow to smaller types, e.g. `byte b = 10`.
+func (tc *typeChecker) pushConstant(ttype *typ.JavaType, value int64, bounds loc.Bounds) {
+	value = wrapConstant(ttype, value)
+	tc.expressionStack.Push(typedExpression{
+		loc:                 bounds,
+		ttype:               ttype,
+		placeholderExprType: ExprTypeUnset,
+		constant:            &value,
+		variable:            nil,
+	})
+}
+
+// assignable says whether an expression can be assigned to a variable of the given type (JLS 5.2), either by
+// widening or boxing it, or because it's a constant that fits, e.g. `byte b = 10`.
+func (tc *typeChecker) assignable(expr typedExpression, to *typ.JavaType) bool {
+	return expr.ttype.CoercesTo(to) || fitsConstant(expr, to)
+}
+
+// fitsConstant says whether an expression is a constant of type int or narrower whose value is in range for
+// the given byte, short or char type, or for the type they box to, so it can be narrowed to it implicitly.
+func fitsConstant(expr typedExpression, to *typ.JavaType) bool {
+	if expr.constant == nil || expr.ttype == nil || to == nil {
+		return false
+	}
+	if !smallIntegralTypes.Contains(expr.ttype.Name) && expr.ttype.Name != "int" {
+		return false
+	}
+	target := to.Unboxed()
+	return smallIntegralTypes.Contains(target.Name) && assertIsIntegral(target) && wrapConstant(target, *expr.constant) == *expr.constant
+}
+
+// wrapConstant converts an integer value to the given integral type, like a cast does, e.g. 200 becomes -56
+// as a byte.
+func wrapConstant(ttype *typ.JavaType, value int64) int64 {
+	switch ttype.Name {
+	case "byte":
+		return int64(int8(value))
+	case "short":
+		return int64(int16(value))
+	case "char":
+		return int64(uint16(value))
+	case "int":
+		return int64(int32(value))
+	}
+	return value
+}
+
+// unaryConstant evaluates a unary operator on a constant.
+func unaryConstant(op string, value int64) (int64, bool) {
+	switch op {
+	case "+":
+		return value, true
+	case "-":
+		return -value, true
+	case "~":
+		return ^value, true
+	}
+	return 0, false
+}
+
+// binaryConstant evaluates a binary operator on two constants, whose result has the given integral type.
+// Dividing by zero isn't a constant, since it throws an exception at runtime.
+func binaryConstant(bop string, result *typ.JavaType, left, right int64) (int64, bool) {
+	// Operands of shifts are promoted separately, so the left one has the type of the result
+	shiftMask := int64(31)
+	if result.Name == "long" {
+		shiftMask = 63
+	}
+
+	switch bop {
+	case "+":
+		return left + right, true
+	case "-":
+		return left - right, true
+	case "*":
+		return left * right, true
+	case "/", "%":
+		if right == 0 {
+			return 0, false
+		}
+		if bop == "/" {
+			return left / right, true
+		}
+		return left % right, true
+	case "<<":
+		return left << (right & shiftMask), true
+	case ">>":
+		return left >> (right & shiftMask), true
+	case ">>>":
+		if result.Name == "long" {
+			return int64(uint64(left) >> (right & shiftMask)), true
+		}
+		return int64(uint32(left) >> (right & shiftMask)), true
+	case "&":
+		return left & right, true
+	case "|":
+		return left | right, true
+	case "^":
+		return left ^ right, true
+	}
+	return 0, false
+}
+
+// integerLiteralValue returns the value of an integer literal, e.g. `0x1F` or `1_000L`. Hexadecimal, octal and
+// binary literals may use all the bits of the type, e.g. `0xFFFFFFFF` is -1.
+func integerLiteralValue(text string) (int64, bool) {
+	text = strings.TrimRight(strings.ReplaceAll(text, "_", ""), "lL")
+	if value, err := strconv.ParseInt(text, 0, 64); err == nil {
+		return value, true
+	}
+	if value, err := strconv.ParseUint(text, 0, 64); err == nil && text != "0" && text[0] == '0' {
+		return int64(value), true
+	}
+	return 0, false
+}
+
+// charLiteralValue returns the value of a char literal, e.g. `'a'` or `'\n'`.
+func charLiteralValue(text string) (int64, bool) {
+	value, err := strconv.Unquote(text)
+	runes := []rune(value)
+	if err != nil || len(runes) != 1 || runes[0] > 0xFFFF {
+		return 0, false
+	}
+	return int64(runes[0]), true
+}
@@ -0,0 +1,260 @@
+package typecheck
+
+import (
+	"fmt"
+	"java-mini-ls-go/javaparser"
+	"java-mini-ls-go/parse/loc"
+	"java-mini-ls-go/parse/typ"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+)
+
+// patternBinding is a variable declared by a type pattern, e.g. `s` in `o instanceof String s` or in
+// `case String s ->`.
+type patternBinding struct {
+	ident    *javaparser.IdentifierContext
+	typeType javaparser.ITypeTypeContext
+}
+
+// patternBindings returns the pattern variables that are definitely matched when a condition is true, or
+// when it's false if whenTrue is false (JLS 6.3.1). For example, `o instanceof String s && s.isEmpty()`
+// introduces `s` when it's true, and `!(o instanceof String s)` introduces it when it's false.
+func patternBindings(exprI javaparser.IExpressionContext, whenTrue bool) []patternBinding {
+	expr, ok := exprI.(*javaparser.ExpressionContext)
+	if !ok {
+		return nil
+	}
+
+	if primary, ok := expr.Primary().(*javaparser.PrimaryContext); ok && primary.Expression() != nil {
+		// `(condition)`
+		return patternBindings(primary.Expression(), whenTrue)
+	}
+	if prefix := expr.GetPrefix(); prefix != nil && prefix.GetText() == "!" {
+		return patternBindings(expr.Expression(0), !whenTrue)
+	}
+
+	bop := expr.GetBop()
+	if bop == nil {
+		return nil
+	}
+	switch bop.GetText() {
+	case "instanceof":
+		if pattern, ok := expr.Pattern().(*javaparser.PatternContext); ok && whenTrue {
+			return []patternBinding{{ident: pattern.Identifier().(*javaparser.IdentifierContext), typeType: pattern.TypeType()}}
+		}
+	case "&&":
+		if whenTrue {
+			return append(patternBindings(expr.Expression(0), true), patternBindings(expr.Expression(1), true)...)
+		}
+	case "||":
+		if !whenTrue {
+			return append(patternBindings(expr.Expression(0), false), patternBindings(expr.Expression(1), false)...)
+		}
+	}
+	return nil
+}
+
+// guardedPatternBindings returns the pattern variables of a `case` pattern in a switch, along with the ones
+// its guards introduce, e.g. `s` for `case String s && s.length() > 1`.
+func guardedPatternBindings(ctx *javaparser.GuardedPatternContext) []patternBinding {
+	var bindings []patternBinding
+	if inner, ok := ctx.GuardedPattern().(*javaparser.GuardedPatternContext); ok {
+		bindings = guardedPatternBindings(inner)
+	} else if ident, ok := ctx.Identifier().(*javaparser.IdentifierContext); ok {
+		bindings = []patternBinding{{ident: ident, typeType: ctx.TypeType()}}
+	}
+	for _, guard := range ctx.AllExpression() {
+		bindings = append(bindings, patternBindings(guard, true)...)
+	}
+	return bindings
+}
+
+// flowBindings returns the pattern variables that are in scope throughout the given part of the code
+// because of the condition it's guarded by, e.g. the right side of `o instanceof String s && s.isEmpty()`.
+func flowBindings(ctx antlr.ParserRuleContext) []patternBinding {
+	switch parent := ctx.GetParent().(type) {
+	case *javaparser.ExpressionContext:
+		if parent.GetTern() != nil {
+			// `condition ? whenTrue : whenFalse`
+			switch ctx {
+			case parent.Expression(1):
+				return patternBindings(parent.Expression(0), true)
+			case parent.Expression(2):
+				return patternBindings(parent.Expression(0), false)
+			}
+			return nil
+		}
+		if bop := parent.GetBop(); bop != nil && ctx == parent.Expression(1) {
+			switch bop.GetText() {
+			case "&&":
+				return patternBindings(parent.Expression(0), true)
+			case "||":
+				return patternBindings(parent.Expression(0), false)
+			}
+		}
+	case *javaparser.StatementContext:
+		switch {
+		case parent.IF() != nil && ctx == parent.Statement(0):
+			return patternBindings(parent.ParExpression().(*javaparser.ParExpressionContext).Expression(), true)
+		case parent.IF() != nil && ctx == parent.Statement(1):
+			return patternBindings(parent.ParExpression().(*javaparser.ParExpressionContext).Expression(), false)
+		case parent.WHILE() != nil && parent.DO() == nil && ctx == parent.Statement(0):
+			return patternBindings(parent.ParExpression().(*javaparser.ParExpressionContext).Expression(), true)
+		case parent.FOR() != nil && ctx == parent.Statement(0):
+			if forControl, ok := parent.ForControl().(*javaparser.ForControlContext); ok && forControl.Expression() != nil {
+				return patternBindings(forControl.Expression(), true)
+			}
+		}
+	case *javaparser.GuardedPatternContext:
+		// The guards of a `case` pattern
+		if _, ok := ctx.(*javaparser.ExpressionContext); ok {
+			if ident, ok := parent.Identifier().(*javaparser.IdentifierContext); ok {
+				return []patternBinding{{ident: ident, typeType: parent.TypeType()}}
+			}
+			if inner, ok := parent.GuardedPattern().(*javaparser.GuardedPatternContext); ok {
+				return guardedPatternBindings(inner)
+			}
+		}
+	case *javaparser.SwitchLabeledRuleContext:
+		// What a `case` pattern leads to
+		if guarded, ok := parent.GuardedPattern().(*javaparser.GuardedPatternContext); ok && ctx == parent.SwitchRuleOutcome() {
+			return guardedPatternBindings(guarded)
+		}
+	}
+
+	// The statements after `case String s:`
+	if group, ok := ctx.(*javaparser.SwitchBlockStatementGroupContext); ok {
+		var bindings []patternBinding
+		for _, labelI := range group.AllSwitchLabel() {
+			label := labelI.(*javaparser.SwitchLabelContext)
+			if ident, ok := label.GetVarName().(*javaparser.IdentifierContext); ok {
+				bindings = append(bindings, patternBinding{ident: ident, typeType: label.TypeType()})
+			}
+		}
+		return bindings
+	}
+	return nil
+}
+
+// enterFlowScope starts a scope for the pattern variables in scope throughout the given part of the code, if
+// there are any.
+func (tc *typeChecker) enterFlowScope(ctx antlr.ParserRuleContext) {
+	bindings := flowBindings(ctx)
+	if len(bindings) == 0 {
+		return
+	}
+	tc.pushFlowScope(ctx, bindings, loc.ParserRuleContextToBounds(ctx))
+}
+
+func (tc *typeChecker) pushFlowScope(ctx antlr.ParserRuleContext, bindings []patternBinding, bounds loc.Bounds) {
+	locals := make([]*typ.JavaLocal, len(bindings))
+	for i, binding := range bindings {
+		locals[i] = tc.patternLocal(binding)
+	}
+
+	scope := tc.pushLocalScope(ctx, bounds)
+	for _, local := range locals {
+		scope.addLocal(local)
+	}
+}
+
+// checkFlowAfterStatement brings the pattern variables of an `if` statement's condition into scope for the
+// rest of the block it's in when they're matched whenever the statement completes, e.g. `s` after
+// `if (!(o instanceof String s)) return;`.
+func (tc *typeChecker) checkFlowAfterStatement(ctx *javaparser.StatementContext) {
+	if ctx.IF() == nil || ctx.ELSE() != nil || canCompleteNormally(ctx.Statement(0)) {
+		return
+	}
+	bindings := patternBindings(ctx.ParExpression().(*javaparser.ParExpressionContext).Expression(), false)
+	if len(bindings) == 0 {
+		return
+	}
+
+	blockStatement, ok := ctx.GetParent().(*javaparser.BlockStatementContext)
+	if !ok {
+		return
+	}
+	// The block, or whatever else the statements are in, e.g. a `case`
+	container := blockStatement.GetParent().(antlr.ParserRuleContext)
+	bounds := loc.Bounds{
+		Start: loc.ParserRuleContextToBounds(ctx).End,
+		End:   loc.ParserRuleContextToBounds(container).End,
+	}
+	tc.pushFlowScope(container, bindings, bounds)
+}
+
+// canCompleteNormally says whether execution could continue after a statement, rather than always
+// returning, throwing or jumping somewhere else (JLS 14.22). This is only approximate: loops and switches
+// are assumed to complete.
+func canCompleteNormally(stmtI javaparser.IStatementContext) bool {
+	stmt, ok := stmtI.(*javaparser.StatementContext)
+	if !ok {
+		return true
+	}
+
+	switch {
+	case stmt.RETURN() != nil, stmt.THROW() != nil, stmt.BREAK() != nil, stmt.CONTINUE() != nil, stmt.YIELD() != nil:
+		return false
+	case stmt.IF() != nil:
+		return stmt.ELSE() == nil || canCompleteNormally(stmt.Statement(0)) || canCompleteNormally(stmt.Statement(1))
+	case stmt.GetBlockLabel() != nil:
+		blockStatements := stmt.GetBlockLabel().(*javaparser.BlockContext).AllBlockStatement()
+		if len(blockStatements) == 0 {
+			return true
+		}
+		last := blockStatements[len(blockStatements)-1].(*javaparser.BlockStatementContext)
+		return canCompleteNormally(last.Statement())
+	}
+	return true
+}
+
+// patternLocal returns the local variable declared by a type pattern, declaring it if it hasn't been yet.
+func (tc *typeChecker) patternLocal(binding patternBinding) *typ.JavaLocal {
+	if local, ok := tc.patternLocals[binding.ident]; ok {
+		return local
+	}
+
+	// Pattern variables in field initializers belong to the class, like the parameters of lambdas there
+	var parent typ.JavaSymbol = tc.getEnclosingType()
+	if symbol := tc.currentScope.Symbol; symbol != nil && isMethodOrConstructor(symbol) {
+		parent = symbol
+	}
+
+	location := tc.makeCodeLocation(loc.ParserRuleContextToBounds(binding.ident))
+	local := typ.NewJavaLocal(binding.ident.GetText(), tc.lookupOrCreateType(binding.typeType.GetText()), parent, location)
+	tc.defUsages.Add(location, local, false)
+	tc.patternLocals[binding.ident] = local
+	return local
+}
+
+// e.g. `o instanceof String` or `o instanceof String s`
+func (tc *typeChecker) handleInstanceof(ctx *javaparser.ExpressionContext) {
+	bounds := loc.ParserRuleContextToBounds(ctx)
+	operand := tc.expressionStack.Pop()
+
+	typeType := ctx.TypeType()
+	if pattern, ok := ctx.Pattern().(*javaparser.PatternContext); ok {
+		typeType = pattern.TypeType()
+		tc.patternLocal(patternBinding{ident: pattern.Identifier().(*javaparser.IdentifierContext), typeType: typeType})
+	}
+
+	if operand.ttype == nil || typeType == nil {
+		tc.pushExprTypeName("boolean", bounds)
+		return
+	}
+
+	// Primitives can't be tested at all
+	tested := tc.lookupOrCreateType(typeType.GetText())
+	if operand.ttype.Type == typ.JavaTypePrimitive || !operand.ttype.CastsTo(tested) {
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     fmt.Sprintf("Incompatible conditional operand types %s and %s", operand.ttype.ShortName(), tested.ShortName()),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+
+	tc.pushExprTypeName("boolean", bounds)
+}
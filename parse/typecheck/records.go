@@ -0,0 +1,544 @@
+package typecheck
+
+import (
+	"fmt"
+	"java-mini-ls-go/javaparser"
+	"java-mini-ls-go/parse/loc"
+	"java-mini-ls-go/parse/typ"
+	"strings"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+)
+
+// recordComponents returns the components declared in the header of a record, e.g. `int x` and `int y` in
+// `record Point(int x, int y)`.
+func recordComponents(ctx *javaparser.RecordDeclarationContext) []*javaparser.RecordComponentContext {
+	header, ok := ctx.RecordHeader().(*javaparser.RecordHeaderContext)
+	if !ok {
+		return nil
+	}
+	componentList, ok := header.RecordComponentList().(*javaparser.RecordComponentListContext)
+	if !ok {
+		return nil
+	}
+
+	components := []*javaparser.RecordComponentContext{}
+	for _, componentI := range componentList.AllRecordComponent() {
+		components = append(components, componentI.(*javaparser.RecordComponentContext))
+	}
+	return components
+}
+
+// addRecordComponents adds the private final field that each component of a record is stored in, and makes
+// the record extend java.lang.Record and implement the interfaces it says it does.
+func (tg *typeGatherer) addRecordComponents(ctx *javaparser.RecordDeclarationContext) {
+	recordType := tg.ownedType(tg.resolver.currentType())
+	if recordType == nil {
+		return
+	}
+
+	recordType.Extends = []*typ.JavaType{tg.lookupType("java.lang.Record")}
+	recordType.Implements = tg.getImplementsTypes(ctx)
+
+	for _, component := range recordComponents(ctx) {
+		location := tg.makeCodeLocation(loc.ParserRuleContextToBounds(component.Identifier()))
+		field := &typ.JavaField{
+			Name:           component.Identifier().GetText(),
+			Type:           tg.lookupType(component.TypeType().GetText()),
+			ParentType:     recordType,
+			Definition:     &location,
+			Usages:         []loc.CodeLocation{},
+			Visibility:     typ.VisibilityPrivate,
+			IsStatic:       false,
+			IsFinal:        true,
+			IsEnumConstant: false,
+			Original:       nil,
+		}
+		recordType.Fields = append(recordType.Fields, field)
+		tg.defUsages.Add(location, field, false)
+	}
+}
+
+// addImplicitRecordMembers adds the members that a record has without declaring them (JLS 8.10.3): an
+// accessor method for each component, the canonical constructor taking all the components, and equals,
+// hashCode and toString. Ones the record declares itself are kept instead.
+func (tg *typeGatherer) addImplicitRecordMembers(ctx *javaparser.RecordDeclarationContext) {
+	recordType := tg.ownedType(tg.resolver.currentType())
+	if recordType == nil {
+		return
+	}
+
+	components := recordComponents(ctx)
+	params := tg.componentParams(components)
+	for i, component := range components {
+		if declaredMethod(recordType, params[i].Name) == nil {
+			// Going to the definition of an accessor goes to the component
+			location := tg.makeCodeLocation(loc.ParserRuleContextToBounds(component.Identifier()))
+			recordType.Methods = append(recordType.Methods, implicitMethod(recordType, params[i].Name, params[i].Type, &location))
+		}
+	}
+
+	if canonicalConstructor(recordType, components) == nil {
+		location := tg.makeCodeLocation(loc.ParserRuleContextToBounds(ctx.Identifier()))
+		recordType.Constructors = append(recordType.Constructors, &typ.JavaConstructor{
+			ParentType: recordType,
+			Params:     params,
+			Definition: &location,
+			Usages:     []loc.CodeLocation{},
+			Visibility: recordType.Visibility,
+			Original:   nil,
+		})
+	}
+
+	if declaredMethod(recordType, "equals", tg.lookupType("java.lang.Object")) == nil {
+		recordType.Methods = append(recordType.Methods, implicitMethod(recordType, "equals", tg.lookupType("boolean"), nil, tg.lookupType("java.lang.Object")))
+	}
+	if declaredMethod(recordType, "hashCode") == nil {
+		recordType.Methods = append(recordType.Methods, implicitMethod(recordType, "hashCode", tg.lookupType("int"), nil))
+	}
+	if declaredMethod(recordType, "toString") == nil {
+		recordType.Methods = append(recordType.Methods, implicitMethod(recordType, "toString", tg.lookupType("java.lang.String"), nil))
+	}
+}
+
+// componentParams returns the parameters of the canonical constructor of a record, one for each component.
+func (tg *typeGatherer) componentParams(components []*javaparser.RecordComponentContext) []*typ.JavaParameter {
+	params := make([]*typ.JavaParameter, len(components))
+	for i, component := range components {
+		params[i] = &typ.JavaParameter{
+			Name:      component.Identifier().GetText(),
+			Type:      tg.lookupType(component.TypeType().GetText()),
+			IsVarargs: false,
+		}
+	}
+	return params
+}
+
+// addCompactConstructor adds the canonical constructor of a record declared in its compact form, e.g.
+// `Point { ... }`, which implicitly takes each of the components.
+func (tg *typeGatherer) addCompactConstructor(ctx *javaparser.CompactConstructorDeclarationContext) {
+	recordType := tg.ownedType(tg.resolver.currentType())
+	recordCtx, ok := ctx.GetParent().GetParent().(*javaparser.RecordDeclarationContext)
+	if recordType == nil || !ok {
+		return
+	}
+
+	location := tg.makeCodeLocation(loc.ParserRuleContextToBounds(ctx.Identifier()))
+	constructor := &typ.JavaConstructor{
+		ParentType: recordType,
+		Params:     tg.componentParams(recordComponents(recordCtx)),
+		Definition: &location,
+		Usages:     []loc.CodeLocation{},
+		Visibility: compactConstructorModifiers(ctx).visibility,
+		Original:   nil,
+	}
+	recordType.Constructors = append(recordType.Constructors, constructor)
+	tg.defUsages.Add(location, constructor, false)
+}
+
+// implicitMethod creates a public instance method that a type has without declaring it, like the accessors of
+// a record.
+func implicitMethod(ttype *typ.JavaType, name string, returnType *typ.JavaType, definition *loc.CodeLocation, paramTypes ...*typ.JavaType) *typ.JavaMethod {
+	params := make([]*typ.JavaParameter, len(paramTypes))
+	for i, paramType := range paramTypes {
+		params[i] = &typ.JavaParameter{
+			Name:      fmt.Sprintf("arg%d", i),
+			Type:      paramType,
+			IsVarargs: false,
+		}
+	}
+
+	return &typ.JavaMethod{
+		Name:       name,
+		ParentType: ttype,
+		ReturnType: returnType,
+		Params:     params,
+		Definition: definition,
+		Usages:     []loc.CodeLocation{},
+		Visibility: typ.VisibilityPublic,
+		IsStatic:   false,
+		IsAbstract: false,
+		TypeParams: nil,
+		Original:   nil,
+	}
+}
+
+// declaredMethod returns the method a type itself declares with the given name and parameter types, or nil if
+// there isn't one.
+func declaredMethod(ttype *typ.JavaType, name string, paramTypes ...*typ.JavaType) *typ.JavaMethod {
+	for _, method := range ttype.Methods {
+		if method.Name == name && !method.IsStatic && hasParamTypes(method.Params, paramTypes) {
+			return method
+		}
+	}
+	return nil
+}
+
+// canonicalConstructor returns the constructor of a record that takes each of its components in order, or
+// nil if there isn't one yet.
+func canonicalConstructor(recordType *typ.JavaType, components []*javaparser.RecordComponentContext) *typ.JavaConstructor {
+	for _, constructor := range recordType.Constructors {
+		if isCanonicalConstructor(recordType, constructor, components) {
+			return constructor
+		}
+	}
+	return nil
+}
+
+// isCanonicalConstructor says whether a constructor of a record takes each of its components in order.
+func isCanonicalConstructor(recordType *typ.JavaType, constructor *typ.JavaConstructor, components []*javaparser.RecordComponentContext) bool {
+	if len(constructor.Params) != len(components) {
+		return false
+	}
+	for i, component := range components {
+		field, ok := recordType.LookupMember(component.Identifier().GetText()).(*typ.JavaField)
+		if !ok || !hasParamTypes(constructor.Params[i:i+1], []*typ.JavaType{field.Type}) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasParamTypes(params []*typ.JavaParameter, paramTypes []*typ.JavaType) bool {
+	if len(params) != len(paramTypes) {
+		return false
+	}
+	for i, param := range params {
+		if param.Type == nil || paramTypes[i] == nil || !param.Type.Erasure().IsSameType(paramTypes[i].Erasure()) {
+			return false
+		}
+	}
+	return true
+}
+
+// enclosingRecord returns the record whose body we're directly inside of, along with its declaration, or nil
+// if we're not directly inside of one.
+func (tc *typeChecker) enclosingRecord(ctx antlr.ParserRuleContext) (*typ.JavaType, *javaparser.RecordDeclarationContext) {
+	enclosing := tc.getEnclosingType()
+	if enclosing == nil || enclosing.Type != typ.JavaTypeRecord {
+		return nil, nil
+	}
+
+	for parent := ctx.GetParent(); parent != nil; parent = parent.GetParent() {
+		switch parent := parent.(type) {
+		case *javaparser.RecordBodyContext:
+			return enclosing, parent.GetParent().(*javaparser.RecordDeclarationContext)
+		case *javaparser.ClassBodyDeclarationContext, *javaparser.MemberDeclarationContext,
+			*javaparser.GenericConstructorDeclarationContext, *javaparser.GenericMethodDeclarationContext:
+			continue
+		}
+		return nil, nil
+	}
+	return nil, nil
+}
+
+// checkRecordField checks that a field declared in the body of a record is static, since all of a record's
+// instance fields are its components.
+func (tc *typeChecker) checkRecordField(ctx *javaparser.FieldDeclarationContext) {
+	if recordType, _ := tc.enclosingRecord(ctx); recordType == nil || tc.memberModifiers.Top().isStatic {
+		return
+	}
+
+	varDecls := ctx.VariableDeclarators().(*javaparser.VariableDeclaratorsContext).AllVariableDeclarator()
+	for _, varDeclI := range varDecls {
+		declaratorID := varDeclI.(*javaparser.VariableDeclaratorContext).VariableDeclaratorId()
+		ident := declaratorID.(*javaparser.VariableDeclaratorIdContext).Identifier()
+		tc.addError(TypeError{
+			Loc:         loc.ParserRuleContextToBounds(ident),
+			Message:     fmt.Sprintf("User declared non-static fields %s are not permitted in a record", ident.GetText()),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+}
+
+// checkRecordConstructor checks a constructor declared in the body of a record (JLS 8.10.4). The canonical
+// one, which takes each of the components, has to use their names, can't throw checked exceptions, can't be
+// less visible than the record, and has to initialize each of the fields the components are stored in. Any
+// other constructor has to start by calling another one of the record's constructors.
+func (tc *typeChecker) checkRecordConstructor(ctx *javaparser.ConstructorDeclarationContext) {
+	recordType, recordCtx := tc.enclosingRecord(ctx)
+	constructor, ok := tc.currentScope.Symbol.(*typ.JavaConstructor)
+	if recordType == nil || !ok {
+		return
+	}
+	nameBounds := loc.ParserRuleContextToBounds(ctx.Identifier())
+
+	components := recordComponents(recordCtx)
+	if !isCanonicalConstructor(recordType, constructor, components) {
+		if !startsWithThisCall(ctx) {
+			tc.addError(TypeError{
+				Loc:         nameBounds,
+				Message:     "A non-canonical constructor must start with an explicit invocation to a constructor",
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+		}
+		return
+	}
+
+	name := fmt.Sprintf("%s(%s)", recordType.Name, strings.Join(typeNames(constructor.Params), ", "))
+	if ctx.THROWS() != nil {
+		tc.addError(TypeError{
+			Loc:         loc.ParserRuleContextToBounds(ctx.QualifiedNameList()),
+			Message:     fmt.Sprintf("Throws clause not allowed for canonical constructor %s", name),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+	if accessRank(constructor.Visibility) < accessRank(recordType.Visibility) {
+		tc.addError(TypeError{
+			Loc:         nameBounds,
+			Message:     fmt.Sprintf("Cannot reduce the visibility of a canonical constructor %s from that of the record", name),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+
+	paramIdents := constructorParamIdents(ctx)
+	for i, component := range components {
+		if i < len(paramIdents) && paramIdents[i].GetText() != component.Identifier().GetText() {
+			tc.addError(TypeError{
+				Loc:         loc.ParserRuleContextToBounds(paramIdents[i]),
+				Message:     fmt.Sprintf("Illegal parameter name %s in canonical constructor, expected %s, the corresponding component name", paramIdents[i].GetText(), component.Identifier().GetText()),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+		}
+	}
+
+	assigned := map[string][]*javaparser.ExpressionContext{}
+	findFieldAssignments(ctx.GetConstructorBody(), assigned)
+	for _, component := range components {
+		if name := component.Identifier().GetText(); len(assigned[name]) == 0 {
+			tc.addError(TypeError{
+				Loc:         nameBounds,
+				Message:     fmt.Sprintf("The blank final field %s may not have been initialized", name),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+		}
+	}
+}
+
+// checkCompactConstructor checks the compact canonical constructor of a record, e.g. `Point { ... }` (JLS
+// 8.10.4.2). Like the canonical constructor, it can't be less visible than the record. The fields the
+// components are stored in are assigned once its body is done, so the body can't assign them itself.
+// Calls to other constructors and `return` statements in the body are checked where they are.
+func (tc *typeChecker) checkCompactConstructor(ctx *javaparser.CompactConstructorDeclarationContext) {
+	recordType, recordCtx := tc.enclosingRecord(ctx)
+	constructor, ok := tc.currentScope.Symbol.(*typ.JavaConstructor)
+	if recordType == nil || !ok {
+		return
+	}
+
+	if accessRank(constructor.Visibility) < accessRank(recordType.Visibility) {
+		name := fmt.Sprintf("%s(%s)", recordType.Name, strings.Join(typeNames(constructor.Params), ", "))
+		tc.addError(TypeError{
+			Loc:         loc.ParserRuleContextToBounds(ctx.Identifier()),
+			Message:     fmt.Sprintf("Cannot reduce the visibility of a canonical constructor %s from that of the record", name),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+
+	assigned := map[string][]*javaparser.ExpressionContext{}
+	findFieldAssignments(ctx.GetConstructorBody(), assigned)
+	for _, component := range recordComponents(recordCtx) {
+		name := component.Identifier().GetText()
+		for _, field := range assigned[name] {
+			tc.addError(TypeError{
+				Loc:         loc.ParserRuleContextToBounds(field),
+				Message:     fmt.Sprintf("Illegal explicit assignment of a final field %s in compact constructor", name),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+		}
+	}
+}
+
+// checkCompactConstructorReturn checks that a statement isn't a `return` from the compact canonical
+// constructor of a record, which would skip assigning the fields.
+func (tc *typeChecker) checkCompactConstructorReturn(ctx *javaparser.StatementContext) {
+	if ctx.RETURN() == nil || !inCompactConstructor(ctx) {
+		return
+	}
+	tc.addError(TypeError{
+		Loc:         loc.ParserRuleContextToBounds(ctx),
+		Message:     "The body of a compact constructor must not contain a return statement",
+		Related:     nil,
+		Severity:    SeverityError,
+		Unnecessary: false,
+		Fix:         nil,
+	})
+}
+
+// inCompactConstructor says whether some code is in the body of the compact canonical constructor of a
+// record, other than in lambdas and classes declared there.
+func inCompactConstructor(tree antlr.Tree) bool {
+	for parent := tree.GetParent(); parent != nil; parent = parent.GetParent() {
+		switch parent.(type) {
+		case *javaparser.CompactConstructorDeclarationContext:
+			return true
+		case *javaparser.LambdaExpressionContext, *javaparser.ClassBodyContext:
+			return false
+		}
+	}
+	return false
+}
+
+// checkRecordAccessor checks a method declared in the body of a record that's the accessor of one of its
+// components, which has to be public and return the component's type.
+func (tc *typeChecker) checkRecordAccessor(ctx *javaparser.MethodDeclarationContext) {
+	recordType, _ := tc.enclosingRecord(ctx)
+	method, ok := tc.currentScope.Symbol.(*typ.JavaMethod)
+	if recordType == nil || !ok || method.IsStatic || len(method.Params) > 0 {
+		return
+	}
+	field, ok := recordType.LookupMember(method.Name).(*typ.JavaField)
+	if !ok || field.ParentType != recordType || field.IsStatic {
+		return
+	}
+
+	if method.Visibility != typ.VisibilityPublic {
+		tc.addError(TypeError{
+			Loc:         loc.ParserRuleContextToBounds(ctx.Identifier()),
+			Message:     "The accessor method must be declared public",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+	if method.ReturnType == nil || field.Type == nil || !method.ReturnType.IsSameType(field.Type) {
+		tc.addError(TypeError{
+			Loc:         loc.ParserRuleContextToBounds(ctx.TypeTypeOrVoid()),
+			Message:     fmt.Sprintf("Illegal return type of accessor; should be the same as the declared type %s of the record component", typeNameOrUnknown(field.Type)),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+	if ctx.THROWS() != nil {
+		tc.addError(TypeError{
+			Loc:         loc.ParserRuleContextToBounds(ctx.QualifiedNameList()),
+			Message:     "Throws clause not allowed for explicitly declared accessor method",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+}
+
+// startsWithThisCall says whether the body of a constructor starts by calling another constructor of the same
+// class, e.g. `this(x, 0);`
+func startsWithThisCall(ctx *javaparser.ConstructorDeclarationContext) bool {
+	block, ok := ctx.GetConstructorBody().(*javaparser.BlockContext)
+	if !ok || len(block.AllBlockStatement()) == 0 {
+		return false
+	}
+	statement, ok := block.BlockStatement(0).(*javaparser.BlockStatementContext).Statement().(*javaparser.StatementContext)
+	if !ok {
+		return false
+	}
+	expression, ok := statement.GetStatementExpression().(*javaparser.ExpressionContext)
+	if !ok {
+		return false
+	}
+	methodCall, ok := expression.MethodCall().(*javaparser.MethodCallContext)
+	return ok && methodCall.THIS() != nil
+}
+
+// constructorParamIdents returns the names of a constructor's parameters, as they're declared.
+func constructorParamIdents(ctx *javaparser.ConstructorDeclarationContext) []javaparser.IIdentifierContext {
+	paramList, ok := ctx.FormalParameters().(*javaparser.FormalParametersContext).FormalParameterList().(*javaparser.FormalParameterListContext)
+	if !ok {
+		return nil
+	}
+
+	idents := []javaparser.IIdentifierContext{}
+	for _, paramI := range paramList.AllFormalParameter() {
+		declaratorID := paramI.(*javaparser.FormalParameterContext).VariableDeclaratorId()
+		idents = append(idents, declaratorID.(*javaparser.VariableDeclaratorIdContext).Identifier())
+	}
+	if lastParam, ok := paramList.LastFormalParameter().(*javaparser.LastFormalParameterContext); ok {
+		idents = append(idents, lastParam.VariableDeclaratorId().(*javaparser.VariableDeclaratorIdContext).Identifier())
+	}
+	return idents
+}
+
+// findFieldAssignments finds the fields assigned through `this`, e.g. `x` in `this.x = x;`, inside of some
+// code, other than in lambdas and classes declared there, which run some other time. Each field's name is
+// mapped to the `this.x` expressions it's assigned through.
+func findFieldAssignments(tree antlr.Tree, assigned map[string][]*javaparser.ExpressionContext) {
+	switch node := tree.(type) {
+	case *javaparser.LambdaExpressionContext, *javaparser.ClassBodyContext:
+		return
+	case *javaparser.ExpressionContext:
+		if bop := node.GetBop(); bop != nil && bop.GetText() == "=" {
+			if name, ok := thisFieldName(node.Expression(0)); ok {
+				assigned[name] = append(assigned[name], node.Expression(0).(*javaparser.ExpressionContext))
+			}
+		}
+	}
+
+	for _, child := range tree.GetChildren() {
+		findFieldAssignments(child, assigned)
+	}
+}
+
+// thisFieldName returns the name of the field that an expression like `this.x` refers to.
+func thisFieldName(exprI javaparser.IExpressionContext) (string, bool) {
+	expr, ok := exprI.(*javaparser.ExpressionContext)
+	if !ok || expr.GetDotop() == nil || expr.Identifier() == nil {
+		return "", false
+	}
+	left, ok := expr.Expression(0).(*javaparser.ExpressionContext)
+	if !ok {
+		return "", false
+	}
+	primary, ok := left.Primary().(*javaparser.PrimaryContext)
+	if !ok || primary.THIS() == nil {
+		return "", false
+	}
+	return expr.Identifier().GetText(), true
+}
+
+// accessRank orders visibilities from the least to the most accessible.
+func accessRank(visibility typ.VisibilityType) int {
+	switch visibility {
+	case typ.VisibilityPrivate:
+		return 0
+	case typ.VisibilityProtected:
+		return 2
+	case typ.VisibilityPublic:
+		return 3
+	}
+	return 1
+}
+
+func typeNames(params []*typ.JavaParameter) []string {
+	names := make([]string, len(params))
+	for i, param := range params {
+		names[i] = typeNameOrUnknown(param.Type)
+	}
+	return names
+}
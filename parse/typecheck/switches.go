@@ -0,0 +1,447 @@
+package typecheck
+
+import (
+	"fmt"
+	"java-mini-ls-go/javaparser"
+	"java-mini-ls-go/parse/loc"
+	"java-mini-ls-go/parse/typ"
+	"java-mini-ls-go/util"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+)
+
+// switchContext is what's known about a switch statement or expression while its cases are being checked.
+type switchContext struct {
+	// The switch statement, or the switch expression
+	ctx antlr.ParserRuleContext
+	// The type of the value being switched on. Nil until it's been evaluated.
+	selector *typ.JavaType
+	// Whether it's a switch expression whose value is used, rather than a statement
+	isExpression bool
+	// What each case of a switch expression evaluates to, either after `->` or from `yield`
+	values []typedExpression
+	// Whether every value is matched by some case, because there's a `default` case or a pattern that
+	// matches anything the selector could be
+	exhaustive bool
+	// The names of the enum constants there are cases for
+	enumConstants *util.Set[string]
+}
+
+// Types other than these (and their boxed versions) can only be switched on with patterns
+var switchablePrimitives = util.SetFromValues("char", "byte", "short", "int")
+
+func (tc *typeChecker) EnterStatement(ctx *javaparser.StatementContext) {
+	if ctx.SWITCH() != nil {
+		tc.enterSwitch(ctx, false)
+	}
+}
+
+func (tc *typeChecker) EnterSwitchExpression(ctx *javaparser.SwitchExpressionContext) {
+	// The cases are checked on their own, like the body of a lambda
+	tc.outerExpressionStacks.Push(tc.expressionStack)
+	tc.expressionStack = util.NewStack[typedExpression]()
+
+	_, isExpression := ctx.GetParent().(*javaparser.ExpressionContext)
+	tc.enterSwitch(ctx, isExpression)
+}
+
+func (tc *typeChecker) enterSwitch(ctx antlr.ParserRuleContext, isExpression bool) {
+	tc.switches.Push(&switchContext{
+		ctx:           ctx,
+		selector:      nil,
+		isExpression:  isExpression,
+		values:        nil,
+		exhaustive:    false,
+		enumConstants: util.NewSet[string](),
+	})
+}
+
+func (tc *typeChecker) ExitSwitchExpression(ctx *javaparser.SwitchExpressionContext) {
+	sc := tc.switches.Pop()
+	tc.expressionStack = tc.outerExpressionStacks.Pop()
+	if !sc.isExpression {
+		return
+	}
+
+	bounds := loc.ParserRuleContextToBounds(ctx)
+	if !sc.exhaustive && !tc.coversAllEnumConstants(sc) {
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     "A switch expression should have a default case",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+
+	tc.pushExprType(tc.switchExpressionType(sc, ctx.GetParent()), bounds)
+}
+
+// ExitParExpression takes the condition of an `if`, `while` or `do` statement off the expression stack, so
+// that the statements inside of it don't see it, or the value a switch switches on.
+func (tc *typeChecker) ExitParExpression(ctx *javaparser.ParExpressionContext) {
+	switch parent := ctx.GetParent().(type) {
+	case *javaparser.StatementContext:
+		switch {
+		case parent.IF() != nil, parent.WHILE() != nil:
+			tc.checkCondition(tc.expressionStack.Pop())
+			return
+		case parent.SYNCHRONIZED() != nil:
+			tc.expressionStack.Pop()
+			return
+		case parent.SWITCH() == nil:
+			return
+		}
+	case *javaparser.SwitchExpressionContext:
+	default:
+		return
+	}
+
+	sc := tc.switches.Top()
+	selector := tc.expressionStack.Pop()
+	sc.selector = selector.ttype
+	if sc.selector == nil || sc.selector.Type != typ.JavaTypePrimitive || switchablePrimitives.Contains(sc.selector.Name) {
+		return
+	}
+
+	tc.addError(TypeError{
+		Loc:         selector.loc,
+		Message:     fmt.Sprintf("Cannot switch on a value of type %s. Only convertible int values, strings or enum variables are permitted", sc.selector.Name),
+		Related:     nil,
+		Severity:    SeverityError,
+		Unnecessary: false,
+		Fix:         nil,
+	})
+	sc.selector = tc.lookupOrCreateType(typ.TypeNameLSPAny)
+}
+
+// ExitSwitchLabel checks a `case` of a switch statement, e.g. `case 1:` or `case String s:`
+func (tc *typeChecker) ExitSwitchLabel(ctx *javaparser.SwitchLabelContext) {
+	sc := tc.switches.Top()
+	switch {
+	case ctx.DEFAULT() != nil:
+		sc.exhaustive = true
+	case ctx.GetConstantExpression() != nil:
+		tc.checkCaseConstant(sc, tc.expressionStack.Pop())
+	case ctx.GetEnumConstantName() != nil:
+		name := ctx.GetEnumConstantName()
+		tc.checkCaseConstant(sc, tc.enumCaseConstant(sc, name.GetText(), loc.TokenToBounds(name)))
+	case ctx.GetVarName() != nil:
+		ident := ctx.GetVarName().(*javaparser.IdentifierContext)
+		tc.checkCasePattern(sc, patternBinding{ident: ident, typeType: ctx.TypeType()}, false)
+	}
+}
+
+// EnterSwitchRuleOutcome checks the `case` of a switch expression (or of a switch statement using `->`)
+// before what it leads to, e.g. `case 1, 2` in `case 1, 2 -> "small";`.
+func (tc *typeChecker) EnterSwitchRuleOutcome(ctx *javaparser.SwitchRuleOutcomeContext) {
+	rule, ok := ctx.GetParent().(*javaparser.SwitchLabeledRuleContext)
+	if !ok {
+		return
+	}
+
+	sc := tc.switches.Top()
+	switch {
+	case rule.DEFAULT() != nil:
+		sc.exhaustive = true
+	case rule.NULL_LITERAL() != nil:
+		if sc.selector != nil && sc.selector.Type == typ.JavaTypePrimitive {
+			tc.addError(TypeError{
+				Loc:         loc.TokenToBounds(rule.NULL_LITERAL().GetSymbol()),
+				Message:     fmt.Sprintf("Type mismatch: cannot convert from null to %s", sc.selector.Name),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+		}
+	case rule.ExpressionList() != nil:
+		// The constants were evaluated in order, so they come off the stack backwards
+		constants := make([]typedExpression, len(rule.ExpressionList().(*javaparser.ExpressionListContext).AllExpression()))
+		for i := len(constants) - 1; i >= 0; i-- {
+			constants[i] = tc.expressionStack.Pop()
+		}
+		for _, constant := range constants {
+			tc.checkCaseConstant(sc, constant)
+		}
+	case rule.GuardedPattern() != nil:
+		guarded := rule.GuardedPattern().(*javaparser.GuardedPatternContext)
+		guards := make([]typedExpression, len(guardExpressions(guarded)))
+		for i := len(guards) - 1; i >= 0; i-- {
+			guards[i] = tc.expressionStack.Pop()
+		}
+		for _, guard := range guards {
+			tc.checkCondition(guard)
+		}
+
+		pattern := innermostGuardedPattern(guarded)
+		if ident, ok := pattern.Identifier().(*javaparser.IdentifierContext); ok {
+			tc.checkCasePattern(sc, patternBinding{ident: ident, typeType: pattern.TypeType()}, len(guards) > 0)
+		}
+	}
+}
+
+// checkCondition checks that a condition, like that of an `if` statement, is a boolean.
+func (tc *typeChecker) checkCondition(condition typedExpression) {
+	if condition.ttype == nil || condition.ttype.CoercesTo(tc.lookupType("boolean")) {
+		return
+	}
+	tc.addError(TypeError{
+		Loc:         condition.loc,
+		Message:     fmt.Sprintf("Type mismatch: cannot convert from %s to boolean", condition.ttype.ShortName()),
+		Related:     nil,
+		Severity:    SeverityError,
+		Unnecessary: false,
+		Fix:         nil,
+	})
+}
+
+// guardExpressions returns the conditions a `case` pattern is guarded by, e.g. `s.isEmpty()` in
+// `case String s && s.isEmpty()`, in the order they're evaluated.
+func guardExpressions(ctx *javaparser.GuardedPatternContext) []javaparser.IExpressionContext {
+	var guards []javaparser.IExpressionContext
+	if inner, ok := ctx.GuardedPattern().(*javaparser.GuardedPatternContext); ok {
+		guards = guardExpressions(inner)
+	}
+	return append(guards, ctx.AllExpression()...)
+}
+
+// innermostGuardedPattern returns the part of a guarded `case` pattern that declares the pattern variable.
+func innermostGuardedPattern(ctx *javaparser.GuardedPatternContext) *javaparser.GuardedPatternContext {
+	for {
+		inner, ok := ctx.GuardedPattern().(*javaparser.GuardedPatternContext)
+		if !ok {
+			return ctx
+		}
+		ctx = inner
+	}
+}
+
+// checkCaseConstant checks that a constant a `case` matches could be the value being switched on.
+func (tc *typeChecker) checkCaseConstant(sc *switchContext, constant typedExpression) {
+	if sc.selector == nil || constant.ttype == nil || constant.ttype.CoercesTo(sc.selector) {
+		return
+	}
+	// Integer constants fit into narrower types if they're in range, e.g. `case 1` for a `byte`
+	if assertIsIntegral(constant.ttype.Unboxed()) && assertIsIntegral(sc.selector.Unboxed()) {
+		return
+	}
+
+	tc.addError(TypeError{
+		Loc:         constant.loc,
+		Message:     fmt.Sprintf("Type mismatch: cannot convert from %s to %s", constant.ttype.ShortName(), sc.selector.ShortName()),
+		Related:     nil,
+		Severity:    SeverityError,
+		Unnecessary: false,
+		Fix:         nil,
+	})
+}
+
+// checkCasePattern checks that the value being switched on could match a `case` pattern, e.g.
+// `case String s`. An unguarded pattern that anything being switched on matches makes the switch exhaustive.
+func (tc *typeChecker) checkCasePattern(sc *switchContext, binding patternBinding, isGuarded bool) {
+	local := tc.patternLocal(binding)
+	if sc.selector == nil || local.Type == nil {
+		return
+	}
+
+	if sc.selector.Type == typ.JavaTypePrimitive || !sc.selector.CastsTo(local.Type) {
+		tc.addError(TypeError{
+			Loc:         loc.ParserRuleContextToBounds(binding.typeType),
+			Message:     fmt.Sprintf("Type mismatch: cannot convert from %s to %s", sc.selector.ShortName(), local.Type.ShortName()),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+		return
+	}
+
+	if !isGuarded && sc.selector.CoercesTo(local.Type) {
+		sc.exhaustive = true
+	}
+}
+
+// isCaseConstant says whether an identifier is a constant that a `case` matches, e.g. `RED` in `case RED:`,
+// rather than part of a more complicated expression.
+func isCaseConstant(ctx *javaparser.PrimaryContext) bool {
+	expr, ok := ctx.GetParent().(*javaparser.ExpressionContext)
+	if !ok {
+		return false
+	}
+	switch parent := expr.GetParent().(type) {
+	case *javaparser.SwitchLabelContext:
+		return parent.GetConstantExpression() == expr
+	case *javaparser.ExpressionListContext:
+		_, ok := parent.GetParent().(*javaparser.SwitchLabeledRuleContext)
+		return ok
+	}
+	return false
+}
+
+// enumCaseConstant looks up a constant of the enum being switched on, which `case` labels name without
+// qualifying them, e.g. `case RED:` rather than `case Color.RED:`.
+func (tc *typeChecker) enumCaseConstant(sc *switchContext, name string, bounds loc.Bounds) typedExpression {
+	sc.enumConstants.Add(name)
+	if field, ok := sc.selector.LookupMember(name).(*typ.JavaField); ok && field.IsEnumConstant {
+		tc.defUsages.Add(tc.makeCodeLocation(bounds), field, true)
+		return typedExpression{loc: bounds, ttype: field.Type, placeholderExprType: ExprTypeUnset, constant: nil, variable: nil}
+	}
+
+	// The constants of library enums aren't known, so there's nothing to check those against
+	if sc.selector.GetOriginal().Definition != nil {
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     fmt.Sprintf("Can't find member named %s of type %s", name, sc.selector.ShortName()),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+	return typedExpression{loc: bounds, ttype: sc.selector, placeholderExprType: ExprTypeUnset, constant: nil, variable: nil}
+}
+
+// enumSwitch returns the switch whose `case` the given identifier is the constant of, if it switches on
+// an enum. Returns nil otherwise.
+func (tc *typeChecker) enumSwitch(ctx *javaparser.PrimaryContext) *switchContext {
+	if tc.switches.Empty() || !isCaseConstant(ctx) {
+		return nil
+	}
+	sc := tc.switches.Top()
+	if sc.selector == nil || sc.selector.Type != typ.JavaTypeEnum {
+		return nil
+	}
+	return sc
+}
+
+// coversAllEnumConstants says whether a switch on an enum has a case for each of its constants. If they
+// aren't known, it's assumed to.
+func (tc *typeChecker) coversAllEnumConstants(sc *switchContext) bool {
+	if sc.selector == nil || sc.selector.Type == typ.JavaTypeLSPAny {
+		return true
+	}
+	if sc.selector.Type != typ.JavaTypeEnum {
+		return false
+	}
+	for _, field := range sc.selector.GetOriginal().Fields {
+		if field.IsEnumConstant && !sc.enumConstants.Contains(field.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// recordSwitchValue remembers what a case of a switch expression evaluates to when leaving a statement that
+// gives it a value: `yield`, or the expression after `->`.
+func (tc *typeChecker) recordSwitchValue(ctx *javaparser.StatementContext) {
+	var switchExpr *javaparser.SwitchExpressionContext
+	switch {
+	case ctx.YIELD() != nil && ctx.Expression(0) != nil:
+		switchExpr = enclosingSwitchExpression(ctx)
+	case ctx.GetStatementExpression() != nil:
+		switchExpr = arrowSwitchExpression(ctx)
+	}
+	if switchExpr == nil {
+		return
+	}
+
+	for i := tc.switches.Size() - 1; i >= 0; i-- {
+		if sc := tc.switches.At(i); sc.ctx == switchExpr {
+			sc.values = append(sc.values, tc.expressionStack.Top())
+			return
+		}
+	}
+}
+
+// enclosingSwitchExpression returns the switch expression a `yield` statement gives a value to, or nil if
+// there isn't one.
+func enclosingSwitchExpression(tree antlr.Tree) *javaparser.SwitchExpressionContext {
+	for node := tree.GetParent(); node != nil; node = node.GetParent() {
+		switch ctx := node.(type) {
+		case *javaparser.SwitchExpressionContext:
+			return ctx
+		case *javaparser.LambdaExpressionContext, *javaparser.ClassBodyDeclarationContext, *javaparser.InterfaceBodyDeclarationContext:
+			return nil
+		}
+	}
+	return nil
+}
+
+// arrowSwitchExpression returns the switch expression an expression statement is the value of a case of,
+// like `"one"` in `case 1 -> "one";`, or nil if it isn't one.
+func arrowSwitchExpression(ctx *javaparser.StatementContext) *javaparser.SwitchExpressionContext {
+	blockStatement, ok := ctx.GetParent().(*javaparser.BlockStatementContext)
+	if !ok {
+		return nil
+	}
+	outcome, ok := blockStatement.GetParent().(*javaparser.SwitchRuleOutcomeContext)
+	if !ok || len(outcome.AllBlockStatement()) != 1 {
+		return nil
+	}
+	rule, ok := outcome.GetParent().(*javaparser.SwitchLabeledRuleContext)
+	if !ok || rule.ARROW() == nil {
+		return nil
+	}
+	switchExpr, _ := rule.GetParent().(*javaparser.SwitchExpressionContext)
+	return switchExpr
+}
+
+// switchExpressionType returns the type of a switch expression (JLS 15.28.1). Where a particular type is
+// expected, like in `String s = switch ...`, that's the type, and each case has to fit into it. Otherwise,
+// it's the type every case has in common.
+func (tc *typeChecker) switchExpressionType(sc *switchContext, expr antlr.Tree) *typ.JavaType {
+	var values []typedExpression
+	for _, value := range sc.values {
+		if !isVoid(value.ttype) {
+			values = append(values, value)
+		}
+	}
+
+	if expected := tc.expectedType(expr); expected != nil {
+		for _, value := range values {
+			if !tc.assignable(value, expected) {
+				tc.addError(TypeError{
+					Loc:         value.loc,
+					Message:     fmt.Sprintf("Type mismatch: cannot convert from %s to %s", value.ttype.ShortName(), expected.ShortName()),
+					Related:     nil,
+					Severity:    SeverityError,
+					Unnecessary: false,
+					Fix:         nil,
+				})
+			}
+		}
+		return expected
+	}
+
+	if len(values) == 0 {
+		return tc.lookupOrCreateType(typ.TypeNameLSPAny)
+	}
+	types := util.Map(values, func(value typedExpression) *typ.JavaType {
+		return value.ttype
+	})
+
+	sameType, allBoolean, allNumeric := true, true, true
+	for _, ttype := range types {
+		sameType = sameType && ttype.IsSameType(types[0])
+		allBoolean = allBoolean && assertIsBoolean(ttype.Unboxed())
+		allNumeric = allNumeric && assertIsNumeric(ttype.Unboxed())
+	}
+	switch {
+	case sameType:
+		return types[0]
+	case allBoolean:
+		return tc.lookupType("boolean")
+	case allNumeric:
+		numeric := types[0].Unboxed()
+		for _, ttype := range types[1:] {
+			numeric = tc.binaryNumericPromotion(numeric, ttype.Unboxed())
+		}
+		return numeric
+	}
+	if lub := typ.LeastUpperBound(util.Map(types, tc.boxed)); lub != nil {
+		return lub
+	}
+	return tc.lookupOrCreateType(typ.TypeNameLSPAny)
+}
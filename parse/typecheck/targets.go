@@ -0,0 +1,172 @@
+package typecheck
+
+import (
+	"java-mini-ls-go/javaparser"
+	"java-mini-ls-go/parse/typ"
+	"java-mini-ls-go/util"
+)
+
+// functionalTarget returns the type a lambda expression or method reference is expected to have where it
+// appears (JLS 15.27.3 and 15.13.2), or nil if there is none. If it's passed to a generic method, also
+// returns the type variables of the method that are left to be inferred from it.
+//
+// fits says whether the expression could implement the method of a functional interface. It's used to pick
+// between overloads of the method the expression is passed to.
+func (tc *typeChecker) functionalTarget(expr *javaparser.ExpressionContext, fits func(function *typ.JavaMethod) bool) (*typ.JavaType, []*typ.JavaType) {
+	if expected := tc.expectedType(expr); expected != nil {
+		return expected, nil
+	}
+
+	switch parent := expr.GetParent().(type) {
+	case *javaparser.ExpressionContext:
+		// e.g. `f = x -> x + 1`, where the left side has already been evaluated
+		if bop := parent.GetBop(); bop != nil && bop.GetText() == "=" && parent.Expression(1) == expr {
+			return tc.expressionStack.Top().ttype, nil
+		}
+		// e.g. `done ? x -> x + 1 : null`, which takes on the type the whole conditional is expected to have
+		if parent.GetTern() != nil && parent.Expression(0) != expr {
+			return tc.expectedType(parent), nil
+		}
+	case *javaparser.CastExprContext:
+		return tc.lookupType(parent.TypeType(0).GetText()), nil
+	case *javaparser.ExpressionListContext:
+		return tc.argumentTarget(parent, expr, fits)
+	}
+	return nil, nil
+}
+
+// argumentTarget returns the type of the parameter that a lambda expression or method reference is passed
+// in for in a method or constructor call, along with the type variables of a generic method that the
+// arguments before it don't determine. If there are several overloads, the first one that takes a
+// functional interface that fits is picked.
+func (tc *typeChecker) argumentTarget(exprList *javaparser.ExpressionListContext, arg *javaparser.ExpressionContext, fits func(function *typ.JavaMethod) bool) (*typ.JavaType, []*typ.JavaType) {
+	argIdx := 0
+	for i, expr := range exprList.AllExpression() {
+		if expr == arg {
+			argIdx = i
+		}
+	}
+
+	// The arguments before this one have already been evaluated, and are on top of the expression stack
+	earlierArgs := make([]*typ.JavaType, argIdx)
+	for i := range earlierArgs {
+		earlierArgs[i] = tc.boxed(tc.expressionStack.TopMinus(argIdx - 1 - i).ttype)
+	}
+
+	var fallback *typ.JavaType
+	var fallbackFreeVars []*typ.JavaType
+	for _, callable := range tc.callCandidates(exprList, argIdx) {
+		params := callable.CallableParams()
+		var param *typ.JavaType
+		switch {
+		case callable.IsVarargs && argIdx >= len(params)-1:
+			param = params[len(params)-1].ComponentType()
+		case argIdx < len(params):
+			param = params[argIdx]
+		default:
+			continue
+		}
+
+		var freeVars []*typ.JavaType
+		if len(callable.TypeParams) > 0 {
+			knownParams := params
+			if argIdx < len(params) {
+				knownParams = params[:argIdx]
+			}
+			bindings := typ.InferTypeArgs(callable.TypeParams, knownParams, earlierArgs, nil, nil)
+			for _, typeParam := range callable.TypeParams {
+				if !mentionedByAny(knownParams, typeParam) {
+					delete(bindings, typeParam)
+					freeVars = append(freeVars, typeParam)
+				}
+			}
+			param = param.Substitute(bindings)
+		}
+
+		function := param.NonWildcardParameterization().FunctionalMethod()
+		if function == nil {
+			continue
+		}
+		if fits(function) {
+			return param, freeVars
+		}
+		if fallback == nil {
+			fallback, fallbackFreeVars = param, freeVars
+		}
+	}
+	return fallback, fallbackFreeVars
+}
+
+func mentionedByAny(types []*typ.JavaType, typeVar *typ.JavaType) bool {
+	for _, ttype := range types {
+		if ttype.Mentions([]*typ.JavaType{typeVar}) {
+			return true
+		}
+	}
+	return false
+}
+
+// callCandidates returns the overloads (the special LSP method or constructor types) that a call with the
+// given arguments could be calling. argIdx is how many of the arguments have been evaluated so far.
+func (tc *typeChecker) callCandidates(exprList *javaparser.ExpressionListContext, argIdx int) []*typ.JavaType {
+	switch call := exprList.GetParent().(type) {
+	case *javaparser.MethodCallContext:
+		ident := call.Identifier()
+		if ident == nil {
+			// `this(...)` or `super(...)`
+			enclosing := tc.getEnclosingType()
+			if enclosing == nil {
+				return nil
+			}
+			if call.SUPER() != nil {
+				enclosing = tc.superclass(enclosing)
+			}
+			return constructorTypes(enclosing)
+		}
+
+		var methods []*typ.JavaMethod
+		if dotExpr, ok := call.GetParent().(*javaparser.ExpressionContext); ok && dotExpr.GetDotop() != nil {
+			// The receiver is right before the arguments on the stack
+			receiver := tc.expressionStack.TopMinus(argIdx).ttype
+			if receiver == nil {
+				return nil
+			}
+			methods = receiver.LookupMethods(ident.GetText())
+		} else {
+			methods, _ = tc.lookupMethods(ident.GetText())
+		}
+		return util.Map(methods, (*typ.JavaMethod).GetType)
+	case *javaparser.ArgumentsContext:
+		if creator, ok := call.GetParent().GetParent().(*javaparser.CreatorContext); ok && creator.CreatedName() != nil {
+			return constructorTypes(tc.lookupType(creator.CreatedName().GetText()))
+		}
+	}
+	return nil
+}
+
+func constructorTypes(ttype *typ.JavaType) []*typ.JavaType {
+	if ttype == nil {
+		return nil
+	}
+	return util.Map(ttype.LookupConstructors(), (*typ.JavaConstructor).GetType)
+}
+
+// functionalType returns the type of a lambda expression or method reference that implements the given
+// functional interface method of its target type. If the method returns one of the type variables being
+// inferred, they're inferred from what the expression returns, e.g. `Function<String, Integer>` for
+// `s -> s.length()` passed to `map` on a `Stream<String>`.
+func (tc *typeChecker) functionalType(target *typ.JavaType, function *typ.JavaMethod, freeVars []*typ.JavaType, returned []*typ.JavaType) *typ.JavaType {
+	functionalType := target.NonWildcardParameterization()
+	if !function.ReturnType.Mentions(freeVars) {
+		return functionalType
+	}
+
+	var formals, actuals []*typ.JavaType
+	for _, ttype := range returned {
+		if !isVoid(ttype) {
+			formals = append(formals, function.ReturnType)
+			actuals = append(actuals, tc.boxed(ttype))
+		}
+	}
+	return functionalType.Substitute(typ.InferTypeArgs(freeVars, formals, actuals, nil, nil))
+}
@@ -34,6 +34,20 @@ func (tcs *TypeCheckingScope) addLocal(local *typ.JavaLocal) {
 	tcs.Locals[local.Name] = local
 }
 
+// lookupEnclosingLocal returns the local by the given name that's declared in this scope or the ones it's
+// nested in, up to the innermost type, or nil if there isn't one.
+func (tcs *TypeCheckingScope) lookupEnclosingLocal(name string) *typ.JavaLocal {
+	for scope := tcs; scope != nil; scope = scope.Parent {
+		if local, ok := scope.Locals[name]; ok {
+			return local
+		}
+		if _, ok := scope.Symbol.(*typ.JavaType); ok {
+			break
+		}
+	}
+	return nil
+}
+
 func (tcs *TypeCheckingScope) Contains(location loc.FileLocation) bool {
 	withinLines := location.Line >= tcs.Location.Start.Line && location.Line <= tcs.Location.End.Line
 	if !withinLines {
@@ -73,6 +87,16 @@ func (tcs *TypeCheckingScope) LookupScopeFor(location loc.FileLocation) *TypeChe
 	return tcs
 }
 
+// EnclosingType returns the innermost type this scope is inside of (or is the scope of), or nil if there is none.
+func (tcs *TypeCheckingScope) EnclosingType() *typ.JavaType {
+	for scope := tcs; scope != nil; scope = scope.Parent {
+		if ttype, ok := scope.Symbol.(*typ.JavaType); ok {
+			return ttype
+		}
+	}
+	return nil
+}
+
 // AllSymbols returns a list of all symbols that are relevant to the current scope, including
 // symbols from parent scopes.
 //
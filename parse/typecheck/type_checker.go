@@ -12,8 +12,8 @@ import (
 
 	"github.com/antlr/antlr4/runtime/Go/antlr"
 	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
 	"go.uber.org/zap"
-	"golang.org/x/exp/slices"
 )
 
 type ExprType int
@@ -77,22 +77,80 @@ const (
 type TypeError struct {
 	Loc     loc.Bounds
 	Message string
+	// Related is a list of other places in the code that help explain the error, e.g. the original
+	// definition of a variable that's being redefined. Usually nil.
+	Related []RelatedLocation
+	// Severity is how serious the problem is. The zero value is an error.
+	Severity TypeErrorSeverity
+	// Unnecessary marks code that can be removed without changing anything, e.g. an unused import,
+	// so that editors can fade it out.
+	Unnecessary bool
+	// Fix is a change to the code that fixes the problem, which editors can offer as a quick fix, e.g. adding
+	// the methods a class is missing. Usually nil.
+	Fix *QuickFix
+}
+
+type TypeErrorSeverity int
+
+const (
+	SeverityError TypeErrorSeverity = iota
+	SeverityWarning
+)
+
+// QuickFix is a change to the code that fixes a TypeError.
+type QuickFix struct {
+	// Title describes the change, e.g. "Add unimplemented methods"
+	Title string
+	Edits []TextEdit
+}
+
+// TextEdit replaces the code at Loc with NewText. An empty Loc inserts NewText there.
+type TextEdit struct {
+	Loc     loc.Bounds
+	NewText string
+}
+
+// RelatedLocation is a location in the code that's related to a TypeError, with a message
+// describing how it's related.
+type RelatedLocation struct {
+	Loc     loc.CodeLocation
+	Message string
 }
 
 func (te *TypeError) ToDiagnostic() protocol.Diagnostic {
+	severity := protocol.DiagnosticSeverityError
+	if te.Severity == SeverityWarning {
+		severity = protocol.DiagnosticSeverityWarning
+	}
+
+	tags := []protocol.DiagnosticTag{}
+	if te.Unnecessary {
+		tags = append(tags, protocol.DiagnosticTagUnnecessary)
+	}
+
 	return protocol.Diagnostic{
 		Range:              loc.BoundsToRange(te.Loc),
-		Severity:           protocol.DiagnosticSeverityError,
+		Severity:           severity,
 		Code:               nil,
 		CodeDescription:    nil,
 		Source:             "java-mini-ls",
 		Message:            te.Message,
-		Tags:               []protocol.DiagnosticTag{},
-		RelatedInformation: []protocol.DiagnosticRelatedInformation{},
+		Tags:               tags,
+		RelatedInformation: util.Map(te.Related, RelatedLocation.toRelatedInformation),
 		Data:               nil,
 	}
 }
 
+func (rl RelatedLocation) toRelatedInformation() protocol.DiagnosticRelatedInformation {
+	return protocol.DiagnosticRelatedInformation{
+		Location: protocol.Location{
+			URI:   uri.New(rl.Loc.FileUri),
+			Range: loc.BoundsToRange(rl.Loc.Loc),
+		},
+		Message: rl.Message,
+	}
+}
+
 //goland:noinspection GoNameStartsWithPackageName
 type TypeCheckResult struct {
 	TypeErrors      []TypeError
@@ -132,6 +190,11 @@ type typedExpression struct {
 	// Normally the above fields are all that's necessary. But sometimes we'll push a placeholder value
 	// that doesn't have the above fields, and uses a scope type instead.
 	placeholderExprType ExprType
+
+	// The value of a constant expression of an integral type, e.g. `1 << 4`, or nil if it isn't one
+	constant *int64
+	// The local or field the expression refers to, e.g. `x` or `point.x`, or nil if it isn't one
+	variable typ.JavaSymbol
 }
 
 func (te typedExpression) String() string {
@@ -150,6 +213,39 @@ type typeChecker struct {
 	rootScope       *TypeCheckingScope
 	currentScope    *TypeCheckingScope
 	defUsages       *DefinitionsUsagesLookup
+	resolver        *typeResolver
+
+	// Whether we're inside a package or import declaration, whose names don't count as references
+	inPackageOrImport bool
+	// Every identifier referenced in the file outside of package and import declarations. Used
+	// to figure out which imports are unused.
+	referencedNames *util.Set[string]
+
+	// The modifiers of each class body declaration we're inside of, innermost last. Instance members can't
+	// be referred to without a `.` from static ones.
+	memberModifiers util.Stack[modifiers]
+	// For each type we're inside of, innermost last, how many class body declarations we were already inside
+	// of when entering it. The ones after that are the ones inside of the type.
+	typeMemberDepths util.Stack[int]
+
+	// The types of the anonymous class bodies in the file, so that `new T() { ... }` can evaluate to them
+	anonymousTypes map[*javaparser.ClassBodyContext]*typ.JavaType
+	// The expression stacks of the expressions that anonymous class bodies are inside of, innermost on top.
+	// Statements inside of the class body shouldn't clear them.
+	outerExpressionStacks util.Stack[util.Stack[typedExpression]]
+	// The lambda expressions we're inside the body of, innermost on top. Their bodies get their own
+	// expression stacks too.
+	lambdas util.Stack[*lambdaContext]
+	// The switch statements and expressions we're inside of, innermost on top
+	switches util.Stack[*switchContext]
+	// The variables declared by type patterns, e.g. `s` in `o instanceof String s`
+	patternLocals map[*javaparser.IdentifierContext]*typ.JavaLocal
+	// The values of the final variables of integral types that are initialized with constant expressions, e.g.
+	// `K` in `final int K = 3`. Those are constant variables, which count as constant expressions too (JLS 4.12.4).
+	constantVariables map[typ.JavaSymbol]int64
+	// The blocks and other parts of the code we're inside of that have locals of their own, like the
+	// parts where pattern variables are in scope, innermost on top
+	localScopes util.Stack[localScope]
 
 	// A stack used to keep track of the types of various expressions.
 	// For example, in the binary expression `9 + 10`:
@@ -189,6 +285,18 @@ func newTypeChecker(logger *zap.Logger, fileURI string, fileVersion int, builtin
 		rootScope:              rootScope,
 		currentScope:           rootScope,
 		defUsages:              defUsages,
+		resolver:               newTypeResolver(builtins, userTypes),
+		inPackageOrImport:      false,
+		referencedNames:        util.NewSet[string](),
+		memberModifiers:        util.NewStack[modifiers](),
+		typeMemberDepths:       util.NewStack[int](),
+		anonymousTypes:         make(map[*javaparser.ClassBodyContext]*typ.JavaType),
+		outerExpressionStacks:  util.NewStack[util.Stack[typedExpression]](),
+		lambdas:                util.NewStack[*lambdaContext](),
+		switches:               util.NewStack[*switchContext](),
+		patternLocals:          make(map[*javaparser.IdentifierContext]*typ.JavaLocal),
+		constantVariables:      make(map[typ.JavaSymbol]int64),
+		localScopes:            util.NewStack[localScope](),
 		expressionStack:        util.NewStack[typedExpression](),
 	}
 }
@@ -206,13 +314,8 @@ func (tc *typeChecker) addError(err TypeError) {
 }
 
 func (tc *typeChecker) lookupType(typeName string) *typ.JavaType {
-	userType := tc.userTypes.Get(typeName)
-	if userType != nil {
-		return userType
-	}
-
 	// will return nil if not found
-	return tc.builtins.Get(typeName)
+	return tc.resolver.resolve(typeName)
 }
 
 func (tc *typeChecker) lookupOrCreateType(typeName string) *typ.JavaType {
@@ -221,9 +324,16 @@ func (tc *typeChecker) lookupOrCreateType(typeName string) *typ.JavaType {
 		return found
 	}
 
+	if elementName, dimensions := typ.SplitArrayDims(typeName); dimensions > 0 {
+		return typ.NewArrayType(tc.lookupOrCreateType(elementName), dimensions)
+	}
+
 	// Type doesn't exist, create it
 	fmt.Println("Creating built-in type: ", typeName)
 	jtype := typ.NewJavaType(typeName, "", typ.VisibilityPublic, typ.JavaTypeClass, nil)
+	if object := tc.resolver.objectType(); object != nil {
+		jtype.Extends = []*typ.JavaType{object}
+	}
 	tc.builtins.Add(jtype)
 
 	return jtype
@@ -234,15 +344,50 @@ func (tc *typeChecker) pushExprType(ttype *typ.JavaType, bounds loc.Bounds) {
 		loc:                 bounds,
 		ttype:               ttype,
 		placeholderExprType: ExprTypeUnset,
+		constant:            nil,
+		variable:            nil,
+	})
+}
+
+// pushVariable pushes a reference to a local or field, which is a constant expression if it's a constant
+// variable.
+func (tc *typeChecker) pushVariable(variable typ.JavaSymbol, ttype *typ.JavaType, bounds loc.Bounds) {
+	var constant *int64
+	if value, ok := tc.constantVariables[originalVariable(variable)]; ok {
+		constant = &value
+	}
+	tc.expressionStack.Push(typedExpression{
+		loc:                 bounds,
+		ttype:               ttype,
+		placeholderExprType: ExprTypeUnset,
+		constant:            constant,
+		variable:            variable,
 	})
 }
 
+// pushMember pushes a reference to a member of a type, which is a variable if it's a field.
+func (tc *typeChecker) pushMember(member typ.JavaSymbol, bounds loc.Bounds) {
+	if field, ok := member.(*typ.JavaField); ok {
+		tc.pushVariable(field, field.Type, bounds)
+		return
+	}
+	tc.pushExprType(member.GetType(), bounds)
+}
+
+// originalVariable returns a field as declared, for a field of a parameterized type, or else the variable itself.
+func originalVariable(variable typ.JavaSymbol) typ.JavaSymbol {
+	if field, ok := variable.(*typ.JavaField); ok && field.Original != nil {
+		return field.Original
+	}
+	return variable
+}
+
 func (tc *typeChecker) pushExprTypeName(typeName string, bounds loc.Bounds) {
 	tc.pushExprType(tc.lookupOrCreateType(typeName), bounds)
 }
 
 func (tc *typeChecker) pushAnyType(bounds loc.Bounds) {
-	tc.pushExprTypeName("any", bounds)
+	tc.pushExprTypeName(typ.TypeNameLSPAny, bounds)
 }
 
 func (tc *typeChecker) pushPlaceholder(exprType ExprType) {
@@ -250,18 +395,11 @@ func (tc *typeChecker) pushPlaceholder(exprType ExprType) {
 		loc:                 loc.Bounds{}, //nolint:exhaustruct
 		ttype:               nil,
 		placeholderExprType: exprType,
+		constant:            nil,
+		variable:            nil,
 	})
 }
 
-func (tc *typeChecker) insideExpressionType(exprType ExprType) bool {
-	for _, typedExpr := range tc.expressionStack.Values() {
-		if typedExpr.placeholderExprType == exprType {
-			return true
-		}
-	}
-	return false
-}
-
 // Pop everything off the expression stack until we get to a particular type of placeholder
 func (tc *typeChecker) popUntilPlaceholderType(exprType ExprType) []typedExpression {
 	ret := []typedExpression{}
@@ -276,20 +414,35 @@ func (tc *typeChecker) popUntilPlaceholderType(exprType ExprType) []typedExpress
 }
 
 // checkAndAddVariable adds a local variable, while first checking whether the local
-// is already defined, and if so, adding an error.
+// is already defined, and if so, adding an error. The locals of a method can't be redeclared in the
+// blocks and lambdas inside it, but they can be in a local or anonymous class.
 func (tc *typeChecker) checkAndAddVariable(name string, ttype *typ.JavaType, bounds loc.Bounds, scopeType string) {
 	topScope := tc.currentScope
-	if _, ok := topScope.Locals[name]; ok {
+	if existing := topScope.lookupEnclosingLocal(name); existing != nil {
 		currMethodName := tc.scopeTracker.ScopeStack.Top().Name
 		tc.addError(TypeError{
 			Loc:     bounds,
 			Message: fmt.Sprintf("Variable %s is already defined in %s %s", name, scopeType, currMethodName),
+			Related: []RelatedLocation{{
+				Loc:     *existing.Definition,
+				Message: fmt.Sprintf("%s is first defined here", name),
+			}},
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
 		})
 	}
 
-	enclosingMethod, ok := topScope.Symbol.(*typ.JavaMethod)
-	if ok {
-		// We're inside a method, so it's a local
+	enclosingMethod := topScope.Symbol
+	if enclosingMethod == nil {
+		// A block or lambda outside of a method, e.g. an initializer block or a lambda in a field
+		// initializer. Its locals belong to the class.
+		enclosingMethod = tc.getEnclosingType()
+	} else if !isMethodOrConstructor(enclosingMethod) {
+		enclosingMethod = nil
+	}
+	if enclosingMethod != nil {
+		// We're inside a method (or lambda), so it's a local
 		local := typ.NewJavaLocal(name, ttype, enclosingMethod, tc.makeCodeLocation(bounds))
 		topScope.addLocal(local)
 		tc.defUsages.Add(tc.makeCodeLocation(bounds), local, false)
@@ -297,14 +450,7 @@ func (tc *typeChecker) checkAndAddVariable(name string, ttype *typ.JavaType, bou
 }
 
 func (tc *typeChecker) getEnclosingType() *typ.JavaType {
-	scopes := tc.scopeTracker.ScopeStack
-	for i := scopes.Size() - 1; i >= 0; i-- {
-		scope := scopes.TopMinus(i)
-		if scope.Type.IsClassType() {
-			return tc.lookupOrCreateType(scope.Name)
-		}
-	}
-	return nil
+	return tc.resolver.currentType()
 }
 
 func (tc *typeChecker) EnterEveryRule(ctx antlr.ParserRuleContext) {
@@ -314,59 +460,285 @@ func (tc *typeChecker) EnterEveryRule(ctx antlr.ParserRuleContext) {
 		symbolForScope := tc.getSymbolFromScope(newScope)
 		typeScope := newTypeCheckingScope(symbolForScope, tc.currentScope, bounds)
 
-		if newScope.Type.IsMethodType() {
-			// Add method params to Locals.
-			// First, look up method in types.
-			enclosingType := tc.getEnclosingType()
-			if enclosingType != nil {
-				// TODO handle method overrides (same name)
-				methodIdx := slices.IndexFunc(enclosingType.Methods, func(method *typ.JavaMethod) bool {
-					return method.Name == newScope.Name
-				})
-				if methodIdx != -1 {
-					method := enclosingType.Methods[methodIdx]
+		if newScope.Type.IsClassType() {
+			tc.enterType(newScope, ctx, symbolForScope.(*typ.JavaType))
+		}
 
-					for _, param := range method.Params {
-						local := typ.NewJavaLocal(param.Name, param.Type, method, tc.makeCodeLocation(bounds))
-						typeScope.addLocal(local)
-					}
-				}
+		if newScope.Type.IsMethodType() {
+			// Makes the method's type variables available. If the symbol isn't a method, this pushes nil.
+			method, _ := symbolForScope.(*typ.JavaMethod)
+			tc.resolver.enterMethod(method)
+
+			// Add method params to Locals
+			for _, param := range paramsOf(symbolForScope) {
+				local := typ.NewJavaLocal(param.Name, param.Type, symbolForScope, tc.makeCodeLocation(bounds))
+				typeScope.addLocal(local)
 			}
 		}
 
 		tc.currentScope = typeScope
 	}
+
+	tc.enterLocalScope(ctx)
+	tc.enterFlowScope(ctx)
+}
+
+// enterType starts checking the body of a type declaration, or of an anonymous class.
+func (tc *typeChecker) enterType(scope *parse.Scope, ctx antlr.ParserRuleContext, ttype *typ.JavaType) {
+	switch {
+	case scope.Type == parse.ScopeTypeAnonymousClass:
+		tc.anonymousTypes[ctx.(*javaparser.ClassBodyContext)] = ttype
+		tc.outerExpressionStacks.Push(tc.expressionStack)
+		tc.expressionStack = util.NewStack[typedExpression]()
+	case scope.LocalIndex > 0:
+		tc.resolver.declareLocalType(scope.Name, ttype)
+	}
+
+	tc.resolver.enterType(ttype)
+	tc.typeMemberDepths.Push(tc.memberModifiers.Size())
+	tc.checkUnimplementedMethods(scope, ctx, ttype)
+}
+
+// EnterClassDeclarationExtends makes the superclass of a class navigable, e.g. `Base` in
+// `class Derived extends Base`. That also makes the file depend on the one declaring it.
+func (tc *typeChecker) EnterClassDeclarationExtends(ctx *javaparser.ClassDeclarationExtendsContext) {
+	tc.addSupertypeUsage(ctx.TypeType())
+}
+
+// EnterTypeList makes the interfaces a type implements or extends navigable, e.g. `Runnable` in
+// `class Task implements Runnable`.
+func (tc *typeChecker) EnterTypeList(ctx *javaparser.TypeListContext) {
+	switch ctx.GetParent().(type) {
+	case *javaparser.ClassDeclarationImplementsContext, *javaparser.InterfaceDeclarationExtendsContext,
+		*javaparser.EnumDeclarationContext, *javaparser.RecordDeclarationContext:
+		for _, typeType := range ctx.AllTypeType() {
+			tc.addSupertypeUsage(typeType)
+		}
+	}
+}
+
+func (tc *typeChecker) addSupertypeUsage(typeTypeI javaparser.ITypeTypeContext) {
+	typeType, ok := typeTypeI.(*javaparser.TypeTypeContext)
+	if !ok {
+		return
+	}
+	classType, ok := typeType.ClassOrInterfaceType().(*javaparser.ClassOrInterfaceTypeContext)
+	if !ok {
+		return
+	}
+
+	// Without any type arguments, e.g. `Map.Entry` for `Map.Entry<K, V>`
+	identifiers := classType.AllIdentifier()
+	names := util.Map(identifiers, func(ident javaparser.IIdentifierContext) string { return ident.GetText() })
+	supertype := tc.lookupType(strings.Join(names, "."))
+	if supertype == nil {
+		return
+	}
+	bounds := loc.ParserRuleContextToBounds(identifiers[len(identifiers)-1])
+	tc.defUsages.Add(tc.makeCodeLocation(bounds), supertype.GetOriginal(), true)
+}
+
+// exitType finishes checking the body of a type declaration, or of an anonymous class.
+func (tc *typeChecker) exitType(scope *parse.Scope) {
+	if scope.Type == parse.ScopeTypeAnonymousClass {
+		tc.expressionStack = tc.outerExpressionStacks.Pop()
+	}
+
+	tc.resolver.exitType()
+	tc.typeMemberDepths.Pop()
 }
 
 func (tc *typeChecker) getSymbolFromScope(scope *parse.Scope) typ.JavaSymbol {
 	if scope.Type.IsClassType() {
+		declared := tc.resolver.declaredType(scope)
+		if declared != nil {
+			return declared
+		}
+		if scope.Type == parse.ScopeTypeAnonymousClass {
+			// There's no name to look it up by
+			anonymousType := typ.NewJavaType("", "", typ.VisibilityDefault, typ.JavaTypeClass, nil)
+			anonymousType.LocalIndex = scope.LocalIndex
+			return anonymousType
+		}
 		return tc.lookupOrCreateType(scope.Name)
 	}
 
-	// it's a method, should be inside a class
-	if tc.currentScope.Symbol == nil {
-		tc.logger.Error(fmt.Sprintf("can't get symbol from scope, tc.currentScope.Symbol is nil. Scope: %v", scope))
+	// It's a method or constructor, so it should be inside a class
+	enclosingType := tc.getEnclosingType()
+	if enclosingType == nil {
+		tc.logger.Error(fmt.Sprintf("can't get symbol from scope, there's no enclosing type. Scope: %v", scope))
 		return nil
 	}
 
-	enclosingType, ok := tc.currentScope.Symbol.(*typ.JavaType)
-	if !ok {
-		tc.logger.Error(fmt.Sprintf("can't get symbol from scope, enclosing type is %T. Scope: %v", tc.currentScope.Symbol, scope))
-		return nil
+	// There may be overloads with the same name, so find the one declared right here
+	if declared := declaredMember(enclosingType, scope.Bounds); declared != nil {
+		return declared
 	}
-
-	// Look up method on enclosingType
 	return enclosingType.LookupMember(scope.Name)
 }
 
+// declaredMember returns the method or constructor of a type whose name is at the given bounds, or nil if
+// there isn't one.
+func declaredMember(ttype *typ.JavaType, nameBounds loc.Bounds) typ.JavaSymbol {
+	for _, method := range ttype.Methods {
+		if method.Definition != nil && method.Definition.Loc.Equals(nameBounds) {
+			return method
+		}
+	}
+	for _, constructor := range ttype.Constructors {
+		if constructor.Definition != nil && constructor.Definition.Loc.Equals(nameBounds) {
+			return constructor
+		}
+	}
+	return nil
+}
+
+func isMethodOrConstructor(symbol typ.JavaSymbol) bool {
+	return symbol.Kind() == typ.JavaSymbolMethod || symbol.Kind() == typ.JavaSymbolConstructor
+}
+
+// paramsOf returns the parameters of a method or constructor, or nil if it's neither.
+func paramsOf(symbol typ.JavaSymbol) []*typ.JavaParameter {
+	switch callable := symbol.(type) {
+	case *typ.JavaMethod:
+		return callable.Params
+	case *typ.JavaConstructor:
+		return callable.Params
+	}
+	return nil
+}
+
 func (tc *typeChecker) ExitEveryRule(ctx antlr.ParserRuleContext) {
+	tc.exitLocalScopes(ctx)
+
 	oldScope := tc.scopeTracker.CheckExitScope(ctx)
 	if oldScope != nil {
 		tc.currentScope = tc.currentScope.Parent
+
+		if oldScope.Type.IsClassType() {
+			tc.exitType(oldScope)
+		}
+		if oldScope.Type.IsMethodType() {
+			tc.resolver.exitMethod()
+		}
+	}
+}
+
+func (tc *typeChecker) EnterPackageDeclaration(ctx *javaparser.PackageDeclarationContext) {
+	tc.resolver.setPackage(ctx)
+	tc.inPackageOrImport = true
+}
+
+func (tc *typeChecker) ExitPackageDeclaration(_ *javaparser.PackageDeclarationContext) {
+	tc.inPackageOrImport = false
+}
+
+func (tc *typeChecker) EnterImportDeclaration(ctx *javaparser.ImportDeclarationContext) {
+	imp := tc.resolver.addImport(ctx)
+	tc.inPackageOrImport = true
+
+	// Make the last part of the imported name navigable, e.g. `List` in `import java.util.List;`
+	identifiers := ctx.QualifiedName().(*javaparser.QualifiedNameContext).AllIdentifier()
+	lastIdent := identifiers[len(identifiers)-1]
+	bounds := loc.ParserRuleContextToBounds(lastIdent)
+
+	var imported typ.JavaSymbol
+	if imp.isOnDemand {
+		if importedType := tc.resolver.importedType(imp); importedType != nil {
+			imported = importedType
+		}
+	} else {
+		imported = tc.resolver.importedSymbol(imp)
+	}
+	if imported != nil {
+		tc.defUsages.Add(tc.makeCodeLocation(bounds), imported, true)
+	}
+}
+
+func (tc *typeChecker) ExitImportDeclaration(_ *javaparser.ImportDeclarationContext) {
+	tc.inPackageOrImport = false
+}
+
+func (tc *typeChecker) EnterIdentifier(ctx *javaparser.IdentifierContext) {
+	if !tc.inPackageOrImport {
+		tc.referencedNames.Add(ctx.GetText())
+	}
+}
+
+func (tc *typeChecker) ExitCompilationUnit(_ *javaparser.CompilationUnitContext) {
+	tc.checkImports()
+}
+
+// checkImports reports imports that refer to things that don't exist, and imports that are never used. An
+// import that repeats an earlier one is never used, and neither is an on-demand import whose names are all
+// imported by single-type imports too, since those take precedence.
+func (tc *typeChecker) checkImports() {
+	singleImports := util.NewSet[string]()
+	for _, imp := range tc.resolver.imports {
+		bounds := loc.ParserRuleContextToBounds(imp.ctx)
+
+		if !tc.resolver.isResolvable(imp) {
+			tc.addError(TypeError{
+				Loc:         bounds,
+				Message:     fmt.Sprintf("The import %s cannot be resolved", imp.ctx.QualifiedName().GetText()),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+			continue
+		}
+
+		used := false
+		if !imp.isOnDemand {
+			key := imp.ctx.GetText()
+			used = !singleImports.Contains(key) && tc.referencedNames.Contains(lastNamePart(imp.qualifiedName))
+			singleImports.Add(key)
+		} else {
+			for _, name := range tc.referencedNames.Values() {
+				if tc.resolver.providesName(imp, name) && !tc.hasSingleImport(name) {
+					used = true
+					break
+				}
+			}
+		}
+		if !used {
+			tc.addError(TypeError{
+				Loc:         bounds,
+				Message:     fmt.Sprintf("The import %s is never used", imp.ctx.QualifiedName().GetText()),
+				Related:     nil,
+				Severity:    SeverityWarning,
+				Unnecessary: true,
+				Fix:         nil,
+			})
+		}
+	}
+}
+
+// hasSingleImport says whether a single-type or single static import that can be resolved brings the given
+// name into scope.
+func (tc *typeChecker) hasSingleImport(name string) bool {
+	for _, imp := range tc.resolver.imports {
+		if !imp.isOnDemand && lastNamePart(imp.qualifiedName) == name && tc.resolver.isResolvable(imp) {
+			return true
+		}
 	}
+	return false
 }
 
-func (tc *typeChecker) ExitStatement(_ *javaparser.StatementContext) {
+func (tc *typeChecker) ExitStatement(ctx *javaparser.StatementContext) {
+	// What a lambda body returns is checked once the whole lambda is
+	if ctx.RETURN() != nil && ctx.Expression(0) != nil && !tc.lambdas.Empty() && enclosingLambda(ctx) == tc.lambdas.Top().ctx {
+		lambda := tc.lambdas.Top()
+		lambda.returns = append(lambda.returns, tc.expressionStack.Top())
+	}
+	tc.checkCompactConstructorReturn(ctx)
+	tc.recordSwitchValue(ctx)
+	if ctx.SWITCH() != nil {
+		tc.switches.Pop()
+	}
+	tc.checkFlowAfterStatement(ctx)
+
 	// zero out the expression stack when we leave a statement
 	tc.expressionStack.Clear()
 }
@@ -376,32 +748,255 @@ func (tc *typeChecker) ExitBlockStatement(_ *javaparser.BlockStatementContext) {
 	tc.expressionStack.Clear()
 }
 
+func (tc *typeChecker) EnterBlock(_ *javaparser.BlockContext) {
+	tc.resolver.enterBlock()
+}
+
+func (tc *typeChecker) ExitBlock(_ *javaparser.BlockContext) {
+	tc.resolver.exitBlock()
+}
+
+func (tc *typeChecker) EnterClassBodyDeclaration(ctx *javaparser.ClassBodyDeclarationContext) {
+	tc.memberModifiers.Push(memberModifiers(ctx))
+}
+
+func (tc *typeChecker) ExitClassBodyDeclaration(_ *javaparser.ClassBodyDeclarationContext) {
+	tc.memberModifiers.Pop()
+}
+
+func (tc *typeChecker) EnterInterfaceBodyDeclaration(ctx *javaparser.InterfaceBodyDeclarationContext) {
+	tc.memberModifiers.Push(interfaceMemberModifiers(ctx))
+}
+
+func (tc *typeChecker) ExitInterfaceBodyDeclaration(_ *javaparser.InterfaceBodyDeclarationContext) {
+	tc.memberModifiers.Pop()
+}
+
+// inStaticContext says whether we're inside a static method, static field initializer or static initializer
+// block, where there's no instance to refer to members of.
+func (tc *typeChecker) inStaticContext() bool {
+	return tc.inStaticContextOf(0)
+}
+
+// inStaticContextOf says whether there's no instance of an enclosing type to refer to its members of, where
+// depth 0 is the innermost type, 1 the one it's nested in, and so on. That's the case when we're inside of
+// a static member of it, or of a static nested type, e.g. in a local class declared in a static method.
+func (tc *typeChecker) inStaticContextOf(depth int) bool {
+	if depth >= tc.typeMemberDepths.Size() {
+		return false
+	}
+	for i := tc.typeMemberDepths.TopMinus(depth); i < tc.memberModifiers.Size(); i++ {
+		if tc.memberModifiers.At(i).isStatic {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStaticAccess checks that a field or method is referred to the right way for whether it's static.
+// The receiver is the type of the expression on the left of the `.`, or nil if there's no `.`, in which case
+// staticContext says whether there's an instance of the type the member was found in. Instance members can't
+// be referred to through the name of a type, or without a `.` from a static context, and static members
+// should be referred to through the name of their type rather than an instance.
+func (tc *typeChecker) checkStaticAccess(member typ.JavaSymbol, receiver *typ.JavaType, staticContext bool, bounds loc.Bounds) {
+	viaTypeName := receiver != nil && receiver.Type == typ.JavaTypeLSPClass
+
+	if typ.IsInstanceMember(member) && (viaTypeName || receiver == nil && staticContext) {
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     staticReferenceMessage(member),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+
+	if typ.IsStaticMember(member) && receiver != nil && !viaTypeName && receiver.Type != typ.JavaTypeLSPAny {
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     staticAccessMessage(member),
+			Related:     nil,
+			Severity:    SeverityWarning,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+}
+
+// checkAccess reports a field, method or constructor that can't be accessed from where it's used, because
+// of its access modifiers. The qualifier is as in typ.IsAccessible.
+func (tc *typeChecker) checkAccess(member typ.JavaSymbol, qualifier *typ.JavaType, bounds loc.Bounds) {
+	if typ.IsAccessible(member, tc.getEnclosingType(), qualifier) {
+		return
+	}
+
+	var message string
+	switch member := member.(type) {
+	case *typ.JavaField:
+		message = fmt.Sprintf("The field %s.%s is not visible", typeNameOrUnknown(member.ParentType), member.Name)
+	case *typ.JavaMethod:
+		message = fmt.Sprintf("The method %s from the type %s is not visible", member.NameWithArgs(), typeNameOrUnknown(member.ParentType))
+	default:
+		message = fmt.Sprintf("The constructor %s is not visible", member.ShortName())
+	}
+
+	tc.addError(TypeError{
+		Loc:         bounds,
+		Message:     message,
+		Related:     nil,
+		Severity:    SeverityError,
+		Unnecessary: false,
+		Fix:         nil,
+	})
+}
+
+func staticReferenceMessage(member typ.JavaSymbol) string {
+	if method, ok := member.(*typ.JavaMethod); ok {
+		return fmt.Sprintf("Cannot make a static reference to the non-static method %s from the type %s", method.NameWithArgs(), typeNameOrUnknown(method.ParentType))
+	}
+	return fmt.Sprintf("Cannot make a static reference to the non-static field %s", member.ShortName())
+}
+
+func staticAccessMessage(member typ.JavaSymbol) string {
+	if method, ok := member.(*typ.JavaMethod); ok {
+		return fmt.Sprintf("The static method %s from the type %s should be accessed in a static way", method.NameWithArgs(), typeNameOrUnknown(method.ParentType))
+	}
+	field := member.(*typ.JavaField)
+	return fmt.Sprintf("The static field %s.%s should be accessed in a static way", typeNameOrUnknown(field.ParentType), field.Name)
+}
+
+func (tc *typeChecker) EnterFieldDeclaration(ctx *javaparser.FieldDeclarationContext) {
+	tc.checkRecordField(ctx)
+}
+
+func (tc *typeChecker) EnterConstructorDeclaration(ctx *javaparser.ConstructorDeclarationContext) {
+	tc.checkRecordConstructor(ctx)
+}
+
+func (tc *typeChecker) EnterCompactConstructorDeclaration(ctx *javaparser.CompactConstructorDeclarationContext) {
+	tc.checkCompactConstructor(ctx)
+}
+
+func (tc *typeChecker) EnterMethodDeclaration(ctx *javaparser.MethodDeclarationContext) {
+	tc.checkRecordAccessor(ctx)
+}
+
 func (tc *typeChecker) ExitFieldDeclaration(ctx *javaparser.FieldDeclarationContext) {
-	tc.handleTypedVariableDecl(ctx, loc.ParserRuleContextToBounds(ctx), false)
+	tc.handleTypedVariableDecl(ctx, tc.declaredType(ctx), loc.ParserRuleContextToBounds(ctx), false)
+}
+
+// ExitConstDeclaration checks the values of the constants of an interface, e.g. `int MAX = 10;`, which all have
+// to have one.
+func (tc *typeChecker) ExitConstDeclaration(ctx *javaparser.ConstDeclarationContext) {
+	declarators := ctx.AllConstantDeclarator()
+
+	// The values come off the stack backwards
+	for i := len(declarators) - 1; i >= 0 && !tc.expressionStack.Empty(); i-- {
+		expr := tc.expressionStack.Pop()
+		declarator := declarators[i].(*javaparser.ConstantDeclaratorContext)
+		varType := tc.constantType(declarator)
+		if !tc.assignable(expr, varType) {
+			tc.addError(TypeError{
+				Loc:         expr.loc,
+				Message:     fmt.Sprintf("Type mismatch: cannot convert from %s to %s", expr.ttype.ShortName(), varType.ShortName()),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+		} else if field := tc.declaredField(declarator.Identifier().GetText()); field != nil {
+			tc.addConstantVariable(field, varType, expr)
+		}
+	}
 }
 
 func (tc *typeChecker) ExitLocalVariableDeclaration(ctx *javaparser.LocalVariableDeclarationContext) {
 	typedI := ctx.TypedLocalVarDecl()
 	if typedI != nil {
 		typed := typedI.(*javaparser.TypedLocalVarDeclContext)
-		tc.handleTypedVariableDecl(typed, loc.ParserRuleContextToBounds(ctx), true)
+		tc.handleTypedVariableDecl(typed, tc.declaredType(typed), loc.ParserRuleContextToBounds(ctx), true)
 	} else {
 		untyped := ctx.UntypedLocalVarDecl().(*javaparser.UntypedLocalVarDeclContext)
 		tc.handleUntypedLocalVariableDecl(untyped)
 	}
 }
 
+// declaredType returns the type that a variable or field is declared with, e.g. `String` in `String a = "hi"`.
+func (tc *typeChecker) declaredType(ctx typedDeclarationCtx) *typ.JavaType {
+	return tc.lookupOrCreateType(ctx.TypeType().GetText())
+}
+
+// expectedType returns the type the value of the given expression is expected to have, based on where it
+// appears: the declared type of the variable it initializes, or the return type of the method or lambda
+// expression it's returned from. This is the target type used to infer type arguments, e.g. `String` for `T` in
+// `List<String> list = Collections.emptyList()`. Returns nil if there is none.
+func (tc *typeChecker) expectedType(expr antlr.Tree) *typ.JavaType {
+	switch parent := expr.GetParent().(type) {
+	case *javaparser.VariableInitializerContext:
+		if declarator, ok := parent.GetParent().(*javaparser.VariableDeclaratorContext); ok {
+			return tc.declaratorType(declarator)
+		}
+		if declarator, ok := parent.GetParent().(*javaparser.ConstantDeclaratorContext); ok {
+			return tc.constantType(declarator)
+		}
+	case *javaparser.LambdaBodyContext:
+		return tc.lambdaReturnType()
+	case *javaparser.StatementContext:
+		if parent.RETURN() != nil {
+			if enclosingLambda(parent) != nil {
+				return tc.lambdaReturnType()
+			}
+			if method := tc.resolver.currentMethod(); method != nil {
+				return method.ReturnType
+			}
+		}
+	}
+	return nil
+}
+
+// declaratorType returns the type of the variable declared by a declarator, including any array dimensions
+// after its name, e.g. `int[]` for `numbers` in `int numbers[] = {1, 2}`. Returns nil for `var` declarations.
+func (tc *typeChecker) declaratorType(declarator *javaparser.VariableDeclaratorContext) *typ.JavaType {
+	// Skip past the variableDeclarators to get to the declaration
+	decl, ok := declarator.GetParent().GetParent().(typedDeclarationCtx)
+	if !ok {
+		return nil
+	}
+	return withDeclaratorDims(tc.declaredType(decl), declarator.VariableDeclaratorId())
+}
+
+// constantType returns the type of a constant declared in an interface, including any array dimensions after
+// its name.
+func (tc *typeChecker) constantType(declarator *javaparser.ConstantDeclaratorContext) *typ.JavaType {
+	constDecl := declarator.GetParent().(*javaparser.ConstDeclarationContext)
+	return withConstantDims(tc.lookupOrCreateType(constDecl.TypeType().GetText()), declarator)
+}
+
+// boxed returns the class wrapping the given type if it's a primitive type, e.g. `Integer` for `int`.
+// Otherwise, returns the type itself.
+func (tc *typeChecker) boxed(ttype *typ.JavaType) *typ.JavaType {
+	if ttype == nil {
+		return nil
+	}
+	return ttype.Boxed()
+}
+
 // e.g. `String a = "hi"`
-func (tc *typeChecker) handleTypedVariableDecl(ctx typedDeclarationCtx, bounds loc.Bounds, isLocal bool) {
-	ttype := tc.lookupOrCreateType(ctx.TypeType().GetText())
+func (tc *typeChecker) handleTypedVariableDecl(ctx typedDeclarationCtx, ttype *typ.JavaType, bounds loc.Bounds, isLocal bool) {
+	// The types of the variables that have initializers, and the variables themselves, in order
+	var initializedTypes []*typ.JavaType
+	var initializedVariables []typ.JavaSymbol
 
 	// There can be multiple variable declarators
 	varDecls := ctx.VariableDeclarators().(*javaparser.VariableDeclaratorsContext).AllVariableDeclarator()
 	for _, varDeclI := range varDecls {
 		varDecl := varDeclI.(*javaparser.VariableDeclaratorContext)
 
-		ident := varDecl.VariableDeclaratorId().(*javaparser.VariableDeclaratorIdContext).Identifier()
+		declaratorID := varDecl.VariableDeclaratorId()
+		ident := declaratorID.(*javaparser.VariableDeclaratorIdContext).Identifier()
 		varName := ident.GetText()
+		varType := withDeclaratorDims(ttype, declaratorID)
 
 		var scopeType string
 		if isLocal {
@@ -411,43 +1006,143 @@ func (tc *typeChecker) handleTypedVariableDecl(ctx typedDeclarationCtx, bounds l
 		}
 
 		// TODO fix bounds, the error message also red underlines the equals sign
-		tc.checkAndAddVariable(varName, ttype, loc.ParserRuleContextToBounds(ident), scopeType)
+		tc.checkAndAddVariable(varName, varType, loc.ParserRuleContextToBounds(ident), scopeType)
+
+		if varDecl.VariableInitializer() != nil {
+			initializedTypes = append(initializedTypes, varType)
+			initializedVariables = append(initializedVariables, tc.declaredVariable(ctx, varName, isLocal))
+		}
 	}
 
 	// Make sure every value in the expression stack (which is the value of all the initializer expressions
-	// for these local vars) coerces to the type declared.
-	for !tc.expressionStack.Empty() {
+	// for these local vars) coerces to the type declared. They come off the stack backwards.
+	for i := len(initializedTypes) - 1; !tc.expressionStack.Empty(); i-- {
 		expr := tc.expressionStack.Pop()
-		if !expr.ttype.CoercesTo(ttype) {
+		varType := ttype
+		if i >= 0 {
+			varType = initializedTypes[i]
+		}
+
+		if !tc.assignable(expr, varType) {
 			tc.addError(TypeError{
-				Loc:     expr.loc,
-				Message: fmt.Sprintf("Type mismatch: cannot convert from %s to %s", expr.ttype.Name, ttype.Name),
+				Loc:         expr.loc,
+				Message:     fmt.Sprintf("Type mismatch: cannot convert from %s to %s", expr.ttype.ShortName(), varType.ShortName()),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
 			})
+		} else if i >= 0 {
+			tc.addConstantVariable(initializedVariables[i], varType, expr)
 		}
 	}
 }
 
-// e.g. `var a = "hi"`
-func (tc *typeChecker) handleUntypedLocalVariableDecl(ctx *javaparser.UntypedLocalVarDeclContext) {
-	// In order for type to be inferred, we must have already pushed the expression type
-	ttype := tc.expressionStack.Pop().ttype
-
-	tc.checkAndAddVariable(ctx.Identifier().GetText(), ttype, loc.ParserRuleContextToBounds(ctx.Identifier()), "method")
-}
-
-func (tc *typeChecker) ExitPrimary(ctx *javaparser.PrimaryContext) {
-	literal := ctx.Literal()
-	if literal != nil {
-		tc.handleLiteral(literal.(*javaparser.LiteralContext))
+// declaredVariable returns the local or field that a variable declaration in the current scope declares, or
+// nil if it can't be found.
+func (tc *typeChecker) declaredVariable(ctx typedDeclarationCtx, name string, isLocal bool) typ.JavaSymbol {
+	if !isLocal {
+		// A nil *JavaField would make a JavaSymbol that isn't nil
+		if field := tc.declaredField(name); field != nil {
+			return field
+		}
+		return nil
 	}
 
-	ident := ctx.Identifier()
-	if ident != nil {
-		tc.handleIdentifier(ident.(*javaparser.IdentifierContext))
+	local, ok := tc.currentScope.Locals[name]
+	if !ok {
+		return nil
+	}
+	// Only final locals can be constant variables
+	decl, ok := ctx.(antlr.Tree).GetParent().(*javaparser.LocalVariableDeclarationContext)
+	if !ok || !hasFinalModifier(decl.AllVariableModifier()) {
+		return nil
 	}
+	return local
 }
 
-func (tc *typeChecker) handleLiteral(ctx *javaparser.LiteralContext) {
+// declaredField returns the field by the given name that the type we're in declares, or nil if there's none.
+func (tc *typeChecker) declaredField(name string) *typ.JavaField {
+	enclosing := tc.getEnclosingType()
+	if enclosing == nil {
+		return nil
+	}
+	for _, field := range enclosing.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// addConstantVariable records the value of a variable if it's a constant variable: a final one of an integral
+// type, initialized with a constant expression, e.g. `final int K = 3` (JLS 4.12.4).
+func (tc *typeChecker) addConstantVariable(variable typ.JavaSymbol, varType *typ.JavaType, value typedExpression) {
+	if variable == nil || value.constant == nil || varType.Type != typ.JavaTypePrimitive || !assertIsIntegral(varType) {
+		return
+	}
+	if field, ok := variable.(*typ.JavaField); ok && !field.IsFinal {
+		return
+	}
+	tc.constantVariables[variable] = wrapConstant(varType, *value.constant)
+}
+
+// e.g. `var a = "hi"`
+func (tc *typeChecker) handleUntypedLocalVariableDecl(ctx *javaparser.UntypedLocalVarDeclContext) {
+	// In order for type to be inferred, we must have already pushed the expression type
+	ttype := tc.expressionStack.Pop().ttype
+
+	tc.checkAndAddVariable(ctx.Identifier().GetText(), ttype, loc.ParserRuleContextToBounds(ctx.Identifier()), "method")
+}
+
+func (tc *typeChecker) ExitPrimary(ctx *javaparser.PrimaryContext) {
+	if ctx.Expression() != nil {
+		tc.handleParenthesized(ctx)
+	}
+
+	literal := ctx.Literal()
+	if literal != nil {
+		tc.handleLiteral(literal.(*javaparser.LiteralContext))
+	}
+
+	ident := ctx.Identifier()
+	if ident != nil {
+		if sc := tc.enumSwitch(ctx); sc != nil {
+			tc.expressionStack.Push(tc.enumCaseConstant(sc, ident.GetText(), loc.ParserRuleContextToBounds(ident)))
+		} else {
+			tc.handleIdentifier(ident.(*javaparser.IdentifierContext))
+		}
+	}
+
+	if ctx.THIS() != nil && ctx.GetChildCount() == 1 {
+		tc.handleThis(ctx)
+	}
+}
+
+// handleThis pushes the type of `this`, which is the enclosing type. There's no `this` in a static context.
+func (tc *typeChecker) handleThis(ctx *javaparser.PrimaryContext) {
+	bounds := loc.ParserRuleContextToBounds(ctx)
+
+	enclosing := tc.getEnclosingType()
+	if enclosing == nil || tc.inStaticContext() {
+		if enclosing != nil {
+			tc.addError(TypeError{
+				Loc:         bounds,
+				Message:     "Cannot use this in a static context",
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+		}
+		tc.pushAnyType(bounds)
+		return
+	}
+
+	tc.pushExprType(enclosing, bounds)
+}
+
+func (tc *typeChecker) handleLiteral(ctx *javaparser.LiteralContext) {
 	bounds := loc.ParserRuleContextToBounds(ctx)
 
 	intLit := ctx.IntegerLiteral()
@@ -460,7 +1155,11 @@ func (tc *typeChecker) handleLiteral(ctx *javaparser.LiteralContext) {
 			typeName = "long"
 		}
 
-		tc.pushExprTypeName(typeName, bounds)
+		if value, ok := integerLiteralValue(intLitTxt); ok {
+			tc.pushConstant(tc.lookupOrCreateType(typeName), value, bounds)
+		} else {
+			tc.pushExprTypeName(typeName, bounds)
+		}
 		return
 	}
 
@@ -480,7 +1179,11 @@ func (tc *typeChecker) handleLiteral(ctx *javaparser.LiteralContext) {
 
 	charLit := ctx.CHAR_LITERAL()
 	if charLit != nil {
-		tc.pushExprTypeName("char", bounds)
+		if value, ok := charLiteralValue(charLit.GetText()); ok {
+			tc.pushConstant(tc.lookupOrCreateType("char"), value, bounds)
+		} else {
+			tc.pushExprTypeName("char", bounds)
+		}
 		return
 	}
 
@@ -513,18 +1216,32 @@ func (tc *typeChecker) handleIdentifier(ctx *javaparser.IdentifierContext) {
 	bounds := loc.ParserRuleContextToBounds(ctx)
 	identName := ctx.GetText()
 
-	// Is there a local by that name?
-	topTypeScope := tc.currentScope
-	ident, ok := topTypeScope.Locals[identName]
-	if ok {
-		tc.defUsages.Add(tc.makeCodeLocation(bounds), ident, true)
-		tc.pushExprType(ident.Type, bounds)
-		return
+	// Is there a local or a member of an enclosing type by that name? Inner ones shadow outer ones, e.g. the
+	// fields of a local class shadow the locals of the method it's declared in.
+	depth := 0
+	for scope := tc.currentScope; scope != nil; scope = scope.Parent {
+		if local, ok := scope.Locals[identName]; ok {
+			tc.defUsages.Add(tc.makeCodeLocation(bounds), local, true)
+			tc.pushVariable(local, local.Type, bounds)
+			return
+		}
+
+		enclosing, ok := scope.Symbol.(*typ.JavaType)
+		if !ok {
+			continue
+		}
+		if member := enclosing.LookupMember(identName); member != nil {
+			tc.defUsages.Add(tc.makeCodeLocation(bounds), member, true)
+			tc.checkStaticAccess(member, nil, tc.inStaticContextOf(depth), bounds)
+			tc.checkAccess(member, nil, bounds)
+			tc.pushMember(member, bounds)
+			return
+		}
+		depth++
 	}
 
-	enclosing := tc.getEnclosingType()
-	// Is there a class member by that name?
-	member := enclosing.LookupMember(identName)
+	// Is there a statically imported field or method by that name?
+	member := tc.resolver.resolveStaticMember(identName)
 	if member != nil {
 		tc.defUsages.Add(tc.makeCodeLocation(bounds), member, true)
 		tc.pushExprType(member.GetType(), bounds)
@@ -539,10 +1256,14 @@ func (tc *typeChecker) handleIdentifier(ctx *javaparser.IdentifierContext) {
 		return
 	}
 
-	// Not found
+	// Not found, but it may be a type that isn't imported, e.g. `Collections` in `Collections.sort(list)`
 	tc.addError(TypeError{
-		Loc:     bounds,
-		Message: fmt.Sprintf("Unknown identifier: %s", identName),
+		Loc:         bounds,
+		Message:     fmt.Sprintf("Unknown identifier: %s", identName),
+		Related:     nil,
+		Severity:    SeverityError,
+		Unnecessary: false,
+		Fix:         tc.importFix(ctx, identName),
 	})
 
 	// The rest of the expression needs something to continue
@@ -550,87 +1271,638 @@ func (tc *typeChecker) handleIdentifier(ctx *javaparser.IdentifierContext) {
 }
 
 func (tc *typeChecker) ExitCreator(ctx *javaparser.CreatorContext) {
-	// TODO handle array creations eg. `var intArray = new int[5]`
+	if arrayCreatorRest := ctx.ArrayCreatorRest(); arrayCreatorRest != nil {
+		tc.handleArrayCreation(ctx, arrayCreatorRest.(*javaparser.ArrayCreatorRestContext))
+		return
+	}
 
 	createdName := ctx.CreatedName().(*javaparser.CreatedNameContext)
-	// TODO handle generics
-	// TODO handle multiple identifiers, e.g. `new OuterClass.InnerClass()`
-	identName := createdName.Identifier(0).GetText()
+	// May be qualified, e.g. `new Outer.Inner()`, in which case the constructor is named after the last part
+	identName := createdName.GetText()
+	isDiamond := strings.HasSuffix(identName, "<>")
+	identifiers := createdName.AllIdentifier()
+	nameCtx := identifiers[len(identifiers)-1]
 
 	createdType := tc.lookupType(identName)
-	if createdType != nil {
-		tc.defUsages.Add(tc.makeCodeLocation(loc.ParserRuleContextToBounds(ctx)), createdType, true)
+	if createdType == nil {
+		name := strings.Join(util.Map(identifiers, func(ident javaparser.IIdentifierContext) string {
+			return ident.GetText()
+		}), ".")
+		tc.reportUnresolvedType(ctx, name, loc.Bounds{
+			Start: loc.ParserRuleContextToBounds(identifiers[0]).Start,
+			End:   loc.ParserRuleContextToBounds(nameCtx).End,
+		})
+		tc.pushAnyType(loc.ParserRuleContextToBounds(ctx))
+		return
 	}
 
-	// TODO constructor resolution & type checking
+	tc.defUsages.Add(tc.makeCodeLocation(loc.ParserRuleContextToBounds(ctx)), createdType, true)
+
+	classCreatorRest := ctx.ClassCreatorRest().(*javaparser.ClassCreatorRestContext)
+	createdType = tc.handleClassCreation(ctx, nameCtx, classCreatorRest, createdType, isDiamond)
+
+	// `new T() { ... }` creates an instance of the anonymous class, which is a T
+	if classBody, ok := classCreatorRest.ClassBody().(*javaparser.ClassBodyContext); ok && tc.anonymousTypes[classBody] != nil {
+		createdType = tc.anonymousTypes[classBody]
+	}
+	tc.pushExprType(createdType, loc.ParserRuleContextToBounds(ctx))
+}
+
+// handleClassCreation checks the args of `new T(args)`, and returns the created type.
+func (tc *typeChecker) handleClassCreation(ctx *javaparser.CreatorContext, nameCtx antlr.ParserRuleContext, classCreatorRest *javaparser.ClassCreatorRestContext, createdType *typ.JavaType, isDiamond bool) *typ.JavaType {
+	args := tc.popArgs(classCreatorRest.Arguments().(*javaparser.ArgumentsContext).ExpressionList())
+	expectedType := tc.expectedType(ctx.GetParent())
+	isDiamond = isDiamond && createdType.GetOriginal().IsGeneric()
+
+	if createdType.Type == typ.JavaTypeEnum {
+		// Enums only have the constants they declare
+		tc.addError(TypeError{
+			Loc:         loc.ParserRuleContextToBounds(ctx),
+			Message:     fmt.Sprintf("Cannot instantiate the type %s", createdType.ShortName()),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+		return createdType
+	}
+	if createdType.Type == typ.JavaTypeInterface || createdType.Type == typ.JavaTypeTypeVariable {
+		// Anonymous classes can implement interfaces, but otherwise there's nothing to construct
+		if classCreatorRest.ClassBody() == nil {
+			tc.addError(TypeError{
+				Loc:         loc.ParserRuleContextToBounds(ctx),
+				Message:     fmt.Sprintf("Cannot instantiate the type %s", createdType.ShortName()),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+		}
+		if isDiamond {
+			return tc.inferDiamondTypeArgs(createdType, expectedType)
+		}
+		return createdType
+	}
 
-	tc.pushExprTypeName(identName, loc.ParserRuleContextToBounds(ctx))
+	return tc.handleConstructorCall(ctx, nameCtx, createdType, args, isDiamond, classCreatorRest.ClassBody() != nil, expectedType)
+}
+
+// handleConstructorCall picks which of the constructors of a type `new T(args)` calls, checks the args, and
+// returns the created type. For the diamond `<>`, the type arguments are inferred from the args and the
+// expected type. isAnonymous is for `new T(args) { ... }`, which creates an anonymous subclass of T.
+func (tc *typeChecker) handleConstructorCall(ctx antlr.ParserRuleContext, nameCtx antlr.ParserRuleContext, createdType *typ.JavaType, args []typedExpression, isDiamond bool, isAnonymous bool, expectedType *typ.JavaType) *typ.JavaType {
+	bounds := loc.ParserRuleContextToBounds(ctx)
+	generic := createdType.GetOriginal()
+
+	constructors := createdType.LookupConstructors()
+	if isDiamond {
+		constructors = generic.Constructors
+	}
+	if len(constructors) == 0 {
+		// Library types that can't be constructed this way, e.g. ones with private constructors only
+		if isDiamond {
+			return tc.inferDiamondTypeArgs(createdType, expectedType)
+		}
+		return createdType
+	}
+
+	constructorTypes := util.Map(constructors, func(constructor *typ.JavaConstructor) *typ.JavaType {
+		constructorType := constructor.GetType()
+		if isDiamond {
+			// Treat it like a generic method returning e.g. `ArrayList<E>`
+			constructorType.GenericArgs[0] = generic.Parameterize(generic.TypeParams)
+			constructorType.TypeParams = generic.TypeParams
+		}
+		return constructorType
+	})
+	overloads := util.Map(constructors, func(constructor *typ.JavaConstructor) typ.JavaSymbol {
+		return constructor
+	})
+
+	// For a diamond, the constructor types are generic, so the type arguments are inferred for each one
+	idx := tc.resolveCallOf(bounds, overloads, constructorTypes, args, generic.Name)
+	if idx == -1 {
+		if isDiamond {
+			return tc.inferDiamondTypeArgs(createdType, expectedType)
+		}
+		return createdType
+	}
+
+	tc.addConstructorUsage(loc.ParserRuleContextToBounds(nameCtx), constructors[idx])
+	// An anonymous subclass calls the constructor through its own one's `super(...)`, so a protected one is
+	// accessible from anywhere (JLS 6.6.2.2)
+	if !isAnonymous || constructors[idx].GetVisibility() != typ.VisibilityProtected {
+		tc.checkAccess(constructors[idx], createdType, bounds)
+	}
+
+	returnType := tc.checkCall(bounds, constructorTypes[idx], args, generic.Name, expectedType)
+	if isDiamond {
+		return returnType
+	}
+	return createdType
+}
+
+// addConstructorUsage records a usage of a constructor. Implicit default constructors aren't declared
+// anywhere, so going to the definition of one should go to the type instead.
+func (tc *typeChecker) addConstructorUsage(bounds loc.Bounds, constructor *typ.JavaConstructor) {
+	location := tc.makeCodeLocation(bounds)
+	if constructor.GetOriginal().Definition == nil {
+		constructor.AddUsage(location)
+		return
+	}
+	tc.defUsages.Add(location, constructor, true)
+}
+
+// e.g. `new int[5]` or `new String[] {"a", "b"}`
+func (tc *typeChecker) handleArrayCreation(ctx *javaparser.CreatorContext, rest *javaparser.ArrayCreatorRestContext) {
+	bounds := loc.ParserRuleContextToBounds(ctx)
+
+	if rest.ArrayInitializer() != nil {
+		// The array initializer has already checked its elements against the created type, and pushed it
+		tc.pushExprType(tc.expressionStack.Pop().ttype, bounds)
+		return
+	}
+
+	// The sizes of each dimension must be ints
+	dimensionExprs := make([]typedExpression, len(rest.AllExpression()))
+	for i := len(dimensionExprs) - 1; i >= 0; i-- {
+		dimensionExprs[i] = tc.expressionStack.Pop()
+	}
+	for _, dimensionExpr := range dimensionExprs {
+		tc.checkIsIntIndex(dimensionExpr)
+	}
+
+	tc.pushExprType(tc.arrayCreationType(rest), bounds)
+}
+
+// arrayCreationType returns the type of the array created by an array creation expression, e.g. `int[][]`
+// for `new int[5][]`.
+func (tc *typeChecker) arrayCreationType(rest *javaparser.ArrayCreatorRestContext) *typ.JavaType {
+	creator := rest.GetParent().(*javaparser.CreatorContext)
+	elementType := tc.lookupOrCreateType(creator.CreatedName().GetText())
+
+	dimensions := 0
+	for _, child := range rest.GetChildren() {
+		if terminal, ok := child.(antlr.TerminalNode); ok && terminal.GetText() == "[" {
+			dimensions++
+		}
+	}
+
+	return typ.NewArrayType(elementType, dimensions)
+}
+
+// e.g. `{1, 2, 3}` in `int[] numbers = {1, 2, 3}`
+func (tc *typeChecker) ExitArrayInitializer(ctx *javaparser.ArrayInitializerContext) {
+	bounds := loc.ParserRuleContextToBounds(ctx)
+
+	elements := make([]typedExpression, len(ctx.AllVariableInitializer()))
+	for i := len(elements) - 1; i >= 0; i-- {
+		elements[i] = tc.expressionStack.Pop()
+	}
+
+	arrayType := tc.arrayInitializerType(ctx)
+	if arrayType == nil || arrayType.Type != typ.JavaTypeArray {
+		message := "Array initializer needs an explicit target type"
+		if arrayType != nil {
+			message = fmt.Sprintf("Illegal initializer for %s", arrayType.ShortName())
+		}
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+		tc.pushAnyType(bounds)
+		return
+	}
+
+	componentType := arrayType.ComponentType()
+	for _, element := range elements {
+		if element.ttype != nil && !tc.assignable(element, componentType) {
+			tc.addError(TypeError{
+				Loc:         element.loc,
+				Message:     fmt.Sprintf("Type mismatch: cannot convert from %s to %s", element.ttype.ShortName(), componentType.ShortName()),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+		}
+	}
+
+	tc.pushExprType(arrayType, bounds)
+}
+
+// arrayInitializerType returns the type of the array an array initializer creates, which comes from
+// where it's used: the variable it initializes, the array creation expression it's part of, or the
+// array initializer it's nested inside of. Returns nil if there is none.
+func (tc *typeChecker) arrayInitializerType(ctx *javaparser.ArrayInitializerContext) *typ.JavaType {
+	switch parent := ctx.GetParent().(type) {
+	case *javaparser.VariableInitializerContext:
+		switch grandparent := parent.GetParent().(type) {
+		case *javaparser.VariableDeclaratorContext:
+			return tc.declaratorType(grandparent)
+		case *javaparser.ConstantDeclaratorContext:
+			return tc.constantType(grandparent)
+		case *javaparser.ArrayInitializerContext:
+			outer := tc.arrayInitializerType(grandparent)
+			if outer != nil && outer.Type == typ.JavaTypeArray {
+				return outer.ComponentType()
+			}
+		}
+	case *javaparser.ArrayCreatorRestContext:
+		return tc.arrayCreationType(parent)
+	}
+	return nil
+}
+
+// Types that can be used as array indexes and sizes, since they're promoted to int
+var intIndexTypes = util.SetFromValues("byte", "short", "char", "int", "Byte", "Short", "Character", "Integer")
+
+// checkIsIntIndex adds an error if an expression used as an array index or size isn't an int.
+func (tc *typeChecker) checkIsIntIndex(expr typedExpression) {
+	if expr.ttype == nil || expr.ttype.Type == typ.JavaTypeLSPAny {
+		return
+	}
+
+	isPrimitiveOrBoxed := expr.ttype.Type == typ.JavaTypePrimitive || expr.ttype.Package == "java.lang"
+	if !isPrimitiveOrBoxed || !intIndexTypes.Contains(expr.ttype.Name) {
+		tc.addError(TypeError{
+			Loc:         expr.loc,
+			Message:     fmt.Sprintf("Type mismatch: cannot convert from %s to int", expr.ttype.ShortName()),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+}
+
+// inferDiamondTypeArgs fills in the type arguments of a generic type created with the diamond `<>` from
+// the expected type alone, e.g. `String` for `List<String> list = new ArrayList<>()`. It's for when there's
+// no constructor to infer them from, see handleConstructorCall.
+func (tc *typeChecker) inferDiamondTypeArgs(createdType *typ.JavaType, expectedType *typ.JavaType) *typ.JavaType {
+	generic := createdType.GetOriginal()
+
+	// Treat it like a generic method returning `ArrayList<E>`
+	bindings := typ.InferTypeArgs(generic.TypeParams, nil, nil, generic.Parameterize(generic.TypeParams), tc.boxed(expectedType))
+
+	return generic.Parameterize(util.Map(generic.TypeParams, func(typeParam *typ.JavaType) *typ.JavaType {
+		return bindings[typeParam]
+	}))
 }
 
 func (tc *typeChecker) ExitMethodCall(ctx *javaparser.MethodCallContext) {
-	if tc.insideExpressionType(ExprTypeDotExpr) {
-		// If we're a method call inside of a dot expression (e.g. `System.exit()`), don't worry
-		// about it, since it'll get handled by handleDotExpr()
+	if parent, ok := ctx.GetParent().(*javaparser.ExpressionContext); ok && parent.GetDotop() != nil {
+		// If we're the method being called in a dot expression (e.g. `exit()` in `System.exit()`), don't
+		// worry about it, since it'll get handled by handleDotExpr(). Other method calls inside of a dot
+		// expression, like `first(list)` in `first(list).length()`, still get handled here.
 		return
 	}
 
-	// TODO handle this()/super() method calls (allowed in constructors only I believe)
 	ident := ctx.Identifier()
-	if ident != nil {
-		tc.handleIdentifier(ident.(*javaparser.IdentifierContext))
+	if ident == nil {
+		tc.handleConstructorInvocation(ctx)
+		return
 	}
 
+	methods, depth := tc.lookupMethods(ident.GetText())
+	if len(methods) > 0 {
+		if method := tc.handleMethodCall(ctx, methods, ident.GetText(), tc.expectedType(ctx.GetParent())); method != nil {
+			tc.checkStaticAccess(method, nil, tc.inStaticContextOf(depth), loc.ParserRuleContextToBounds(ident))
+			tc.checkAccess(method, nil, loc.ParserRuleContextToBounds(ident))
+		}
+		return
+	}
+
+	// Not a method, but it could still be something else by that name, like a field
+	tc.handleIdentifier(ident.(*javaparser.IdentifierContext))
+	tc.expressionStack.Pop()
+	tc.popArgs(ctx.ExpressionList())
+	tc.pushAnyType(loc.ParserRuleContextToBounds(ctx))
+}
+
+// handleConstructorInvocation checks an explicit call to another constructor of the same class, `this(...)`,
+// or to one of the superclass, `super(...)`. These are only allowed as the first statement of a constructor,
+// other than the compact canonical constructor of a record.
+func (tc *typeChecker) handleConstructorInvocation(ctx *javaparser.MethodCallContext) {
 	bounds := loc.ParserRuleContextToBounds(ctx)
+	args := tc.popArgs(ctx.ExpressionList())
 
-	// The identifier should be a method of type __LSPMethod__
-	methodType := tc.expressionStack.Pop().ttype
-	if methodType.Type != typ.JavaTypeLSPMethod {
-		//tc.logger.Error("method is not __LSPMethod__, instead it's: " + methodType.Name)
-		tc.pushAnyType(bounds)
+	switch {
+	case inCompactConstructor(ctx):
+		// The compact canonical constructor of a record always calls the superclass's constructor implicitly
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     "The body of a compact constructor must not contain an explicit constructor call",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	case !isFirstStatementOfConstructor(ctx):
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     "Constructor call must be the first statement in a constructor",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	}
+
+	enclosing := tc.getEnclosingType()
+	if enclosing == nil {
+		return
+	}
+
+	keyword := ctx.THIS()
+	targetType := enclosing
+	if keyword == nil {
+		keyword = ctx.SUPER()
+		targetType = tc.superclass(enclosing)
+	}
+	if targetType == nil {
+		return
+	}
+
+	constructors := targetType.LookupConstructors()
+	if len(constructors) == 0 {
+		return
+	}
+	overloads := util.Map(constructors, func(constructor *typ.JavaConstructor) typ.JavaSymbol {
+		return constructor
+	})
+
+	name := targetType.GetOriginal().Name
+	idx := tc.resolveCall(bounds, overloads, args, name)
+	if idx == -1 {
 		return
 	}
 
-	tc.handleMethodCall(ctx, methodType, ident.GetText())
+	tc.addConstructorUsage(loc.TokenToBounds(keyword.GetSymbol()), constructors[idx])
+	tc.checkAccess(constructors[idx], nil, bounds)
+	tc.checkCall(bounds, constructors[idx].GetType(), args, name, nil)
+}
+
+// superclass returns the class a class extends, which is Object if it doesn't say.
+func (tc *typeChecker) superclass(ttype *typ.JavaType) *typ.JavaType {
+	if len(ttype.Extends) > 0 && ttype.Extends[0] != nil {
+		return ttype.Extends[0]
+	}
+	return tc.lookupType("java.lang.Object")
+}
+
+// isFirstStatementOfConstructor says whether a method call is the entire first statement in the body of a
+// constructor, e.g. `super(name);`
+func isFirstStatementOfConstructor(ctx *javaparser.MethodCallContext) bool {
+	expression, ok := ctx.GetParent().(*javaparser.ExpressionContext)
+	if !ok {
+		return false
+	}
+	statement, ok := expression.GetParent().(*javaparser.StatementContext)
+	if !ok || statement.GetStatementExpression() != expression {
+		return false
+	}
+	blockStatement, ok := statement.GetParent().(*javaparser.BlockStatementContext)
+	if !ok {
+		return false
+	}
+	block, ok := blockStatement.GetParent().(*javaparser.BlockContext)
+	if !ok || block.BlockStatement(0) != blockStatement {
+		return false
+	}
+	constructor, ok := block.GetParent().(*javaparser.ConstructorDeclarationContext)
+	return ok && constructor.GetConstructorBody() == block
+}
+
+// lookupMethods returns the overloads of a method called without a `.`, e.g. `print()`: ones of the
+// innermost enclosing type that has a method by that name, or else ones brought in by static imports.
+// Also returns how far out that type is, as in inStaticContextOf.
+func (tc *typeChecker) lookupMethods(name string) ([]*typ.JavaMethod, int) {
+	enclosingTypes := tc.resolver.enclosingTypes
+	for depth := 0; depth < enclosingTypes.Size(); depth++ {
+		if methods := enclosingTypes.TopMinus(depth).LookupMethods(name); len(methods) > 0 {
+			return methods, depth
+		}
+	}
+	return tc.resolver.resolveStaticMethods(name), 0
+}
+
+// popArgs pops the arguments of a method or constructor call off of the expression stack, in the order
+// they were written in. The expression list may be nil if there aren't any.
+func (tc *typeChecker) popArgs(exprList javaparser.IExpressionListContext) []typedExpression {
+	numArgs := 0
+	if exprList != nil {
+		numArgs = len(exprList.(*javaparser.ExpressionListContext).AllExpression())
+	}
+
+	// Backwards, since that's the order they come off the stack in
+	args := make([]typedExpression, numArgs)
+	for i := numArgs - 1; i >= 0; i-- {
+		args[i] = tc.expressionStack.Pop()
+	}
+	return args
 }
 
-func (tc *typeChecker) handleMethodCall(ctx *javaparser.MethodCallContext, methodType *typ.JavaType, methodName string) {
+// handleMethodCall picks which of the overloads of a method is being called, checks the arguments, and
+// pushes its return type. The expected type of the call (see expectedType) is used to infer the type
+// arguments of generic methods, and may be nil. Returns the method being called, or nil if it's unclear.
+func (tc *typeChecker) handleMethodCall(ctx *javaparser.MethodCallContext, methods []*typ.JavaMethod, methodName string, expectedType *typ.JavaType) *typ.JavaMethod {
 	bounds := loc.ParserRuleContextToBounds(ctx)
 
-	// At this point, all expressions should be in order on the expression stack,
-	// from being previously visited.
-	// We just need to pop them off one by one and make sure they are compatible with
-	// the arguments of this method.
-	paramTypes := methodType.GenericArgs[2:]
-	// Iterate backwards since we'll pop them off the stack backwards
-	foundArguments := 0
-	for i := len(paramTypes) - 1; i >= 0; i-- {
-		// param is the one in the function def
-		paramType := paramTypes[i]
+	// At this point, all args should be in order on the expression stack, from being previously visited.
+	args := tc.popArgs(ctx.ExpressionList())
+
+	overloads := util.Map(methods, func(method *typ.JavaMethod) typ.JavaSymbol {
+		return method
+	})
+	idx := tc.resolveCall(bounds, overloads, args, methodName)
+	if idx == -1 {
+		tc.pushAnyType(bounds)
+		return nil
+	}
 
-		// arg is the one in the function call
-		argType := tc.expressionStack.Pop()
+	method := methods[idx]
+	tc.defUsages.Add(tc.makeCodeLocation(loc.ParserRuleContextToBounds(ctx.Identifier())), method, true)
+
+	// Now that the function call is resolved, push its return type onto the expression stack
+	tc.pushExprType(tc.checkCall(bounds, method.GetType(), args, methodName, expectedType), bounds)
+	return method
+}
+
+// resolveCall picks which of the overloads (methods or constructors) a call with the given args refers to.
+// Returns -1 and reports an error if it's ambiguous, or if none of several overloads fit the args. If there's
+// only one overload, it's picked either way, so that checkCall can say what's wrong with the args. Args of an
+// unknown type fit anything, so several overloads fitting them, or none fitting the others, isn't reported.
+func (tc *typeChecker) resolveCall(bounds loc.Bounds, overloads []typ.JavaSymbol, args []typedExpression, name string) int {
+	return tc.resolveCallOf(bounds, overloads, util.Map(overloads, typ.JavaSymbol.GetType), args, name)
+}
+
+// resolveCallOf is resolveCall with the types of the overloads given, e.g. generic ones for a diamond.
+func (tc *typeChecker) resolveCallOf(bounds loc.Bounds, overloads []typ.JavaSymbol, candidates []*typ.JavaType, args []typedExpression, name string) int {
+	argTypes := util.Map(args, func(arg typedExpression) *typ.JavaType {
+		return arg.ttype
+	})
+
+	resolved := typ.ResolveOverload(candidates, argTypes)
+	switch {
+	case len(resolved) == 1:
+		return resolved[0]
+	case len(overloads) > 1 && anyUnknown(argTypes):
+		return -1
+	case len(resolved) > 1:
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     fmt.Sprintf("Ambiguous call to %s! Both %s and %s match", name, signature(overloads[resolved[0]]), signature(overloads[resolved[1]])),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+		return -1
+	case len(overloads) == 1:
+		return 0
+	default:
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     fmt.Sprintf("No overload of %s matches the arguments (%s)", name, strings.Join(util.Map(argTypes, typeNameOrUnknown), ", ")),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+		return -1
+	}
+}
 
-		if argType.ttype == nil {
+// anyUnknown says whether any of the types is unknown, e.g. for an undefined variable or `null`.
+func anyUnknown(types []*typ.JavaType) bool {
+	for _, ttype := range types {
+		if ttype == nil || ttype.Type == typ.JavaTypeLSPAny {
+			return true
+		}
+	}
+	return false
+}
+
+// signature is how a method or constructor is referred to in error messages, e.g. `print(String s)`
+func signature(overload typ.JavaSymbol) string {
+	if method, ok := overload.(*typ.JavaMethod); ok {
+		return method.NameWithArgs()
+	}
+	return overload.ShortName()
+}
+
+// checkCall checks the args of a call to a method or constructor (the special LSP types), and returns the
+// type the call results in, with the type arguments of a generic method inferred.
+func (tc *typeChecker) checkCall(bounds loc.Bounds, callable *typ.JavaType, args []typedExpression, name string, expectedType *typ.JavaType) *typ.JavaType {
+	paramTypes := callable.CallableParams()
+	returnType := callable.CallableReturnType()
+	numArgs := len(args)
+
+	// A varargs method can be called with any number of args for its last parameter (JLS 15.12.2.4),
+	// unless an array is passed in for it directly.
+	variableArity := callable.IsVarargs && !tc.isArrayForVarargs(args, paramTypes)
+	if variableArity {
+		fixedParams := paramTypes[:len(paramTypes)-1]
+		componentType := paramTypes[len(paramTypes)-1].ComponentType()
+		expanded := append([]*typ.JavaType{}, fixedParams...)
+		for len(expanded) < numArgs {
+			expanded = append(expanded, componentType)
+		}
+		paramTypes = expanded
+	}
+
+	if len(callable.TypeParams) > 0 {
+		argTypes := util.Map(args, func(arg typedExpression) *typ.JavaType {
+			return tc.boxed(arg.ttype)
+		})
+		bindings := typ.InferTypeArgs(callable.TypeParams, paramTypes, argTypes, returnType, tc.boxed(expectedType))
+
+		paramTypes = util.Map(paramTypes, func(paramType *typ.JavaType) *typ.JavaType {
+			return paramType.Substitute(bindings)
+		})
+		returnType = returnType.Substitute(bindings)
+	}
+
+	for i, arg := range args {
+		if i >= len(paramTypes) {
+			break
+		}
+		// arg is the one in the function call, param is the one in the function def
+		paramType := paramTypes[i]
+
+		if arg.ttype != nil && !arg.ttype.CoercesTo(paramType) {
 			tc.addError(TypeError{
-				Loc:     bounds,
-				Message: fmt.Sprintf("Not enough arguments in function call to %s! Expected %d, got %d", methodName, len(paramTypes), foundArguments),
+				Loc:         bounds,
+				Message:     fmt.Sprintf("Can't use %s as type %s in function call to %s", arg.ttype.ShortName(), paramType.ShortName(), name),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
 			})
-		} else {
-			foundArguments++
+		}
+	}
 
-			if !argType.ttype.CoercesTo(paramType) {
-				tc.addError(TypeError{
-					Loc:     bounds,
-					Message: fmt.Sprintf("Can't use %s as type %s in function call to %s", argType.ttype.ShortName(), paramType.ShortName(), methodName),
-				})
-			}
+	if numArgs < len(paramTypes) {
+		expected := fmt.Sprintf("%d", len(paramTypes))
+		if variableArity {
+			expected = fmt.Sprintf("at least %d", len(paramTypes))
 		}
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     fmt.Sprintf("Not enough arguments in function call to %s! Expected %s, got %d", name, expected, numArgs),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+	} else if numArgs > len(paramTypes) {
+		tc.addError(TypeError{
+			Loc:         bounds,
+			Message:     fmt.Sprintf("Too many arguments in function call to %s! Expected %d, got %d", name, len(paramTypes), numArgs),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
 	}
 
-	// TODO check for too many arguments without clearing the entire expression stack
+	return returnType
+}
 
-	// Now that the function call is resolved, push its return type onto the expression stack
-	tc.pushExprType(methodType.GenericArgs[1], bounds)
+// typeNameOrUnknown is the name of a type for an error message, or `?` if the type isn't known.
+func typeNameOrUnknown(ttype *typ.JavaType) string {
+	if ttype == nil {
+		return "?"
+	}
+	return ttype.ShortName()
+}
+
+// isArrayForVarargs says whether the args of a call to a varargs method pass in an array for the varargs
+// parameter directly, e.g. `String.format(fmt, argsArray)`, in which case the call has fixed arity.
+func (tc *typeChecker) isArrayForVarargs(args []typedExpression, paramTypes []*typ.JavaType) bool {
+	if len(args) != len(paramTypes) || len(paramTypes) == 0 {
+		return false
+	}
+
+	last := args[len(args)-1].ttype
+	if last == nil {
+		return false
+	}
+	if last.Type == typ.JavaTypeLSPAny {
+		// `null` (or an expression with an error) is taken to be the array itself
+		return true
+	}
+	if last.Type != typ.JavaTypeArray {
+		return false
+	}
+
+	// Erased, so that e.g. `T...` takes a `String[]` as the array itself, but an `int[]` as a single arg
+	return last.CoercesTo(paramTypes[len(paramTypes)-1].Erasure())
 }
 
 func (tc *typeChecker) EnterExpression(ctx *javaparser.ExpressionContext) {
@@ -646,13 +1918,61 @@ func (tc *typeChecker) ExitExpression(ctx *javaparser.ExpressionContext) {
 		tc.handleDotExpr(ctx)
 	}
 
+	if ctx.GetIndexop() != nil {
+		tc.handleIndexExpr(ctx)
+	}
+
+	if ctx.COLONCOLON() != nil {
+		tc.handleMethodReference(ctx)
+	}
+
+	if prefix := ctx.GetPrefix(); prefix != nil {
+		tc.handleUnaryExpression(ctx, prefix.GetText())
+	} else if postfix := ctx.GetPostfix(); postfix != nil {
+		tc.handleUnaryExpression(ctx, postfix.GetText())
+	}
+
+	if ctx.GetTern() != nil {
+		tc.handleTernary(ctx)
+	}
+
 	bopToken := ctx.GetBop()
-	if bopToken != nil {
-		bop := bopToken.GetText()
-		tc.handleBinaryExpression(bop, loc.ParserRuleContextToBounds(ctx))
+	if bopToken != nil && ctx.INSTANCEOF() != nil {
+		tc.handleInstanceof(ctx)
+	} else if bopToken != nil {
+		tc.handleBinaryExpression(ctx)
 	}
 }
 
+// e.g. `numbers[0]`
+func (tc *typeChecker) handleIndexExpr(ctx *javaparser.ExpressionContext) {
+	bounds := loc.ParserRuleContextToBounds(ctx)
+
+	index := tc.expressionStack.Pop()
+	array := tc.expressionStack.Pop()
+
+	tc.checkIsIntIndex(index)
+
+	if array.ttype == nil || array.ttype.Type == typ.JavaTypeLSPAny {
+		tc.pushAnyType(bounds)
+		return
+	}
+	if array.ttype.Type != typ.JavaTypeArray {
+		tc.addError(TypeError{
+			Loc:         array.loc,
+			Message:     fmt.Sprintf("The type of the expression must be an array type but it resolved to %s", array.ttype.ShortName()),
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		})
+		tc.pushAnyType(bounds)
+		return
+	}
+
+	tc.pushExprType(array.ttype.ComponentType(), bounds)
+}
+
 func (tc *typeChecker) handleDotExpr(ctx *javaparser.ExpressionContext) {
 	// When entering the dot operator expression, we pushed a placeholder onto the stack.
 	// Now we pop off everything until that placeholder so we can deal with it in a different order.
@@ -665,6 +1985,16 @@ func (tc *typeChecker) handleDotExpr(ctx *javaparser.ExpressionContext) {
 	}
 
 	left := exprs[len(exprs)-1]
+	if left.ttype.Type == typ.JavaTypeLSPAny {
+		// There's already an error for the left side, so don't add more
+		tc.pushAnyType(loc.ParserRuleContextToBounds(ctx))
+		return
+	}
+
+	if ctx.THIS() != nil {
+		tc.handleQualifiedThis(ctx, left)
+		return
+	}
 
 	ident := ctx.Identifier()
 	if ident != nil {
@@ -674,15 +2004,26 @@ func (tc *typeChecker) handleDotExpr(ctx *javaparser.ExpressionContext) {
 
 		var memberType *typ.JavaType
 
-		if member == nil {
+		if nestedType := tc.nestedType(left.ttype, identName); member == nil && nestedType != nil {
+			// Or to a nested type (such as `Map.Entry`)
+			tc.defUsages.Add(tc.makeCodeLocation(loc.ParserRuleContextToBounds(ident)), nestedType, true)
+			memberType = nestedType.GetType()
+		} else if member == nil {
 			tc.addError(TypeError{
-				Loc:     loc.ParserRuleContextToBounds(ident),
-				Message: fmt.Sprintf("Can't find member named %s of type %s", identName, left.ttype.ShortName()),
+				Loc:         loc.ParserRuleContextToBounds(ident),
+				Message:     fmt.Sprintf("Can't find member named %s of type %s", identName, left.ttype.ShortName()),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
 			})
-			memberType = tc.lookupOrCreateType("any")
+			memberType = tc.lookupOrCreateType(typ.TypeNameLSPAny)
 		} else {
 			tc.defUsages.Add(tc.makeCodeLocation(loc.ParserRuleContextToBounds(ident)), member, true)
-			memberType = member.GetType()
+			tc.checkStaticAccess(member, left.ttype, false, loc.ParserRuleContextToBounds(ident))
+			tc.checkAccess(member, left.ttype, loc.ParserRuleContextToBounds(ident))
+			tc.pushMember(member, loc.ParserRuleContextToBounds(ctx))
+			return
 		}
 
 		tc.pushExprType(memberType, loc.ParserRuleContextToBounds(ctx))
@@ -701,25 +2042,33 @@ func (tc *typeChecker) handleDotExpr(ctx *javaparser.ExpressionContext) {
 		}
 
 		identName := ident.GetText()
-		member := left.ttype.LookupMember(identName)
+		methods := left.ttype.LookupMethods(identName)
 
-		if member == nil {
-			tc.addError(TypeError{
-				Loc:     loc.ParserRuleContextToBounds(ident),
-				Message: fmt.Sprintf("Can't find member named %s on type %s", identName, left.ttype.ShortName()),
-			})
-			tc.pushAnyType(loc.ParserRuleContextToBounds(ident))
-			return
-		}
+		if len(methods) == 0 {
+			member := left.ttype.LookupMember(identName)
+			if member == nil {
+				tc.addError(TypeError{
+					Loc:         loc.ParserRuleContextToBounds(ident),
+					Message:     fmt.Sprintf("Can't find member named %s on type %s", identName, left.ttype.ShortName()),
+					Related:     nil,
+					Severity:    SeverityError,
+					Unnecessary: false,
+					Fix:         nil,
+				})
+				tc.pushAnyType(loc.ParserRuleContextToBounds(ident))
+				return
+			}
 
-		tc.defUsages.Add(tc.makeCodeLocation(loc.ParserRuleContextToBounds(ident)), member, true)
+			tc.defUsages.Add(tc.makeCodeLocation(loc.ParserRuleContextToBounds(ident)), member, true)
 
-		methodType := member.GetType()
-		if methodType.Type != typ.JavaTypeLSPMethod {
 			bounds := loc.ParserRuleContextToBounds(methodCall)
 			tc.addError(TypeError{
-				Loc:     bounds,
-				Message: fmt.Sprintf("%s is not callable", methodType.FullName()),
+				Loc:         bounds,
+				Message:     fmt.Sprintf("%s is not callable", member.GetType().FullName()),
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
 			})
 			tc.pushAnyType(bounds)
 			return
@@ -731,188 +2080,63 @@ func (tc *typeChecker) handleDotExpr(ctx *javaparser.ExpressionContext) {
 		for _, arg := range args {
 			tc.expressionStack.Push(arg)
 		}
-		tc.handleMethodCall(methodCall, methodType, left.ttype.GetClassName()+"."+identName)
+		if method := tc.handleMethodCall(methodCall, methods, left.ttype.GetClassName()+"."+identName, tc.expectedType(ctx)); method != nil {
+			tc.checkStaticAccess(method, left.ttype, false, loc.ParserRuleContextToBounds(ident))
+			tc.checkAccess(method, left.ttype, loc.ParserRuleContextToBounds(ident))
+		}
 	}
 
 	// TODO handle other possibilities
 }
 
-// Binary operators that take in two numbers and return a number
-var concatBop = util.SetFromValues("+", "+=")
-var arithmeticBops = util.SetFromValues("+", "-", "*", "/", "%", "+=", "-=", "*=", "/=", "%=")
-var bitshiftBops = util.SetFromValues(">>", ">>>", "<<", ">>=", ">>>=", "<<=")
-var comparisonBops = util.SetFromValues("<", ">", "<=", ">=")
-var bitwiseBops = util.SetFromValues("&", "|", "^", "&=", "|=", "^=")
-var equalityBops = util.SetFromValues("==", "!=")
-var booleanBops = util.SetFromValues("&&", "||", "&&=", "||=")
-
-func (tc *typeChecker) handleBinaryExpression(bop string, exprBounds loc.Bounds) {
-	right := tc.expressionStack.Pop()
-	left := tc.expressionStack.Pop()
-
-	exprNilFunc := func(side string) {
-		tc.addError(TypeError{
-			Message: fmt.Sprintf("TODO: %s expression is nil (this shouldn't happen, contact extension maintainers)", side),
-			Loc:     exprBounds,
-		})
-		tc.expressionStack.Push(typedExpression{
-			loc:                 exprBounds,
-			ttype:               tc.lookupOrCreateType("any"),
-			placeholderExprType: ExprTypeUnset,
-		})
-	}
-	if right.ttype == nil {
-		exprNilFunc("right")
-		return
-	}
-	if left.ttype == nil {
-		exprNilFunc("left")
-		return
-	}
-
-	alwaysReturnString := func(_ *typ.JavaType, _ *typ.JavaType) *typ.JavaType {
-		return tc.lookupOrCreateType("String")
-	}
-	alwaysReturnBoolean := func(_ *typ.JavaType, _ *typ.JavaType) *typ.JavaType {
-		return tc.lookupOrCreateType("boolean")
-	}
-
-	opType := "unknown"
-	assertionFunc := emptyTypeAssertion
-	returnTypeFunc := alwaysReturnBoolean
-	// Some of the operators can contain = but are not assignment operators
-	definitelyNotAssignment := false
-
-	// Actually do the bop checking
-	if concatBop.Contains(bop) && (right.ttype.Name == "String" || left.ttype.Name == "String") {
-		opType = "concatenation"
-		assertionFunc = assertIsNumericOrString
-		returnTypeFunc = alwaysReturnString
-	} else if arithmeticBops.Contains(bop) {
-		opType = "arithmetic"
-		assertionFunc = assertIsNumeric
-		returnTypeFunc = determineArithmeticBopReturnType
-	} else if bitshiftBops.Contains(bop) {
-		opType = "bitshift"
-		assertionFunc = assertIsIntegral
-		returnTypeFunc = determineArithmeticBopReturnType
-	} else if bitwiseBops.Contains(bop) {
-		opType = "bitwise"
-		assertionFunc = assertIsIntegral
-		returnTypeFunc = determineArithmeticBopReturnType
-	} else if comparisonBops.Contains(bop) {
-		definitelyNotAssignment = true
-		opType = "comparison"
-		assertionFunc = assertIsNumeric
-		returnTypeFunc = alwaysReturnBoolean
-	} else if equalityBops.Contains(bop) {
-		definitelyNotAssignment = true
-		opType = "equality"
-		assertionFunc = emptyTypeAssertion
-		returnTypeFunc = alwaysReturnBoolean
-	} else if booleanBops.Contains(bop) {
-		opType = "boolean"
-		assertionFunc = assertIsBoolean
-		returnTypeFunc = alwaysReturnBoolean
-	}
-
-	var returnType *typ.JavaType
-
-	if !definitelyNotAssignment && strings.Contains(bop, "=") {
-		// Assignment is sort of a special case.
-		// Always returns the type of the left element
-		returnType = left.ttype
-	} else {
-		returnType = tc.determineBopReturnType(left, right, opType, assertionFunc, returnTypeFunc)
-	}
-
-	tc.expressionStack.Push(typedExpression{
-		loc:                 exprBounds,
-		ttype:               returnType,
-		placeholderExprType: ExprTypeUnset,
-	})
-}
-
-func (tc *typeChecker) determineBopReturnType(left, right typedExpression, opType string, assertionFunc func(ttype *typ.JavaType) bool, returnTypeFunc func(left *typ.JavaType, right *typ.JavaType) *typ.JavaType) *typ.JavaType {
-	// First check to make sure that both operands are valid types. If one is not, just return the other one.
-	assertionErrorFunc := func(expr typedExpression) {
-		tc.addError(TypeError{
-			Message: fmt.Sprintf("Cannot use %s operator on %s", opType, expr.ttype.Name),
-			Loc:     expr.loc,
-		})
-	}
-	if !assertionFunc(right.ttype) {
-		assertionErrorFunc(right)
-		return left.ttype
-	}
-	if !assertionFunc(left.ttype) {
-		assertionErrorFunc(left)
-		return right.ttype
+// nestedType looks up a type nested inside of the type referred to by a type name, e.g. `Entry` in `Map.Entry`.
+// Returns nil if there isn't one, or if it's not a type name.
+func (tc *typeChecker) nestedType(typeName *typ.JavaType, name string) *typ.JavaType {
+	if typeName.Type != typ.JavaTypeLSPClass {
+		return nil
 	}
-
-	// Then invoke returnTypeFunc to figure out what the new return type should be.
-	return returnTypeFunc(left.ttype, right.ttype)
+	return tc.resolver.get(typeName.GenericArgs[0].QualifiedName() + "." + name)
 }
 
-func assertIsNumericOrString(ttype *typ.JavaType) bool {
-	return ttype.Name == "String" || assertIsNumeric(ttype)
-}
-
-var numericTypes = util.SetFromValues("byte", "char", "short", "int", "long", "float", "double")
-
-func assertIsNumeric(ttype *typ.JavaType) bool {
-	return ttype.Type == typ.JavaTypePrimitive && numericTypes.Contains(ttype.Name)
-}
-
-var integralTypes = util.SetFromValues("byte", "char", "short", "int", "long")
-
-func assertIsIntegral(ttype *typ.JavaType) bool {
-	return ttype.Type == typ.JavaTypePrimitive && integralTypes.Contains(ttype.Name)
-}
-
-func assertIsBoolean(ttype *typ.JavaType) bool {
-	return ttype.Type == typ.JavaTypePrimitive && ttype.Name == "boolean"
-}
-
-func emptyTypeAssertion(_ *typ.JavaType) bool {
-	return true
-}
-
-// TODO char doesn't quite belong in this list, it's a bit of a special case
-// I think if you add 2 chars they stay a char
-// If you add a char to a short/byte it gets promoted to an int maybe? because char is unsigned
-var integralTypeWidths = []string{"byte", "short", "char", "int", "long"}
-
-// TODO this is for general arithmetic operators, it may be different for bitwise/bitshift ones
-// (e.g. right now this accepts Strings)
-func determineArithmeticBopReturnType(left *typ.JavaType, right *typ.JavaType) *typ.JavaType {
-	// If either left or right is a double, the return value is a double
-	if left.Name == "double" {
-		return left
-	}
-	if right.Name == "double" {
-		return right
+// handleQualifiedThis pushes the type of e.g. `Outer.this`, which is the instance of an enclosing type that
+// the code is inside of.
+func (tc *typeChecker) handleQualifiedThis(ctx *javaparser.ExpressionContext, left typedExpression) {
+	bounds := loc.ParserRuleContextToBounds(ctx)
+	if left.ttype.Type != typ.JavaTypeLSPClass {
+		tc.pushAnyType(bounds)
+		return
 	}
+	qualifyingType := left.ttype.GenericArgs[0]
 
-	// If left or right is a float, return value is a float
-	if left.Name == "float" {
-		return left
-	}
-	if right.Name == "float" {
-		return right
+	enclosingTypes := tc.resolver.enclosingTypes
+	for depth := 0; depth < enclosingTypes.Size(); depth++ {
+		enclosing := enclosingTypes.TopMinus(depth)
+		if enclosing.GetOriginal() != qualifyingType.GetOriginal() {
+			continue
+		}
+		if tc.inStaticContextOf(depth) {
+			tc.addError(TypeError{
+				Loc:         bounds,
+				Message:     "Cannot use this in a static context",
+				Related:     nil,
+				Severity:    SeverityError,
+				Unnecessary: false,
+				Fix:         nil,
+			})
+			tc.pushAnyType(bounds)
+			return
+		}
+		tc.pushExprType(enclosing, bounds)
+		return
 	}
 
-	// Finally, if both are integral types, we return whichever is wider
-	return widerOfIntegralTypes(left, right)
-}
-
-func widerOfIntegralTypes(left *typ.JavaType, right *typ.JavaType) *typ.JavaType {
-	idxOfLeft := slices.Index(integralTypeWidths, left.Name)
-	idxOfRight := slices.Index(integralTypeWidths, right.Name)
-
-	if idxOfLeft > idxOfRight {
-		return left
-	} else {
-		return right
-	}
+	tc.addError(TypeError{
+		Loc:         bounds,
+		Message:     fmt.Sprintf("No enclosing instance of the type %s is in scope", qualifyingType.ShortName()),
+		Related:     nil,
+		Severity:    SeverityError,
+		Unnecessary: false,
+		Fix:         nil,
+	})
+	tc.pushAnyType(bounds)
 }
@@ -1,11 +1,14 @@
 package typecheck
 
 import (
+	"fmt"
+	"github.com/antlr/antlr4/runtime/Go/antlr"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap/zaptest"
 	"java-mini-ls-go/parse"
 	"java-mini-ls-go/parse/loc"
 	"java-mini-ls-go/parse/typ"
+	"java-mini-ls-go/util"
 	"testing"
 )
 
@@ -187,6 +190,17 @@ public class MainClass {
 				},
 			},
 			Message: "Variable a is already defined in method add",
+			Related: []RelatedLocation{{
+				Loc: loc.CodeLocation{
+					FileUri: "type_checker_test",
+					Version: 0,
+					Loc: loc.Bounds{
+						Start: loc.FileLocation{Line: 4, Character: 6},
+						End:   loc.FileLocation{Line: 4, Character: 7},
+					},
+				},
+				Message: "a is first defined here",
+			}},
 		},
 	}, typeErrors)
 }
@@ -620,7 +634,7 @@ func TestCheckTypes_DotOperator_Method_OneArgument_Error(t *testing.T) {
 class MainClass {
 	void main() {
 		var a = System.in;
-		System.out.append("not_a_char");
+		System.out.append(false);
 	}
 }`)
 	typeErrors := typeCheckResult.TypeErrors
@@ -632,10 +646,10 @@ class MainClass {
 			},
 			End: loc.FileLocation{
 				Line:      5,
-				Character: 33,
+				Character: 26,
 			},
 		},
-		Message: "Can't use String as type char in function call to PrintStream.append",
+		Message: "No overload of PrintStream.append matches the arguments (boolean)",
 	}}, typeErrors)
 }
 
@@ -682,20 +696,2215 @@ class MainClass {
 					Character: 37,
 				},
 			},
-			Message: "Can't use String as type int in function call to HelperClass.print3things",
+			Message: "Not enough arguments in function call to HelperClass.print3things! Expected 3, got 2",
+		},
+	}, typeErrors)
+}
+
+// gatherAndCheckFiles gathers the types from all the given files before type checking each of them,
+// like the language server does for a workspace. Returns the type errors for each file.
+func gatherAndCheckFiles(t *testing.T, files map[string]string) map[string][]TypeError {
+	builtins, err := typ.LoadBuiltinTypes()
+	if err != nil {
+		t.Fatalf("Error loading builtin types: %s", err.Error())
+	}
+	userTypes := typ.NewTypeMap()
+
+	trees := map[string]antlr.Tree{}
+	defUsages := map[string]*DefinitionsUsagesLookup{}
+	for fileURI, code := range files {
+		tree, parseErrors := parse.Parse(code)
+		assert.Equal(t, 0, len(parseErrors))
+		trees[fileURI] = tree
+		defUsages[fileURI] = NewDefinitionsUsagesLookup()
+	}
+
+	for fileURI, tree := range trees {
+		GatherTypesFirstPass(fileURI, 0, tree, builtins, userTypes, defUsages[fileURI])
+	}
+	for fileURI, tree := range trees {
+		GatherTypesSecondPass(fileURI, 0, tree, builtins, userTypes, defUsages[fileURI])
+	}
+
+	ret := map[string][]TypeError{}
+	for fileURI, tree := range trees {
+		ret[fileURI] = CheckTypes(zaptest.NewLogger(t), fileURI, 0, tree, userTypes, builtins, defUsages[fileURI]).TypeErrors
+	}
+	return ret
+}
+
+func TestCheckTypes_SameNameDifferentPackages(t *testing.T) {
+	typeErrors := gatherAndCheckFiles(t, map[string]string{
+		"a/Util.java": `package a;
+public class Util {
+	public int get() { return 1; }
+}`,
+		"b/Util.java": `package b;
+public class Util {
+	public String get() { return "1"; }
+}`,
+		"a/SamePackage.java": `package a;
+public class SamePackage {
+	public void main() {
+		Util u = new Util();
+		int i = u.get();
+	}
+}`,
+		"c/Imported.java": `package c;
+import b.Util;
+public class Imported {
+	public void main() {
+		Util u = new Util();
+		String s = u.get();
+	}
+}`,
+		"c/OnDemand.java": `package c;
+import a.*;
+public class OnDemand {
+	public void main() {
+		Util u = new Util();
+		int i = u.get();
+	}
+}`,
+	})
+
+	for fileURI, errs := range typeErrors {
+		assert.Equal(t, []TypeError{}, errs, fileURI)
+	}
+}
+
+func TestCheckTypes_UserClassNamedObject(t *testing.T) {
+	typeErrors := gatherAndCheckFiles(t, map[string]string{
+		"mine/Object.java": `package mine;
+public class Object {}`,
+		"mine/UsesMine.java": `package mine;
+public class UsesMine {
+	public void main() {
+		Object o = 5;
+	}
+}`,
+		"other/UsesJavaLang.java": `package other;
+public class UsesJavaLang {
+	public void main() {
+		Object o = 5;
+	}
+}`,
+	})
+
+	assert.Equal(t, []TypeError{}, typeErrors["other/UsesJavaLang.java"])
+	assert.Equal(t, []TypeError{
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 4, Character: 13},
+				End:   loc.FileLocation{Line: 4, Character: 14},
+			},
+			Message: "Type mismatch: cannot convert from int to Object",
+			Related: nil,
 		},
+	}, typeErrors["mine/UsesMine.java"])
+}
+
+func TestCheckTypes_Imports_Errors(t *testing.T) {
+	typeErrors := gatherAndCheckFiles(t, map[string]string{
+		"Main.java": `package main;
+import java.util.Nope;
+import nope.*;
+import static java.lang.Math.nope;
+import java.util.List;
+import java.util.*;
+public class Main {
+	public void main() {
+		ArrayList list = new ArrayList();
+	}
+}`,
+	})
+
+	assert.Equal(t, []TypeError{
 		{
 			Loc: loc.Bounds{
-				Start: loc.FileLocation{
-					Line:      10,
-					Character: 14,
-				},
-				End: loc.FileLocation{
-					Line:      10,
-					Character: 37,
+				Start: loc.FileLocation{Line: 2, Character: 0},
+				End:   loc.FileLocation{Line: 2, Character: 22},
+			},
+			Message: "The import java.util.Nope cannot be resolved",
+			Related: nil,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 3, Character: 0},
+				End:   loc.FileLocation{Line: 3, Character: 14},
+			},
+			Message: "The import nope cannot be resolved",
+			Related: nil,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 4, Character: 0},
+				End:   loc.FileLocation{Line: 4, Character: 34},
+			},
+			Message: "The import java.lang.Math.nope cannot be resolved",
+			Related: nil,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 5, Character: 0},
+				End:   loc.FileLocation{Line: 5, Character: 22},
+			},
+			Message:     "The import java.util.List is never used",
+			Related:     nil,
+			Severity:    SeverityWarning,
+			Unnecessary: true,
+		},
+	}, typeErrors["Main.java"])
+}
+
+func TestCheckTypes_Imports_StaticWithoutMember(t *testing.T) {
+	typeErrors := gatherAndCheckFiles(t, map[string]string{
+		"Foo.java": `public class Foo {}`,
+		"Main.java": `import static Foo;
+public class Main {}`,
+	})
+
+	assert.Equal(t, []TypeError{
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 1, Character: 0},
+				End:   loc.FileLocation{Line: 1, Character: 18},
+			},
+			Message: "The import Foo cannot be resolved",
+			Related: nil,
+		},
+	}, typeErrors["Main.java"])
+}
+
+func TestCheckTypes_Imports_Redundant(t *testing.T) {
+	typeErrors := gatherAndCheckFiles(t, map[string]string{
+		"Main.java": `import java.util.List;
+import java.util.List;
+import java.util.*;
+import java.io.*;
+import java.io.File;
+public class Main {
+	public void main(List<String> names, File file, Reader reader) {}
+}`,
+	})
+
+	unused := func(line int, end int, name string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: 0},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     fmt.Sprintf("The import %s is never used", name),
+			Related:     nil,
+			Severity:    SeverityWarning,
+			Unnecessary: true,
+		}
+	}
+	assert.Equal(t, []TypeError{
+		unused(2, 22, "java.util.List"),
+		unused(3, 19, "java.util"),
+	}, typeErrors["Main.java"])
+}
+
+func TestCheckTypes_StaticImports(t *testing.T) {
+	typeErrors := gatherAndCheckFiles(t, map[string]string{
+		"util/Util.java": `package util;
+public class Util {
+	public static int answer() { return 42; }
+	public static String NAME = "util";
+}`,
+		"main/Main.java": `package main;
+import static util.Util.answer;
+import static util.Util.*;
+import static java.lang.Math.PI;
+public class Main {
+	public void main() {
+		int i = answer();
+		String s = NAME;
+		double d = PI;
+	}
+}`,
+	})
+
+	assert.Equal(t, []TypeError{}, typeErrors["main/Main.java"])
+}
+
+func TestCheckTypes_ImportUsage(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `import java.util.ArrayList;
+public class Main {
+	public void main() {
+		ArrayList list = new ArrayList();
+	}
+}`)
+
+	assert.Equal(t, []TypeError{}, typeCheckResult.TypeErrors)
+
+	result := typeCheckResult.DefUsagesLookup.Lookup(loc.FileLocation{Line: 1, Character: 20})
+	ttype, ok := result.(*typ.JavaType)
+	assert.True(t, ok)
+	assert.Equal(t, "java.util.ArrayList", ttype.QualifiedName())
+}
+
+func TestCheckTypes_Imports_ShadowedByMemberTypes(t *testing.T) {
+	typeErrors := gatherAndCheckFiles(t, map[string]string{
+		"shapes/Entry.java": `package shapes;
+public class Entry {
+	public int sides;
+}`,
+		"app/Main.java": `package app;
+import shapes.Entry;
+public class Main {
+	static class Entry {
+		String name;
+	}
+	void main(Entry entry) {
+		String name = entry.name;
+		int sides = entry.sides;
+	}
+}`,
+	})
+
+	// The member type is the one in scope, not the imported one (JLS 6.4.1)
+	assert.Equal(t, []TypeError{
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 9, Character: 20},
+				End:   loc.FileLocation{Line: 9, Character: 25},
+			},
+			Message:     "Can't find member named sides of type Entry",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+	}, typeErrors["app/Main.java"])
+}
+
+func TestCheckTypes_UnresolvedTypes(t *testing.T) {
+	unresolved := func(line int, start int, end int, message string, fix *QuickFix) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         fix,
+		}
+	}
+	importFix := func(title string, line int, character int, newText string) *QuickFix {
+		return &QuickFix{
+			Title: title,
+			Edits: []TextEdit{{
+				Loc: loc.Bounds{
+					Start: loc.FileLocation{Line: line, Character: character},
+					End:   loc.FileLocation{Line: line, Character: character},
 				},
+				NewText: newText,
+			}},
+		}
+	}
+
+	// Types outside of java.lang have to be imported, which the quick fix does after the other imports
+	typeCheckResult := parseAndTypeCheck(t, `package app;
+
+import java.util.List;
+
+public class Main {
+	public void main(Map.Entry<String, Integer> entry) {
+		List<String> names = new ArrayList<>();
+		Missing missing = null;
+		Collections.sort(names);
+	}
+}`)
+	assert.Equal(t, []TypeError{
+		unresolved(6, 18, 27, "Map.Entry cannot be resolved to a type", importFix("Import 'Map' (java.util)", 3, 22, "\nimport java.util.Map;")),
+		unresolved(7, 27, 36, "ArrayList cannot be resolved to a type", importFix("Import 'ArrayList' (java.util)", 3, 22, "\nimport java.util.ArrayList;")),
+		unresolved(8, 2, 9, "Missing cannot be resolved to a type", nil),
+		unresolved(9, 2, 13, "Unknown identifier: Collections", importFix("Import 'Collections' (java.util)", 3, 22, "\nimport java.util.Collections;")),
+	}, typeCheckResult.TypeErrors)
+
+	// Without any imports, it goes after the package declaration
+	typeCheckResult = parseAndTypeCheck(t, `package app;
+
+public class Main {
+	Set<String> names;
+}`)
+	assert.Equal(t, []TypeError{
+		unresolved(4, 1, 4, "Set cannot be resolved to a type", importFix("Import 'Set' (java.util)", 1, 12, "\n\nimport java.util.Set;")),
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_Generics_BuiltinTypes(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `import java.util.List;
+public class Main {
+	public void main(List<String> list, List raw) {
+		int length = list.get(0).length();
+		String s = list.get(0);
+		Integer i = list.get(0);
+		Object o = raw.get(0);
+	}
+}`)
+
+	assert.Equal(t, []TypeError{
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 6, Character: 19},
+				End:   loc.FileLocation{Line: 6, Character: 25},
 			},
-			Message: "Not enough arguments in function call to HelperClass.print3things! Expected 3, got 2",
+			Message: "Type mismatch: cannot convert from String to Integer",
+			Related: nil,
 		},
-	}, typeErrors)
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_Generics_UserTypes(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `import java.util.List;
+public class Box<T extends Number> {
+	private T value;
+	public T get() {
+		T copy = value;
+		Number n = value;
+		String s = value;
+		return copy;
+	}
+	public <R> R identity(R r) {
+		R copy = r;
+		T other = r;
+		return copy;
+	}
+}
+class Main {
+	public void main(Box<Integer> box, List<Integer> ints, List<? extends Number> nums) {
+		Integer i = box.get();
+		int v = box.get().intValue();
+		Number n = nums.get(0);
+		List<? extends Number> wide = ints;
+		List<Number> narrow = ints;
+	}
+}`)
+
+	assert.Equal(t, []TypeError{
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 7, Character: 13},
+				End:   loc.FileLocation{Line: 7, Character: 18},
+			},
+			Message: "Type mismatch: cannot convert from T to String",
+			Related: nil,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 12, Character: 12},
+				End:   loc.FileLocation{Line: 12, Character: 13},
+			},
+			Message: "Type mismatch: cannot convert from R to T",
+			Related: nil,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 22, Character: 24},
+				End:   loc.FileLocation{Line: 22, Character: 28},
+			},
+			Message: "Type mismatch: cannot convert from List<Integer> to List<Number>",
+			Related: nil,
+		},
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_Generics_Inference(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `import java.util.*;
+public class Main {
+	public <T> T first(List<T> list) {
+		return list.get(0);
+	}
+	public <T> List<T> none() {
+		return Collections.emptyList();
+	}
+	public void main(List<String> strings) {
+		String s = first(strings);
+		int length = first(strings).length();
+		Integer i = first(strings);
+		List<String> empty = none();
+		List<Integer> single = Collections.singletonList(5);
+		List<String> list = new ArrayList<>();
+		Map<String, Integer> map = new HashMap<>();
+		Integer value = map.get("a");
+		String key = map.get("a");
+		List<Integer> wrong = listOf("a");
+	}
+	public <T> List<T> listOf(T t) {
+		return new ArrayList<>();
+	}
+}`)
+
+	assert.Equal(t, []TypeError{
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 12, Character: 14},
+				End:   loc.FileLocation{Line: 12, Character: 28},
+			},
+			Message:     "Type mismatch: cannot convert from String to Integer",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 18, Character: 19},
+				End:   loc.FileLocation{Line: 18, Character: 27},
+			},
+			Message:     "Type mismatch: cannot convert from Integer to String",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 19, Character: 24},
+				End:   loc.FileLocation{Line: 19, Character: 35},
+			},
+			Message:     "Can't use String as type Integer in function call to listOf",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_Generics_DiamondOverloads(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `import java.util.*;
+public class Main {
+	static class Coll<T> {}
+	static class Box<V> {
+		Box() {}
+		Box(int n) {}
+		Box(Coll<V> c) {}
+		V get() { return null; }
+	}
+	public void main(List<String> names, Set<String> set, Map<String, Integer> counts, Comparator<String> cmp, Coll<String> strings) {
+		List<String> copy = new ArrayList<>(names);
+		Set<String> setCopy = new HashSet<>(set);
+		Map<String, Integer> mapCopy = new HashMap<>(counts);
+		List<String> linked = new LinkedList<>(names);
+		Queue<String> queue = new PriorityQueue<>(cmp);
+		Box<String> box = new Box<>(strings);
+		int length = new Box<>(strings).get().length();
+		Box<String> sized = new Box<>(5);
+		Box<String> noFit = new Box<>("a");
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		}
+	}
+
+	// The type arguments are inferred for each of the overloads to see which ones fit
+	assert.Equal(t, []TypeError{
+		expectedError(19, 26, 36, "No overload of Box matches the arguments (String)"),
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_Arrays(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `public class Main {
+	private String names[] = {"a", "b"};
+	public void main(String[] args) {
+		int[] numbers = {1, 2, 3};
+		int[][] grid = new int[3][];
+		String[] copy = new String[] {"a", "b"};
+		int length = args.length + numbers.length;
+		String first = args[0];
+		int last = numbers[numbers.length - 1];
+		int[] row = grid[0];
+		Object[] objects = args;
+		Object object = numbers;
+		String[] cloned = args.clone();
+		String bad = numbers[0];
+		String[] badArray = {1};
+		long index = 0;
+		int badIndex = numbers[index];
+		long[] longs = numbers;
+		int notArray = length[0];
+		int[] sized = new int["3"];
+		String viaGeneric = firstOf(args);
+	}
+	public <T> T firstOf(T[] items) {
+		return items[0];
+	}
+}`)
+
+	assert.Equal(t, []TypeError{
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 14, Character: 15},
+				End:   loc.FileLocation{Line: 14, Character: 25},
+			},
+			Message:     "Type mismatch: cannot convert from int to String",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 15, Character: 23},
+				End:   loc.FileLocation{Line: 15, Character: 24},
+			},
+			Message:     "Type mismatch: cannot convert from int to String",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 17, Character: 25},
+				End:   loc.FileLocation{Line: 17, Character: 30},
+			},
+			Message:     "Type mismatch: cannot convert from long to int",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 18, Character: 17},
+				End:   loc.FileLocation{Line: 18, Character: 24},
+			},
+			Message:     "Type mismatch: cannot convert from int[] to long[]",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 19, Character: 17},
+				End:   loc.FileLocation{Line: 19, Character: 23},
+			},
+			Message:     "The type of the expression must be an array type but it resolved to int",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 20, Character: 24},
+				End:   loc.FileLocation{Line: 20, Character: 27},
+			},
+			Message:     "Type mismatch: cannot convert from String to int",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_Varargs(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+import java.util.*;
+
+class HelperClass {
+	static int sum(String label, int... nums) {
+		return nums.length;
+	}
+
+	static <T> T firstOf(T... items) {
+		return items[0];
+	}
+}
+
+class MainClass {
+	void main() {
+		String a = String.format("none");
+		String b = String.format("%s", "one");
+		String c = String.format("%s %s", "one", 2);
+		String[] arr = new String[] { "one", "two" };
+		String d = String.format("%s %s", arr);
+		int e = HelperClass.sum("none");
+		int f = HelperClass.sum("array", new int[] { 1, 2 });
+		int g = HelperClass.sum("many", 1, 2, 3);
+		String h = HelperClass.firstOf("one", "two");
+		String i = HelperClass.firstOf(arr);
+		List<Integer> nums = Arrays.asList(1, 2, 3);
+		int j = HelperClass.sum("wrong", 1, "two");
+		int k = HelperClass.sum();
+	}
+}`)
+	assert.Equal(t, []TypeError{
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 27, Character: 22},
+				End:   loc.FileLocation{Line: 27, Character: 44},
+			},
+			Message:     "Can't use String as type int in function call to HelperClass.sum",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 28, Character: 22},
+				End:   loc.FileLocation{Line: 28, Character: 27},
+			},
+			Message:     "Not enough arguments in function call to HelperClass.sum! Expected at least 1, got 0",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_Varargs_Hover(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+class HelperClass {
+	static int sum(String label, int... nums) {
+		return nums.length;
+	}
+}`)
+	sum := typeCheckResult.DefUsagesLookup.Lookup(loc.FileLocation{Line: 3, Character: 13})
+	assert.NotNil(t, sum)
+	method, ok := sum.(*typ.JavaMethod)
+	assert.True(t, ok)
+	assert.Equal(t, "String label", method.Params[0].String())
+	assert.Equal(t, "int... nums", method.Params[1].String())
+	assert.True(t, method.GetType().IsVarargs)
+}
+
+func TestCheckTypes_Overloads(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+class Printer {
+	void print(int a) {}
+	void print(long a) {}
+	void print(Object a) {}
+	void print(String a, Object... rest) {}
+	void pick(Integer a, int b) {}
+	void pick(int a, Integer b) {}
+}
+
+class MainClass {
+	void main() {
+		Printer p = new Printer();
+		p.print(1);
+		p.print(1L);
+		Integer boxed = 1;
+		p.print(boxed);
+		p.print('c');
+		p.print("a", 1, 2);
+		p.pick(1, 2);
+		p.print(true, 2);
+		long m = Math.max(1, 2L);
+		int n = Math.max(1, 2);
+		int o = Math.max(1, 2L);
+	}
+}`)
+	assert.Equal(t, []TypeError{
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 20, Character: 4},
+				End:   loc.FileLocation{Line: 20, Character: 14},
+			},
+			Message:     "Ambiguous call to Printer.pick! Both pick(Integer a,int b) and pick(int a,Integer b) match",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 21, Character: 4},
+				End:   loc.FileLocation{Line: 21, Character: 18},
+			},
+			Message:     "No overload of Printer.print matches the arguments (boolean, int)",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 24, Character: 15},
+				End:   loc.FileLocation{Line: 24, Character: 25},
+			},
+			Message:     "Type mismatch: cannot convert from long to int",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+	}, typeCheckResult.TypeErrors)
+
+	// Usages and definitions go to the overload that's called
+	assertOverload := func(line int, expected string) {
+		symbol := typeCheckResult.DefUsagesLookup.Lookup(loc.FileLocation{Line: line, Character: 5})
+		method, ok := symbol.(*typ.JavaMethod)
+		if assert.Truef(t, ok, "No method at line %d", line) {
+			assert.Equal(t, expected, method.NameWithArgs(), "Wrong overload at line %d", line)
+		}
+	}
+	assertOverload(14, "print(int a)")
+	assertOverload(15, "print(long a)")
+	assertOverload(17, "print(Object a)")
+	assertOverload(18, "print(int a)")
+	assertOverload(19, "print(String a,Object... rest)")
+
+	printLong := typeCheckResult.DefUsagesLookup.Lookup(loc.FileLocation{Line: 4, Character: 7}).(*typ.JavaMethod)
+	assert.Equal(t, 1, len(printLong.Usages))
+	assert.Equal(t, 15, printLong.Usages[0].Loc.Start.Line)
+}
+
+func TestCheckTypes_Overloads_UnknownArgs(t *testing.T) {
+	// Only the undefined variables are reported, not which overloads they might fit
+	typeCheckResult := parseAndTypeCheck(t, `
+class MainClass {
+	void main() {
+		System.out.println(nope);
+		String s = String.valueOf(nope);
+		Math.max(nope, 1L);
+	}
+}`)
+	unknownIdentifier := func(line int, start int, end int) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     "Unknown identifier: nope",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		}
+	}
+	assert.Equal(t, []TypeError{
+		unknownIdentifier(4, 21, 25),
+		unknownIdentifier(5, 28, 32),
+		unknownIdentifier(6, 11, 15),
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_Constructors(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+import java.util.*;
+
+class Animal {
+	Animal(String name) {}
+	Animal(String name, int legs) {
+		this(name);
+	}
+}
+
+class Dog extends Animal {
+	Dog() {
+		super("dog");
+	}
+	Dog(int legs) {
+		System.out.println("hi");
+		super("dog", legs);
+	}
+}
+
+class Empty {}
+
+class Box<T> {
+	Box(T value) {}
+}
+
+class MainClass {
+	void main() {
+		Animal a = new Animal("cat");
+		Animal b = new Animal("cat", 4);
+		Animal c = new Animal(4);
+		Empty e = new Empty();
+		Empty f = new Empty(1);
+		Box<String> box = new Box<>("hi");
+		Box<Integer> wrong = new Box<>("hi");
+		Box<String> explicit = new Box<String>(1);
+		List<String> list = new ArrayList<>();
+		Runnable r = new Runnable();
+	}
+}`)
+	assert.Equal(t, []TypeError{
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 17, Character: 2},
+				End:   loc.FileLocation{Line: 17, Character: 20},
+			},
+			Message:     "Constructor call must be the first statement in a constructor",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 31, Character: 17},
+				End:   loc.FileLocation{Line: 31, Character: 26},
+			},
+			Message:     "No overload of Animal matches the arguments (int)",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 33, Character: 16},
+				End:   loc.FileLocation{Line: 33, Character: 24},
+			},
+			Message:     "Too many arguments in function call to Empty! Expected 0, got 1",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 35, Character: 27},
+				End:   loc.FileLocation{Line: 35, Character: 38},
+			},
+			Message:     "Can't use String as type Integer in function call to Box",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 36, Character: 29},
+				End:   loc.FileLocation{Line: 36, Character: 43},
+			},
+			Message:     "Can't use int as type String in function call to Box",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 38, Character: 19},
+				End:   loc.FileLocation{Line: 38, Character: 29},
+			},
+			Message:     "Cannot instantiate the type Runnable",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+	}, typeCheckResult.TypeErrors)
+
+	// Usages are recorded on the constructor that's called
+	animalName := typeCheckResult.DefUsagesLookup.Lookup(loc.FileLocation{Line: 5, Character: 2}).(*typ.JavaConstructor)
+	assert.Equal(t, []int{7, 13, 29}, util.Map(animalName.Usages, func(usage loc.CodeLocation) int {
+		return usage.Loc.Start.Line
+	}))
+	assert.Equal(t, animalName, typeCheckResult.DefUsagesLookup.Lookup(loc.FileLocation{Line: 29, Character: 17}))
+
+	// Including the implicit default constructor
+	empty := typeCheckResult.DefUsagesLookup.Lookup(loc.FileLocation{Line: 32, Character: 17}).(*typ.JavaType)
+	assert.Equal(t, 2, len(empty.Constructors[0].Usages))
+}
+
+func TestCheckTypes_StaticContext(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+class Counter {
+	static int total;
+	int count;
+	static int max = count;
+	void increment() { count = total; }
+	static void reset() {
+		count = 0;
+		increment();
+		int x = this.count;
+		total = 0;
+	}
+}
+
+class MainClass {
+	void main() {
+		Counter c = new Counter();
+		int a = Counter.count;
+		Counter.increment();
+		int b = c.total;
+		c.reset();
+		int d = Counter.total + c.count;
+		Counter.reset();
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string, severity TypeErrorSeverity) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    severity,
+			Unnecessary: false,
+		}
+	}
+	assert.Equal(t, []TypeError{
+		expectedError(5, 18, 23, "Cannot make a static reference to the non-static field count", SeverityError),
+		expectedError(8, 2, 7, "Cannot make a static reference to the non-static field count", SeverityError),
+		expectedError(9, 2, 11, "Cannot make a static reference to the non-static method increment() from the type Counter", SeverityError),
+		expectedError(10, 10, 14, "Cannot use this in a static context", SeverityError),
+		expectedError(18, 18, 23, "Cannot make a static reference to the non-static field count", SeverityError),
+		expectedError(19, 10, 19, "Cannot make a static reference to the non-static method increment() from the type Counter", SeverityError),
+		expectedError(20, 12, 17, "The static field Counter.total should be accessed in a static way", SeverityWarning),
+		expectedError(21, 4, 9, "The static method reset() from the type Counter should be accessed in a static way", SeverityWarning),
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_AccessControl(t *testing.T) {
+	typeErrors := gatherAndCheckFiles(t, map[string]string{
+		"shapes/Shape.java": `package shapes;
+public class Shape {
+	private int id;
+	int sides;
+	protected String name;
+	public int area;
+	protected Shape() {}
+	private void secret() {}
+	protected static int count() { return 0; }
+	void main() {
+		secret();
+		int i = new Shape().id;
+	}
+}`,
+		"shapes/Square.java": `package shapes;
+public class Square {
+	void main() {
+		Shape s = new Shape();
+		int a = s.sides;
+		String n = s.name;
+		int i = s.id;
+		s.secret();
+	}
+}`,
+		"other/Circle.java": `package other;
+import shapes.Shape;
+public class Circle extends Shape {
+	Circle() {
+		super();
+	}
+	void main(Shape shape, Circle circle) {
+		String a = name;
+		String b = circle.name;
+		String c = shape.name;
+		int d = sides;
+		int e = Shape.count();
+		Shape f = new Shape();
+		int g = shape.area;
+	}
+}`,
+	})
+
+	notVisible := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		}
+	}
+	assert.Equal(t, []TypeError{}, typeErrors["shapes/Shape.java"])
+	assert.Equal(t, []TypeError{
+		notVisible(7, 12, 14, "The field Shape.id is not visible"),
+		notVisible(8, 4, 10, "The method secret() from the type Shape is not visible"),
+	}, typeErrors["shapes/Square.java"])
+	assert.Equal(t, []TypeError{
+		notVisible(10, 19, 23, "The field Shape.name is not visible"),
+		notVisible(11, 10, 15, "The field Shape.sides is not visible"),
+		notVisible(13, 16, 23, "The constructor Shape() is not visible"),
+	}, typeErrors["other/Circle.java"])
+}
+
+func TestCheckTypes_AccessControl_AnonymousClasses(t *testing.T) {
+	typeErrors := gatherAndCheckFiles(t, map[string]string{
+		"shapes/Shape.java": `package shapes;
+public class Shape {
+	protected Shape() {}
+	Shape(int sides) {}
+	private Shape(String name) {}
+}`,
+		"other/Main.java": `package other;
+import shapes.Shape;
+public class Main {
+	void main() {
+		Shape a = new Shape() {};
+		Shape b = new Shape(3) {};
+		Shape c = new Shape("c") {};
+		Shape d = new Shape();
+	}
+}`,
+	})
+
+	notVisible := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		}
+	}
+	// An anonymous class can call a protected constructor, but not one that's private or package-private
+	assert.Equal(t, []TypeError{
+		notVisible(6, 16, 27, "The constructor Shape(int sides) is not visible"),
+		notVisible(7, 16, 29, "The constructor Shape(String name) is not visible"),
+		notVisible(8, 16, 23, "The constructor Shape() is not visible"),
+	}, typeErrors["other/Main.java"])
+}
+
+func TestCheckTypes_NestedClasses(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+import java.util.Map;
+
+class Outer {
+	private int count;
+	static int total;
+
+	class Inner {
+		int get() { return count + total; }
+		Outer outer() { return Outer.this; }
+	}
+
+	static class Nested {
+		int get() { return count; }
+		void set() { increment(); }
+		Outer outer() { return Outer.this; }
+	}
+
+	void increment() { count++; }
+
+	void main(Map.Entry<String, Integer> entry) {
+		String key = entry.getKey();
+		Inner inner = new Inner();
+		Outer.Nested nested = new Outer.Nested();
+		int n = nested.get() + inner.get();
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		}
+	}
+	assert.Equal(t, []TypeError{
+		expectedError(14, 21, 26, "Cannot make a static reference to the non-static field count"),
+		expectedError(15, 15, 24, "Cannot make a static reference to the non-static method increment() from the type Outer"),
+		expectedError(16, 25, 35, "Cannot use this in a static context"),
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_LocalAndAnonymousClasses(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+class Outer {
+	int count;
+
+	void increment() { count++; }
+
+	void main() {
+		int limit = 3;
+		class Local {
+			int twice() { return limit * 2 + count; }
+		}
+		Local local = new Local();
+		int t = local.twice();
+
+		Runnable r = new Runnable() {
+			int runs;
+			public void run() { runs++; increment(); String s = runs; }
+		};
+		r.run();
+		Object o = new Object() {
+			public String toString() { return "" + limit; }
+		};
+		String str = o.toString();
+	}
+
+	void other() {
+		int t = new Local().twice();
+		class Local {
+			String twice() { return "twice"; }
+		}
+		String s = new Local().twice();
+	}
+
+	static void staticMain() {
+		class StaticLocal {
+			int get() { return count; }
+		}
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		}
+	}
+	assert.Equal(t, []TypeError{
+		expectedError(17, 55, 59, "Type mismatch: cannot convert from int to String"),
+		expectedError(27, 14, 19, "Local cannot be resolved to a type"),
+		expectedError(36, 22, 27, "Cannot make a static reference to the non-static field count"),
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_Lambdas(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+import java.util.List;
+import java.util.ArrayList;
+import java.util.function.Function;
+import java.util.function.Supplier;
+import java.util.function.BiFunction;
+
+class Lambdas {
+	Supplier<String> greeting = () -> "hi";
+
+	static <T, R> List<R> mapAll(List<T> list, Function<? super T, ? extends R> mapper) {
+		return new ArrayList<>();
+	}
+
+	void main() {
+		List<String> names = new ArrayList<>();
+		Function<String, Integer> length = s -> s.length();
+		BiFunction<Integer, Integer, Integer> compare = (a, b) -> a.compareTo(b);
+		Runnable run = () -> names.clear();
+		names.forEach(name -> { String upper = name.toUpperCase(); });
+		List<Integer> lengths = mapAll(names, name -> name.length());
+		Supplier<Integer> block = () -> {
+			int answer = 42;
+			return answer;
+		};
+		Function<Integer, Function<Integer, Integer> > curried = x -> y -> x.compareTo(y);
+		length = (String s) -> s.indexOf("a");
+
+		Function<String, Integer> wrong = s -> s;
+		Runnable notVoid = () -> 1 + 2;
+		Supplier<String> voidValue = () -> names.clear();
+		String notFunctional = () -> "hi";
+		Function<String, Integer> arity = (a, b) -> 1;
+		Supplier<String> blockWrong = () -> {
+			return 5;
+		};
+		List<String> mapped = mapAll(names, name -> name.length());
+		int unknown = name.length();
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		}
+	}
+	assert.Equal(t, []TypeError{
+		expectedError(29, 41, 42, "Type mismatch: cannot convert from String to Integer"),
+		expectedError(30, 27, 32, "Void methods cannot return a value"),
+		expectedError(31, 43, 50, "Type mismatch: cannot convert from void to String"),
+		expectedError(32, 25, 35, "The target type of this expression must be a functional interface"),
+		expectedError(33, 36, 42, "Lambda expression's signature does not match the signature of the functional interface method apply(String t)"),
+		expectedError(35, 10, 11, "Type mismatch: cannot convert from int to String"),
+		expectedError(37, 24, 60, "Can't use Function<String,Integer> as type Function<? super String,? extends String> in function call to mapAll"),
+		expectedError(38, 16, 20, "Unknown identifier: name"),
+	}, typeCheckResult.TypeErrors)
+
+	// Lambda parameters are locals of the lambda, typed after the functional interface
+	name := typeCheckResult.DefUsagesLookup.Lookup(loc.FileLocation{Line: 20, Character: 16})
+	local, ok := name.(*typ.JavaLocal)
+	assert.True(t, ok)
+	assert.Equal(t, "String", local.Type.ShortName())
+	scope := typeCheckResult.RootScope.LookupScopeFor(loc.FileLocation{Line: 20, Character: 45})
+	assert.Contains(t, util.Map(scope.AllSymbols(), typ.JavaSymbol.ShortName), "name")
+}
+
+func TestCheckTypes_MethodReferences(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+import java.util.List;
+import java.util.ArrayList;
+import java.util.function.Function;
+import java.util.function.Supplier;
+import java.util.function.Consumer;
+
+class References {
+	static int twice(int x) {
+		return x * 2;
+	}
+
+	static <T, R> List<R> mapAll(List<T> list, Function<? super T, ? extends R> mapper) {
+		return new ArrayList<>();
+	}
+
+	void main() {
+		Function<String, Integer> length = String::length;
+		Consumer<String> print = System.out::println;
+		Function<String, Integer> parse = Integer::parseInt;
+		Supplier<List<String> > make = ArrayList::new;
+		Function<Integer, Integer> doubled = References::twice;
+		Function<Integer, int[]> makeArray = int[]::new;
+		List<String> names = new ArrayList<>();
+		List<Integer> lengths = mapAll(names, String::length);
+
+		Function<String, String> wrongReturn = String::length;
+		Function<String, Integer> missing = String::nothing;
+		String notFunctional = String::length;
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		}
+	}
+	assert.Equal(t, []TypeError{
+		expectedError(27, 41, 55, "The type of length() from the type String is int, this is incompatible with the descriptor's return type: String"),
+		expectedError(28, 38, 53, "The type String does not define nothing(String) that is applicable here"),
+		expectedError(29, 25, 39, "The target type of this expression must be a functional interface"),
+	}, typeCheckResult.TypeErrors)
+
+	// Method references are usages of the method they refer to
+	twice := typeCheckResult.DefUsagesLookup.Lookup(loc.FileLocation{Line: 22, Character: 52})
+	assert.NotNil(t, twice)
+	if twice != nil {
+		assert.Equal(t, typ.JavaSymbolMethod, twice.Kind())
+		assert.Equal(t, "twice", twice.ShortName())
+	}
+}
+
+func TestCheckTypes_RawLibraryTargets(t *testing.T) {
+	// The standard library's methods mostly take raw functional interfaces, like `map(Function mapper)`, which
+	// don't say what the parameters of the lambda or method reference are
+	typeCheckResult := parseAndTypeCheck(t, `
+import java.util.*;
+import java.util.stream.*;
+
+class RawTargets {
+	void main(List<String> list, Map<String, Integer> counts) {
+		list.stream().map(s -> s.length()).collect(Collectors.toList());
+		list.stream().filter(s -> s.isEmpty()).count();
+		list.stream().map(String::length);
+		list.removeIf(s -> s.isEmpty());
+		list.sort((a, b) -> a.compareTo(b));
+		Comparator.comparing(String::length);
+		Optional.of("a").map(String::length);
+		counts.forEach((k, v) -> k.length());
+	}
+}`)
+	assert.Equal(t, []TypeError{}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_LibraryCollectionParams(t *testing.T) {
+	// The standard library's methods take collections like `addAll(Collection<? extends E>)` and
+	// `containsAll(Collection<?>)`, which the JSON file only has as the raw `Collection`. That leaves adding
+	// a collection of some other element type unreported, but never rejects ones that fit.
+	typeCheckResult := parseAndTypeCheck(t, `
+import java.util.*;
+
+class CollectionParams {
+	void main(List<Object> objects, List<String> names, Set<Integer> numbers, List<Integer> counts) {
+		objects.addAll(names);
+		boolean added = names.addAll(counts);
+		boolean all = names.containsAll(numbers);
+		boolean some = objects.containsAll(names);
+		names.removeAll(numbers);
+		names.retainAll(objects);
+		Set<Object> copy = new HashSet<>(names);
+	}
+}`)
+	assert.Equal(t, []TypeError{}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_SwitchesAndPatterns(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+class Patterns {
+	enum Color { RED, GREEN }
+
+	int describe(Object o, int n, Color color) {
+		if (o instanceof String s && !s.isEmpty()) {
+			int length = s.length();
+			return length;
+		}
+		if (!(o instanceof Integer i)) {
+			return 0;
+		}
+		int value = i.intValue();
+		String name = switch (n) {
+			case 1 -> "one";
+			case 2, 3 -> {
+				yield "few";
+			}
+			default -> "many";
+		};
+		var number = switch (n) {
+			case 1 -> 1;
+			default -> 2L;
+		};
+		long widened = number;
+		switch (color) {
+			case RED:
+				return 1;
+			default:
+				break;
+		}
+		switch (o) {
+			case String text && text.length() > 2 -> System.out.println(text.length());
+			default -> {}
+		}
+
+		boolean incompatible = name instanceof Integer;
+		s.length();
+		String wrong = switch (n) {
+			case "one" -> "one";
+			default -> 2;
+		};
+		int noDefault = switch (n) {
+			case 1 -> 1;
+		};
+		if (n) {}
+		return value;
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		}
+	}
+	assert.Equal(t, []TypeError{
+		expectedError(37, 25, 48, "Incompatible conditional operand types String and Integer"),
+		expectedError(38, 2, 3, "Unknown identifier: s"),
+		expectedError(40, 8, 13, "Type mismatch: cannot convert from String to int"),
+		expectedError(41, 14, 15, "Type mismatch: cannot convert from int to String"),
+		{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 43, Character: 18},
+				End:   loc.FileLocation{Line: 45, Character: 3},
+			},
+			Message:     "A switch expression should have a default case",
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		},
+		expectedError(46, 6, 7, "Type mismatch: cannot convert from int to boolean"),
+	}, typeCheckResult.TypeErrors)
+
+	// Pattern variables are locals, and their usages refer to them
+	defUsages := typeCheckResult.DefUsagesLookup
+	for _, location := range []loc.FileLocation{{Line: 6, Character: 26}, {Line: 6, Character: 32}, {Line: 7, Character: 16}} {
+		symbol := defUsages.Lookup(location)
+		local, ok := symbol.(*typ.JavaLocal)
+		if assert.Truef(t, ok, "No local at %v", location) {
+			assert.Equal(t, "s", local.Name)
+			assert.Equal(t, "String", local.Type.ShortName())
+		}
+	}
+	local, ok := defUsages.Lookup(loc.FileLocation{Line: 13, Character: 14}).(*typ.JavaLocal)
+	if assert.True(t, ok) {
+		assert.Equal(t, "i", local.Name)
+	}
+
+	// ... and are only in scope where they're matched
+	inBody := typeCheckResult.RootScope.LookupScopeFor(loc.FileLocation{Line: 8, Character: 3})
+	assert.Contains(t, util.Map(inBody.AllSymbols(), typ.JavaSymbol.ShortName), "s")
+	afterBody := typeCheckResult.RootScope.LookupScopeFor(loc.FileLocation{Line: 13, Character: 2})
+	assert.NotContains(t, util.Map(afterBody.AllSymbols(), typ.JavaSymbol.ShortName), "s")
+	assert.Contains(t, util.Map(afterBody.AllSymbols(), typ.JavaSymbol.ShortName), "i")
+}
+
+func TestCheckTypes_Records(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+class Shapes {
+	record Point(int x, int y) implements Comparable<Point> {
+		static final Point ORIGIN = new Point(0, 0);
+		int z;
+
+		Point(int x) {
+			this(x, 0);
+		}
+
+		Point(String s) {
+		}
+
+		int sum() {
+			return x + y + x();
+		}
+
+		public int compareTo(Point other) {
+			return Integer.compare(x, other.x);
+		}
+	}
+
+	record Pair<A, B>(A first, B second) {
+		private Pair(A a, B second) throws Exception {
+			this.first = a;
+		}
+
+		A first() {
+			return first;
+		}
+
+		public String second() {
+			return "";
+		}
+	}
+
+	void use() {
+		Point p = new Point(1, 2);
+		int sum = p.x() + p.y() + p.hashCode();
+		boolean equal = p.equals(new Point(3));
+		String text = p.toString();
+		Comparable<Point> comparable = p;
+		Record record = p;
+		new Point();
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		}
+	}
+	assert.Equal(t, []TypeError{
+		expectedError(5, 6, 7, "User declared non-static fields z are not permitted in a record"),
+		expectedError(11, 2, 7, "A non-canonical constructor must start with an explicit invocation to a constructor"),
+		expectedError(24, 37, 46, "Throws clause not allowed for canonical constructor Pair(A, B)"),
+		expectedError(24, 10, 14, "Cannot reduce the visibility of a canonical constructor Pair(A, B) from that of the record"),
+		expectedError(24, 17, 18, "Illegal parameter name a in canonical constructor, expected first, the corresponding component name"),
+		expectedError(24, 10, 14, "The blank final field second may not have been initialized"),
+		expectedError(28, 4, 9, "The accessor method must be declared public"),
+		expectedError(32, 9, 15, "Illegal return type of accessor; should be the same as the declared type B of the record component"),
+		expectedError(44, 6, 13, "No overload of Point matches the arguments ()"),
+	}, typeCheckResult.TypeErrors)
+
+	// Accessors go to their components, and so does the canonical constructor to the record
+	defUsages := typeCheckResult.DefUsagesLookup
+	accessor := defUsages.Lookup(loc.FileLocation{Line: 39, Character: 14})
+	if assert.NotNil(t, accessor) {
+		assert.Equal(t, "x", accessor.ShortName())
+		assert.Equal(t, loc.FileLocation{Line: 3, Character: 18}, accessor.GetDefinition().Loc.Start)
+	}
+	constructor := defUsages.Lookup(loc.FileLocation{Line: 38, Character: 17})
+	if assert.NotNil(t, constructor) {
+		assert.Equal(t, typ.JavaSymbolConstructor, constructor.Kind())
+		assert.Equal(t, loc.FileLocation{Line: 3, Character: 8}, constructor.GetDefinition().Loc.Start)
+	}
+}
+
+func TestCheckTypes_CompactRecordConstructors(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+class Ranges {
+	record Range(int lo, int hi) {
+		Range {
+			if (lo > hi) {
+				throw new IllegalArgumentException(lo + " > " + hi);
+			}
+			Runnable check = () -> { return; };
+		}
+	}
+
+	public record Name(String first, String last) {
+		Name {
+			super();
+			this.first = first.trim();
+			if (last == null) {
+				return;
+			}
+		}
+	}
+
+	void use() {
+		Range range = new Range(1, 2);
+		int width = range.hi() - range.lo();
+		new Range(1);
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		}
+	}
+	assert.Equal(t, []TypeError{
+		expectedError(13, 2, 6, "Cannot reduce the visibility of a canonical constructor Name(String, String) from that of the record"),
+		expectedError(15, 3, 13, "Illegal explicit assignment of a final field first in compact constructor"),
+		expectedError(14, 3, 10, "The body of a compact constructor must not contain an explicit constructor call"),
+		expectedError(17, 4, 11, "The body of a compact constructor must not contain a return statement"),
+		expectedError(25, 6, 14, "Not enough arguments in function call to Range! Expected 2, got 1"),
+	}, typeCheckResult.TypeErrors)
+
+	// The compact constructor is the canonical one, and takes the components as its parameters
+	defUsages := typeCheckResult.DefUsagesLookup
+	constructor := defUsages.Lookup(loc.FileLocation{Line: 23, Character: 20})
+	if assert.NotNil(t, constructor) {
+		assert.Equal(t, typ.JavaSymbolConstructor, constructor.Kind())
+		assert.Equal(t, loc.FileLocation{Line: 4, Character: 2}, constructor.GetDefinition().Loc.Start)
+	}
+	param := defUsages.Lookup(loc.FileLocation{Line: 5, Character: 8})
+	if assert.NotNil(t, param) {
+		assert.Equal(t, typ.JavaSymbolLocal, param.Kind())
+	}
+}
+
+func TestCheckTypes_Enums(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+class Solar {
+	interface Sized { double size(); }
+
+	enum Planet implements Sized {
+		MERCURY(3.3e23, 2.4e6),
+		EARTH(5.9e24, 6.3e6) {
+			public double size() { return radius * 2; }
+			String greet() { return "Hello from " + name(); }
+		},
+		PLUTO("small");
+
+		private final double mass;
+		private final double radius;
+
+		Planet(double mass, double radius) {
+			this.mass = mass;
+			this.radius = radius;
+		}
+
+		public double size() { return radius; }
+	}
+
+	enum Color { RED, GREEN }
+
+	void use(Color color) {
+		Color red = Color.RED;
+		Color[] all = Color.values();
+		Color parsed = Color.valueOf("GREEN");
+		int ordinal = red.ordinal() + red.compareTo(Color.GREEN);
+		String name = red.name();
+		Sized sized = Planet.EARTH;
+		Enum<Color> asEnum = red;
+		int n = switch (color) {
+			case RED -> 1;
+			case GREEN -> 2;
+		};
+
+		switch (color) {
+			case BLUE:
+				break;
+		}
+		Color created = new Color();
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		}
+	}
+	assert.Equal(t, []TypeError{
+		expectedError(11, 2, 7, "Can't use String as type double in function call to Planet"),
+		expectedError(11, 2, 7, "Not enough arguments in function call to Planet! Expected 2, got 1"),
+		expectedError(40, 8, 12, "Can't find member named BLUE of type Color"),
+		expectedError(43, 22, 29, "Cannot instantiate the type Color"),
+	}, typeCheckResult.TypeErrors)
+
+	// Enum constants are fields, and their bodies are anonymous subclasses of the enum
+	defUsages := typeCheckResult.DefUsagesLookup
+	red := defUsages.Lookup(loc.FileLocation{Line: 27, Character: 21})
+	if assert.NotNil(t, red) {
+		assert.Equal(t, typ.JavaSymbolField, red.Kind())
+		assert.Equal(t, loc.FileLocation{Line: 24, Character: 14}, red.GetDefinition().Loc.Start)
+	}
+	earthBody := typeCheckResult.RootScope.LookupScopeFor(loc.FileLocation{Line: 8, Character: 20})
+	assert.Contains(t, util.Map(earthBody.AllSymbols(), typ.JavaSymbol.ShortName), "greet")
+}
+
+func TestCheckTypes_Interfaces(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+class Shapes {
+	interface Shape {
+		double UNIT = 1.0;
+		String NAME = 5;
+		double area();
+		default String describe() { return "Area " + area(); }
+		static Shape unit() { return () -> UNIT; }
+		static void broken() { area(); }
+	}
+
+	interface Named<T> {
+		T name(T prefix);
+	}
+
+	static class Circle implements Shape {
+		private final double radius = UNIT;
+		public double area() { return radius * radius; }
+	}
+
+	abstract static class Partial implements Shape {}
+
+	static class Square extends Partial implements Named<String> {
+	}
+
+	void use(Circle circle) {
+		String description = circle.describe();
+		double unit = Circle.UNIT;
+		Shape shape = Shape.unit();
+		Circle.unit();
+		Runnable runnable = new Runnable() {};
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+		}
+	}
+	squareFix := &QuickFix{
+		Title: "Add unimplemented methods",
+		Edits: []TextEdit{{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 24, Character: 0},
+				End:   loc.FileLocation{Line: 24, Character: 0},
+			},
+			NewText: `
+		@Override
+		public double area() {
+			// TODO Auto-generated method stub
+			return 0;
+		}
+
+		@Override
+		public String name(String prefix) {
+			// TODO Auto-generated method stub
+			return null;
+		}
+`,
+		}},
+	}
+	runnableFix := &QuickFix{
+		Title: "Add unimplemented methods",
+		Edits: []TextEdit{{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: 31, Character: 38},
+				End:   loc.FileLocation{Line: 31, Character: 38},
+			},
+			NewText: `
+			@Override
+			public void run() {
+				// TODO Auto-generated method stub
+			}
+		`,
+		}},
+	}
+	withFix := func(err TypeError, fix *QuickFix) TypeError {
+		err.Fix = fix
+		return err
+	}
+	assert.Equal(t, []TypeError{
+		expectedError(5, 16, 17, "Type mismatch: cannot convert from int to String"),
+		expectedError(9, 25, 29, "Cannot make a static reference to the non-static method area() from the type Shape"),
+		withFix(expectedError(23, 14, 20, "The type Square must implement the inherited abstract method Shape.area()"), squareFix),
+		withFix(expectedError(23, 14, 20, "The type Square must implement the inherited abstract method Named.name(String prefix)"), squareFix),
+		expectedError(30, 9, 13, "Can't find member named unit on type __LSPClass__"),
+		withFix(expectedError(31, 26, 34, "The type <anonymous Runnable> must implement the inherited abstract method Runnable.run()"), runnableFix),
+	}, typeCheckResult.TypeErrors)
+
+	// Interface constants and default methods are members of the classes that implement the interface
+	defUsages := typeCheckResult.DefUsagesLookup
+	describe := defUsages.Lookup(loc.FileLocation{Line: 27, Character: 30})
+	if assert.NotNil(t, describe) {
+		assert.Equal(t, typ.JavaSymbolMethod, describe.Kind())
+		assert.Equal(t, loc.FileLocation{Line: 7, Character: 17}, describe.GetDefinition().Loc.Start)
+	}
+	unit := defUsages.Lookup(loc.FileLocation{Line: 17, Character: 32})
+	if assert.NotNil(t, unit) {
+		assert.Equal(t, typ.JavaSymbolField, unit.Kind())
+		assert.True(t, typ.IsStaticMember(unit))
+	}
+}
+
+func TestCheckTypes_ObjectMembers(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+class Objects {
+	interface Greeter {
+		String greet();
+	}
+
+	static class Integer {}
+
+	int hash() {
+		return this.hashCode() + toString().length();
+	}
+
+	boolean same(Objects other, Greeter greeter) {
+		Object object = other;
+		Object boxed = 5;
+		Object fromInterface = greeter;
+		Object[] array = new Greeter[] { greeter };
+		Integer integer = 5;
+		greeter.clone();
+		return other.equals(greeter) && greeter.hashCode() == greeter.toString().length();
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		}
+	}
+
+	// Only the public methods of Object are members of interfaces, and a class named Integer isn't the one
+	// that `int` boxes to
+	assert.Equal(t, []TypeError{
+		expectedError(18, 20, 21, "Type mismatch: cannot convert from int to Integer"),
+		expectedError(19, 10, 15, "Can't find member named clone on type Greeter"),
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_BlockScopes(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+import java.util.List;
+
+class Blocks {
+	void loops(List<String> names, int count) {
+		for (int i = 0; i < count; i++) {
+			int doubled = i * 2;
+		}
+		for (int i = 0; i < count; i++) {
+			int doubled = i * 3;
+		}
+		for (String name : names) {
+			int length = name.length();
+		}
+		for (var name : names) {
+			name.isEmpty();
+		}
+		for (int name : names) {}
+		for (String c : count) {}
+		int after = i;
+		{
+			String block = "a";
+		}
+		{
+			String block = "b";
+			int count = 1;
+		}
+	}
+
+	void resources(Object o) {
+		try (var reader = new java.io.StringReader("x")) {
+			reader.read();
+		} catch (java.io.IOException | IllegalStateException e) {
+			int code = e;
+		} catch (RuntimeException e) {
+			e.getMessage();
+		}
+		Runnable first = () -> { int inner = 1; };
+		Runnable second = () -> { int inner = 2; };
+		java.util.function.Function<String, String> f = o -> o;
+		Runnable local = new Runnable() {
+			public void run() {
+				int o = 1;
+			}
+		};
+		switch (o.hashCode()) {
+			case 1: int x = 1; break;
+			case 2: int x = 2; break;
+		}
+	}
+}`)
+	bounds := func(startLine int, start int, endLine int, end int) loc.Bounds {
+		return loc.Bounds{
+			Start: loc.FileLocation{Line: startLine, Character: start},
+			End:   loc.FileLocation{Line: endLine, Character: end},
+		}
+	}
+	expectedError := func(errorBounds loc.Bounds, message string) TypeError {
+		return TypeError{
+			Loc:         errorBounds,
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		}
+	}
+	alreadyDefined := func(errorBounds loc.Bounds, name string, method string, firstBounds loc.Bounds) TypeError {
+		typeError := expectedError(errorBounds, fmt.Sprintf("Variable %s is already defined in method %s", name, method))
+		typeError.Related = []RelatedLocation{{
+			Loc:     loc.CodeLocation{FileUri: "type_checker_test", Version: 0, Loc: firstBounds},
+			Message: fmt.Sprintf("%s is first defined here", name),
+		}}
+		return typeError
+	}
+
+	// Locals declared in sibling blocks, loops and lambdas don't clash, but ones that would shadow a local of
+	// the enclosing method do, except inside a local or anonymous class
+	assert.Equal(t, []TypeError{
+		expectedError(bounds(18, 18, 18, 23), "Type mismatch: cannot convert from element type String to int"),
+		expectedError(bounds(19, 18, 19, 23), "Can only iterate over an array or an instance of java.lang.Iterable"),
+		expectedError(bounds(20, 14, 20, 15), "Unknown identifier: i"),
+		alreadyDefined(bounds(26, 7, 26, 12), "count", "loops", bounds(5, 1, 28, 2)),
+		expectedError(bounds(34, 14, 34, 15), "Type mismatch: cannot convert from Exception to int"),
+		alreadyDefined(bounds(40, 50, 40, 51), "o", "resources", bounds(30, 1, 50, 2)),
+		alreadyDefined(bounds(48, 15, 48, 16), "x", "resources", bounds(47, 15, 47, 16)),
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_EnhancedForRawIterable(t *testing.T) {
+	// The library's `Map.entrySet` returns a raw `Set`, whose elements could be anything
+	typeCheckResult := parseAndTypeCheck(t, `
+import java.util.Map;
+
+class Entries {
+	void loop(Map<String, Integer> counts) {
+		for (Map.Entry<String, Integer> entry : counts.entrySet()) {}
+		for (var entry : counts.entrySet()) {}
+	}
+}`)
+	assert.Equal(t, []TypeError{}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_Operators(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+class Operators {
+	int count;
+
+	void unary(int i, boolean done, String s, Integer boxed) {
+		boolean notDone = !done;
+		int negated = -boxed;
+		long inverted = ~i;
+		int notInt = !i;
+		String negatedString = -s;
+		boxed++;
+		--count;
+		s++;
+		5++;
+	}
+
+	void binary(int i, long l, char c, byte b, Integer boxed, String s, Object o) {
+		long sum = i + l;
+		int boxedSum = boxed * 2 + 1;
+		char next = c + 1;
+		int shifted = i << l;
+		String joined = s + i + c;
+		boolean same = boxed == i;
+		boolean mixed = s == boxed;
+		boolean compared = o == s;
+		int both = i & b;
+		boolean bothBool = i > 0 & s.isEmpty();
+		int bad = s * 2;
+	}
+
+	void conditionals(boolean done, int i, Integer boxed, String s) {
+		int either = done ? i : boxed;
+		char letter = done ? 'a' : 0;
+		char notLetter = done ? 'a' : i;
+		Integer maybe = done ? null : 1;
+		double widened = done ? 1 : 2.5;
+		CharSequence text = done ? s : new StringBuilder();
+		int noCondition = i ? 1 : 2;
+		java.util.function.Function<String, Integer> length = done ? str -> str.size() : null;
+	}
+
+	void casts(Object o, long l, String s) {
+		String str = (String) o;
+		int narrowed = (int) l;
+		Integer notInteger = (Integer) s;
+		byte wrapped = (byte) 200;
+		int grouped = (narrowed + 1) * 2;
+	}
+
+	void assignments(int i, String s, Integer boxed, int[] values) {
+		i = "x";
+		s = 5;
+		i += 1.5;
+		s += 1;
+		boxed = 5;
+		values[0] = 1;
+		this.count = 2;
+		(i) = 3;
+		(i + 1) = 5;
+		5 = i;
+		unary(i, true, s, boxed) = 1;
+		boolean b = true;
+		b += 1;
+	}
+
+	void constants() {
+		byte small = 100;
+		byte tooBig = 200;
+		char fromInt = 65;
+		char negative = -1;
+		short fromChar = 'a';
+		Character boxedChar = 65;
+		Integer fromCharLiteral = 'a';
+		byte shifted = 1 << 6;
+		byte[] bytes = {1, 2, 300};
+	}
+}`)
+	bounds := func(line int, start int, end int) loc.Bounds {
+		return loc.Bounds{
+			Start: loc.FileLocation{Line: line, Character: start},
+			End:   loc.FileLocation{Line: line, Character: end},
+		}
+	}
+	expectedError := func(errorBounds loc.Bounds, message string) TypeError {
+		return TypeError{
+			Loc:         errorBounds,
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		}
+	}
+
+	// Boxed operands are unboxed, numbers are promoted to at least int, conditionals and casts have the type
+	// of their operands or target, and int constants narrow to smaller types if they fit
+	assert.Equal(t, []TypeError{
+		expectedError(bounds(9, 15, 17), "The operator ! is undefined for the argument type(s) int"),
+		expectedError(bounds(10, 25, 27), "The operator - is undefined for the argument type(s) String"),
+		expectedError(bounds(13, 2, 5), "The operator ++ is undefined for the argument type(s) String"),
+		expectedError(bounds(14, 2, 5), "Invalid argument to operation ++/--"),
+		expectedError(bounds(20, 14, 19), "Type mismatch: cannot convert from int to char"),
+		expectedError(bounds(24, 18, 28), "Incompatible operand types String and Integer"),
+		expectedError(bounds(28, 12, 17), "The operator * is undefined for the argument type(s) String, int"),
+		expectedError(bounds(34, 19, 33), "Type mismatch: cannot convert from int to char"),
+		expectedError(bounds(38, 20, 21), "Type mismatch: cannot convert from int to boolean"),
+		expectedError(bounds(39, 74, 78), "Can't find member named size on type String"),
+		expectedError(bounds(45, 23, 34), "Cannot cast from String to Integer"),
+		expectedError(bounds(51, 6, 9), "Type mismatch: cannot convert from String to int"),
+		expectedError(bounds(52, 6, 7), "Type mismatch: cannot convert from int to String"),
+		expectedError(bounds(59, 2, 9), "The left-hand side of an assignment must be a variable"),
+		expectedError(bounds(60, 2, 3), "The left-hand side of an assignment must be a variable"),
+		expectedError(bounds(61, 2, 26), "The left-hand side of an assignment must be a variable"),
+		expectedError(bounds(63, 2, 8), "The operator + is undefined for the argument type(s) boolean, int"),
+		expectedError(bounds(68, 16, 19), "Type mismatch: cannot convert from int to byte"),
+		expectedError(bounds(70, 18, 20), "Type mismatch: cannot convert from int to char"),
+		expectedError(bounds(73, 28, 31), "Type mismatch: cannot convert from char to Integer"),
+		expectedError(bounds(75, 24, 27), "Type mismatch: cannot convert from int to byte"),
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_ConstantVariables(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+interface Limits {
+	int MAX = 100;
+}
+class Main {
+	static final int SMALL = 3;
+	static final int BIG = 300;
+	final int instance = 4;
+	static int notFinal = 5;
+
+	void main(int param) {
+		final int k = 3;
+		byte b = k;
+		byte fromField = SMALL;
+		byte qualified = Main.SMALL;
+		byte fromInterface = Limits.MAX;
+		byte folded = k * SMALL + 1;
+		char c = instance;
+		byte tooBig = BIG;
+		int notConstant = 1;
+		byte fromLocal = notConstant;
+		byte fromStatic = notFinal;
+		final int fromParam = param;
+		byte fromNonConstant = fromParam;
+		final Integer boxed = 3;
+		byte fromBoxed = boxed;
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		}
+	}
+
+	// Final variables initialized with constants are constants too, so they narrow if their values fit
+	assert.Equal(t, []TypeError{
+		expectedError(19, 16, 19, "Type mismatch: cannot convert from int to byte"),
+		expectedError(21, 19, 30, "Type mismatch: cannot convert from int to byte"),
+		expectedError(22, 20, 28, "Type mismatch: cannot convert from int to byte"),
+		expectedError(24, 25, 34, "Type mismatch: cannot convert from int to byte"),
+		expectedError(26, 19, 24, "Type mismatch: cannot convert from Integer to byte"),
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_FinalFieldAssignments(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+class Point {
+	final int x;
+	final int y;
+	static final int ORIGIN;
+	int[] values = new int[3];
+
+	static {
+		ORIGIN = 0;
+	}
+
+	{
+		y = 2;
+	}
+
+	Point(int x) {
+		this.x = x;
+		Runnable r = () -> { this.x = 1; };
+	}
+
+	void move(Point other) {
+		x = 1;
+		this.y += 2;
+		other.x++;
+		values.length = 3;
+		values[0] = 1;
+		ORIGIN = 1;
+	}
+}
+class Main {
+	void main(Point point, String[] names) {
+		point.x = 1;
+		names.length = 0;
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		}
+	}
+
+	// Final fields can only be assigned in the constructors and initializers of their class
+	assert.Equal(t, []TypeError{
+		expectedError(18, 23, 29, "The final field Point.x cannot be assigned"),
+		expectedError(22, 2, 3, "The final field Point.x cannot be assigned"),
+		expectedError(23, 2, 8, "The final field Point.y cannot be assigned"),
+		expectedError(24, 2, 9, "The final field Point.x cannot be assigned"),
+		expectedError(25, 2, 15, "The final field array.length cannot be assigned"),
+		expectedError(27, 2, 8, "The final field Point.ORIGIN cannot be assigned"),
+		expectedError(32, 2, 9, "The final field Point.x cannot be assigned"),
+		expectedError(33, 2, 14, "The final field array.length cannot be assigned"),
+	}, typeCheckResult.TypeErrors)
+}
+
+func TestCheckTypes_CastsToInterfaces(t *testing.T) {
+	typeCheckResult := parseAndTypeCheck(t, `
+class Casts {
+	interface Shape {}
+	static final class Point {}
+	static class Open {}
+	static final class Circle implements Shape {}
+	enum Color { RED }
+	record Pair(int a) {}
+
+	void casts(Point p, Open o, Circle c, Color color, Pair pair, Shape shape) {
+		Shape fromFinal = (Shape) p;
+		Shape fromOpen = (Shape) o;
+		Shape fromCircle = (Shape) c;
+		Shape fromEnum = (Shape) color;
+		Shape fromRecord = (Shape) pair;
+		Point toFinal = (Point) shape;
+		Open toOpen = (Open) shape;
+		boolean isShape = p instanceof Shape;
+		boolean mayBeShape = o instanceof Shape;
+	}
+}`)
+	expectedError := func(line int, start int, end int, message string) TypeError {
+		return TypeError{
+			Loc: loc.Bounds{
+				Start: loc.FileLocation{Line: line, Character: start},
+				End:   loc.FileLocation{Line: line, Character: end},
+			},
+			Message:     message,
+			Related:     nil,
+			Severity:    SeverityError,
+			Unnecessary: false,
+			Fix:         nil,
+		}
+	}
+
+	// A final class, enum or record can't have a subclass that implements some other interface
+	assert.Equal(t, []TypeError{
+		expectedError(11, 20, 29, "Cannot cast from Point to Shape"),
+		expectedError(14, 19, 32, "Cannot cast from Color to Shape"),
+		expectedError(15, 21, 33, "Cannot cast from Pair to Shape"),
+		expectedError(16, 18, 31, "Cannot cast from Shape to Point"),
+		expectedError(18, 20, 38, "Incompatible conditional operand types Point and Shape"),
+	}, typeCheckResult.TypeErrors)
 }
@@ -22,11 +22,16 @@ func GatherTypes(fileURI string, fileVersion int, tree antlr.Tree, builtins *typ
 	return userTypes, defUsages
 }
 
+// GatherTypesFirstPass gathers the types declared in the file, replacing all the types that a previous
+// version of the file declared.
 func GatherTypesFirstPass(fileURI string, fileVersion int, tree antlr.Tree, builtins *typ.TypeMap, userTypes *typ.TypeMap, defUsages *DefinitionsUsagesLookup) {
 	visitor := newTypeGatherer(fileURI, fileVersion, builtins, userTypes, defUsages)
 	antlr.ParseTreeWalkerDefault.Walk(visitor, tree)
+	userTypes.ReplaceFile(fileURI, fileVersion, visitor.gatheredTypes)
 }
 
+// GatherTypesSecondPass gathers the members of the types declared in the file. Must be called after
+// GatherTypesFirstPass has been called on the same version of the file.
 func GatherTypesSecondPass(fileURI string, fileVersion int, tree antlr.Tree, builtins *typ.TypeMap, userTypes *typ.TypeMap, defUsages *DefinitionsUsagesLookup) {
 	visitor := newTypeGatherer(fileURI, fileVersion, builtins, userTypes, defUsages)
 	visitor.setSecondPass()
@@ -39,21 +44,32 @@ type formalParametersCtx interface {
 }
 
 type methodCtx interface {
+	antlr.ParserRuleContext
 	formalParametersCtx
 	TypeTypeOrVoid() javaparser.ITypeTypeOrVoidContext
 }
 
+// typeParametersCtx is a declaration of a generic type or method
+type typeParametersCtx interface {
+	TypeParameters() javaparser.ITypeParametersContext
+}
+
 type typeGatherer struct {
 	javaparser.BaseJavaParserListener
-	scopeTracker          *parse.ScopeTracker
-	builtins              *typ.TypeMap
-	userTypes             *typ.TypeMap
-	defUsages             *DefinitionsUsagesLookup
-	currFileURI           string
-	currFileVersion       int
-	currPackageName       string
-	isFirstPass           bool
-	currentMemberIsStatic bool
+	scopeTracker    *parse.ScopeTracker
+	builtins        *typ.TypeMap
+	userTypes       *typ.TypeMap
+	defUsages       *DefinitionsUsagesLookup
+	currFileURI     string
+	currFileVersion int
+	currPackageName string
+	isFirstPass     bool
+	// The modifiers of each class body declaration we're inside of, innermost on top
+	memberModifiers util.Stack[modifiers]
+	resolver        *typeResolver
+
+	// Types declared in the file, collected during the first pass
+	gatheredTypes []*typ.JavaType
 }
 
 func newTypeGatherer(fileURI string, fileVersion int, builtins *typ.TypeMap, userTypes *typ.TypeMap, defUsages *DefinitionsUsagesLookup) *typeGatherer {
@@ -67,7 +83,9 @@ func newTypeGatherer(fileURI string, fileVersion int, builtins *typ.TypeMap, use
 		currFileVersion:        fileVersion,
 		currPackageName:        "",
 		isFirstPass:            true,
-		currentMemberIsStatic:  false,
+		memberModifiers:        util.NewStack[modifiers](),
+		resolver:               newTypeResolver(builtins, userTypes),
+		gatheredTypes:          []*typ.JavaType{},
 	}
 }
 
@@ -96,69 +114,142 @@ func (tg *typeGatherer) EnterEveryRule(ctx antlr.ParserRuleContext) {
 	}
 }
 
-func (tg *typeGatherer) handleNewScopeFirstPass(newScope *parse.Scope, _ antlr.ParserRuleContext) {
+func (tg *typeGatherer) handleNewScopeFirstPass(newScope *parse.Scope, ctx antlr.ParserRuleContext) {
 	switch newScope.Type {
 	case parse.ScopeTypeClass:
-		tg.addNewTypeFromScope(newScope, typ.JavaTypeClass)
+		tg.addNewTypeFromScope(newScope, ctx, typ.JavaTypeClass)
 	case parse.ScopeTypeInterface:
-		tg.addNewTypeFromScope(newScope, typ.JavaTypeInterface)
+		tg.addNewTypeFromScope(newScope, ctx, typ.JavaTypeInterface)
 	case parse.ScopeTypeEnum:
-		tg.addNewTypeFromScope(newScope, typ.JavaTypeEnum)
+		tg.addNewTypeFromScope(newScope, ctx, typ.JavaTypeEnum)
 	case parse.ScopeTypeAnnotationType:
-		tg.addNewTypeFromScope(newScope, typ.JavaTypeAnnotation)
+		tg.addNewTypeFromScope(newScope, ctx, typ.JavaTypeAnnotation)
 	case parse.ScopeTypeRecord:
-		tg.addNewTypeFromScope(newScope, typ.JavaTypeRecord)
+		tg.addNewTypeFromScope(newScope, ctx, typ.JavaTypeRecord)
+	case parse.ScopeTypeAnonymousClass:
+		tg.addNewTypeFromScope(newScope, ctx, typ.JavaTypeClass)
 	}
 }
 
 func (tg *typeGatherer) handleNewScopeSecondPass(scope *parse.Scope, ctx antlr.ParserRuleContext) {
+	if scope.Type.IsClassType() {
+		ttype := tg.ownedType(tg.resolver.declaredType(scope))
+		if scope.LocalIndex > 0 && scope.Type != parse.ScopeTypeAnonymousClass {
+			tg.resolver.declareLocalType(scope.Name, ttype)
+		}
+		tg.resolver.enterType(ttype)
+		tg.resetMembers(ttype)
+		if ttype != nil {
+			tg.resolveTypeParamBounds(ctx, ttype.TypeParams)
+		}
+	}
+
 	switch scope.Type {
 	case parse.ScopeTypeClass:
-		tg.checkScopeExtendsImplements(scope, ctx)
+		tg.checkScopeExtendsImplements(ctx)
 	case parse.ScopeTypeInterface:
-		tg.checkScopeExtendsImplements(scope, ctx)
+		tg.checkScopeExtendsImplements(ctx)
+	case parse.ScopeTypeEnum:
+		tg.addEnumConstants(ctx.(*javaparser.EnumDeclarationContext))
+	case parse.ScopeTypeRecord:
+		tg.addRecordComponents(ctx.(*javaparser.RecordDeclarationContext))
+	case parse.ScopeTypeAnonymousClass:
+		tg.setAnonymousSupertype(ctx)
 
+	// Generic constructors and methods get added when we get to the declaration inside of them
 	case parse.ScopeTypeConstructor:
-		fallthrough
-	case parse.ScopeTypeGenericConstructor:
 		tg.addNewConstructorFromScope(ctx.(formalParametersCtx))
+	case parse.ScopeTypeCompactConstructor:
+		tg.addCompactConstructor(ctx.(*javaparser.CompactConstructorDeclarationContext))
 
 	case parse.ScopeTypeMethod:
-		fallthrough
-	case parse.ScopeTypeGenericMethod:
-		fallthrough
-	case parse.ScopeTypeInterfaceMethod:
-		fallthrough
-	case parse.ScopeTypeGenericInterfaceMethod:
 		tg.addNewMethodFromScope(scope, ctx.(methodCtx))
+	case parse.ScopeTypeInterfaceMethod, parse.ScopeTypeGenericInterfaceMethod:
+		body := ctx.(interfaceMethodCtx).InterfaceCommonBodyDeclaration()
+		tg.addNewMethodFromScope(scope, body.(*javaparser.InterfaceCommonBodyDeclarationContext))
 	}
 }
 
 func (tg *typeGatherer) ExitEveryRule(ctx antlr.ParserRuleContext) {
-	_ = tg.scopeTracker.CheckExitScope(ctx)
+	oldScope := tg.scopeTracker.CheckExitScope(ctx)
+	if oldScope != nil && oldScope.Type.IsClassType() {
+		if !tg.isFirstPass && oldScope.Type == parse.ScopeTypeClass {
+			tg.addDefaultConstructor(tg.ownedType(tg.resolver.currentType()))
+		}
+		if !tg.isFirstPass && oldScope.Type == parse.ScopeTypeEnum {
+			tg.addImplicitEnumMembers(tg.ownedType(tg.resolver.currentType()))
+		}
+		if !tg.isFirstPass && oldScope.Type == parse.ScopeTypeRecord {
+			tg.addImplicitRecordMembers(ctx.(*javaparser.RecordDeclarationContext))
+		}
+		tg.resolver.exitType()
+	}
+}
+
+// addDefaultConstructor adds the implicit no-arg constructor to a class that doesn't declare any constructors.
+func (tg *typeGatherer) addDefaultConstructor(ttype *typ.JavaType) {
+	if ttype == nil || len(ttype.Constructors) > 0 {
+		return
+	}
+
+	ttype.Constructors = append(ttype.Constructors, &typ.JavaConstructor{
+		ParentType: ttype,
+		Params:     []*typ.JavaParameter{},
+		Definition: nil,
+		Usages:     []loc.CodeLocation{},
+		Visibility: ttype.Visibility,
+		Original:   nil,
+	})
+}
+
+// EnterBlock is called when production block is entered.
+func (tg *typeGatherer) EnterBlock(_ *javaparser.BlockContext) {
+	tg.resolver.enterBlock()
+}
+
+// ExitBlock is called when production block is exited.
+func (tg *typeGatherer) ExitBlock(_ *javaparser.BlockContext) {
+	tg.resolver.exitBlock()
 }
 
 // EnterPackageDeclaration is called when production packageDeclaration is entered.
 func (tg *typeGatherer) EnterPackageDeclaration(ctx *javaparser.PackageDeclarationContext) {
 	tg.currPackageName = ctx.QualifiedName().GetText()
+	tg.resolver.setPackage(ctx)
+}
+
+// EnterImportDeclaration is called when production importDeclaration is entered.
+func (tg *typeGatherer) EnterImportDeclaration(ctx *javaparser.ImportDeclarationContext) {
+	tg.resolver.addImport(ctx)
 }
 
 // EnterClassBodyDeclaration is called when production classBodyDeclaration is entered.
 func (tg *typeGatherer) EnterClassBodyDeclaration(ctx *javaparser.ClassBodyDeclarationContext) {
-	if ctx.STATIC() != nil {
-		// TODO is it possible for class body declarations to be nested? In that case this would
-		// need to be a stack instead of a single bool
-		tg.currentMemberIsStatic = true
-	}
+	// Class body declarations can be nested, e.g. members of a nested class, so keep a stack
+	tg.memberModifiers.Push(memberModifiers(ctx))
 }
 
 // ExitClassBodyDeclaration is called when production classBodyDeclaration is exited.
-func (tg *typeGatherer) ExitClassBodyDeclaration(ctx *javaparser.ClassBodyDeclarationContext) {
-	if ctx.STATIC() != nil {
-		// If this is true, we set currentMemberIsStatic to true on the way in.
-		// So we want to make sure to set it to false on the way out.
-		tg.currentMemberIsStatic = false
+func (tg *typeGatherer) ExitClassBodyDeclaration(_ *javaparser.ClassBodyDeclarationContext) {
+	tg.memberModifiers.Pop()
+}
+
+// EnterInterfaceBodyDeclaration is called when production interfaceBodyDeclaration is entered.
+func (tg *typeGatherer) EnterInterfaceBodyDeclaration(ctx *javaparser.InterfaceBodyDeclarationContext) {
+	tg.memberModifiers.Push(interfaceMemberModifiers(ctx))
+}
+
+// ExitInterfaceBodyDeclaration is called when production interfaceBodyDeclaration is exited.
+func (tg *typeGatherer) ExitInterfaceBodyDeclaration(_ *javaparser.InterfaceBodyDeclarationContext) {
+	tg.memberModifiers.Pop()
+}
+
+// currentMemberModifiers returns the modifiers of the member currently being declared.
+func (tg *typeGatherer) currentMemberModifiers() modifiers {
+	if tg.memberModifiers.Empty() {
+		return noModifiers
 	}
+	return tg.memberModifiers.Top()
 }
 
 // EnterFieldDeclaration is called when production fieldDeclaration is entered.
@@ -167,8 +258,10 @@ func (tg *typeGatherer) EnterFieldDeclaration(ctx *javaparser.FieldDeclarationCo
 		return
 	}
 
-	currTypeName := tg.scopeTracker.ScopeStack.Top().Name
-	currType := tg.userTypes.Get(currTypeName)
+	currType := tg.ownedType(tg.resolver.currentType())
+	if currType == nil {
+		return
+	}
 
 	fieldTypeName := ctx.TypeType().GetText()
 	fieldType := tg.lookupType(fieldTypeName)
@@ -177,21 +270,23 @@ func (tg *typeGatherer) EnterFieldDeclaration(ctx *javaparser.FieldDeclarationCo
 	if varDeclsI != nil {
 		varDecls := varDeclsI.(*javaparser.VariableDeclaratorsContext)
 		for _, varDecl := range varDecls.AllVariableDeclarator() {
-			ident := varDecl.(*javaparser.VariableDeclaratorContext).VariableDeclaratorId().(*javaparser.VariableDeclaratorIdContext).Identifier()
+			declaratorID := varDecl.(*javaparser.VariableDeclaratorContext).VariableDeclaratorId()
+			ident := declaratorID.(*javaparser.VariableDeclaratorIdContext).Identifier()
 			fieldName := ident.GetText()
 			bounds := loc.ParserRuleContextToBounds(ident)
 			defLocation := tg.makeCodeLocation(bounds)
 
 			field := &typ.JavaField{
-				Name:       fieldName,
-				Type:       fieldType,
-				ParentType: currType,
-				Definition: &defLocation,
-				Usages:     []loc.CodeLocation{},
-				Visibility: 0,
-				IsStatic:   tg.currentMemberIsStatic,
-				// TODO real value for IsFinal
-				IsFinal: false,
+				Name:           fieldName,
+				Type:           withDeclaratorDims(fieldType, declaratorID),
+				ParentType:     currType,
+				Definition:     &defLocation,
+				Usages:         []loc.CodeLocation{},
+				Visibility:     tg.currentMemberModifiers().visibility,
+				IsStatic:       tg.currentMemberModifiers().isStatic,
+				IsFinal:        tg.currentMemberModifiers().isFinal,
+				IsEnumConstant: false,
+				Original:       nil,
 			}
 
 			currType.Fields = append(currType.Fields, field)
@@ -201,31 +296,188 @@ func (tg *typeGatherer) EnterFieldDeclaration(ctx *javaparser.FieldDeclarationCo
 	}
 }
 
-func (tg *typeGatherer) addNewTypeFromScope(scope *parse.Scope, ttype typ.JavaTypeType) {
+// EnterConstDeclaration is called when production constDeclaration is entered.
+func (tg *typeGatherer) EnterConstDeclaration(ctx *javaparser.ConstDeclarationContext) {
+	if tg.isFirstPass {
+		return
+	}
+
+	currType := tg.ownedType(tg.resolver.currentType())
+	if currType == nil {
+		return
+	}
+
+	constType := tg.lookupType(ctx.TypeType().GetText())
+	for _, declaratorI := range ctx.AllConstantDeclarator() {
+		declarator := declaratorI.(*javaparser.ConstantDeclaratorContext)
+		ident := declarator.Identifier()
+		defLocation := tg.makeCodeLocation(loc.ParserRuleContextToBounds(ident))
+
+		field := &typ.JavaField{
+			Name:           ident.GetText(),
+			Type:           withConstantDims(constType, declarator),
+			ParentType:     currType,
+			Definition:     &defLocation,
+			Usages:         []loc.CodeLocation{},
+			Visibility:     tg.currentMemberModifiers().visibility,
+			IsStatic:       true,
+			IsFinal:        true,
+			IsEnumConstant: false,
+			Original:       nil,
+		}
+
+		currType.Fields = append(currType.Fields, field)
+
+		tg.defUsages.Add(defLocation, field, false)
+	}
+}
+
+func (tg *typeGatherer) addNewTypeFromScope(scope *parse.Scope, ctx antlr.ParserRuleContext, ttype typ.JavaTypeType) {
 	location := tg.makeCodeLocation(scope.Bounds)
-	newType := typ.NewJavaType(scope.Name, tg.currPackageName, typ.VisibilityPublic, ttype, &location)
-	tg.userTypes.Add(newType)
-	tg.defUsages.Add(location, newType, false)
+	mods := typeModifiers(ctx)
+	newType := typ.NewJavaType(declaredSimpleName(scope), tg.currPackageName, mods.visibility, ttype, &location)
+	newType.IsFinal = mods.isFinal
+	newType.EnclosingType = tg.resolver.currentType()
+	newType.LocalIndex = scope.LocalIndex
+	newType.TypeParams = tg.makeTypeParams(ctx)
+	tg.resolver.enterType(newType)
+	tg.gatheredTypes = append(tg.gatheredTypes, newType)
+	if !newType.IsAnonymous() {
+		// The name of an anonymous class is that of its supertype, which is a usage of that instead
+		tg.defUsages.Add(location, newType, false)
+	}
+}
+
+// makeTypeParams creates the type variables declared by a generic type or method, without their bounds,
+// since the bounds may refer to types that haven't been gathered yet. Returns nil if there aren't any.
+func (tg *typeGatherer) makeTypeParams(ctx antlr.ParserRuleContext) []*typ.JavaType {
+	typeParamsCtx := getTypeParametersCtx(ctx)
+	if typeParamsCtx == nil {
+		return nil
+	}
+
+	return util.Map(typeParamsCtx.AllTypeParameter(), func(typeParamI javaparser.ITypeParameterContext) *typ.JavaType {
+		ident := typeParamI.(*javaparser.TypeParameterContext).Identifier()
+		location := tg.makeCodeLocation(loc.ParserRuleContextToBounds(ident))
+		typeVar := typ.NewTypeVariable(ident.GetText(), &location)
+		tg.defUsages.Add(location, typeVar, false)
+		return typeVar
+	})
 }
 
-func (tg *typeGatherer) checkScopeExtendsImplements(scope *parse.Scope, ctx antlr.ParserRuleContext) {
-	existingType := tg.lookupType(scope.Name)
+// resolveTypeParamBounds fills in the bounds of the type variables declared by a generic type or method.
+// Type variables without bounds are bounded by `Object`.
+func (tg *typeGatherer) resolveTypeParamBounds(ctx antlr.ParserRuleContext, typeParams []*typ.JavaType) {
+	typeParamsCtx := getTypeParametersCtx(ctx)
+	if typeParamsCtx == nil {
+		return
+	}
+
+	for i, typeParamI := range typeParamsCtx.AllTypeParameter() {
+		if i >= len(typeParams) {
+			break
+		}
+
+		bounds := []*typ.JavaType{}
+		if boundI := typeParamI.(*javaparser.TypeParameterContext).TypeBound(); boundI != nil {
+			for _, boundTypeI := range boundI.(*javaparser.TypeBoundContext).AllTypeType() {
+				if bound := tg.lookupType(boundTypeI.GetText()); bound != nil {
+					bounds = append(bounds, bound)
+				}
+			}
+		}
+		if len(bounds) == 0 {
+			if object := tg.resolver.objectType(); object != nil {
+				bounds = append(bounds, object)
+			}
+		}
+
+		typeParams[i].Extends = bounds
+	}
+}
+
+func getTypeParametersCtx(ctx antlr.ParserRuleContext) *javaparser.TypeParametersContext {
+	withTypeParams, ok := ctx.(typeParametersCtx)
+	if !ok || withTypeParams.TypeParameters() == nil {
+		return nil
+	}
+	return withTypeParams.TypeParameters().(*javaparser.TypeParametersContext)
+}
+
+// ownedType returns the given type if it was declared in the current version of the current file,
+// or nil if it has since been replaced by a newer version of the file (or another file).
+func (tg *typeGatherer) ownedType(ttype *typ.JavaType) *typ.JavaType {
+	if ttype == nil || !ttype.IsOwnedBy(tg.currFileURI, tg.currFileVersion) {
+		return nil
+	}
+	return ttype
+}
+
+// resetMembers clears out the members of the given type, so that gathering the members again
+// doesn't duplicate them.
+func (tg *typeGatherer) resetMembers(ttype *typ.JavaType) {
+	if ttype == nil {
+		return
+	}
+
+	ttype.Constructors = make([]*typ.JavaConstructor, 0)
+	ttype.Fields = make([]*typ.JavaField, 0)
+	ttype.Methods = make([]*typ.JavaMethod, 0)
+}
+
+func (tg *typeGatherer) checkScopeExtendsImplements(ctx antlr.ParserRuleContext) {
+	existingType := tg.resolver.currentType()
+	if existingType == nil {
+		return
+	}
 	existingType.Extends = tg.getExtendsTypes(ctx)
 	existingType.Implements = tg.getImplementsTypes(ctx)
 	// TODO add existingType.Permits if it's relevant (new java 17 feature I think)
 }
 
+// setAnonymousSupertype makes the type of an anonymous class body extend the class, or implement the interface,
+// it's created from, e.g. `Runnable` for `new Runnable() { ... }`.
+func (tg *typeGatherer) setAnonymousSupertype(ctx antlr.ParserRuleContext) {
+	anonymousType := tg.resolver.currentType()
+	if anonymousType == nil {
+		return
+	}
+
+	if _, ok := ctx.GetParent().(*javaparser.EnumConstantContext); ok {
+		// The body of an enum constant, which is declared directly inside of the enum
+		anonymousType.Extends = []*typ.JavaType{anonymousType.EnclosingType}
+		anonymousType.Implements = []*typ.JavaType{}
+		return
+	}
+
+	createdName := ctx.GetParent().GetParent().(*javaparser.CreatorContext).CreatedName()
+	// Resolve the name from outside of the anonymous class, where it's written
+	tg.resolver.exitType()
+	supertype := tg.lookupType(createdName.GetText())
+	tg.resolver.enterType(anonymousType)
+
+	if supertype != nil && supertype.Type == typ.JavaTypeInterface {
+		anonymousType.Extends = tg.implicitSuperclass()
+		anonymousType.Implements = []*typ.JavaType{supertype}
+		return
+	}
+	anonymousType.Extends = []*typ.JavaType{supertype}
+	anonymousType.Implements = []*typ.JavaType{}
+}
+
 func (tg *typeGatherer) getExtendsTypes(ctx antlr.ParserRuleContext) []*typ.JavaType {
 	typeTypes := []*javaparser.TypeTypeContext{}
 
 	switch tctx := ctx.(type) {
 	case *javaparser.ClassDeclarationContext:
 		extendsI := tctx.ClassDeclarationExtends()
-		if extendsI != nil {
-			extends := extendsI.(*javaparser.ClassDeclarationExtendsContext)
-			typeTypes = []*javaparser.TypeTypeContext{
-				extends.TypeType().(*javaparser.TypeTypeContext),
-			}
+		if extendsI == nil {
+			// Classes that don't say what they extend extend Object
+			return tg.implicitSuperclass()
+		}
+		extends := extendsI.(*javaparser.ClassDeclarationExtendsContext)
+		typeTypes = []*javaparser.TypeTypeContext{
+			extends.TypeType().(*javaparser.TypeTypeContext),
 		}
 	case *javaparser.InterfaceDeclarationContext:
 		extendsI := tctx.InterfaceDeclarationExtends()
@@ -247,15 +499,30 @@ func (tg *typeGatherer) getExtendsTypes(ctx antlr.ParserRuleContext) []*typ.Java
 	})
 }
 
+// implicitSuperclass returns what a class extends when it doesn't say, which is just Object, or nothing if
+// Object isn't known.
+func (tg *typeGatherer) implicitSuperclass() []*typ.JavaType {
+	if object := tg.resolver.objectType(); object != nil {
+		return []*typ.JavaType{object}
+	}
+	return []*typ.JavaType{}
+}
+
 func (tg *typeGatherer) getImplementsTypes(ctx antlr.ParserRuleContext) []*typ.JavaType {
-	tctx, ok := ctx.(*javaparser.ClassDeclarationContext)
-	if !ok {
-		return []*typ.JavaType{}
+	var typeListI javaparser.ITypeListContext
+	switch tctx := ctx.(type) {
+	case *javaparser.ClassDeclarationContext:
+		if implementsI := tctx.ClassDeclarationImplements(); implementsI != nil {
+			typeListI = implementsI.(*javaparser.ClassDeclarationImplementsContext).TypeList()
+		}
+	case *javaparser.EnumDeclarationContext:
+		typeListI = tctx.TypeList()
+	case *javaparser.RecordDeclarationContext:
+		typeListI = tctx.TypeList()
 	}
 
-	implementsI := tctx.ClassDeclarationImplements()
-	if implementsI != nil {
-		typeList := implementsI.(*javaparser.ClassDeclarationImplementsContext).TypeList().(*javaparser.TypeListContext)
+	if typeListI != nil {
+		typeList := typeListI.(*javaparser.TypeListContext)
 
 		implTypes := []*typ.JavaType{}
 		allTypeTypes := typeList.AllTypeType()
@@ -274,8 +541,16 @@ func (tg *typeGatherer) getImplementsTypes(ctx antlr.ParserRuleContext) []*typ.J
 
 func (tg *typeGatherer) addNewConstructorFromScope(ctx formalParametersCtx) {
 	// The top is the current scope, so we use top minus 1 to get the enclosing class
-	currTypeName := tg.scopeTracker.ScopeStack.TopMinus(1).Name
-	currType := tg.userTypes.Get(currTypeName)
+	currType := tg.ownedType(tg.resolver.currentType())
+	if currType == nil {
+		return
+	}
+
+	visibility := tg.currentMemberModifiers().visibility
+	if currType.Type == typ.JavaTypeEnum {
+		// Only the enum's own constants can be created
+		visibility = typ.VisibilityPrivate
+	}
 
 	location := tg.makeCodeLocation(loc.ParserRuleContextToBounds(ctx.Identifier()))
 	newConstructor := &typ.JavaConstructor{
@@ -283,7 +558,8 @@ func (tg *typeGatherer) addNewConstructorFromScope(ctx formalParametersCtx) {
 		Params:     tg.getArgsFromContext(ctx),
 		Definition: &location,
 		Usages:     []loc.CodeLocation{},
-		Visibility: 0,
+		Visibility: visibility,
+		Original:   nil,
 	}
 
 	currType.Constructors = append(currType.Constructors, newConstructor)
@@ -293,8 +569,10 @@ func (tg *typeGatherer) addNewConstructorFromScope(ctx formalParametersCtx) {
 
 func (tg *typeGatherer) addNewMethodFromScope(scope *parse.Scope, ctx methodCtx) {
 	// The top is the current scope, so we use top minus 1 to get the enclosing class
-	currTypeName := tg.scopeTracker.ScopeStack.TopMinus(1).Name
-	currType := tg.userTypes.Get(currTypeName)
+	currType := tg.ownedType(tg.resolver.currentType())
+	if currType == nil {
+		return
+	}
 
 	location := tg.makeCodeLocation(loc.ParserRuleContextToBounds(ctx.Identifier()))
 	method := &typ.JavaMethod{
@@ -304,8 +582,19 @@ func (tg *typeGatherer) addNewMethodFromScope(scope *parse.Scope, ctx methodCtx)
 		Params:     nil,
 		Definition: &location,
 		Usages:     []loc.CodeLocation{},
-		Visibility: 0,
-		IsStatic:   false,
+		Visibility: tg.currentMemberModifiers().visibility,
+		IsStatic:   tg.currentMemberModifiers().isStatic,
+		IsAbstract: tg.currentMemberModifiers().isAbstract,
+		TypeParams: nil,
+		Original:   nil,
+	}
+
+	// Generic methods declare their type parameters just outside of the method declaration itself
+	if generic, ok := ctx.GetParent().(antlr.ParserRuleContext); ok && getTypeParametersCtx(generic) != nil {
+		method.TypeParams = tg.makeTypeParams(generic)
+		tg.resolver.enterMethod(method)
+		defer tg.resolver.exitMethod()
+		tg.resolveTypeParamBounds(generic, method.TypeParams)
 	}
 
 	returnType := ctx.TypeTypeOrVoid().GetText()
@@ -314,7 +603,6 @@ func (tg *typeGatherer) addNewMethodFromScope(scope *parse.Scope, ctx methodCtx)
 	}
 
 	method.Params = tg.getArgsFromContext(ctx)
-	method.IsStatic = tg.currentMemberIsStatic
 
 	currType.Methods = append(currType.Methods, method)
 
@@ -343,9 +631,10 @@ func (tg *typeGatherer) getArgsFromContext(ctx formalParametersCtx) []*typ.JavaP
 		paramList := paramListI.(*javaparser.FormalParameterListContext)
 		for _, argICtx := range paramList.AllFormalParameter() {
 			argCtx := argICtx.(*javaparser.FormalParameterContext)
+			declaratorID := argCtx.VariableDeclaratorId()
 			arg := &typ.JavaParameter{
-				Name:      argCtx.VariableDeclaratorId().GetText(),
-				Type:      tg.lookupType(argCtx.TypeType().GetText()),
+				Name:      declaratorID.(*javaparser.VariableDeclaratorIdContext).Identifier().GetText(),
+				Type:      withDeclaratorDims(tg.lookupType(argCtx.TypeType().GetText()), declaratorID),
 				IsVarargs: false,
 			}
 			args = append(args, arg)
@@ -354,10 +643,18 @@ func (tg *typeGatherer) getArgsFromContext(ctx formalParametersCtx) []*typ.JavaP
 		lastParamI := paramList.LastFormalParameter()
 		if lastParamI != nil {
 			lastParam := lastParamI.(*javaparser.LastFormalParameterContext)
+			declaratorID := lastParam.VariableDeclaratorId()
+			isVarargs := lastParam.ELLIPSIS() != nil
+			paramType := withDeclaratorDims(tg.lookupType(lastParam.TypeType().GetText()), declaratorID)
+			if isVarargs && paramType != nil {
+				// Within the method, varargs params are arrays
+				paramType = typ.NewArrayType(paramType, 1)
+			}
+
 			arg := &typ.JavaParameter{
-				Name:      lastParam.VariableDeclaratorId().GetText(),
-				Type:      tg.lookupType(lastParam.TypeType().GetText()),
-				IsVarargs: lastParam.ELLIPSIS() != nil,
+				Name:      declaratorID.(*javaparser.VariableDeclaratorIdContext).Identifier().GetText(),
+				Type:      paramType,
+				IsVarargs: isVarargs,
 			}
 			args = append(args, arg)
 		}
@@ -367,10 +664,5 @@ func (tg *typeGatherer) getArgsFromContext(ctx formalParametersCtx) []*typ.JavaP
 }
 
 func (tg *typeGatherer) lookupType(typeName string) *typ.JavaType {
-	userType := tg.userTypes.Get(typeName)
-	if userType != nil {
-		return userType
-	}
-
-	return tg.builtins.Get(typeName)
+	return tg.resolver.resolve(typeName)
 }
@@ -91,14 +91,22 @@ public class Main {
 						IsVarargs: false,
 					},
 				},
+				Visibility: typ.VisibilityPublic,
+				IsStatic:   true,
 			},
 		},
-		Constructors: []*typ.JavaConstructor{},
-		Fields:       []*typ.JavaField{},
-		Type:         typ.JavaTypeClass,
-		Extends:      []*typ.JavaType{},
-		Implements:   []*typ.JavaType{},
-		Visibility:   typ.VisibilityPublic,
+		// The implicit default constructor
+		Constructors: []*typ.JavaConstructor{
+			{
+				Params:     []*typ.JavaParameter{},
+				Visibility: typ.VisibilityPublic,
+			},
+		},
+		Fields:     []*typ.JavaField{},
+		Type:       typ.JavaTypeClass,
+		Extends:    []*typ.JavaType{},
+		Implements: []*typ.JavaType{},
+		Visibility: typ.VisibilityPublic,
 	})
 
 	assert.Equal(t, expectedTypes, types)
@@ -147,32 +155,39 @@ class MyClass {
 		Type: typ.JavaTypeClass,
 		Fields: []*typ.JavaField{
 			{
-				Name: "nestedInt",
-				Type: intType,
+				Name:       "nestedInt",
+				Type:       intType,
+				Visibility: typ.VisibilityPublic,
 			},
 		},
-		Constructors: []*typ.JavaConstructor{},
-		Methods:      []*typ.JavaMethod{},
-		Extends:      []*typ.JavaType{},
-		Implements:   []*typ.JavaType{},
-		Visibility:   typ.VisibilityPublic,
+		// The implicit default constructor, which is package-private like the class
+		Constructors: []*typ.JavaConstructor{
+			{
+				Params: []*typ.JavaParameter{},
+			},
+		},
+		Methods:    []*typ.JavaMethod{},
+		Extends:    []*typ.JavaType{},
+		Implements: []*typ.JavaType{},
 	}
 
-	expectedTypes := typ.NewTypeMap()
-	expectedTypes.Add(&typ.JavaType{
+	myClassType := &typ.JavaType{
 		Name: "MyClass",
 		Fields: []*typ.JavaField{
 			{
-				Name: "name",
-				Type: strType,
+				Name:       "name",
+				Type:       strType,
+				Visibility: typ.VisibilityPublic,
 			},
 			{
-				Name: "asdf",
-				Type: intType,
+				Name:       "asdf",
+				Type:       intType,
+				Visibility: typ.VisibilityPublic,
 			},
 			{
-				Name: "n",
-				Type: nestedType,
+				Name:       "n",
+				Type:       nestedType,
+				Visibility: typ.VisibilityPrivate,
 			},
 		},
 		Constructors: []*typ.JavaConstructor{
@@ -183,6 +198,7 @@ class MyClass {
 						Type: intType,
 					},
 				},
+				Visibility: typ.VisibilityPublic,
 			},
 		},
 		Methods: []*typ.JavaMethod{
@@ -190,13 +206,17 @@ class MyClass {
 				Name:       "DoSomething",
 				ReturnType: intType,
 				Params:     []*typ.JavaParameter{},
+				Visibility: typ.VisibilityPublic,
 			},
 		},
 		Type:       typ.JavaTypeClass,
 		Extends:    []*typ.JavaType{},
 		Implements: []*typ.JavaType{},
-		Visibility: typ.VisibilityPublic,
-	})
+	}
+	nestedType.EnclosingType = myClassType
+
+	expectedTypes := typ.NewTypeMap()
+	expectedTypes.Add(myClassType)
 	expectedTypes.Add(nestedType)
 
 	assert.Equal(t, expectedTypes, types)
@@ -242,10 +262,10 @@ enum MyEnum {
 	types, _ := GatherTypes("testfile", 0, tree, builtins)
 	stripOutStuffWeDontWannaTest(types)
 
-	expectedTypes := typ.NewTypeMap()
-	expectedTypes.Add(&typ.JavaType{
+	myEnum := &typ.JavaType{
 		Name: "MyEnum",
 		Type: typ.JavaTypeEnum,
+		// Enum constructors are always private
 		Constructors: []*typ.JavaConstructor{
 			{
 				Params: []*typ.JavaParameter{
@@ -254,25 +274,198 @@ enum MyEnum {
 						Type: strType,
 					},
 				},
-			},
-		},
-		Fields: []*typ.JavaField{
-			{
-				Name: "value",
-				Type: strType,
-			},
-		},
-		Methods: []*typ.JavaMethod{
-			{
-				Name:       "getValue",
-				ReturnType: strType,
-				Params:     []*typ.JavaParameter{},
+				Visibility: typ.VisibilityPrivate,
 			},
 		},
 		Extends:    []*typ.JavaType{},
 		Implements: []*typ.JavaType{},
-		Visibility: typ.VisibilityPublic,
-	})
+	}
+	constant := func(name string) *typ.JavaField {
+		return &typ.JavaField{
+			Name:           name,
+			Type:           myEnum,
+			Visibility:     typ.VisibilityPublic,
+			IsStatic:       true,
+			IsFinal:        true,
+			IsEnumConstant: true,
+		}
+	}
+	myEnum.Fields = []*typ.JavaField{
+		constant("First"),
+		constant("Second"),
+		constant("Third"),
+		{
+			Name:       "value",
+			Type:       strType,
+			Visibility: typ.VisibilityPrivate,
+		},
+	}
+	// Along with the implicit values(), valueOf(String), name() and ordinal(). The types they mention from
+	// java.lang aren't among the builtins here, so they're nil.
+	myEnum.Methods = []*typ.JavaMethod{
+		{
+			Name:       "getValue",
+			ReturnType: strType,
+			Params:     []*typ.JavaParameter{},
+			Visibility: typ.VisibilityPublic,
+		},
+		{
+			Name:       "values",
+			ReturnType: typ.NewArrayType(myEnum, 1),
+			Params:     []*typ.JavaParameter{},
+			Visibility: typ.VisibilityPublic,
+			IsStatic:   true,
+		},
+		{
+			Name:       "valueOf",
+			ReturnType: myEnum,
+			Params:     []*typ.JavaParameter{{Name: "arg0", Type: nil}},
+			Visibility: typ.VisibilityPublic,
+			IsStatic:   true,
+		},
+		{
+			Name:       "name",
+			Params:     []*typ.JavaParameter{},
+			Visibility: typ.VisibilityPublic,
+		},
+		{
+			Name:       "ordinal",
+			Params:     []*typ.JavaParameter{},
+			Visibility: typ.VisibilityPublic,
+		},
+	}
+
+	expectedTypes := typ.NewTypeMap()
+	expectedTypes.Add(myEnum)
 
 	assert.Equal(t, expectedTypes, types)
 }
+
+func TestGatherTypes_Interface(t *testing.T) {
+	tree, errors := parse.Parse(`
+interface Shape {
+  String NAME = "shape", ALIASES[] = {};
+
+  String describe(String prefix);
+
+  default String describe() {
+    return describe("");
+  }
+
+  static Shape unit() {
+    return null;
+  }
+
+  private String helper() {
+    return NAME;
+  }
+}`)
+	assert.Equal(t, 0, len(errors))
+
+	strType := &typ.JavaType{Name: "String"}
+	builtins := typ.NewTypeMap()
+	builtins.Add(strType)
+
+	types, _ := GatherTypes("testfile", 0, tree, builtins)
+	stripOutStuffWeDontWannaTest(types)
+
+	// Constants are implicitly public, static and final, and methods public, and abstract if they don't have a body
+	shape := &typ.JavaType{
+		Name:         "Shape",
+		Type:         typ.JavaTypeInterface,
+		Constructors: []*typ.JavaConstructor{},
+		Extends:      []*typ.JavaType{},
+		Implements:   []*typ.JavaType{},
+	}
+	shape.Fields = []*typ.JavaField{
+		{
+			Name:       "NAME",
+			Type:       strType,
+			Visibility: typ.VisibilityPublic,
+			IsStatic:   true,
+			IsFinal:    true,
+		},
+		{
+			Name:       "ALIASES",
+			Type:       typ.NewArrayType(strType, 1),
+			Visibility: typ.VisibilityPublic,
+			IsStatic:   true,
+			IsFinal:    true,
+		},
+	}
+	shape.Methods = []*typ.JavaMethod{
+		{
+			Name:       "describe",
+			ReturnType: strType,
+			Params:     []*typ.JavaParameter{{Name: "prefix", Type: strType}},
+			Visibility: typ.VisibilityPublic,
+			IsAbstract: true,
+		},
+		{
+			Name:       "describe",
+			ReturnType: strType,
+			Params:     []*typ.JavaParameter{},
+			Visibility: typ.VisibilityPublic,
+		},
+		{
+			Name:       "unit",
+			ReturnType: shape,
+			Params:     []*typ.JavaParameter{},
+			Visibility: typ.VisibilityPublic,
+			IsStatic:   true,
+		},
+		{
+			Name:       "helper",
+			ReturnType: strType,
+			Params:     []*typ.JavaParameter{},
+			Visibility: typ.VisibilityPrivate,
+		},
+	}
+
+	expectedTypes := typ.NewTypeMap()
+	expectedTypes.Add(shape)
+
+	assert.Equal(t, expectedTypes, types)
+}
+
+func TestGatherTypes_RegatherReplacesFileContents(t *testing.T) {
+	builtins := typ.NewTypeMap()
+	builtins.Add(&typ.JavaType{Name: "int"})
+	userTypes := typ.NewTypeMap()
+
+	gather := func(fileURI string, version int, code string) {
+		tree, errors := parse.Parse(code)
+		assert.Equal(t, 0, len(errors))
+		defUsages := NewDefinitionsUsagesLookup()
+		GatherTypesFirstPass(fileURI, version, tree, builtins, userTypes, defUsages)
+		GatherTypesSecondPass(fileURI, version, tree, builtins, userTypes, defUsages)
+	}
+
+	gather("other", 0, `class Other {}`)
+	gather("testfile", 0, `class Before {
+	public int get() { return 1; }
+}`)
+	assert.NotNil(t, userTypes.Get("Before"))
+
+	// Renaming the class removes the old one, but leaves types from other files alone
+	gather("testfile", 1, `class After {
+	public int get() { return 1; }
+}`)
+	assert.Nil(t, userTypes.Get("Before"))
+	assert.NotNil(t, userTypes.Get("After"))
+	assert.NotNil(t, userTypes.Get("Other"))
+
+	// Running the second pass again doesn't duplicate members
+	tree, _ := parse.Parse(`class After {
+	public int get() { return 1; }
+}`)
+	GatherTypesSecondPass("testfile", 1, tree, builtins, userTypes, NewDefinitionsUsagesLookup())
+	assert.Equal(t, 1, len(userTypes.Get("After").Methods))
+
+	// An older version of the file doesn't replace a newer one
+	gather("testfile", 0, `class Before {
+	public int get() { return 1; }
+}`)
+	assert.Nil(t, userTypes.Get("Before"))
+	assert.Equal(t, 1, len(userTypes.Get("After").Methods))
+}
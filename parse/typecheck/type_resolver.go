@@ -0,0 +1,496 @@
+package typecheck
+
+import (
+	"java-mini-ls-go/javaparser"
+	"java-mini-ls-go/parse"
+	"java-mini-ls-go/parse/typ"
+	"java-mini-ls-go/util"
+	"strings"
+)
+
+// typeResolver turns type names, as they're written in a file, into types. It follows Java's rules
+// for which types are in scope based on the file's package and imports:
+//
+// 1. Type variables of the generic methods and types we're currently inside of
+// 2. Local classes declared in the blocks we're currently inside of
+// 3. Types imported by name (`import java.util.List;`)
+// 4. Member types of the types we're currently inside of
+// 5. Types in the same package
+// 6. Types imported on demand (`import java.util.*;`), including the implicit `import java.lang.*;`
+//
+// Names that are already fully qualified (`java.util.List`) or refer to a nested type
+// (`Map.Entry`) are resolved too, as are type arguments (`List<String>`) and array types (`int[]`).
+//
+// It also resolves static members brought in by static imports (`import static java.lang.Math.max;`).
+type typeResolver struct {
+	builtins  *typ.TypeMap
+	userTypes *typ.TypeMap
+
+	packageName string
+	// Every import in the file, in the order they were declared
+	imports []*importDecl
+	// Simple name -> fully qualified name, for single-type imports
+	singleTypeImports map[string]string
+	// Package names (or type names, for nested types) that are imported on demand
+	onDemandImports []string
+
+	// The types whose declarations we're currently inside of, innermost on top.
+	// May contain nils for types that couldn't be found.
+	enclosingTypes util.Stack[*typ.JavaType]
+	// The methods whose declarations we're currently inside of, innermost on top.
+	// May contain nils for methods that couldn't be found.
+	enclosingMethods util.Stack[*typ.JavaMethod]
+	// Simple name -> type, for the local classes declared in each block we're currently inside of,
+	// innermost on top
+	localTypes util.Stack[map[string]*typ.JavaType]
+}
+
+func newTypeResolver(builtins *typ.TypeMap, userTypes *typ.TypeMap) *typeResolver {
+	return &typeResolver{
+		builtins:          builtins,
+		userTypes:         userTypes,
+		packageName:       "",
+		imports:           []*importDecl{},
+		singleTypeImports: make(map[string]string),
+		onDemandImports:   []string{},
+		enclosingTypes:    util.NewStack[*typ.JavaType](),
+		enclosingMethods:  util.NewStack[*typ.JavaMethod](),
+		localTypes:        util.NewStack[map[string]*typ.JavaType](),
+	}
+}
+
+func (tr *typeResolver) setPackage(ctx *javaparser.PackageDeclarationContext) {
+	tr.packageName = ctx.QualifiedName().GetText()
+}
+
+// importDecl is a single import declaration in a file
+type importDecl struct {
+	ctx           *javaparser.ImportDeclarationContext
+	qualifiedName string
+	isStatic      bool
+	isOnDemand    bool
+}
+
+func (tr *typeResolver) addImport(ctx *javaparser.ImportDeclarationContext) *importDecl {
+	imp := &importDecl{
+		ctx:           ctx,
+		qualifiedName: ctx.QualifiedName().GetText(),
+		isStatic:      ctx.STATIC() != nil,
+		isOnDemand:    ctx.MUL() != nil,
+	}
+	tr.imports = append(tr.imports, imp)
+
+	// Static imports can bring in member types as well as fields and methods, so both kinds
+	// are treated as type imports too. If no type by that name exists, resolve just won't find one.
+	if imp.isOnDemand {
+		tr.onDemandImports = append(tr.onDemandImports, imp.qualifiedName)
+	} else {
+		tr.singleTypeImports[lastNamePart(imp.qualifiedName)] = imp.qualifiedName
+	}
+
+	return imp
+}
+
+// importedType returns the type an import refers to: the imported type itself for single-type
+// imports, or the type whose members are imported for static imports and imports of nested types.
+// Returns nil if the import doesn't refer to a type, e.g. `import java.util.*;`, or if it can't be found.
+func (tr *typeResolver) importedType(imp *importDecl) *typ.JavaType {
+	if imp.isStatic && !imp.isOnDemand {
+		lastDot := strings.LastIndex(imp.qualifiedName, ".")
+		if lastDot == -1 {
+			// e.g. `import static Foo;`, which doesn't name a member of anything
+			return nil
+		}
+		return tr.get(imp.qualifiedName[:lastDot])
+	}
+	return tr.get(imp.qualifiedName)
+}
+
+// importedSymbol returns what a single (non on-demand) import brings into scope. For static imports that's
+// a static field or method, or a member type. Returns nil if it can't be found.
+func (tr *typeResolver) importedSymbol(imp *importDecl) typ.JavaSymbol {
+	if !imp.isStatic {
+		if found := tr.get(imp.qualifiedName); found != nil {
+			return found
+		}
+		return nil
+	}
+
+	if !strings.Contains(imp.qualifiedName, ".") {
+		return nil
+	}
+	if member := staticMember(tr.importedType(imp), lastNamePart(imp.qualifiedName)); member != nil {
+		return member
+	}
+	if found := tr.get(imp.qualifiedName); found != nil {
+		return found
+	}
+	return nil
+}
+
+// isResolvable returns whether the thing an import refers to exists.
+func (tr *typeResolver) isResolvable(imp *importDecl) bool {
+	switch {
+	case imp.isStatic && imp.isOnDemand:
+		return tr.importedType(imp) != nil
+	case imp.isOnDemand:
+		return tr.importedType(imp) != nil || tr.userTypes.HasPackage(imp.qualifiedName) || tr.builtins.HasPackage(imp.qualifiedName)
+	default:
+		return tr.importedSymbol(imp) != nil
+	}
+}
+
+// providesName returns whether the import brings something with the given simple name into scope.
+func (tr *typeResolver) providesName(imp *importDecl, name string) bool {
+	if !imp.isOnDemand {
+		return lastNamePart(imp.qualifiedName) == name
+	}
+	if tr.get(imp.qualifiedName+"."+name) != nil {
+		return true
+	}
+	return imp.isStatic && staticMember(tr.importedType(imp), name) != nil
+}
+
+// resolveStaticMember looks up a static field or method that was brought into scope by a static import.
+// Returns nil if there isn't one.
+func (tr *typeResolver) resolveStaticMember(name string) typ.JavaSymbol {
+	// Single-static-imports shadow static-import-on-demand declarations
+	for _, onDemand := range []bool{false, true} {
+		for _, imp := range tr.imports {
+			if !imp.isStatic || imp.isOnDemand != onDemand || !tr.providesName(imp, name) {
+				continue
+			}
+			if member := staticMember(tr.importedType(imp), name); member != nil {
+				return member
+			}
+		}
+	}
+	return nil
+}
+
+// resolveStaticMethods returns the overloads of a static method brought in by static imports, e.g. every `max`
+// for `import static java.lang.Math.max;`. Returns an empty slice if there aren't any.
+func (tr *typeResolver) resolveStaticMethods(name string) []*typ.JavaMethod {
+	// Single-static-imports shadow static-import-on-demand declarations
+	for _, onDemand := range []bool{false, true} {
+		methods := []*typ.JavaMethod{}
+		for _, imp := range tr.imports {
+			if !imp.isStatic || imp.isOnDemand != onDemand || !tr.providesName(imp, name) {
+				continue
+			}
+			if importedType := tr.importedType(imp); importedType != nil {
+				for _, method := range importedType.LookupMethods(name) {
+					if method.IsStatic {
+						methods = append(methods, method)
+					}
+				}
+			}
+		}
+		if len(methods) > 0 {
+			return methods
+		}
+	}
+	return []*typ.JavaMethod{}
+}
+
+// staticMember looks up a static field or method of a type or its supertypes. Returns nil if there isn't one.
+func staticMember(ttype *typ.JavaType, name string) typ.JavaSymbol {
+	if ttype == nil {
+		return nil
+	}
+
+	for _, field := range ttype.Fields {
+		if field.IsStatic && field.Name == name {
+			return field
+		}
+	}
+	for _, method := range ttype.Methods {
+		if method.IsStatic && method.Name == name {
+			return method
+		}
+	}
+	for _, supertype := range util.CombineSlices(ttype.Extends, ttype.Implements) {
+		if member := staticMember(supertype, name); member != nil {
+			return member
+		}
+	}
+	return nil
+}
+
+func (tr *typeResolver) enterType(ttype *typ.JavaType) {
+	tr.enclosingTypes.Push(ttype)
+}
+
+func (tr *typeResolver) exitType() {
+	tr.enclosingTypes.Pop()
+}
+
+func (tr *typeResolver) enterMethod(method *typ.JavaMethod) {
+	tr.enclosingMethods.Push(method)
+}
+
+func (tr *typeResolver) exitMethod() {
+	tr.enclosingMethods.Pop()
+}
+
+func (tr *typeResolver) enterBlock() {
+	tr.localTypes.Push(make(map[string]*typ.JavaType))
+}
+
+func (tr *typeResolver) exitBlock() {
+	tr.localTypes.Pop()
+}
+
+// declareLocalType brings a local class into scope for the rest of the block it's declared in.
+func (tr *typeResolver) declareLocalType(name string, ttype *typ.JavaType) {
+	if ttype == nil || tr.localTypes.Empty() {
+		return
+	}
+	tr.localTypes.Top()[name] = ttype
+}
+
+// localType looks up a local class that's in scope, innermost block first. Returns nil if there isn't one.
+func (tr *typeResolver) localType(name string) *typ.JavaType {
+	for i := tr.localTypes.Size() - 1; i >= 0; i-- {
+		if found, ok := tr.localTypes.At(i)[name]; ok {
+			return found
+		}
+	}
+	return nil
+}
+
+// currentType returns the innermost type we're currently inside of, or nil if there is none.
+func (tr *typeResolver) currentType() *typ.JavaType {
+	if tr.enclosingTypes.Empty() {
+		return nil
+	}
+	return tr.enclosingTypes.Top()
+}
+
+// currentMethod returns the innermost method or constructor we're currently inside of, or nil if there is none.
+func (tr *typeResolver) currentMethod() *typ.JavaMethod {
+	if tr.enclosingMethods.Empty() {
+		return nil
+	}
+	return tr.enclosingMethods.Top()
+}
+
+// declaredName returns the fully qualified name of the type a scope declares at the current point in the file.
+// Local and anonymous classes are told apart by their index, see typ.JavaType.LocalIndex.
+func (tr *typeResolver) declaredName(scope *parse.Scope) string {
+	name := declaredSimpleName(scope)
+	if scope.LocalIndex > 0 {
+		name = typ.LocalTypeName(scope.LocalIndex, name)
+	}
+	if enclosing := tr.currentType(); enclosing != nil {
+		return enclosing.QualifiedName() + "." + name
+	}
+	return qualify(tr.packageName, name)
+}
+
+// declaredType looks up the type a scope declares at the current point in the file.
+func (tr *typeResolver) declaredType(scope *parse.Scope) *typ.JavaType {
+	return tr.userTypes.Get(tr.declaredName(scope))
+}
+
+// declaredSimpleName returns the simple name of the type a scope declares, which is empty for anonymous classes.
+func declaredSimpleName(scope *parse.Scope) string {
+	if scope.Type == parse.ScopeTypeAnonymousClass {
+		return ""
+	}
+	return scope.Name
+}
+
+// resolve looks up a type by the name it's referred to by at the current point in the file.
+// Generic types referred to without type arguments are raw types. Returns nil if it can't be found.
+func (tr *typeResolver) resolve(name string) *typ.JavaType {
+	if elementName, dimensions := typ.SplitArrayDims(name); dimensions > 0 {
+		elementType := tr.resolve(elementName)
+		if elementType == nil {
+			return nil
+		}
+		return typ.NewArrayType(elementType, dimensions)
+	}
+
+	baseName, typeArgNames := typ.SplitTypeArgs(name)
+	if typeArgNames != nil {
+		return tr.resolveParameterized(baseName, typeArgNames)
+	}
+
+	if typeVar := tr.typeVariable(name); typeVar != nil {
+		return typeVar
+	}
+
+	found := tr.resolveName(name)
+	if found != nil && found.IsGeneric() {
+		return found.Parameterize(nil)
+	}
+	return found
+}
+
+// withDeclaratorDims adds the array dimensions written after the name of a variable, the old C-style way,
+// to its type, e.g. `int[]` for `int numbers[]`.
+func withDeclaratorDims(ttype *typ.JavaType, declaratorID javaparser.IVariableDeclaratorIdContext) *typ.JavaType {
+	dimensions := strings.Count(declaratorID.GetText(), "[")
+	if ttype == nil || dimensions == 0 {
+		return ttype
+	}
+	return typ.NewArrayType(ttype, dimensions)
+}
+
+// withConstantDims is withDeclaratorDims for the constants of an interface, e.g. `int[]` for `PRIMES` in
+// `int PRIMES[] = {2, 3, 5}`.
+func withConstantDims(ttype *typ.JavaType, declarator *javaparser.ConstantDeclaratorContext) *typ.JavaType {
+	dimensions := len(declarator.AllLBRACK())
+	if ttype == nil || dimensions == 0 {
+		return ttype
+	}
+	return typ.NewArrayType(ttype, dimensions)
+}
+
+// resolveParameterized looks up a generic type with the given type arguments, e.g. `List<String>`.
+// If the type arguments don't fit the type, or it's the diamond `<>`, returns the raw type.
+func (tr *typeResolver) resolveParameterized(baseName string, typeArgNames []string) *typ.JavaType {
+	base := tr.resolve(baseName)
+	if base == nil || len(typeArgNames) == 0 || len(typeArgNames) != len(base.GetOriginal().TypeParams) {
+		return base
+	}
+
+	typeArgs := make([]*typ.JavaType, 0, len(typeArgNames))
+	for _, typeArgName := range typeArgNames {
+		typeArg := tr.resolveTypeArg(typeArgName)
+		if typeArg == nil {
+			return base
+		}
+		typeArgs = append(typeArgs, typeArg)
+	}
+	return base.Parameterize(typeArgs)
+}
+
+// resolveTypeArg looks up a type argument, which might be a wildcard like `? extends Number`.
+func (tr *typeResolver) resolveTypeArg(name string) *typ.JavaType {
+	isWildcard, boundKind, boundName := typ.SplitWildcard(name)
+	if !isWildcard {
+		return tr.resolve(name)
+	}
+
+	object := tr.objectType()
+	if boundKind == "" {
+		return typ.NewWildcardType(object, nil)
+	}
+
+	bound := tr.resolve(boundName)
+	if bound == nil {
+		return typ.NewWildcardType(object, nil)
+	}
+	if boundKind == "super" {
+		return typ.NewWildcardType(object, bound)
+	}
+	return typ.NewWildcardType(bound, nil)
+}
+
+// typeVariable looks up a type variable of the generic methods and types we're currently inside of.
+// Returns nil if there isn't one by that name.
+func (tr *typeResolver) typeVariable(name string) *typ.JavaType {
+	for i := tr.enclosingMethods.Size() - 1; i >= 0; i-- {
+		if method := tr.enclosingMethods.At(i); method != nil {
+			if typeVar := findTypeVariable(method.TypeParams, name); typeVar != nil {
+				return typeVar
+			}
+		}
+	}
+	for i := tr.enclosingTypes.Size() - 1; i >= 0; i-- {
+		if enclosing := tr.enclosingTypes.At(i); enclosing != nil {
+			if typeVar := findTypeVariable(enclosing.TypeParams, name); typeVar != nil {
+				return typeVar
+			}
+		}
+	}
+	return nil
+}
+
+func findTypeVariable(typeParams []*typ.JavaType, name string) *typ.JavaType {
+	for _, typeParam := range typeParams {
+		if typeParam.Name == name {
+			return typeParam
+		}
+	}
+	return nil
+}
+
+// objectType returns java.lang.Object, the bound of type variables and wildcards that don't have one.
+func (tr *typeResolver) objectType() *typ.JavaType {
+	return tr.builtins.Get("java.lang.Object")
+}
+
+// resolveName looks up a type by name, without considering type variables or type arguments.
+func (tr *typeResolver) resolveName(name string) *typ.JavaType {
+	if found := tr.localType(name); found != nil {
+		return found
+	}
+
+	// Member types of the enclosing types shadow the ones imported by name (JLS 6.4.1)
+	for i := tr.enclosingTypes.Size() - 1; i >= 0; i-- {
+		enclosing := tr.enclosingTypes.At(i)
+		if enclosing == nil {
+			continue
+		}
+		if found := tr.get(enclosing.QualifiedName() + "." + name); found != nil {
+			return found
+		}
+	}
+
+	if qualifiedName, ok := tr.singleTypeImports[name]; ok {
+		if found := tr.get(qualifiedName); found != nil {
+			return found
+		}
+	}
+
+	if found := tr.get(qualify(tr.packageName, name)); found != nil {
+		return found
+	}
+
+	for _, onDemand := range tr.onDemandImports {
+		if found := tr.get(onDemand + "." + name); found != nil {
+			return found
+		}
+	}
+
+	if found := tr.get("java.lang." + name); found != nil {
+		return found
+	}
+
+	// Maybe it's already fully qualified
+	if found := tr.get(name); found != nil {
+		return found
+	}
+
+	// Maybe it's a nested type referred to by its outer type, e.g. `Map.Entry`
+	if dotIdx := strings.Index(name, "."); dotIdx != -1 {
+		if outer := tr.resolveName(name[:dotIdx]); outer != nil {
+			if found := tr.get(outer.QualifiedName() + name[dotIdx:]); found != nil {
+				return found
+			}
+		}
+	}
+
+	return nil
+}
+
+func (tr *typeResolver) get(qualifiedName string) *typ.JavaType {
+	if found := tr.userTypes.Get(qualifiedName); found != nil {
+		return found
+	}
+	return tr.builtins.Get(qualifiedName)
+}
+
+func qualify(packageName string, name string) string {
+	if packageName == "" {
+		return name
+	}
+	return packageName + "." + name
+}
+
+func lastNamePart(qualifiedName string) string {
+	return qualifiedName[strings.LastIndex(qualifiedName, ".")+1:]
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"go.lsp.dev/protocol"
+	"java-mini-ls-go/parse/loc"
+	"java-mini-ls-go/parse/typecheck"
+	"java-mini-ls-go/util"
+)
+
+// CodeAction offers the quick fixes for the type errors in the given range. Errors that share a fix, like each
+// of the methods a class is missing, get a single code action between them.
+func (j *JavaLS) CodeAction(_ context.Context, params *protocol.CodeActionParams) ([]protocol.CodeAction, error) {
+	typeErrors, ok := j.typeErrors.Get(string(params.TextDocument.URI))
+	if !ok {
+		return nil, nil
+	}
+
+	actions := []protocol.CodeAction{}
+	actionIndexes := map[*typecheck.QuickFix]int{}
+	for _, typeError := range typeErrors {
+		diagnostic := typeError.ToDiagnostic()
+		if typeError.Fix == nil || !rangesOverlap(diagnostic.Range, params.Range) {
+			continue
+		}
+
+		diagnostic = stampDiagnostics(DiagnosticSourceType, []protocol.Diagnostic{diagnostic})[0]
+		if i, ok := actionIndexes[typeError.Fix]; ok {
+			actions[i].Diagnostics = append(actions[i].Diagnostics, diagnostic)
+			continue
+		}
+
+		actionIndexes[typeError.Fix] = len(actions)
+		actions = append(actions, protocol.CodeAction{
+			Title:       typeError.Fix.Title,
+			Kind:        protocol.QuickFix,
+			Diagnostics: []protocol.Diagnostic{diagnostic},
+			IsPreferred: true,
+			Disabled:    nil,
+			Edit: &protocol.WorkspaceEdit{
+				Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+					params.TextDocument.URI: util.Map(typeError.Fix.Edits, toLSPTextEdit),
+				},
+				DocumentChanges:   nil,
+				ChangeAnnotations: nil,
+			},
+			Command: nil,
+			Data:    nil,
+		})
+	}
+
+	return actions, nil
+}
+
+func toLSPTextEdit(edit typecheck.TextEdit) protocol.TextEdit {
+	return protocol.TextEdit{
+		Range:   loc.BoundsToRange(edit.Loc),
+		NewText: edit.NewText,
+	}
+}
+
+// rangesOverlap says whether two ranges have any part in common, counting ranges that only touch, so that an
+// empty range, like the position of the cursor, overlaps the ranges it's inside of or at the edge of.
+func rangesOverlap(a protocol.Range, b protocol.Range) bool {
+	return !positionBefore(a.End, b.Start) && !positionBefore(b.End, a.Start)
+}
+
+func positionBefore(a protocol.Position, b protocol.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"java-mini-ls-go/parse"
+	"java-mini-ls-go/parse/typ"
+	"java-mini-ls-go/util"
+)
+
+// dependencyGraph keeps track of which files reference symbols defined in which other files, so that
+// when a file's public surface changes, the files depending on it can be re-checked.
+type dependencyGraph struct {
+	mu sync.Mutex
+	// URI -> URIs of the files it references symbols from
+	dependencies map[string]*util.Set[string]
+	// URI -> URIs of the files referencing symbols from it
+	dependents map[string]*util.Set[string]
+}
+
+func newDependencyGraph() *dependencyGraph {
+	return &dependencyGraph{
+		mu:           sync.Mutex{},
+		dependencies: make(map[string]*util.Set[string]),
+		dependents:   make(map[string]*util.Set[string]),
+	}
+}
+
+// setDependencies replaces the list of files that the given file depends on.
+func (dg *dependencyGraph) setDependencies(uri string, dependencies []string) {
+	dg.mu.Lock()
+	defer dg.mu.Unlock()
+
+	if old, ok := dg.dependencies[uri]; ok {
+		for _, dependency := range old.Values() {
+			dg.dependents[dependency].Remove(uri)
+		}
+	}
+
+	dg.dependencies[uri] = util.SetFromSlice(dependencies)
+	for _, dependency := range dependencies {
+		if _, ok := dg.dependents[dependency]; !ok {
+			dg.dependents[dependency] = util.NewSet[string]()
+		}
+		dg.dependents[dependency].Add(uri)
+	}
+}
+
+// getDependents returns the files that reference symbols defined in the given file, sorted by URI.
+func (dg *dependencyGraph) getDependents(uri string) []string {
+	dg.mu.Lock()
+	defer dg.mu.Unlock()
+
+	dependents, ok := dg.dependents[uri]
+	if !ok {
+		return []string{}
+	}
+
+	ret := dependents.Values()
+	sort.Strings(ret)
+	return ret
+}
+
+// publicSurface returns a string describing everything that other files can see of the types
+// defined in the given file: the types themselves, what they extend, and their non-private
+// members. If it changes between two checks of the file, its dependents need re-checking.
+func (j *JavaLS) publicSurface(uri string) string {
+	var lines []string
+
+	for _, ttype := range j.userTypes.AllTypes() {
+		if ttype.Definition == nil || ttype.Definition.FileUri != uri {
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("%s %s.%s", typ.JavaTypeTypeStrs[ttype.Type], ttype.Package, ttype.FullName()))
+		for _, typeParam := range ttype.TypeParams {
+			lines = append(lines, typeParamSurface(ttype.Name, typeParam))
+		}
+		for _, super := range util.CombineSlices(ttype.Extends, ttype.Implements) {
+			if super != nil {
+				lines = append(lines, fmt.Sprintf("%s <: %s", ttype.Name, super.FullName()))
+			}
+		}
+		for _, constructor := range ttype.Constructors {
+			if constructor.Visibility != typ.VisibilityPrivate {
+				lines = append(lines, constructor.FullName())
+			}
+		}
+		for _, field := range ttype.Fields {
+			if field.Visibility != typ.VisibilityPrivate {
+				lines = append(lines, field.FullName())
+			}
+		}
+		for _, method := range ttype.Methods {
+			if method.Visibility != typ.VisibilityPrivate {
+				lines = append(lines, fmt.Sprintf("%s static=%t", method.FullName(), method.IsStatic))
+				for _, typeParam := range method.TypeParams {
+					lines = append(lines, typeParamSurface(method.FullName(), typeParam))
+				}
+			}
+		}
+	}
+
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// typeParamSurface describes a type parameter of a type or method for publicSurface, including its bounds.
+func typeParamSurface(owner string, typeParam *typ.JavaType) string {
+	var bounds []string
+	for _, bound := range typeParam.Extends {
+		if bound != nil {
+			bounds = append(bounds, bound.FullName())
+		}
+	}
+	return fmt.Sprintf("%s <%s extends %s>", owner, typeParam.Name, strings.Join(bounds, " & "))
+}
+
+// updatePublicSurface records the current public surface of the given file, and returns whether
+// it's different from the last time it was recorded.
+func (j *JavaLS) updatePublicSurface(uri string) bool {
+	surface := j.publicSurface(uri)
+	old, ok := j.publicSurfaces.Get(uri)
+	j.publicSurfaces.Set(uri, surface)
+	return !ok || old != surface
+}
+
+// recheckDependents re-gathers and re-checks every file that depends on the given file, and republishes
+// their diagnostics. Just re-checking them isn't enough, since the types they declare refer to the ones
+// the given file used to declare, e.g. as their supertypes. Re-gathering them replaces their own types,
+// so the files that depend on them are re-gathered too, and so on.
+func (j *JavaLS) recheckDependents(ctx context.Context, uri string) {
+	done := util.SetFromValues(uri)
+	pending := j.dependencies.getDependents(uri)
+	for len(pending) > 0 {
+		dependentURI := pending[0]
+		pending = pending[1:]
+		if ctx.Err() != nil {
+			return
+		}
+		if done.Contains(dependentURI) {
+			continue
+		}
+		done.Add(dependentURI)
+
+		textDocument, ok := j.documentTextCache.Get(dependentURI)
+		if !ok {
+			continue
+		}
+
+		j.log.Info(fmt.Sprintf("Re-checking %s since %s changed", dependentURI, uri))
+
+		parsed, _ := parse.Parse(textDocument.Text)
+		if _, ok := j.gatherAndCheckDocument(ctx, textDocument, parsed); !ok {
+			return
+		}
+		pending = append(pending, j.dependencies.getDependents(dependentURI)...)
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"sync"
+
+	"go.lsp.dev/protocol"
+)
+
+// DiagnosticSource identifies what produced a set of diagnostics for a document. Each source's
+// diagnostics are tracked separately, so that e.g. publishing type errors doesn't wipe out syntax errors.
+type DiagnosticSource int
+
+const (
+	DiagnosticSourceSyntax   DiagnosticSource = iota
+	DiagnosticSourceType     DiagnosticSource = iota
+	DiagnosticSourceLint     DiagnosticSource = iota
+	DiagnosticSourceExternal DiagnosticSource = iota
+)
+
+// Order in which each source's diagnostics appear in the merged list
+var diagnosticSourceOrder = []DiagnosticSource{
+	DiagnosticSourceSyntax,
+	DiagnosticSourceType,
+	DiagnosticSourceLint,
+	DiagnosticSourceExternal,
+}
+
+// Code stamped on diagnostics from each source, unless the diagnostic already has one
+var diagnosticSourceCodes = map[DiagnosticSource]string{
+	DiagnosticSourceSyntax:   "syntax",
+	DiagnosticSourceType:     "type",
+	DiagnosticSourceLint:     "lint",
+	DiagnosticSourceExternal: "external",
+}
+
+const diagnosticsSourceName = "java-mini-ls"
+
+type diagnosticsBucket struct {
+	version     int32
+	diagnostics []protocol.Diagnostic
+}
+
+// diagnosticsManager keeps the current diagnostics for each document, bucketed by source.
+type diagnosticsManager struct {
+	mu sync.Mutex
+	// URI -> source -> diagnostics
+	documents map[string]map[DiagnosticSource]diagnosticsBucket
+}
+
+func newDiagnosticsManager() *diagnosticsManager {
+	return &diagnosticsManager{
+		mu:        sync.Mutex{},
+		documents: make(map[string]map[DiagnosticSource]diagnosticsBucket),
+	}
+}
+
+// set replaces the diagnostics from one source for a version of a document, and returns the merged
+// diagnostics from all sources that should now be published for it.
+//
+// Diagnostics from the syntax, type and lint sources are tied to a version of the document, so once a
+// newer version comes in, buckets from older versions are left out of the merged list until they
+// get replaced. External diagnostics don't know about document versions, so they're always included.
+func (dm *diagnosticsManager) set(uri string, version int32, source DiagnosticSource, diagnostics []protocol.Diagnostic) []protocol.Diagnostic {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	buckets, ok := dm.documents[uri]
+	if !ok {
+		buckets = make(map[DiagnosticSource]diagnosticsBucket)
+		dm.documents[uri] = buckets
+	}
+
+	buckets[source] = diagnosticsBucket{
+		version:     version,
+		diagnostics: stampDiagnostics(source, diagnostics),
+	}
+
+	latestVersion := version
+	for src, bucket := range buckets {
+		if src != DiagnosticSourceExternal && bucket.version > latestVersion {
+			latestVersion = bucket.version
+		}
+	}
+
+	merged := []protocol.Diagnostic{}
+	for _, src := range diagnosticSourceOrder {
+		bucket, ok := buckets[src]
+		if !ok {
+			continue
+		}
+		if src != DiagnosticSourceExternal && bucket.version < latestVersion {
+			continue
+		}
+		merged = append(merged, bucket.diagnostics...)
+	}
+
+	return merged
+}
+
+// forget drops the diagnostics for a document, e.g. once it's closed. If it gets opened again, its
+// versions start over, so the old ones would otherwise hide the new ones.
+func (dm *diagnosticsManager) forget(uri string) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	delete(dm.documents, uri)
+}
+
+// stampDiagnostics fills in the code and source of each diagnostic if they're missing.
+func stampDiagnostics(source DiagnosticSource, diagnostics []protocol.Diagnostic) []protocol.Diagnostic {
+	stamped := make([]protocol.Diagnostic, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		if d.Code == nil {
+			d.Code = diagnosticSourceCodes[source]
+		}
+		if d.Source == "" {
+			d.Source = diagnosticsSourceName
+		}
+		stamped = append(stamped, d)
+	}
+	return stamped
+}
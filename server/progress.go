@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"go.lsp.dev/protocol"
+)
+
+// Counter used to give each progress token a unique name
+var progressTokenCounter int32
+
+// progressReporter sends `$/progress` notifications to the client for a single long-running
+// operation, such as indexing a workspace folder.
+//
+// If the client doesn't support work done progress, all of the methods are no-ops, so callers
+// don't need to check.
+type progressReporter struct {
+	j       *JavaLS
+	token   *protocol.ProgressToken
+	enabled bool
+
+	// Guards lastPercentage, since report() gets called from many goroutines at once
+	mu             sync.Mutex
+	lastPercentage uint32
+}
+
+// beginProgress creates a new progress token on the client and sends the "begin" notification.
+// The returned context is cancelled when the client sends a WorkDoneProgressCancel for the token,
+// so the caller should check it periodically and bail out if it's done.
+func (j *JavaLS) beginProgress(ctx context.Context, title string) (*progressReporter, context.Context, context.CancelFunc) {
+	progressCtx, cancel := context.WithCancel(ctx)
+
+	tokenName := fmt.Sprintf("java-mini-ls/%d", atomic.AddInt32(&progressTokenCounter, 1))
+	pr := &progressReporter{
+		j:              j,
+		token:          protocol.NewProgressToken(tokenName),
+		enabled:        j.clientSupportsWorkDoneProgress && j.client != nil,
+		mu:             sync.Mutex{},
+		lastPercentage: 0,
+	}
+
+	if !pr.enabled {
+		return pr, progressCtx, cancel
+	}
+
+	err := j.client.WorkDoneProgressCreate(ctx, &protocol.WorkDoneProgressCreateParams{Token: *pr.token})
+	if err != nil {
+		j.log.Error(fmt.Sprintf("error creating work done progress: %s", err.Error()))
+		pr.enabled = false
+		return pr, progressCtx, cancel
+	}
+
+	j.progressCancels.Set(tokenName, cancel)
+
+	pr.send(&protocol.WorkDoneProgressBegin{
+		Kind:        protocol.WorkDoneProgressKindBegin,
+		Title:       title,
+		Cancellable: true,
+		Message:     "",
+		Percentage:  0,
+	})
+
+	return pr, progressCtx, func() {
+		j.progressCancels.Delete(tokenName)
+		cancel()
+	}
+}
+
+// report sends a "report" notification. Reports that would move the percentage backwards or
+// leave it unchanged are dropped, to avoid flooding the client when called once per file.
+func (pr *progressReporter) report(message string, percentage uint32) {
+	if !pr.enabled {
+		return
+	}
+
+	pr.mu.Lock()
+	if percentage <= pr.lastPercentage {
+		pr.mu.Unlock()
+		return
+	}
+	pr.lastPercentage = percentage
+	pr.mu.Unlock()
+
+	pr.send(&protocol.WorkDoneProgressReport{
+		Kind:        protocol.WorkDoneProgressKindReport,
+		Cancellable: true,
+		Message:     message,
+		Percentage:  percentage,
+	})
+}
+
+// end sends the "end" notification. The reporter shouldn't be used after this.
+func (pr *progressReporter) end(message string) {
+	if !pr.enabled {
+		return
+	}
+
+	pr.send(&protocol.WorkDoneProgressEnd{
+		Kind:    protocol.WorkDoneProgressKindEnd,
+		Message: message,
+	})
+}
+
+func (pr *progressReporter) send(value interface{}) {
+	err := pr.j.client.Progress(context.Background(), &protocol.ProgressParams{
+		Token: *pr.token,
+		Value: value,
+	})
+	if err != nil {
+		pr.j.log.Error(fmt.Sprintf("error sending progress notification: %s", err.Error()))
+	}
+}
+
+// progressPhase tracks progress through one phase of an operation that processes a number of files.
+// Each phase covers the percentage range [start, end) of the overall operation.
+type progressPhase struct {
+	reporter *progressReporter
+	name     string
+	start    uint32
+	end      uint32
+	total    int
+	done     int32
+}
+
+func (pr *progressReporter) phase(name string, start uint32, end uint32, total int) *progressPhase {
+	pp := &progressPhase{
+		reporter: pr,
+		name:     name,
+		start:    start,
+		end:      end,
+		total:    total,
+		done:     0,
+	}
+	pr.report(fmt.Sprintf("%s (0/%d files)", name, total), start)
+	return pp
+}
+
+// fileDone marks one more file as finished in this phase and reports the new percentage.
+// Safe to call from multiple goroutines.
+func (pp *progressPhase) fileDone() {
+	done := atomic.AddInt32(&pp.done, 1)
+
+	percentage := pp.end
+	if pp.total > 0 {
+		percentage = pp.start + uint32(int(pp.end-pp.start)*int(done)/pp.total)
+	}
+
+	pp.reporter.report(fmt.Sprintf("%s (%d/%d files)", pp.name, done, pp.total), percentage)
+}
+
+func (j *JavaLS) WorkDoneProgressCancel(_ context.Context, params *protocol.WorkDoneProgressCancelParams) error {
+	tokenName := params.Token.String()
+	j.log.Info(fmt.Sprintf("WorkDoneProgressCancel %s", tokenName))
+
+	cancel, ok := j.progressCancels.Get(tokenName)
+	if ok {
+		cancel()
+	}
+
+	return nil
+}
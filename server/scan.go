@@ -24,7 +24,7 @@ func (j *JavaLS) rescanEverything(ctx context.Context) {
 
 	for _, folder := range folders {
 		// TODO scan workspace folders in parallel
-		err = j.rescanWorkspaceFolder(folder.URI)
+		err = j.rescanWorkspaceFolder(rescanCtx, folder)
 		if err != nil {
 			j.log.Error(fmt.Sprintf("error scanning workspace folder. Folder=`%s` Error=`%s`", folder.URI, err.Error()))
 		}
@@ -36,7 +36,35 @@ type textDocParsed struct {
 	parsed antlr.Tree
 }
 
-func (j *JavaLS) rescanWorkspaceFolder(folderURI string) error {
+// Percentage of the overall indexing progress at which each phase of rescanWorkspaceFolder starts
+const (
+	progressRead       = 0
+	progressParse      = 20
+	progressGatherPass = 40
+	progressSecondPass = 55
+	progressCheck      = 70
+	progressDone       = 100
+)
+
+func (j *JavaLS) rescanWorkspaceFolder(ctx context.Context, folder protocol.WorkspaceFolder) error {
+	progress, ctx, done := j.beginProgress(ctx, fmt.Sprintf("Indexing %s", folder.Name))
+	defer done()
+
+	err := j.indexWorkspaceFolder(ctx, folder.URI, progress)
+	if err != nil {
+		if ctx.Err() != nil {
+			progress.end("Indexing cancelled")
+		} else {
+			progress.end("Indexing failed")
+		}
+		return err
+	}
+
+	progress.end("Indexing complete")
+	return nil
+}
+
+func (j *JavaLS) indexWorkspaceFolder(ctx context.Context, folderURI string, progress *progressReporter) error {
 	folderPath, err := j.fileResolver.FileURIToPath(folderURI)
 	if err != nil {
 		return errors.Wrapf(err, "error converting file URI %s to path", folderURI)
@@ -48,7 +76,17 @@ func (j *JavaLS) rescanWorkspaceFolder(folderURI string) error {
 	}
 
 	// read files & create TextDocumentItems
+	phase := progress.phase("Reading", progressRead, progressParse, len(allFiles))
 	textDocuments := util.MapAsync(allFiles, func(filePath string) protocol.TextDocumentItem {
+		defer phase.fileDone()
+		if ctx.Err() != nil {
+			return protocol.TextDocumentItem{URI: uri.New(filePath), LanguageID: "java", Version: 0, Text: ""}
+		}
+		// Documents that have been opened in the meantime are checked as they are in the editor instead
+		if textDocument, ok := j.openDocument(string(uri.New(filePath))); ok {
+			return textDocument
+		}
+
 		return protocol.TextDocumentItem{
 			URI:        uri.New(filePath),
 			LanguageID: "java",
@@ -57,14 +95,26 @@ func (j *JavaLS) rescanWorkspaceFolder(folderURI string) error {
 			Text:    j.fileResolver.ReadFile(filePath),
 		}
 	})
+	if ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "indexing aborted while reading files")
+	}
 
 	// parse all files
+	phase = progress.phase("Parsing", progressParse, progressGatherPass, len(textDocuments))
 	tdsParsed := util.MapAsync(textDocuments, func(td protocol.TextDocumentItem) textDocParsed {
+		defer phase.fileDone()
+		if ctx.Err() != nil {
+			return textDocParsed{doc: td, parsed: nil}
+		}
+
 		return textDocParsed{
 			doc:    td,
 			parsed: j.parseTextDocument(td),
 		}
 	})
+	if ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "indexing aborted while parsing files")
+	}
 
 	// create defUsages for each file
 	defUsagesMap := map[string]*typecheck.DefinitionsUsagesLookup{}
@@ -73,7 +123,13 @@ func (j *JavaLS) rescanWorkspaceFolder(folderURI string) error {
 	}
 
 	// Perform each pass on all files before proceeding
+	phase = progress.phase("Gathering types", progressGatherPass, progressSecondPass, len(tdsParsed))
 	util.EachAsync(tdsParsed, func(tdParsed textDocParsed) {
+		defer phase.fileDone()
+		if ctx.Err() != nil {
+			return
+		}
+
 		typecheck.GatherTypesFirstPass(
 			string(tdParsed.doc.URI),
 			int(tdParsed.doc.Version),
@@ -83,7 +139,17 @@ func (j *JavaLS) rescanWorkspaceFolder(folderURI string) error {
 			defUsagesMap[string(tdParsed.doc.URI)],
 		)
 	})
+	if ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "indexing aborted while gathering types")
+	}
+
+	phase = progress.phase("Gathering members", progressSecondPass, progressCheck, len(tdsParsed))
 	util.EachAsync(tdsParsed, func(tdParsed textDocParsed) {
+		defer phase.fileDone()
+		if ctx.Err() != nil {
+			return
+		}
+
 		typecheck.GatherTypesSecondPass(
 			string(tdParsed.doc.URI),
 			int(tdParsed.doc.Version),
@@ -93,18 +159,35 @@ func (j *JavaLS) rescanWorkspaceFolder(folderURI string) error {
 			defUsagesMap[string(tdParsed.doc.URI)],
 		)
 	})
+	if ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "indexing aborted while gathering members")
+	}
+
+	for _, tdParsed := range tdsParsed {
+		j.updatePublicSurface(string(tdParsed.doc.URI))
+	}
+
+	phase = progress.phase("Type checking", progressCheck, progressDone, len(tdsParsed))
 	util.EachAsync(tdsParsed, func(tdParsed textDocParsed) {
+		defer phase.fileDone()
+		if ctx.Err() != nil {
+			return
+		}
+
 		typeCheckingResult := typecheck.CheckTypes(
 			j.log,
 			string(tdParsed.doc.URI),
 			int(tdParsed.doc.Version),
 			tdParsed.parsed,
-			j.builtinTypes,
 			j.userTypes,
+			j.builtinTypes,
 			defUsagesMap[string(tdParsed.doc.URI)],
 		)
 		j.handleTypeCheckResult(tdParsed.doc, typeCheckingResult)
 	})
+	if ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "indexing aborted while type checking")
+	}
 
 	return nil
 }
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// How long to wait after the last edit to a document before re-checking it
+const defaultChangeDebounce = 200 * time.Millisecond
+
+// documentScheduler coalesces rapid edits to the same document into a single re-check.
+//
+// Each call to schedule() cancels whatever was previously scheduled for the document: if it hasn't
+// started yet, it never will, and if it's already running, its context gets cancelled so it can bail
+// out early. Checks for the same document never run concurrently -- a new check waits for the
+// previous one to finish before starting.
+type documentScheduler struct {
+	mu      sync.Mutex
+	delay   time.Duration
+	pending map[string]*scheduledCheck
+}
+
+type scheduledCheck struct {
+	timer  *time.Timer
+	cancel context.CancelFunc
+	// done is closed once this check has finished running (or been skipped)
+	done chan struct{}
+	// prevDone is the done channel of the check that has to finish before this one can start
+	prevDone chan struct{}
+}
+
+func newDocumentScheduler(delay time.Duration) *documentScheduler {
+	return &documentScheduler{
+		mu:      sync.Mutex{},
+		delay:   delay,
+		pending: make(map[string]*scheduledCheck),
+	}
+}
+
+// schedule arranges for run to be called for the document after the debounce delay, superseding
+// anything previously scheduled for that document.
+func (ds *documentScheduler) schedule(ctx context.Context, uri string, run func(ctx context.Context)) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	var prevDone chan struct{}
+	if existing, ok := ds.pending[uri]; ok {
+		existing.cancel()
+		if existing.timer.Stop() {
+			// It never started, so it'll never close its own done channel. We still need to
+			// wait for whatever it was waiting on though.
+			prevDone = existing.prevDone
+			close(existing.done)
+		} else {
+			prevDone = existing.done
+		}
+	}
+
+	checkCtx, cancel := context.WithCancel(ctx)
+	check := &scheduledCheck{
+		timer:    nil,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		prevDone: prevDone,
+	}
+
+	check.timer = time.AfterFunc(ds.delay, func() {
+		defer close(check.done)
+		defer ds.remove(uri, check)
+
+		if check.prevDone != nil {
+			<-check.prevDone
+		}
+		if checkCtx.Err() != nil {
+			return
+		}
+		run(checkCtx)
+	})
+
+	ds.pending[uri] = check
+}
+
+// cancel cancels anything scheduled or running for the given document.
+func (ds *documentScheduler) cancel(uri string) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if existing, ok := ds.pending[uri]; ok {
+		existing.cancel()
+	}
+}
+
+// wait blocks until nothing is scheduled or running for the given document.
+func (ds *documentScheduler) wait(uri string) {
+	for {
+		ds.mu.Lock()
+		existing, ok := ds.pending[uri]
+		ds.mu.Unlock()
+
+		if !ok {
+			return
+		}
+		<-existing.done
+	}
+}
+
+func (ds *documentScheduler) remove(uri string, check *scheduledCheck) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if ds.pending[uri] == check {
+		delete(ds.pending, uri)
+	}
+}
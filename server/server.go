@@ -15,6 +15,7 @@ import (
 	"java-mini-ls-go/parse/typ"
 	"java-mini-ls-go/parse/typecheck"
 	"java-mini-ls-go/util"
+	"sync"
 )
 
 // Runtime check to ensure JavaLS implements interface
@@ -31,6 +32,28 @@ type JavaLS struct {
 	defUsages         *util.SyncMap[string, *typecheck.DefinitionsUsagesLookup]
 	builtinTypes      *typ.TypeMap
 	userTypes         *typ.TypeMap
+	// Latest type errors for each document, for the quick fixes that come with them
+	typeErrors *util.SyncMap[string, []typecheck.TypeError]
+
+	// Cancel functions for in-progress operations that are reporting work done progress, keyed by progress token
+	progressCancels                *util.SyncMap[string, context.CancelFunc]
+	clientSupportsWorkDoneProgress bool
+	// Indexing the workspace at startup, which happens in the background
+	indexing sync.WaitGroup
+
+	// Latest version we've seen of each open document, used to drop out-of-date diagnostics
+	documentVersions *util.SyncMap[string, int32]
+	// Held while storing what was found out about a version of a document, so that results for an older
+	// version, e.g. from indexing the file on disk, can't overwrite the ones for a newer version
+	documentStateLock sync.Mutex
+	// Debounces re-checking documents as they're edited
+	changeScheduler *documentScheduler
+	// Current diagnostics for each document from each source (syntax errors, type errors, etc.)
+	diagnostics *diagnosticsManager
+	// Which files reference symbols from which other files, and the last known public surface of
+	// each file, used to re-check dependents when a file's public surface changes
+	dependencies   *dependencyGraph
+	publicSurfaces *util.SyncMap[string, string]
 
 	// Dependencies that can be mocked for testing
 	diagnosticsPublisher DiagnosticsPublisher
@@ -42,18 +65,29 @@ type JavaLS struct {
 
 func NewServer(ctx context.Context, logger *zap.Logger) *JavaLS {
 	return &JavaLS{
-		ctx:                  ctx,
-		log:                  logger,
-		client:               nil,
-		documentTextCache:    util.NewSyncMap[string, protocol.TextDocumentItem](),
-		symbols:              util.NewSyncMap[string, []*sym.CodeSymbol](),
-		scopes:               util.NewSyncMap[string, *typecheck.TypeCheckingScope](),
-		defUsages:            util.NewSyncMap[string, *typecheck.DefinitionsUsagesLookup](),
-		builtinTypes:         typ.NewTypeMap(),
-		userTypes:            typ.NewTypeMap(),
-		diagnosticsPublisher: &RealDiagnosticsPublisher{},
-		fileResolver:         &RealFileResolver{},
-		ReadStdlibTypes:      false,
+		ctx:               ctx,
+		log:               logger,
+		client:            nil,
+		documentTextCache: util.NewSyncMap[string, protocol.TextDocumentItem](),
+		symbols:           util.NewSyncMap[string, []*sym.CodeSymbol](),
+		scopes:            util.NewSyncMap[string, *typecheck.TypeCheckingScope](),
+		defUsages:         util.NewSyncMap[string, *typecheck.DefinitionsUsagesLookup](),
+		builtinTypes:      typ.NewTypeMap(),
+		userTypes:         typ.NewTypeMap(),
+		typeErrors:        util.NewSyncMap[string, []typecheck.TypeError](),
+		progressCancels:   util.NewSyncMap[string, context.CancelFunc](),
+		// Set during Initialize based on the client's capabilities
+		clientSupportsWorkDoneProgress: false,
+		indexing:                       sync.WaitGroup{},
+		documentVersions:               util.NewSyncMap[string, int32](),
+		documentStateLock:              sync.Mutex{},
+		changeScheduler:                newDocumentScheduler(defaultChangeDebounce),
+		diagnostics:                    newDiagnosticsManager(),
+		dependencies:                   newDependencyGraph(),
+		publicSurfaces:                 util.NewSyncMap[string, string](),
+		diagnosticsPublisher:           &RealDiagnosticsPublisher{},
+		fileResolver:                   &RealFileResolver{},
+		ReadStdlibTypes:                false,
 	}
 }
 
@@ -78,6 +112,9 @@ func (j *JavaLS) Initialize(_ context.Context, params *protocol.InitializeParams
 		return nil, err
 	}
 
+	window := params.Capabilities.Window
+	j.clientSupportsWorkDoneProgress = window != nil && window.WorkDoneProgress
+
 	return &protocol.InitializeResult{
 		Capabilities: protocol.ServerCapabilities{
 			TextDocumentSync: protocol.TextDocumentSyncOptions{
@@ -98,13 +135,27 @@ func (j *JavaLS) Initialize(_ context.Context, params *protocol.InitializeParams
 				ResolveProvider:   false,
 				TriggerCharacters: []string{"."},
 			},
+			SignatureHelpProvider: &protocol.SignatureHelpOptions{
+				TriggerCharacters:   []string{"(", ","},
+				RetriggerCharacters: nil,
+			},
+			CodeActionProvider: &protocol.CodeActionOptions{
+				CodeActionKinds: []protocol.CodeActionKind{protocol.QuickFix},
+			},
 		},
 		ServerInfo: nil,
 	}, nil
 }
 
-func (j *JavaLS) Initialized(ctx context.Context, _ *protocol.InitializedParams) error {
-	j.rescanEverything(ctx)
+func (j *JavaLS) Initialized(_ context.Context, _ *protocol.InitializedParams) error {
+	// Requests are handled one at a time, so indexing in here would hold up every other request until it's
+	// done, including the one to cancel it. The request context is done as soon as this returns, so indexing
+	// hangs off of the server's context instead.
+	j.indexing.Add(1)
+	go func() {
+		defer j.indexing.Done()
+		j.rescanEverything(j.ctx)
+	}()
 
 	j.log.Info("Initialized")
 	return nil
@@ -118,11 +169,16 @@ func (j *JavaLS) Exit(_ context.Context) error {
 	return nil
 }
 
-func (j *JavaLS) DidOpen(_ context.Context, params *protocol.DidOpenTextDocumentParams) error {
+func (j *JavaLS) DidOpen(ctx context.Context, params *protocol.DidOpenTextDocumentParams) error {
 	j.log.Info(fmt.Sprintf("DidOpen %s", params.TextDocument.URI))
 
+	uriString := string(params.TextDocument.URI)
+	j.documentVersions.Set(uriString, params.TextDocument.Version)
+	// Anything still scheduled from before the document was (re-)opened is out of date now
+	j.changeScheduler.cancel(uriString)
+
 	parsed := j.parseTextDocument(params.TextDocument)
-	j.typeCheckDocument(params.TextDocument, parsed)
+	j.typeCheckDocument(ctx, params.TextDocument, parsed)
 
 	return nil
 }
@@ -136,58 +192,156 @@ func (j *JavaLS) DidChange(_ context.Context, params *protocol.DidChangeTextDocu
 		LanguageID: "java",
 	}
 
-	parsed := j.parseTextDocument(item)
-	j.typeCheckDocument(item, parsed)
+	uriString := string(item.URI)
+	j.documentVersions.Set(uriString, item.Version)
+	// Keep the text up-to-date right away, even though the re-check is debounced
+	j.documentTextCache.Set(uriString, item)
+
+	// The request context is done as soon as this notification is handled, so the re-check
+	// needs to hang off of the server's context instead.
+	j.changeScheduler.schedule(j.ctx, uriString, func(ctx context.Context) {
+		parsed := j.parseTextDocument(item)
+		if ctx.Err() != nil {
+			return
+		}
+		j.typeCheckDocument(ctx, item, parsed)
+	})
 
 	return nil
 }
 
-func (j *JavaLS) DidClose(_ context.Context, _ *protocol.DidCloseTextDocumentParams) error {
+func (j *JavaLS) DidClose(_ context.Context, params *protocol.DidCloseTextDocumentParams) error {
+	uriString := string(params.TextDocument.URI)
+	j.changeScheduler.cancel(uriString)
+	j.diagnostics.forget(uriString)
 	return nil
 }
 
 func (j *JavaLS) parseTextDocument(textDocument protocol.TextDocumentItem) antlr.Tree {
 	uriString := string(textDocument.URI)
-	j.documentTextCache.Set(uriString, textDocument)
 
 	parsed, syntaxErrors := parse.Parse(textDocument.Text)
 
 	symbols := sym.FindSymbols(parsed)
-	j.symbols.Set(uriString, symbols)
+	j.setDocumentState(textDocument, func() {
+		j.documentTextCache.Set(uriString, textDocument)
+		j.symbols.Set(uriString, symbols)
+	})
 
-	j.diagnosticsPublisher.PublishDiagnostics(
-		j,
+	j.publishDiagnostics(
 		textDocument,
+		DiagnosticSourceSyntax,
 		util.Map(syntaxErrors, func(se parse.SyntaxError) protocol.Diagnostic { return se.ToDiagnostic() }),
 	)
 
 	return parsed
 }
 
-func (j *JavaLS) typeCheckDocument(textDocument protocol.TextDocumentItem, parsed antlr.Tree) {
+// typeCheckDocument runs all the type gathering & checking passes on a document. If ctx is cancelled
+// partway through, it stops after the current pass without publishing anything.
+func (j *JavaLS) typeCheckDocument(ctx context.Context, textDocument protocol.TextDocumentItem, parsed antlr.Tree) {
+	surfaceChanged, ok := j.gatherAndCheckDocument(ctx, textDocument, parsed)
+
+	// Other files using this one's types may have new errors (or fewer errors) now
+	if ok && surfaceChanged {
+		j.recheckDependents(ctx, string(textDocument.URI))
+	}
+}
+
+// gatherAndCheckDocument gathers the types of a document, replacing the ones it declared before, and type
+// checks it. Returns whether its public surface changed, and false if ctx was cancelled partway through, in
+// which case nothing is published.
+func (j *JavaLS) gatherAndCheckDocument(ctx context.Context, textDocument protocol.TextDocumentItem, parsed antlr.Tree) (bool, bool) {
 	uriString := string(textDocument.URI)
 
 	defUsages := typecheck.NewDefinitionsUsagesLookup()
 	typecheck.GatherTypesFirstPass(uriString, int(textDocument.Version), parsed, j.builtinTypes, j.userTypes, defUsages)
+	if ctx.Err() != nil {
+		return false, false
+	}
 	typecheck.GatherTypesSecondPass(uriString, int(textDocument.Version), parsed, j.builtinTypes, j.userTypes, defUsages)
-	typeCheckingResult := typecheck.CheckTypes(j.log, uriString, int(textDocument.Version), parsed, j.builtinTypes, j.userTypes, defUsages)
+	if ctx.Err() != nil {
+		return false, false
+	}
+	surfaceChanged := j.updatePublicSurface(uriString)
+	typeCheckingResult := typecheck.CheckTypes(j.log, uriString, int(textDocument.Version), parsed, j.userTypes, j.builtinTypes, defUsages)
+	if ctx.Err() != nil {
+		return false, false
+	}
 	j.handleTypeCheckResult(textDocument, typeCheckingResult)
+
+	return surfaceChanged, true
 }
 
 func (j *JavaLS) handleTypeCheckResult(textDocument protocol.TextDocumentItem, typeCheckingResult typecheck.TypeCheckResult) {
 	uriString := string(textDocument.URI)
-	j.scopes.Set(uriString, typeCheckingResult.RootScope)
-	j.defUsages.Set(uriString, typeCheckingResult.DefUsagesLookup)
-
 	typeErrors := typeCheckingResult.TypeErrors
+	j.setDocumentState(textDocument, func() {
+		j.scopes.Set(uriString, typeCheckingResult.RootScope)
+		j.defUsages.Set(uriString, typeCheckingResult.DefUsagesLookup)
+		j.dependencies.setDependencies(uriString, typeCheckingResult.DefUsagesLookup.ReferencedFiles(uriString))
+		j.typeErrors.Set(uriString, typeErrors)
+	})
 
-	j.diagnosticsPublisher.PublishDiagnostics(
-		j,
+	j.publishDiagnostics(
 		textDocument,
+		DiagnosticSourceType,
 		util.Map(typeErrors, func(se typecheck.TypeError) protocol.Diagnostic { return se.ToDiagnostic() }),
 	)
 }
 
+// setDocumentState runs set, which stores what was found out about a version of a document, unless a newer
+// version of the document has been opened or changed since. Indexing reads every file from disk as version
+// 0, which mustn't overwrite what's known about the version open in the editor.
+func (j *JavaLS) setDocumentState(textDocument protocol.TextDocumentItem, set func()) {
+	j.documentStateLock.Lock()
+	defer j.documentStateLock.Unlock()
+
+	latestVersion, ok := j.documentVersions.Get(string(textDocument.URI))
+	if ok && textDocument.Version < latestVersion {
+		return
+	}
+	set()
+}
+
+// openDocument returns the latest version of a document that's open in the editor, which may have changes
+// that haven't been saved to disk.
+func (j *JavaLS) openDocument(uriString string) (protocol.TextDocumentItem, bool) {
+	latestVersion, isOpen := j.documentVersions.Get(uriString)
+	textDocument, ok := j.documentTextCache.Get(uriString)
+	return textDocument, isOpen && ok && textDocument.Version == latestVersion
+}
+
+// publishDiagnostics replaces the diagnostics from one source for a document, and publishes the merged
+// diagnostics from all sources. Diagnostics for an older version of the document than the latest one
+// we've seen are dropped.
+func (j *JavaLS) publishDiagnostics(textDocument protocol.TextDocumentItem, source DiagnosticSource, diagnostics []protocol.Diagnostic) {
+	uriString := string(textDocument.URI)
+	latestVersion, ok := j.documentVersions.Get(uriString)
+	if ok && textDocument.Version < latestVersion {
+		j.log.Info(fmt.Sprintf("Dropping diagnostics for %s version %d, latest is %d", uriString, textDocument.Version, latestVersion))
+		return
+	}
+
+	merged := j.diagnostics.set(uriString, textDocument.Version, source, diagnostics)
+	j.diagnosticsPublisher.PublishDiagnostics(j, textDocument, merged)
+}
+
+// ImportExternalDiagnostics sets the diagnostics for a document that were reported by something
+// outside of the language server, such as a build tool. They get merged with our own diagnostics
+// and stay until they're replaced by another import.
+func (j *JavaLS) ImportExternalDiagnostics(documentURI protocol.DocumentURI, diagnostics []protocol.Diagnostic) {
+	uriString := string(documentURI)
+
+	textDocument, ok := j.documentTextCache.Get(uriString)
+	if !ok {
+		textDocument = protocol.TextDocumentItem{URI: documentURI, LanguageID: "java", Version: 0, Text: ""}
+	}
+
+	merged := j.diagnostics.set(uriString, textDocument.Version, DiagnosticSourceExternal, diagnostics)
+	j.diagnosticsPublisher.PublishDiagnostics(j, textDocument, merged)
+}
+
 var symbolTypeMap = map[sym.CodeSymbolType]protocol.SymbolKind{
 	sym.CodeSymbolClass:       protocol.SymbolKindClass,
 	sym.CodeSymbolConstant:    protocol.SymbolKindConstant,
@@ -264,6 +418,7 @@ func (j *JavaLS) Hover(ctx context.Context, params *protocol.HoverParams) (*prot
 
 // NOTE: line is 0-based here (LSP style)
 // Will probably be used for auto-completion
+//
 //nolint:unused
 func (j *JavaLS) getTextOnLine(fileURI string, line int) (string, error) {
 	text, ok := j.documentTextCache.Get(fileURI)
@@ -385,9 +540,17 @@ func (j *JavaLS) Completion(_ context.Context, params *protocol.CompletionParams
 				Character: dotIdx,
 			})
 			if leftOfDot != nil {
-				allMembers := leftOfDot.GetType().AllMembers()
-				j.log.Info(fmt.Sprintf("Auto-complete dot items: %d", len(allMembers)))
-				return symbolsToCompletionList(allMembers), nil
+				// Only offer the members that can be accessed from here
+				qualifier := leftOfDot.GetType()
+				enclosing := j.enclosingTypeAt(string(params.TextDocument.URI), params.Position)
+				members := []typ.JavaSymbol{}
+				for _, member := range qualifier.AllMembers() {
+					if typ.IsAccessible(member, enclosing, qualifier) {
+						members = append(members, member)
+					}
+				}
+				j.log.Info(fmt.Sprintf("Auto-complete dot items: %d", len(members)))
+				return symbolsToCompletionList(members), nil
 			}
 		}
 	}
@@ -406,6 +569,18 @@ func (j *JavaLS) Completion(_ context.Context, params *protocol.CompletionParams
 	return nil, nil
 }
 
+// enclosingTypeAt returns the innermost type declared around a position in a document, or nil if there is none.
+func (j *JavaLS) enclosingTypeAt(uri string, position protocol.Position) *typ.JavaType {
+	fileScopes, ok := j.scopes.Get(uri)
+	if !ok {
+		return nil
+	}
+	return fileScopes.LookupScopeFor(loc.FileLocation{
+		Line:      int(position.Line + 1),
+		Character: int(position.Character),
+	}).EnclosingType()
+}
+
 func isAlphaNumeric(ch uint8) bool {
 	return (ch >= 'a' && ch <= 'z') ||
 		(ch >= 'A' && ch <= 'Z') ||
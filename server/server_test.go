@@ -2,8 +2,11 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"github.com/golang/mock/gomock"
 	"java-mini-ls-go/util"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -131,7 +134,7 @@ func TestServer_Symbols(t *testing.T) {
 	assert.Nil(t, err)
 
 	symbols, err := jls.DocumentSymbol(ctx, &protocol.DocumentSymbolParams{
-		TextDocument: protocol.TextDocumentIdentifier{uri.New("test_location")},
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri.New("test_location")},
 	})
 	assert.Nil(t, err)
 
@@ -249,7 +252,7 @@ func TestServer_Hover(t *testing.T) {
 	assert.Equal(t, &protocol.Hover{
 		Contents: protocol.MarkupContent{
 			Kind:  protocol.Markdown,
-			Value: "**thing** Thing thing (local var in void Main.main(String[] args))",
+			Value: "**thing** Thing thing (local var in void java.Main.main(String[] args))",
 		},
 		Range: nil,
 	}, result)
@@ -493,6 +496,46 @@ func TestServer_Completion(t *testing.T) {
 	assert.Equal(t, "main", completionList.Items[1].Label)
 }
 
+func TestServer_Completion_BlockScopes(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, _ := testServer(t, ctx)
+
+	err := jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+		TextDocument: createTextDocument("test_location", `public class Main {
+	public void main(String[] args) {
+		for (String arg : args) {
+			int length = arg.length();
+		}
+		try {
+			int attempts = 1;
+		} catch (RuntimeException e) {
+			
+		}
+	}
+}`)})
+	assert.Nil(t, err)
+
+	completionList, err := jls.Completion(ctx, &protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{
+				URI: uri.New("test_location"),
+			},
+			Position: protocol.Position{
+				Line:      8,
+				Character: 3,
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	// The locals of the loop and the `try` block aren't in scope in the `catch` clause
+	labels := util.Map(completionList.Items, func(item protocol.CompletionItem) string {
+		return item.Label
+	})
+	assert.ElementsMatch(t, []string{"e", "args", "main"}, labels)
+}
+
 func TestServer_Completion_Dot(t *testing.T) {
 	ctx, cancel := testCtx()
 	defer cancel()
@@ -531,6 +574,86 @@ func TestServer_Completion_Dot(t *testing.T) {
 	assert.True(t, foundOut)
 }
 
+func TestServer_Completion_DotOnType(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, _ := testServer(t, ctx)
+
+	err := jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+		TextDocument: createTextDocument("test_location", `public class Counter {
+	static int total;
+	int count;
+	static void reset() {}
+	void increment() {}
+	public void main() {
+		Counter.t
+	}
+}`)})
+	assert.Nil(t, err)
+
+	completionList, err := jls.Completion(ctx, &protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{
+				URI: uri.New("test_location"),
+			},
+			Position: protocol.Position{
+				Line:      6,
+				Character: 11,
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	// Only the static members can be accessed through the name of the type
+	labels := util.Map(completionList.Items, func(item protocol.CompletionItem) string {
+		return item.Label
+	})
+	assert.ElementsMatch(t, []string{"total", "reset"}, labels)
+}
+
+func TestServer_Completion_DotOnlyAccessible(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, _ := testServer(t, ctx)
+
+	err := jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+		TextDocument: createTextDocument("test_location", `class Account {
+	private int balance;
+	public String owner;
+	private void audit() {}
+	public void deposit() {}
+}
+class Bank {
+	public void main(Account account) {
+		account.o
+	}
+}`)})
+	assert.Nil(t, err)
+
+	completionList, err := jls.Completion(ctx, &protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{
+				URI: uri.New("test_location"),
+			},
+			Position: protocol.Position{
+				Line:      8,
+				Character: 11,
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	// The private members of Account can't be accessed from Bank, and neither can the protected ones it
+	// inherits from Object
+	labels := util.Map(completionList.Items, func(item protocol.CompletionItem) string {
+		return item.Label
+	})
+	assert.ElementsMatch(t, []string{
+		"owner", "deposit",
+		"equals", "getClass", "hashCode", "notify", "notifyAll", "toString", "wait", "wait", "wait",
+	}, labels)
+}
+
 func TestServer_Completion_DotIsLast(t *testing.T) {
 	ctx, cancel := testCtx()
 	defer cancel()
@@ -641,6 +764,7 @@ public class Def {
 
 	err := jls.Initialized(ctx, nil)
 	assert.Nil(t, err)
+	jls.indexing.Wait()
 
 	err = jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
 		TextDocument: createTextDocument("main.java", `public class Main {
@@ -728,3 +852,743 @@ public class Def {
 	}, refResult)
 
 }
+
+// setUpIndexingMocks makes the mock client report one workspace folder containing the given files,
+// and records every `$/progress` value sent to the client.
+func setUpIndexingMocks(jls *JavaLS, ctrl *gomock.Controller, files map[string]string, onProgress func(value interface{})) {
+	jls.clientSupportsWorkDoneProgress = true
+
+	mockClient := NewMockClient(ctrl)
+	jls.client = mockClient
+	mockClient.
+		EXPECT().
+		WorkspaceFolders(gomock.Any()).
+		Return([]protocol.WorkspaceFolder{
+			{
+				URI:  "test_workspace_folder",
+				Name: "test_workspace_folder",
+			},
+		}, nil).
+		Times(1)
+	mockClient.
+		EXPECT().
+		WorkDoneProgressCreate(gomock.Any(), gomock.Any()).
+		Return(nil).
+		Times(1)
+	mockClient.
+		EXPECT().
+		Progress(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, params *protocol.ProgressParams) error {
+			onProgress(params.Value)
+			return nil
+		}).
+		AnyTimes()
+
+	fileNames := util.Keys(files)
+	fr := NewMockFileResolver(ctrl)
+	jls.fileResolver = fr
+	fr.
+		EXPECT().
+		FileURIToPath(gomock.Any()).
+		DoAndReturn(func(fileUri string) (string, error) {
+			return fileUri, nil
+		}).
+		AnyTimes()
+	fr.
+		EXPECT().
+		ListJavaFilesRecursive(gomock.Eq("test_workspace_folder")).
+		Return(fileNames, nil).
+		Times(1)
+	fr.
+		EXPECT().
+		ReadFile(gomock.Any()).
+		DoAndReturn(func(filePath string) string {
+			return files[filePath]
+		}).
+		AnyTimes()
+}
+
+func TestServer_IndexingProgress(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, ctrl := testServer(t, ctx)
+
+	var mu sync.Mutex
+	var values []interface{}
+	setUpIndexingMocks(jls, ctrl, map[string]string{
+		"abc.java": `public class Abc { public int a; }`,
+		"def.java": `public class Def { public int d; }`,
+	}, func(value interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		values = append(values, value)
+	})
+
+	err := jls.Initialized(ctx, nil)
+	assert.Nil(t, err)
+	jls.indexing.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.GreaterOrEqual(t, len(values), 3)
+
+	begin, ok := values[0].(*protocol.WorkDoneProgressBegin)
+	assert.True(t, ok)
+	assert.Equal(t, "Indexing test_workspace_folder", begin.Title)
+	assert.True(t, begin.Cancellable)
+
+	// Reports should be steadily increasing, and the last one should be at 100%
+	lastPercentage := uint32(0)
+	for _, value := range values[1 : len(values)-1] {
+		report, ok := value.(*protocol.WorkDoneProgressReport)
+		assert.True(t, ok)
+		assert.Greater(t, report.Percentage, lastPercentage)
+		lastPercentage = report.Percentage
+	}
+	assert.Equal(t, uint32(100), lastPercentage)
+
+	end, ok := values[len(values)-1].(*protocol.WorkDoneProgressEnd)
+	assert.True(t, ok)
+	assert.Equal(t, "Indexing complete", end.Message)
+}
+
+func TestServer_IndexingProgress_Cancel(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, ctrl := testServer(t, ctx)
+
+	var mu sync.Mutex
+	var values []interface{}
+	setUpIndexingMocks(jls, ctrl, map[string]string{
+		"abc.java": `public class Abc { public int a; }`,
+	}, func(value interface{}) {
+		mu.Lock()
+		values = append(values, value)
+		mu.Unlock()
+
+		// Cancel as soon as indexing starts
+		if _, ok := value.(*protocol.WorkDoneProgressBegin); ok {
+			err := jls.WorkDoneProgressCancel(ctx, &protocol.WorkDoneProgressCancelParams{
+				Token: *protocol.NewProgressToken(fmt.Sprintf("java-mini-ls/%d", atomic.LoadInt32(&progressTokenCounter))),
+			})
+			assert.Nil(t, err)
+		}
+	})
+
+	err := jls.Initialized(ctx, nil)
+	assert.Nil(t, err)
+	jls.indexing.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	end, ok := values[len(values)-1].(*protocol.WorkDoneProgressEnd)
+	assert.True(t, ok)
+	assert.Equal(t, "Indexing cancelled", end.Message)
+
+	// Abc never got gathered since indexing was cancelled before that phase
+	assert.Nil(t, jls.userTypes.Get("Abc"))
+}
+
+func TestServer_IndexingProgress_CancelMidScan(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, ctrl := testServer(t, ctx)
+
+	var mu sync.Mutex
+	var values []interface{}
+	started := make(chan struct{})
+	resume := make(chan struct{})
+	setUpIndexingMocks(jls, ctrl, map[string]string{
+		"abc.java": `public class Abc { public int a; }`,
+	}, func(value interface{}) {
+		mu.Lock()
+		values = append(values, value)
+		mu.Unlock()
+
+		// Hold indexing up until the client has cancelled it
+		if _, ok := value.(*protocol.WorkDoneProgressBegin); ok {
+			close(started)
+			<-resume
+		}
+	})
+
+	// Returns while indexing is still going, so that the cancellation can be handled
+	err := jls.Initialized(ctx, nil)
+	assert.Nil(t, err)
+	<-started
+
+	err = jls.WorkDoneProgressCancel(ctx, &protocol.WorkDoneProgressCancelParams{
+		Token: *protocol.NewProgressToken(fmt.Sprintf("java-mini-ls/%d", atomic.LoadInt32(&progressTokenCounter))),
+	})
+	assert.Nil(t, err)
+	close(resume)
+	jls.indexing.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	end, ok := values[len(values)-1].(*protocol.WorkDoneProgressEnd)
+	assert.True(t, ok)
+	assert.Equal(t, "Indexing cancelled", end.Message)
+	assert.Nil(t, jls.userTypes.Get("Abc"))
+}
+
+func TestServer_IndexingFinishesAfterDidOpen(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, ctrl := testServer(t, ctx)
+
+	read := make(chan struct{})
+	resume := make(chan struct{})
+	setUpIndexingMocks(jls, ctrl, map[string]string{
+		"abc.java": `public class Abc { public int a; }`,
+	}, func(value interface{}) {
+		// Hold indexing up once it has read the file from disk, until the document has been opened
+		if report, ok := value.(*protocol.WorkDoneProgressReport); ok && report.Message == "Reading (1/1 files)" {
+			close(read)
+			<-resume
+		}
+	})
+
+	err := jls.Initialized(ctx, nil)
+	assert.Nil(t, err)
+	<-read
+
+	doc := createTextDocument("abc.java", `public class Abc { public int b = "hi"; }`)
+	doc.Version = 1
+	err = jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{TextDocument: doc})
+	assert.Nil(t, err)
+	close(resume)
+	jls.indexing.Wait()
+
+	// Indexing the file on disk as version 0 mustn't overwrite what's known about the open version
+	text, ok := jls.documentTextCache.Get(string(doc.URI))
+	assert.True(t, ok)
+	assert.Equal(t, doc, text)
+	symbols, ok := jls.symbols.Get(string(doc.URI))
+	assert.True(t, ok)
+	assert.Equal(t, "b", symbols[0].Children[0].Name)
+	typeErrors, ok := jls.typeErrors.Get(string(doc.URI))
+	assert.True(t, ok)
+	assert.Len(t, typeErrors, 1)
+	abc := jls.userTypes.Get("Abc")
+	if assert.NotNil(t, abc) {
+		assert.NotNil(t, abc.LookupMember("b"))
+		assert.Nil(t, abc.LookupMember("a"))
+	}
+}
+
+func TestServer_DidChange_Debounced(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, ctrl := testServer(t, ctx)
+	jls.changeScheduler = newDocumentScheduler(20 * time.Millisecond)
+
+	var mu sync.Mutex
+	publishedVersions := []int32{}
+
+	mdp := NewMockDiagnosticsPublisher(ctrl)
+	jls.diagnosticsPublisher = mdp
+	mdp.
+		EXPECT().
+		PublishDiagnostics(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_ *JavaLS, textDocument protocol.TextDocumentItem, _ []protocol.Diagnostic) {
+			mu.Lock()
+			defer mu.Unlock()
+			publishedVersions = append(publishedVersions, textDocument.Version)
+		}).
+		AnyTimes()
+
+	err := jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+		TextDocument: createTextDocument("test_location", localTestFileText),
+	})
+	assert.Nil(t, err)
+
+	// Simulate fast typing
+	for version := int32(1); version <= 5; version++ {
+		err = jls.DidChange(ctx, &protocol.DidChangeTextDocumentParams{
+			TextDocument: protocol.VersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri.New("test_location")},
+				Version:                version,
+			},
+			ContentChanges: []protocol.TextDocumentContentChangeEvent{{
+				Range:       protocol.Range{}, //nolint:exhaustruct
+				RangeLength: 0,
+				Text:        localTestFileText2,
+			}},
+		})
+		assert.Nil(t, err)
+	}
+
+	jls.changeScheduler.wait(string(uri.New("test_location")))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Syntax errors + type errors, once for the opened version and once for the final edit
+	assert.Equal(t, []int32{0, 0, 5, 5}, publishedVersions)
+
+	text, ok := jls.documentTextCache.Get(string(uri.New("test_location")))
+	assert.True(t, ok)
+	assert.Equal(t, int32(5), text.Version)
+}
+
+func TestServer_StaleDiagnosticsDropped(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, ctrl := testServer(t, ctx)
+
+	mdp := NewMockDiagnosticsPublisher(ctrl)
+	jls.diagnosticsPublisher = mdp
+	mdp.
+		EXPECT().
+		PublishDiagnostics(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	jls.documentVersions.Set(string(uri.New("test_location")), 3)
+
+	doc := createTextDocument("test_location", localTestFileText)
+	doc.Version = 2
+	jls.publishDiagnostics(doc, DiagnosticSourceType, []protocol.Diagnostic{})
+}
+
+func TestServer_DiagnosticsMerged(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, ctrl := testServer(t, ctx)
+
+	var lastPublished []protocol.Diagnostic
+	mdp := NewMockDiagnosticsPublisher(ctrl)
+	jls.diagnosticsPublisher = mdp
+	mdp.
+		EXPECT().
+		PublishDiagnostics(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_ *JavaLS, _ protocol.TextDocumentItem, diagnostics []protocol.Diagnostic) {
+			lastPublished = diagnostics
+		}).
+		AnyTimes()
+
+	// Has both a syntax error (missing semicolon) and a type error
+	err := jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+		TextDocument: createTextDocument("test_location", `public class Main {
+	public void main() {
+		int a = "hi";
+		int b = 1 +;
+	}
+}`),
+	})
+	assert.Nil(t, err)
+
+	// Type errors got published last, but the syntax errors should still be there
+	codes := util.Map(lastPublished, func(d protocol.Diagnostic) interface{} { return d.Code })
+	assert.Contains(t, codes, "syntax")
+	assert.Contains(t, codes, "type")
+}
+
+func TestServer_ExternalDiagnosticsMerged(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, ctrl := testServer(t, ctx)
+
+	var lastPublished []protocol.Diagnostic
+	mdp := NewMockDiagnosticsPublisher(ctrl)
+	jls.diagnosticsPublisher = mdp
+	mdp.
+		EXPECT().
+		PublishDiagnostics(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_ *JavaLS, _ protocol.TextDocumentItem, diagnostics []protocol.Diagnostic) {
+			lastPublished = diagnostics
+		}).
+		AnyTimes()
+	externalDiagnostic := func(message string) protocol.Diagnostic {
+		return protocol.Diagnostic{
+			Range:              oneLineRange(0, 0, 1),
+			Severity:           protocol.DiagnosticSeverityWarning,
+			Code:               "checkstyle",
+			CodeDescription:    nil,
+			Source:             "checkstyle",
+			Message:            message,
+			Tags:               nil,
+			RelatedInformation: nil,
+			Data:               nil,
+		}
+	}
+	messages := func() []string {
+		return util.Map(lastPublished, func(d protocol.Diagnostic) string { return d.Message })
+	}
+
+	doc := createTextDocument("test_location", `public class Main {
+	int a = "hi";
+}`)
+	err := jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{TextDocument: doc})
+	assert.Nil(t, err)
+
+	// Importing external diagnostics adds to the type errors rather than replacing them
+	jls.ImportExternalDiagnostics(doc.URI, []protocol.Diagnostic{externalDiagnostic("Missing javadoc")})
+	assert.Equal(t, []string{"Type mismatch: cannot convert from String to int", "Missing javadoc"}, messages())
+
+	// Another import replaces the external diagnostics from before
+	jls.ImportExternalDiagnostics(doc.URI, []protocol.Diagnostic{externalDiagnostic("Line is longer than 100 characters")})
+	assert.Equal(t, []string{"Type mismatch: cannot convert from String to int", "Line is longer than 100 characters"}, messages())
+
+	// A newer version replaces the type errors, and the external diagnostics stay
+	doc.Version = 1
+	doc.Text = `public class Main {
+	int a = 1;
+}`
+	err = jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{TextDocument: doc})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Line is longer than 100 characters"}, messages())
+}
+
+func TestServer_DidClose_ForgetsDiagnostics(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, ctrl := testServer(t, ctx)
+
+	published := [][]protocol.Diagnostic{}
+	mdp := NewMockDiagnosticsPublisher(ctrl)
+	jls.diagnosticsPublisher = mdp
+	mdp.
+		EXPECT().
+		PublishDiagnostics(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_ *JavaLS, _ protocol.TextDocumentItem, diagnostics []protocol.Diagnostic) {
+			published = append(published, diagnostics)
+		}).
+		AnyTimes()
+
+	doc := createTextDocument("test_location", `public class Main {
+	int a = "hi";
+}`)
+	doc.Version = 5
+	err := jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{TextDocument: doc})
+	assert.Nil(t, err)
+	err = jls.DidClose(ctx, &protocol.DidCloseTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: doc.URI},
+	})
+	assert.Nil(t, err)
+
+	// Opening it again starts the versions over, which mustn't leave the new syntax errors out in favor
+	// of the type errors from before it was closed
+	published = nil
+	doc = createTextDocument("test_location", `public class Main {
+	int b = 1 +;
+}`)
+	doc.Version = 1
+	err = jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{TextDocument: doc})
+	assert.Nil(t, err)
+
+	if assert.NotEmpty(t, published) {
+		codes := util.Map(published[0], func(d protocol.Diagnostic) interface{} { return d.Code })
+		assert.Contains(t, codes, "syntax")
+		assert.NotContains(t, codes, "type")
+	}
+}
+
+func TestServer_DidOpen_LibraryTypes(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, ctrl := testServer(t, ctx)
+
+	var lastPublished []protocol.Diagnostic
+	mdp := NewMockDiagnosticsPublisher(ctrl)
+	jls.diagnosticsPublisher = mdp
+	mdp.
+		EXPECT().
+		PublishDiagnostics(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_ *JavaLS, _ protocol.TextDocumentItem, diagnostics []protocol.Diagnostic) {
+			lastPublished = diagnostics
+		}).
+		AnyTimes()
+
+	err := jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+		TextDocument: createTextDocument("test_location", `package app;
+
+import java.util.List;
+import java.util.Map;
+
+// ArrayList and HashMap aren't imported, so they can't be used until they are
+public class Main {
+	public void main() {
+		List<String> names = new ArrayList<>();
+		names.add("a");
+		String first = names.get(0);
+		Map<String, Integer> counts = new HashMap<>();
+		counts.put(first, first.length());
+		int count = counts.get(first);
+	}
+}`),
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		"ArrayList cannot be resolved to a type",
+		"HashMap cannot be resolved to a type",
+	}, util.Map(lastPublished, func(d protocol.Diagnostic) string { return d.Message }))
+
+	// Each comes with a quick fix that imports it after the other imports
+	result, err := jls.CodeAction(ctx, &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{
+			URI: uri.New("test_location"),
+		},
+		Range: oneLineRange(8, 30, 30),
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, "Import 'ArrayList' (java.util)", result[0].Title)
+		assert.Equal(t, map[protocol.DocumentURI][]protocol.TextEdit{
+			uri.New("test_location"): {{
+				Range:   oneLineRange(3, 21, 21),
+				NewText: "\nimport java.util.ArrayList;",
+			}},
+		}, result[0].Edit.Changes)
+	}
+}
+
+func TestDiagnosticsManager_OlderVersionsLeftOut(t *testing.T) {
+	dm := newDiagnosticsManager()
+
+	diag := func(message string) []protocol.Diagnostic {
+		return []protocol.Diagnostic{{Message: message}} //nolint:exhaustruct
+	}
+	messages := func(diagnostics []protocol.Diagnostic) []string {
+		return util.Map(diagnostics, func(d protocol.Diagnostic) string { return d.Message })
+	}
+
+	dm.set("file", 1, DiagnosticSourceSyntax, diag("syntax v1"))
+	merged := dm.set("file", 1, DiagnosticSourceType, diag("type v1"))
+	assert.Equal(t, []string{"syntax v1", "type v1"}, messages(merged))
+
+	// The type errors from v1 don't apply to v2
+	merged = dm.set("file", 2, DiagnosticSourceSyntax, diag("syntax v2"))
+	assert.Equal(t, []string{"syntax v2"}, messages(merged))
+
+	merged = dm.set("file", 2, DiagnosticSourceType, diag("type v2"))
+	assert.Equal(t, []string{"syntax v2", "type v2"}, messages(merged))
+	assert.Equal(t, "type", merged[1].Code)
+	assert.Equal(t, "java-mini-ls", merged[1].Source)
+}
+
+func TestServer_DependentsRecheckedWhenSurfaceChanges(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, ctrl := testServer(t, ctx)
+
+	published := map[string][]protocol.Diagnostic{}
+	mdp := NewMockDiagnosticsPublisher(ctrl)
+	jls.diagnosticsPublisher = mdp
+	mdp.
+		EXPECT().
+		PublishDiagnostics(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_ *JavaLS, textDocument protocol.TextDocumentItem, diagnostics []protocol.Diagnostic) {
+			published[string(textDocument.URI)] = diagnostics
+		}).
+		AnyTimes()
+
+	openDocument := func(uriStr string, contents string) {
+		err := jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+			TextDocument: createTextDocument(uriStr, contents),
+		})
+		assert.Nil(t, err)
+	}
+
+	openDocument("util.java", `public class Util {
+	public int get() { return 1; }
+}`)
+	openDocument("main.java", `import java.util.*;
+
+public class Main {
+	public void main() {
+		Util u = new Util();
+		int a = u.get();
+		List<String> names = new ArrayList<>();
+	}
+}`)
+	assert.Equal(t, []string{string(uri.New("main.java"))}, jls.dependencies.getDependents(string(uri.New("util.java"))))
+	assert.Empty(t, published[string(uri.New("main.java"))])
+
+	// Changing the return type of Util.get() should cause an error in main.java, even though
+	// main.java itself hasn't changed
+	openDocument("util.java", `public class Util {
+	public String get() { return "1"; }
+}`)
+	assert.Equal(t, []string{"Type mismatch: cannot convert from String to int"},
+		util.Map(published[string(uri.New("main.java"))], func(d protocol.Diagnostic) string { return d.Message }))
+
+	// And the reference to the new get() method should have been recorded
+	refs := jls.userTypes.Get("Util").LookupMember("get").GetUsages()
+	assert.Len(t, refs, 1)
+	assert.Equal(t, string(uri.New("main.java")), refs[0].FileUri)
+
+	// Changing it back fixes the error
+	openDocument("util.java", `public class Util {
+	public int get() { return 1; }
+}`)
+	assert.Empty(t, published[string(uri.New("main.java"))])
+}
+
+func TestServer_DependentsRegatheredWhenSupertypeChanges(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, ctrl := testServer(t, ctx)
+
+	published := map[string][]protocol.Diagnostic{}
+	mdp := NewMockDiagnosticsPublisher(ctrl)
+	jls.diagnosticsPublisher = mdp
+	mdp.
+		EXPECT().
+		PublishDiagnostics(gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_ *JavaLS, textDocument protocol.TextDocumentItem, diagnostics []protocol.Diagnostic) {
+			published[string(textDocument.URI)] = diagnostics
+		}).
+		AnyTimes()
+
+	openDocument := func(uriStr string, contents string) {
+		err := jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+			TextDocument: createTextDocument(uriStr, contents),
+		})
+		assert.Nil(t, err)
+	}
+	messages := func(uriStr string) []string {
+		return util.Map(published[string(uri.New(uriStr))], func(d protocol.Diagnostic) string { return d.Message })
+	}
+
+	openDocument("a.java", `public class A {}`)
+	openDocument("b.java", `public class B extends A {
+	public int useIt() { return newM(); }
+}`)
+	openDocument("c.java", `public class C extends B {
+	public int useItToo() { return newM(); }
+}`)
+	assert.Equal(t, []string{"Unknown identifier: newM"}, messages("b.java"))
+	assert.Equal(t, []string{"Unknown identifier: newM"}, messages("c.java"))
+
+	// B and C inherit the new method, even though only A changed
+	openDocument("a.java", `public class A {
+	public int newM() { return 1; }
+}`)
+	assert.Empty(t, messages("b.java"))
+	assert.Empty(t, messages("c.java"))
+}
+
+func TestServer_SignatureHelp_Varargs(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, _ := testServer(t, ctx)
+
+	err := jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+		TextDocument: createTextDocument("test_location", `public class Main {
+	static int sum(String label, int... nums) {
+		return nums.length;
+	}
+
+	public void main() {
+		int total = sum("total", 1, 2, 3);
+	}
+}`)})
+	assert.Nil(t, err)
+
+	signatureHelp := func(character uint32) *protocol.SignatureHelp {
+		result, err := jls.SignatureHelp(ctx, &protocol.SignatureHelpParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{
+					URI: uri.New("test_location"),
+				},
+				Position: protocol.Position{
+					Line:      6,
+					Character: character,
+				},
+			},
+		})
+		assert.Nil(t, err)
+		return result
+	}
+
+	expectedSignatures := []protocol.SignatureInformation{{
+		Label: "sum(String label, int... nums)",
+		Parameters: []protocol.ParameterInformation{
+			{Label: "String label"},
+			{Label: "int... nums"},
+		},
+	}}
+
+	// Right after `sum(`
+	assert.Equal(t, &protocol.SignatureHelp{
+		Signatures:      expectedSignatures,
+		ActiveParameter: 0,
+		ActiveSignature: 0,
+	}, signatureHelp(18))
+
+	// In the 3rd argument, which goes to the varargs parameter
+	assert.Equal(t, &protocol.SignatureHelp{
+		Signatures:      expectedSignatures,
+		ActiveParameter: 1,
+		ActiveSignature: 0,
+	}, signatureHelp(31))
+
+	// Outside of the call
+	assert.Nil(t, signatureHelp(8))
+}
+
+func TestServer_CodeAction_AddUnimplementedMethods(t *testing.T) {
+	ctx, cancel := testCtx()
+	defer cancel()
+	jls, _ := testServer(t, ctx)
+
+	err := jls.DidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+		TextDocument: createTextDocument("test_location", `public class Main {
+	interface Shape {
+		double area();
+		String name();
+	}
+
+	static class Circle implements Shape {
+	}
+}`)})
+	assert.Nil(t, err)
+
+	codeActions := func(rrange protocol.Range) []protocol.CodeAction {
+		result, err := jls.CodeAction(ctx, &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{
+				URI: uri.New("test_location"),
+			},
+			Range: rrange,
+		})
+		assert.Nil(t, err)
+		return result
+	}
+
+	// Both missing methods get added by the same quick fix
+	actions := codeActions(oneLineRange(6, 16, 16))
+	if assert.Len(t, actions, 1) {
+		assert.Equal(t, "Add unimplemented methods", actions[0].Title)
+		assert.Equal(t, protocol.QuickFix, actions[0].Kind)
+		assert.Equal(t, []string{
+			"The type Circle must implement the inherited abstract method Shape.area()",
+			"The type Circle must implement the inherited abstract method Shape.name()",
+		}, util.Map(actions[0].Diagnostics, func(d protocol.Diagnostic) string { return d.Message }))
+		assert.Equal(t, map[protocol.DocumentURI][]protocol.TextEdit{
+			uri.New("test_location"): {{
+				Range: oneLineRange(7, 0, 0),
+				NewText: `
+		@Override
+		public double area() {
+			// TODO Auto-generated method stub
+			return 0;
+		}
+
+		@Override
+		public String name() {
+			// TODO Auto-generated method stub
+			return null;
+		}
+`,
+			}},
+		}, actions[0].Edit.Changes)
+	}
+
+	// Nothing to fix elsewhere
+	assert.Empty(t, codeActions(oneLineRange(2, 2, 8)))
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"go.lsp.dev/protocol"
+	"java-mini-ls-go/parse/loc"
+	"java-mini-ls-go/parse/typ"
+	"java-mini-ls-go/util"
+	"strings"
+)
+
+func (j *JavaLS) SignatureHelp(_ context.Context, params *protocol.SignatureHelpParams) (*protocol.SignatureHelp, error) {
+	uriString := string(params.TextDocument.URI)
+	text, ok := j.documentTextCache.Get(uriString)
+	if !ok {
+		return nil, fmt.Errorf("can't find document with uri: %s", uriString)
+	}
+	defUsages, ok := j.defUsages.Get(uriString)
+	if !ok {
+		return nil, nil
+	}
+
+	offset := positionToOffset(text.Text, params.Position)
+	parenIdx, argIdx := findOpenCall(text.Text, offset)
+	if parenIdx == -1 {
+		return nil, nil
+	}
+
+	// The method name is the identifier right before the `(`
+	nameEnd := parenIdx - 1
+	for nameEnd >= 0 && isWhitespace(text.Text[nameEnd]) {
+		nameEnd--
+	}
+	if nameEnd < 0 || !isAlphaNumeric(text.Text[nameEnd]) {
+		return nil, nil
+	}
+
+	symbol := defUsages.Lookup(offsetToFileLocation(text.Text, nameEnd))
+	method, ok := symbol.(*typ.JavaMethod)
+	if !ok {
+		return nil, nil
+	}
+
+	// Show every overload, with the one that was resolved to active
+	overloads := []*typ.JavaMethod{method}
+	activeSignature := 0
+	if method.ParentType != nil {
+		overloads = []*typ.JavaMethod{}
+		for _, other := range method.ParentType.Methods {
+			if other.Name != method.Name {
+				continue
+			}
+			if other == method || other == method.Original {
+				activeSignature = len(overloads)
+			}
+			overloads = append(overloads, other)
+		}
+	}
+
+	signatures := util.Map(overloads, methodSignature)
+
+	return &protocol.SignatureHelp{
+		Signatures:      signatures,
+		ActiveParameter: activeParameter(overloads[activeSignature], argIdx),
+		ActiveSignature: uint32(activeSignature),
+	}, nil
+}
+
+// methodSignature describes the parameters of a method, e.g. `format(String format, Object... args)`.
+func methodSignature(method *typ.JavaMethod) protocol.SignatureInformation {
+	paramLabels := util.MapToString(method.Params)
+
+	return protocol.SignatureInformation{
+		Label:         fmt.Sprintf("%s(%s)", method.Name, strings.Join(paramLabels, ", ")),
+		Documentation: nil,
+		Parameters: util.Map(paramLabels, func(label string) protocol.ParameterInformation {
+			return protocol.ParameterInformation{
+				Label:         label,
+				Documentation: nil,
+			}
+		}),
+		ActiveParameter: 0,
+	}
+}
+
+// activeParameter returns which parameter the argument with the given index is passed in for. All the
+// extra arguments of a varargs method go to its last parameter.
+func activeParameter(method *typ.JavaMethod, argIdx int) uint32 {
+	if len(method.Params) > 0 && method.Params[len(method.Params)-1].IsVarargs && argIdx >= len(method.Params) {
+		return uint32(len(method.Params) - 1)
+	}
+	return uint32(argIdx)
+}
+
+// findOpenCall goes backwards from the offset to find the `(` of the method call it's inside of, and counts
+// the commas in between to find out which argument it's in. Returns -1 for the index of the `(` if the
+// offset isn't inside of a method call.
+func findOpenCall(text string, offset int) (int, int) {
+	if offset > len(text) {
+		offset = len(text)
+	}
+
+	depth := 0
+	argIdx := 0
+	for i := offset - 1; i >= 0; i-- {
+		switch text[i] {
+		case ')', ']':
+			depth++
+		case '(', '[':
+			if depth == 0 {
+				if text[i] == '[' {
+					return -1, 0
+				}
+				return i, argIdx
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				argIdx++
+			}
+		case ';', '{', '}':
+			// Can't be in a method call anymore once we're at the start of the statement
+			return -1, 0
+		}
+	}
+	return -1, 0
+}
+
+// positionToOffset converts a position in a document (with 0-based lines) into an index into its text.
+func positionToOffset(text string, position protocol.Position) int {
+	line := uint32(0)
+	for i := 0; i < len(text); i++ {
+		if line == position.Line {
+			return i + int(position.Character)
+		}
+		if text[i] == '\n' {
+			line++
+		}
+	}
+	return len(text)
+}
+
+// offsetToFileLocation converts an index into a document's text into a location in the document (with 1-based lines).
+func offsetToFileLocation(text string, offset int) loc.FileLocation {
+	lineStart := strings.LastIndex(text[:offset], "\n") + 1
+	return loc.FileLocation{
+		Line:      strings.Count(text[:offset], "\n") + 1,
+		Character: offset - lineStart,
+	}
+}
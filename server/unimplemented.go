@@ -7,10 +7,6 @@ import (
 
 // This file contains initialize/shutdown type calls
 
-func (j *JavaLS) WorkDoneProgressCancel(ctx context.Context, params *protocol.WorkDoneProgressCancelParams) error {
-	panic("WorkDoneProgressCancel unimplemented")
-}
-
 func (j *JavaLS) LogTrace(ctx context.Context, params *protocol.LogTraceParams) error {
 	panic("LogTrace unimplemented")
 }
@@ -19,10 +15,6 @@ func (j *JavaLS) SetTrace(ctx context.Context, params *protocol.SetTraceParams)
 	panic("SetTrace unimplemented")
 }
 
-func (j *JavaLS) CodeAction(ctx context.Context, params *protocol.CodeActionParams) ([]protocol.CodeAction, error) {
-	panic("CodeAction unimplemented")
-}
-
 func (j *JavaLS) CodeLens(ctx context.Context, params *protocol.CodeLensParams) ([]protocol.CodeLens, error) {
 	panic("CodeLens unimplemented")
 }
@@ -109,10 +101,6 @@ func (j *JavaLS) Rename(ctx context.Context, params *protocol.RenameParams) (*pr
 	panic("Rename unimplemented")
 }
 
-func (j *JavaLS) SignatureHelp(ctx context.Context, params *protocol.SignatureHelpParams) (*protocol.SignatureHelp, error) {
-	panic("SignatureHelp unimplemented")
-}
-
 func (j *JavaLS) Symbols(ctx context.Context, params *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
 	panic("Symbols unimplemented")
 }
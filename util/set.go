@@ -38,3 +38,8 @@ func (s *Set[T]) Contains(item T) bool {
 	_, ok := s.values[item]
 	return ok
 }
+
+// Values returns all the items in the set, in no particular order
+func (s *Set[T]) Values() []T {
+	return Keys(s.values)
+}
@@ -28,3 +28,28 @@ func (sm *SyncMap[K, V]) Set(key K, val V) {
 	defer sm.mu.Unlock()
 	sm.data[key] = val
 }
+
+// Delete removes a value from the map
+func (sm *SyncMap[K, V]) Delete(key K) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	delete(sm.data, key)
+}
+
+// Values returns a snapshot of all the values in the map
+func (sm *SyncMap[K, V]) Values() []V {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return Values(sm.data)
+}
+
+// Entries returns a snapshot copy of the map's contents
+func (sm *SyncMap[K, V]) Entries() map[K]V {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	ret := make(map[K]V, len(sm.data))
+	for k, v := range sm.data {
+		ret[k] = v
+	}
+	return ret
+}